@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/api"
+	"github.com/go-go-golems/oak/pkg/diff"
+	"github.com/spf13/cobra"
+)
+
+type APIDiffCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*APIDiffCommand)(nil)
+
+type APIDiffSettings struct {
+	Sources  []string `glazed.parameter:"sources"`
+	From     string   `glazed.parameter:"from"`
+	To       string   `glazed.parameter:"to"`
+	Breaking bool     `glazed.parameter:"breaking"`
+	Markdown bool     `glazed.parameter:"markdown"`
+}
+
+func NewAPIDiffCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmds.NewCommandDescription("api-diff",
+		cmds.WithShort("Report the changes of the exported symbols between two git revisions"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"from",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Git revision of the old version"),
+				parameters.WithRequired(true),
+			),
+			parameters.NewParameterDefinition(
+				"to",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Git revision of the new version"),
+				parameters.WithDefault("HEAD"),
+			),
+			parameters.NewParameterDefinition(
+				"breaking",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Only list the breaking changes"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"markdown",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Output a markdown changelog instead of rows"),
+				parameters.WithDefault(false),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Paths to compare (the current directory by default)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&APIDiffCommand{CommandDescription: description})
+}
+
+func (c *APIDiffCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &APIDiffSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	exports := map[string][]*api.Export{}
+	for _, revision := range []string{s.From, s.To} {
+		files, err := diff.ReadTree(ctx, revision, s.Sources, api.IsDiffFile)
+		if err != nil {
+			return err
+		}
+		exports[revision], err = api.Collect(ctx, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	changes := []*api.Change{}
+	for _, change := range api.Compare(exports[s.From], exports[s.To]) {
+		if change.Breaking || !s.Breaking {
+			changes = append(changes, change)
+		}
+	}
+
+	if s.Markdown {
+		return writeAPIChangelog(os.Stdout, s.From, s.To, changes)
+	}
+
+	for _, change := range changes {
+		e := change.Export()
+		err = gp.AddRow(ctx, types.NewRow(
+			types.MRP("file", e.File),
+			types.MRP("line", int(e.NameStartPoint.Row)+1),
+			types.MRP("symbol", e.QualifiedName),
+			types.MRP("kind", string(e.Kind)),
+			types.MRP("change", string(change.Kind)),
+			types.MRP("breaking", change.Breaking),
+			types.MRP("old", change.OldText()),
+			types.MRP("new", change.NewText()),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAPIChangelog writes the breaking and other changes as markdown
+// lists.
+func writeAPIChangelog(w io.Writer, from, to string, changes []*api.Change) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# API changes from %s to %s\n\n", from, to)
+	if len(changes) == 0 {
+		b.WriteString("No changes to the exported symbols.\n")
+	}
+	for _, breaking := range []bool{true, false} {
+		title := "Breaking changes"
+		if !breaking {
+			title = "Other changes"
+		}
+		section := []string{}
+		for _, change := range changes {
+			if change.Breaking == breaking {
+				section = append(section, changelogEntry(change))
+			}
+		}
+		if len(section) > 0 {
+			fmt.Fprintf(&b, "## %s\n\n%s\n\n", title, strings.Join(section, "\n"))
+		}
+	}
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+func changelogEntry(change *api.Change) string {
+	e := change.Export()
+	prefix := fmt.Sprintf("- `%s`: %s `%s`", e.File, e.Kind, e.QualifiedName)
+	switch change.Kind {
+	case api.ChangeAdded:
+		return fmt.Sprintf("%s added: `%s`", prefix, change.New.Signature)
+	case api.ChangeRemoved:
+		return fmt.Sprintf("%s removed: `%s`", prefix, change.Old.Signature)
+	case api.ChangeKindChanged:
+		return fmt.Sprintf("%s was a %s: `%s` is now `%s`",
+			prefix, change.Old.Kind, change.Old.Signature, change.New.Signature)
+	case api.ChangeParameters:
+		return fmt.Sprintf("%s parameters changed from `%s` to `%s`", prefix, change.OldText(), change.NewText())
+	case api.ChangeResult:
+		return fmt.Sprintf("%s return type changed from `%s` to `%s`", prefix, change.OldText(), change.NewText())
+	default:
+		return fmt.Sprintf("%s type changed from `%s` to `%s`", prefix, change.OldText(), change.NewText())
+	}
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/go-go-golems/oak/pkg/cache"
+	"github.com/spf13/cobra"
+)
+
+func NewCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of query results",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "stats",
+		Short: "Show the location, number of entries, size and maximum size of the cache",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := openCache()
+			cobra.CheckErr(err)
+			stats, err := c.Stats()
+			cobra.CheckErr(err)
+
+			fmt.Printf("directory: %s\n", stats.Dir)
+			fmt.Printf("entries: %d\n", stats.Entries)
+			fmt.Printf("size: %s\n", formatSize(stats.Size))
+			if stats.MaxSize > 0 {
+				fmt.Printf("max size: %s\n", formatSize(stats.MaxSize))
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove all the entries of the cache",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := openCache()
+			cobra.CheckErr(err)
+			stats, err := c.Stats()
+			cobra.CheckErr(err)
+			err = c.Clear()
+			cobra.CheckErr(err)
+
+			fmt.Printf("removed %d entries (%s) from %s\n", stats.Entries, formatSize(stats.Size), stats.Dir)
+		},
+	})
+
+	return cmd
+}
+
+// openCache returns the cache in the default directory, even when caching
+// is disabled with --no-cache.
+func openCache() (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir), nil
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
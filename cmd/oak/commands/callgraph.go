@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/callgraph"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type CallgraphCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*CallgraphCommand)(nil)
+
+type CallgraphSettings struct {
+	Sources   []string `glazed.parameter:"sources"`
+	Root      string   `glazed.parameter:"root"`
+	Symbol    string   `glazed.parameter:"symbol"`
+	Depth     int      `glazed.parameter:"depth"`
+	Direction string   `glazed.parameter:"direction"`
+	Unique    bool     `glazed.parameter:"unique"`
+	Dot       bool     `glazed.parameter:"dot"`
+	Tree      bool     `glazed.parameter:"tree"`
+}
+
+const directionBoth = "both"
+
+func NewCallgraphCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmds.NewCommandDescription("callgraph",
+		cmds.WithShort("Approximate the callers and callees of functions and methods"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"root",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Root of the repository, the paths are relative to it"),
+				parameters.WithDefault("."),
+			),
+			parameters.NewParameterDefinition(
+				"symbol",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Function or method to start from, by name or qualified name (Index.Build)"),
+				parameters.WithDefault(""),
+			),
+			parameters.NewParameterDefinition(
+				"depth",
+				parameters.ParameterTypeInteger,
+				parameters.WithHelp("Number of calls to follow from the symbol, 0 for no limit"),
+				parameters.WithDefault(1),
+			),
+			parameters.NewParameterDefinition(
+				"direction",
+				parameters.ParameterTypeChoice,
+				parameters.WithHelp("Calls to follow from the symbol"),
+				parameters.WithChoices(string(callgraph.DirectionCallees), string(callgraph.DirectionCallers), directionBoth),
+				parameters.WithDefault(directionBoth),
+			),
+			parameters.NewParameterDefinition(
+				"unique",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Only keep the calls linked to a single definition"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"dot",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Write the call graph to stdout in the DOT language of graphviz"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"tree",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Write the calls from the symbol to stdout as an indented tree"),
+				parameters.WithDefault(false),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Files and directories to scan (the root by default)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&CallgraphCommand{CommandDescription: description})
+}
+
+func (s *CallgraphSettings) directions() []callgraph.Direction {
+	switch s.Direction {
+	case string(callgraph.DirectionCallers):
+		return []callgraph.Direction{callgraph.DirectionCallers}
+	case string(callgraph.DirectionCallees):
+		return []callgraph.Direction{callgraph.DirectionCallees}
+	}
+	return []callgraph.Direction{callgraph.DirectionCallers, callgraph.DirectionCallees}
+}
+
+func (c *CallgraphCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &CallgraphSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+	if s.Tree && s.Symbol == "" {
+		return errors.New("--tree needs a --symbol to start from")
+	}
+
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = []string{s.Root}
+	}
+	g, err := callgraph.Build(ctx, s.Root, sources)
+	if err != nil {
+		return err
+	}
+	if s.Unique {
+		g = g.Unique()
+	}
+
+	if s.Symbol == "" {
+		if s.Dot {
+			return callgraph.WriteDOT(os.Stdout, g.Calls, nil)
+		}
+		for _, call := range g.Calls {
+			err = gp.AddRow(ctx, callRow(call))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := g.Find(s.Symbol)
+	if len(start) == 0 {
+		return errors.Errorf("no function or method named %s", s.Symbol)
+	}
+
+	if s.Tree {
+		for i, direction := range s.directions() {
+			if i > 0 {
+				fmt.Println()
+			}
+			err = g.WriteTree(os.Stdout, start, direction, s.Depth)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	steps := []*callgraph.Step{}
+	for _, direction := range s.directions() {
+		steps = append(steps, g.Walk(start, direction, s.Depth)...)
+	}
+
+	if s.Dot {
+		calls := make([]*callgraph.Call, 0, len(steps))
+		for _, step := range steps {
+			calls = append(calls, step.Call)
+		}
+		return callgraph.WriteDOT(os.Stdout, calls, start)
+	}
+
+	for _, step := range steps {
+		err = gp.AddRow(ctx, callRow(step.Call,
+			types.MRP("direction", string(step.Direction)),
+			types.MRP("depth", step.Depth),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callRow returns the row of a call, starting with the given fields.
+func callRow(call *callgraph.Call, fields ...types.MapRowPair) types.Row {
+	return types.NewRow(append(fields,
+		types.MRP("caller", call.Caller.QualifiedName()),
+		types.MRP("caller_file", call.Caller.File.Path),
+		types.MRP("line", call.Line),
+		types.MRP("callee", call.Callee.QualifiedName()),
+		types.MRP("callee_file", call.Callee.File.Path),
+		types.MRP("callee_line", int(call.Callee.NameStartPoint.Row)+1),
+		types.MRP("candidates", call.Candidates),
+	)...)
+}
@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg/check"
+	cmds2 "github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of oak check, by highest severity found. Info and hint
+// findings don't fail the check.
+const (
+	checkExitWarning = 1
+	checkExitError   = 2
+	checkExitFailure = 3
+)
+
+func NewCheckCommand(allCommands []glazed_cmds.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check [sources...]",
+		Short: "Run the rules of oak commands over source files",
+		Long: "Run the rules of the command files in --rules (or of all the loaded commands) over the " +
+			"given files and directories, and report the findings as text, GitHub annotations, SARIF " +
+			"or JUnit XML. The command exits with 2 if errors were found, and 1 if warnings were found.",
+		Run: func(cmd *cobra.Command, args []string) {
+			severity, err := runCheck(cmd, args, allCommands)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(checkExitFailure)
+			}
+
+			switch severity {
+			case cmds2.SeverityError:
+				os.Exit(checkExitError)
+			case cmds2.SeverityWarning:
+				os.Exit(checkExitWarning)
+			case cmds2.SeverityInfo, cmds2.SeverityHint:
+			}
+		},
+	}
+
+	cmd.Flags().StringSlice("rules", nil,
+		"Command files, or directories of command files, with the rules to run (default: the loaded commands)")
+	cmd.Flags().String("format", "text", "Output format: "+strings.Join(check.Formats, ", "))
+	cmd.Flags().StringP("output", "o", "", "Write the report to a file instead of stdout")
+	cmd.Flags().Bool("fix", false, "Apply the fixes of the rules to the files, and only report the remaining findings")
+
+	return cmd
+}
+
+// runCheck runs the check and writes the report, returning the highest
+// severity found.
+func runCheck(cmd *cobra.Command, args []string, allCommands []glazed_cmds.Command) (cmds2.RuleSeverity, error) {
+	rulePaths, err := cmd.Flags().GetStringSlice("rules")
+	if err != nil {
+		return "", err
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return "", err
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", err
+	}
+	fix, err := cmd.Flags().GetBool("fix")
+	if err != nil {
+		return "", err
+	}
+
+	var ruleCommands []*cmds2.OakCommand
+	if len(rulePaths) > 0 {
+		files, err := collectCommandFiles(rulePaths)
+		if err != nil {
+			return "", err
+		}
+		ruleCommands, err = cmds2.LoadRuleCommands(files)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		for _, command := range allCommands {
+			oc, err := cmds2.RuleCommand(command)
+			if err != nil {
+				return "", err
+			}
+			if oc != nil {
+				ruleCommands = append(ruleCommands, oc)
+			}
+		}
+	}
+	if len(ruleCommands) == 0 {
+		return "", fmt.Errorf("no rules found")
+	}
+
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	checker := check.NewChecker(ruleCommands)
+	result, err := checker.Run(cmd.Context(), args)
+	if err != nil {
+		return "", err
+	}
+
+	if fix {
+		err = checker.ApplyFixes(cmd.Context(), result)
+		if err != nil {
+			return "", err
+		}
+		for _, file := range result.Files {
+			if n, ok := result.Fixed[file]; ok {
+				_, _ = fmt.Fprintf(os.Stderr, "fixed %s (%d fixes)\n", file, n)
+			}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		w = f
+	}
+	err = check.WriteReport(w, result, format)
+	if err != nil {
+		return "", err
+	}
+
+	return result.MaxSeverity(), nil
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/deps"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type DepsCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*DepsCommand)(nil)
+
+type DepsSettings struct {
+	Sources      []string `glazed.parameter:"sources"`
+	Root         string   `glazed.parameter:"root"`
+	Local        bool     `glazed.parameter:"local"`
+	Dot          bool     `glazed.parameter:"dot"`
+	WithExternal bool     `glazed.parameter:"with-external"`
+	Cycles       bool     `glazed.parameter:"cycles"`
+}
+
+func NewDepsCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmds.NewCommandDescription("deps",
+		cmds.WithShort("List the imports of a repository, as edges, a DOT graph or cycles"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"root",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Root of the repository, the paths are relative to it"),
+				parameters.WithDefault("."),
+			),
+			parameters.NewParameterDefinition(
+				"local",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Only list the imports resolved to files of the repository"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"dot",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Write the import graph to stdout in the DOT language of graphviz"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"with-external",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Include the external packages in the DOT graph"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"cycles",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("List the import cycles, and fail if there are any"),
+				parameters.WithDefault(false),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Files and directories to scan (the root by default)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&DepsCommand{CommandDescription: description})
+}
+
+func (c *DepsCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &DepsSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = []string{s.Root}
+	}
+	edges, err := deps.Collect(ctx, s.Root, sources)
+	if err != nil {
+		return err
+	}
+
+	if s.Dot {
+		return deps.WriteDOT(os.Stdout, edges, s.WithExternal)
+	}
+
+	if s.Cycles {
+		cycles := deps.Cycles(edges)
+		for i, cycle := range cycles {
+			err = gp.AddRow(ctx, types.NewRow(
+				types.MRP("cycle", i+1),
+				types.MRP("length", len(cycle.Nodes)),
+				types.MRP("path", strings.Join(cycle.Path, " -> ")),
+				types.MRP("nodes", cycle.Nodes),
+			))
+			if err != nil {
+				return err
+			}
+		}
+		if len(cycles) > 0 {
+			// output the rows before failing
+			err = gp.Close(ctx)
+			if err != nil {
+				return err
+			}
+			return errors.Errorf("found %d import cycles", len(cycles))
+		}
+		return nil
+	}
+
+	for _, e := range edges {
+		if s.Local && e.Kind != deps.KindLocal {
+			continue
+		}
+		err = gp.AddRow(ctx, types.NewRow(
+			types.MRP("from", e.From),
+			types.MRP("to", e.To),
+			types.MRP("kind", string(e.Kind)),
+			types.MRP("import", e.Import),
+			types.MRP("file", e.File),
+			types.MRP("line", e.Line),
+			types.MRP("language", e.Language),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
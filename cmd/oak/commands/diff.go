@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/diff"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type DiffCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*DiffCommand)(nil)
+
+type DiffSettings struct {
+	Sources    []string `glazed.parameter:"sources"`
+	Rev        string   `glazed.parameter:"rev"`
+	Similarity float64  `glazed.parameter:"similarity"`
+	Context    int      `glazed.parameter:"context"`
+	Markdown   bool     `glazed.parameter:"markdown"`
+}
+
+func NewDiffCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmds.NewCommandDescription("diff",
+		cmds.WithShort("Compare the definitions of two versions of files"),
+		cmds.WithLong("Compare two files (oak diff old.go new.go), or the files changed between\n"+
+			"git revisions (oak diff --rev A..B [paths...]), and report the added, removed,\n"+
+			"renamed, moved and modified definitions."),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"rev",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Git revisions to compare, A..B, or A to compare with the working tree"),
+			),
+			parameters.NewParameterDefinition(
+				"similarity",
+				parameters.ParameterTypeFloat,
+				parameters.WithHelp("Minimum similarity of the code of a removed and an added definition to report a rename"),
+				parameters.WithDefault(diff.DefaultOptions.Similarity),
+			),
+			parameters.NewParameterDefinition(
+				"context",
+				parameters.ParameterTypeInteger,
+				parameters.WithHelp("Number of context lines of the diffs"),
+				parameters.WithDefault(diff.DefaultOptions.Context),
+			),
+			parameters.NewParameterDefinition(
+				"markdown",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Output a markdown summary of the changes instead of rows"),
+				parameters.WithDefault(false),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Old and new files, or the paths to compare with --rev"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&DiffCommand{CommandDescription: description})
+}
+
+func (c *DiffCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &DiffSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	options := diff.Options{Similarity: s.Similarity, Context: s.Context}
+	var files []*diff.File
+	if s.Rev != "" {
+		from, to, err := diff.ParseRevisions(s.Rev)
+		if err != nil {
+			return err
+		}
+		files, err = diff.CompareRevisions(ctx, from, to, s.Sources, options)
+		if err != nil {
+			return err
+		}
+	} else {
+		if len(s.Sources) != 2 {
+			return errors.New("expected an old and a new file, or --rev")
+		}
+		file, err := diff.CompareFiles(ctx, s.Sources[0], s.Sources[1], options)
+		if err != nil {
+			return err
+		}
+		files = []*diff.File{file}
+	}
+
+	if s.Markdown {
+		return writeDiffMarkdown(os.Stdout, files)
+	}
+
+	for _, f := range files {
+		for _, change := range f.Changes {
+			oldName, oldLines, newLines := "", "", ""
+			if change.Old != nil {
+				oldName = change.Old.QualifiedName
+				oldLines = lineRange(change.Old)
+			}
+			if change.New != nil {
+				newLines = lineRange(change.New)
+			}
+			err = gp.AddRow(ctx, types.NewRow(
+				types.MRP("file", f.Name),
+				types.MRP("status", string(change.Status)),
+				types.MRP("kind", string(change.Kind)),
+				types.MRP("name", change.Name()),
+				types.MRP("old_name", oldName),
+				types.MRP("old_lines", oldLines),
+				types.MRP("new_lines", newLines),
+				types.MRP("similarity", fmt.Sprintf("%.2f", change.Similarity)),
+				types.MRP("diff", change.Diff),
+			))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lineRange(d *diff.Definition) string {
+	return fmt.Sprintf("%d-%d", d.StartPoint.Row+1, d.EndPoint.Row+1)
+}
+
+// writeDiffMarkdown writes a summary of the changes, one section per file,
+// with the diffs of the definitions in diff code blocks.
+func writeDiffMarkdown(w io.Writer, files []*diff.File) error {
+	var b strings.Builder
+	for _, f := range files {
+		if len(f.Changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "## %s\n\n", f.Name)
+		for _, change := range f.Changes {
+			switch change.Status {
+			case diff.StatusRenamed:
+				fmt.Fprintf(&b, "- **renamed** %s `%s` to `%s` (similarity %.2f)\n",
+					change.Kind, change.Old.QualifiedName, change.New.QualifiedName, change.Similarity)
+			case diff.StatusMoved:
+				if change.Old.QualifiedName != change.New.QualifiedName {
+					fmt.Fprintf(&b, "- **moved** %s `%s` to `%s`\n",
+						change.Kind, change.Old.QualifiedName, change.New.QualifiedName)
+				} else {
+					fmt.Fprintf(&b, "- **moved** %s `%s` from line %d to line %d\n",
+						change.Kind, change.Name(), change.Old.StartPoint.Row+1, change.New.StartPoint.Row+1)
+				}
+			default:
+				fmt.Fprintf(&b, "- **%s** %s `%s`\n", change.Status, change.Kind, change.Name())
+			}
+			if change.Diff != "" {
+				fmt.Fprintf(&b, "\n  ```diff\n")
+				for _, line := range strings.Split(strings.TrimSuffix(change.Diff, "\n"), "\n") {
+					fmt.Fprintf(&b, "  %s\n", line)
+				}
+				fmt.Fprintf(&b, "  ```\n\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		b.WriteString("No changes to definitions.\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/api"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type DocCoverageCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*DocCoverageCommand)(nil)
+
+type DocCoverageSettings struct {
+	Sources      []string `glazed.parameter:"sources"`
+	Root         string   `glazed.parameter:"root"`
+	By           string   `glazed.parameter:"by"`
+	Members      bool     `glazed.parameter:"members"`
+	Undocumented bool     `glazed.parameter:"undocumented"`
+	Min          float64  `glazed.parameter:"min"`
+}
+
+func NewDocCoverageCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmds.NewCommandDescription("doc-coverage",
+		cmds.WithShort("Report the documentation coverage of the exported symbols"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"root",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Root of the repository, the paths are relative to it"),
+				parameters.WithDefault("."),
+			),
+			parameters.NewParameterDefinition(
+				"by",
+				parameters.ParameterTypeChoice,
+				parameters.WithHelp("Report the coverage per file or per package (directory)"),
+				parameters.WithChoices("file", "package"),
+				parameters.WithDefault("file"),
+			),
+			parameters.NewParameterDefinition(
+				"members",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Also count fields, properties and enum members"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"undocumented",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("List the undocumented symbols instead of the coverage"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"min",
+				parameters.ParameterTypeFloat,
+				parameters.WithHelp("Minimum total coverage percentage, 0 for no minimum"),
+				parameters.WithDefault(0.0),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Files and directories to check (the root by default)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&DocCoverageCommand{CommandDescription: description})
+}
+
+func (c *DocCoverageCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &DocCoverageSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = []string{s.Root}
+	}
+	files, err := api.ReadFiles(s.Root, sources)
+	if err != nil {
+		return err
+	}
+	exports, err := api.Collect(ctx, files)
+	if err != nil {
+		return err
+	}
+	if !s.Members {
+		filtered := []*api.Export{}
+		for _, e := range exports {
+			if !api.IsMember(e.Kind) {
+				filtered = append(filtered, e)
+			}
+		}
+		exports = filtered
+	}
+
+	coverages, total := api.DocCoverage(exports, s.By == "package")
+	if s.Undocumented {
+		for _, e := range total.Undocumented {
+			err = gp.AddRow(ctx, types.NewRow(
+				types.MRP("file", e.File),
+				types.MRP("line", int(e.NameStartPoint.Row)+1),
+				types.MRP("symbol", e.QualifiedName),
+				types.MRP("kind", string(e.Kind)),
+				types.MRP("language", e.Language),
+			))
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, coverage := range append(coverages, total) {
+			err = gp.AddRow(ctx, types.NewRow(
+				types.MRP(s.By, coverage.Name),
+				types.MRP("language", coverage.Language),
+				types.MRP("symbols", coverage.Symbols),
+				types.MRP("documented", coverage.Documented),
+				types.MRP("coverage", fmt.Sprintf("%.1f", coverage.Percent())),
+			))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.Min > 0 && total.Percent() < s.Min {
+		// output the rows before failing
+		err = gp.Close(ctx)
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("documentation coverage %.1f%% is below the minimum %.1f%%", total.Percent(), s.Min)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/dupes"
+	"github.com/spf13/cobra"
+)
+
+type DupesCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*DupesCommand)(nil)
+
+type DupesSettings struct {
+	Sources    []string `glazed.parameter:"sources"`
+	Root       string   `glazed.parameter:"root"`
+	MinTokens  int      `glazed.parameter:"min-tokens"`
+	MinLines   int      `glazed.parameter:"min-lines"`
+	Similarity float64  `glazed.parameter:"similarity"`
+}
+
+func NewDupesCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	description := cmds.NewCommandDescription("dupes",
+		cmds.WithShort("Find duplicated and near-duplicated code"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"root",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Root of the repository, the paths are relative to it"),
+				parameters.WithDefault("."),
+			),
+			parameters.NewParameterDefinition(
+				"min-tokens",
+				parameters.ParameterTypeInteger,
+				parameters.WithHelp("Minimum number of tokens of duplicated code"),
+				parameters.WithDefault(dupes.DefaultOptions.MinTokens),
+			),
+			parameters.NewParameterDefinition(
+				"min-lines",
+				parameters.ParameterTypeInteger,
+				parameters.WithHelp("Minimum number of lines of duplicated code"),
+				parameters.WithDefault(dupes.DefaultOptions.MinLines),
+			),
+			parameters.NewParameterDefinition(
+				"similarity",
+				parameters.ParameterTypeFloat,
+				parameters.WithHelp("Minimum similarity of near-duplicated functions, 1 for exact duplicates only"),
+				parameters.WithDefault(dupes.DefaultOptions.Similarity),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Files and directories to search (the root by default)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&DupesCommand{CommandDescription: description})
+}
+
+func (c *DupesCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &DupesSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = []string{s.Root}
+	}
+	clusters, err := dupes.Find(ctx, s.Root, sources, dupes.Options{
+		MinTokens:  s.MinTokens,
+		MinLines:   s.MinLines,
+		Similarity: s.Similarity,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, cluster := range clusters {
+		kind := "near"
+		if cluster.Exact {
+			kind = "exact"
+		}
+		for _, f := range cluster.Fragments {
+			err = gp.AddRow(ctx, types.NewRow(
+				types.MRP("cluster", i+1),
+				types.MRP("kind", kind),
+				types.MRP("similarity", fmt.Sprintf("%.2f", cluster.Similarity)),
+				types.MRP("file", f.File),
+				types.MRP("start_line", int(f.StartPoint.Row)+1),
+				types.MRP("end_line", int(f.EndPoint.Row)+1),
+				types.MRP("tokens", f.Tokens),
+				types.MRP("node", f.Type),
+				types.MRP("function", f.Name),
+				types.MRP("language", f.Language),
+			))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/go-go-golems/oak/pkg/export"
+	"github.com/spf13/cobra"
+)
+
+func NewExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the symbols of a repository for code navigation tools",
+	}
+
+	ctagsCmd := &cobra.Command{
+		Use:   "ctags [files or directories...]",
+		Short: "Write a tags file in the extended format of Universal Ctags",
+		Run: func(cmd *cobra.Command, args []string) {
+			p, err := collectExport(cmd, args)
+			cobra.CheckErr(err)
+			err = writeExport(cmd, func(w io.Writer) error {
+				return export.WriteCtags(w, p)
+			})
+			cobra.CheckErr(err)
+		},
+	}
+	ctagsCmd.Flags().StringP("output", "o", "tags", "Output file, - for stdout")
+	cmd.AddCommand(ctagsCmd)
+
+	scipCmd := &cobra.Command{
+		Use:   "scip [files or directories...]",
+		Short: "Write a SCIP index with the definitions and references",
+		Run: func(cmd *cobra.Command, args []string) {
+			p, err := collectExport(cmd, args)
+			cobra.CheckErr(err)
+			pkg, err := cmd.Flags().GetString("package")
+			cobra.CheckErr(err)
+			err = writeExport(cmd, func(w io.Writer) error {
+				return export.WriteSCIP(w, p, export.SCIPOptions{
+					Package:   pkg,
+					Arguments: os.Args[1:],
+				})
+			})
+			cobra.CheckErr(err)
+		},
+	}
+	scipCmd.Flags().StringP("output", "o", "index.scip", "Output file, - for stdout")
+	scipCmd.Flags().String("package", "", "Package name of the symbols (default: the name of the root directory)")
+	cmd.AddCommand(scipCmd)
+
+	cmd.PersistentFlags().String("root", ".", "Directory the paths of the files are relative to")
+
+	return cmd
+}
+
+// collectExport parses the files given as arguments, the root directory by
+// default.
+func collectExport(cmd *cobra.Command, args []string) (*export.Project, error) {
+	root, err := cmd.Flags().GetString("root")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		args = []string{root}
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return export.Collect(ctx, root, args)
+}
+
+func writeExport(cmd *cobra.Command, write func(w io.Writer) error) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
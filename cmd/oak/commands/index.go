@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/index"
+	"github.com/spf13/cobra"
+)
+
+func NewIndexCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Manage the index of symbol definitions and references",
+	}
+
+	buildCmd := &cobra.Command{
+		Use:   "build [files or directories...]",
+		Short: "Create or update the index with the symbols of a repository",
+		Long: "Create or update the index with the symbols of the given files and directories " +
+			"(the root of the index by default). Unchanged files are skipped.",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, err := cmd.Flags().GetString("db")
+			cobra.CheckErr(err)
+
+			idx, err := index.Open(index.Locate(db), true)
+			cobra.CheckErr(err)
+			defer func() {
+				_ = idx.Close()
+			}()
+
+			if len(args) == 0 {
+				args = []string{idx.Root()}
+			}
+			stats, err := idx.Build(cmd.Context(), args)
+			cobra.CheckErr(err)
+
+			fmt.Printf("%d files, %d indexed (%d symbols, %d references), %d removed\n",
+				stats.Files, stats.Indexed, stats.Symbols, stats.References, stats.Removed)
+		},
+	}
+	buildCmd.Flags().String("db", index.DefaultPath, "Path of the index")
+	cmd.AddCommand(buildCmd)
+
+	return cmd
+}
+
+type FindSettings struct {
+	Name     string `glazed.parameter:"name"`
+	Match    string `glazed.parameter:"match"`
+	Kind     string `glazed.parameter:"kind"`
+	Language string `glazed.parameter:"language"`
+	Path     string `glazed.parameter:"path"`
+	DB       string `glazed.parameter:"db"`
+}
+
+func (s *FindSettings) filter() index.Filter {
+	return index.Filter{
+		Name:     s.Name,
+		Match:    index.Match(s.Match),
+		Kind:     s.Kind,
+		Language: s.Language,
+		Path:     s.Path,
+	}
+}
+
+// newFindDescription returns the description of find-symbol and find-refs,
+// which share their flags apart from --kind.
+func newFindDescription(name string, short string, withKind bool) (*cmds.CommandDescription, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := []*parameters.ParameterDefinition{
+		parameters.NewParameterDefinition(
+			"match",
+			parameters.ParameterTypeChoice,
+			parameters.WithHelp("How to match the name"),
+			parameters.WithChoices(index.Matches...),
+			parameters.WithDefault(string(index.MatchExact)),
+		),
+		parameters.NewParameterDefinition(
+			"language",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Only return results in files of this language"),
+			parameters.WithDefault(""),
+		),
+		parameters.NewParameterDefinition(
+			"path",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Only return results in this file or directory"),
+			parameters.WithDefault(""),
+		),
+		parameters.NewParameterDefinition(
+			"db",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Path of the index"),
+			parameters.WithDefault(index.DefaultPath),
+		),
+	}
+	if withKind {
+		flags = append(flags, parameters.NewParameterDefinition(
+			"kind",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Only return symbols of this kind (function, method, class, ...)"),
+			parameters.WithDefault(""),
+		))
+	}
+
+	return cmds.NewCommandDescription(name,
+		cmds.WithShort(short),
+		cmds.WithFlags(flags...),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"name",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Name to look for (all names if empty)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	), nil
+}
+
+type FindSymbolCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*FindSymbolCommand)(nil)
+
+func NewFindSymbolCommand() (*cobra.Command, error) {
+	description, err := newFindDescription("find-symbol", "Find symbol definitions in the index", true)
+	if err != nil {
+		return nil, err
+	}
+	return cli.BuildCobraCommand(&FindSymbolCommand{CommandDescription: description})
+}
+
+func (c *FindSymbolCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &FindSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Open(index.Locate(s.DB), false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	results, err := idx.FindSymbols(ctx, s.filter())
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		err = gp.AddRow(ctx, types.NewRow(
+			types.MRP("name", r.Name),
+			types.MRP("kind", r.Kind),
+			types.MRP("container", r.Container),
+			types.MRP("parent", r.Parent),
+			types.MRP("file", idx.DisplayPath(r.File)),
+			types.MRP("line", r.Line),
+			types.MRP("column", r.Column),
+			types.MRP("end_line", r.EndLine),
+			types.MRP("signature", r.Signature),
+			types.MRP("doc", r.Doc),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type FindRefsCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*FindRefsCommand)(nil)
+
+func NewFindRefsCommand() (*cobra.Command, error) {
+	description, err := newFindDescription("find-refs", "Find references to a name in the index", false)
+	if err != nil {
+		return nil, err
+	}
+	return cli.BuildCobraCommand(&FindRefsCommand{CommandDescription: description})
+}
+
+func (c *FindRefsCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &FindSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Open(index.Locate(s.DB), false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	results, err := idx.FindReferences(ctx, s.filter())
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		err = gp.AddRow(ctx, types.NewRow(
+			types.MRP("name", r.Name),
+			types.MRP("type", r.Type),
+			types.MRP("file", idx.DisplayPath(r.File)),
+			types.MRP("line", r.Line),
+			types.MRP("column", r.Column),
+			types.MRP("symbol", r.Symbol),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/oak/pkg"
@@ -120,6 +121,46 @@ func RegisterLegacyCommands(rootCmd *cobra.Command) {
 			cobra.CheckErr(err)
 			skipWhitespace, err := cmd.Flags().GetBool("skip-whitespace")
 			cobra.CheckErr(err)
+			querySkeleton, err := cmd.Flags().GetBool("query-skeleton")
+			cobra.CheckErr(err)
+			at, err := cmd.Flags().GetString("at")
+			cobra.CheckErr(err)
+			byteRange, err := cmd.Flags().GetString("bytes")
+			cobra.CheckErr(err)
+			nodeType, err := cmd.Flags().GetString("node-type")
+			cobra.CheckErr(err)
+			maxDepth, err := cmd.Flags().GetInt("max-depth")
+			cobra.CheckErr(err)
+			overlayQueryFile, err := cmd.Flags().GetString("query-file")
+			cobra.CheckErr(err)
+			overlayQueryName, err := cmd.Flags().GetString("query-name")
+			cobra.CheckErr(err)
+
+			var overlayQueries []tree_sitter.SitterQuery
+			if overlayQueryFile != "" {
+				overlayQueries, err = loadOverlayQueries(overlayQueryFile, overlayQueryName)
+				cobra.CheckErr(err)
+			}
+
+			var atPoint *sitter.Point
+			if at != "" {
+				p, err := tree_sitter.ParsePoint(at)
+				cobra.CheckErr(err)
+				atPoint = &p
+			}
+			var bytesRange *tree_sitter.DumpByteRange
+			if byteRange != "" {
+				r, err := tree_sitter.ParseByteRange(byteRange)
+				cobra.CheckErr(err)
+				bytesRange = &r
+			}
+			if querySkeleton && dumpFormat == "" {
+				dumpFormat = string(tree_sitter.FormatSExpr)
+			}
+			// selecting a subtree or overlaying queries requires one of the enhanced formats
+			if dumpFormat == "" && (atPoint != nil || bytesRange != nil || nodeType != "" || maxDepth > 0 || overlayQueries != nil) {
+				dumpFormat = string(tree_sitter.FormatText)
+			}
 
 			for _, inputFile := range args {
 				var lang *sitter.Language
@@ -147,6 +188,12 @@ func RegisterLegacyCommands(rootCmd *cobra.Command) {
 
 				// Use the enhanced dumping with custom format if specified
 				if dumpFormat != "" {
+					var annotations tree_sitter.DumpAnnotations
+					if overlayQueries != nil {
+						annotations, err = tree_sitter.AnnotateQueries(lang, tree.RootNode(), overlayQueries, sourceCode)
+						cobra.CheckErr(err)
+					}
+
 					var format tree_sitter.DumpFormat
 					switch dumpFormat {
 					case "text":
@@ -157,6 +204,14 @@ func RegisterLegacyCommands(rootCmd *cobra.Command) {
 						format = tree_sitter.FormatJSON
 					case "yaml":
 						format = tree_sitter.FormatYAML
+					case "sexpr":
+						format = tree_sitter.FormatSExpr
+					case "dot":
+						format = tree_sitter.FormatDOT
+					case "mermaid":
+						format = tree_sitter.FormatMermaid
+					case "html":
+						format = tree_sitter.FormatHTML
 					default:
 						format = tree_sitter.FormatText
 					}
@@ -166,9 +221,16 @@ func RegisterLegacyCommands(rootCmd *cobra.Command) {
 						ShowContent:    showContent,
 						ShowAttributes: showAttributes,
 						SkipWhitespace: skipWhitespace,
+						QuerySkeleton:  querySkeleton,
+						At:             atPoint,
+						Bytes:          bytesRange,
+						NodeType:       nodeType,
+						MaxDepth:       maxDepth,
+						Annotations:    annotations,
 					}
 
-					err = oak.DumpTreeToWriter(tree, sourceCode, os.Stdout, format, options)
+					err = oak.DumpTreeToWriter(tree, sourceCode, os.Stdout, format, options,
+						tree_sitter.WithDumpTitle(inputFile))
 					cobra.CheckErr(err)
 				} else {
 					// Use the original DumpTree for backward compatibility
@@ -180,12 +242,50 @@ func RegisterLegacyCommands(rootCmd *cobra.Command) {
 
 	parseCmd.Flags().String("language", "", "Language name")
 	// Add dump format flags
-	parseCmd.Flags().String("dump-format", "", "Output format for the tree dump (text, xml, json, yaml)")
+	parseCmd.Flags().String("dump-format", "", "Output format for the tree dump (text, xml, json, yaml, sexpr, dot, mermaid, html)")
 	parseCmd.Flags().Bool("show-bytes", false, "Show byte offsets in the tree dump")
 	parseCmd.Flags().Bool("show-content", true, "Show node content in the tree dump")
 	parseCmd.Flags().Bool("show-attributes", true, "Show node attributes in the tree dump")
 	parseCmd.Flags().Bool("skip-whitespace", true, "Skip whitespace-only nodes in the tree dump")
+	parseCmd.Flags().Bool("query-skeleton", false, "Output a query skeleton for the selected node (implies --dump-format sexpr)")
+	parseCmd.Flags().String("at", "", "Only dump the smallest named node at line:col (1-based)")
+	parseCmd.Flags().String("bytes", "", "Only dump the smallest named node covering the byte range start-end")
+	parseCmd.Flags().String("node-type", "", "Only dump nodes of the given type")
+	parseCmd.Flags().String("query-file", "", "Annotate the dumped nodes with the captures of a query file (.scm) or oak command (.yaml)")
+	parseCmd.Flags().String("query-name", "", "Name of the query when using a plain query file")
+	parseCmd.Flags().Int("max-depth", 0, "Maximum depth of the tree dump below the selected nodes (0 for unlimited)")
 
 	rootCmd.AddCommand(parseCmd)
 	rootCmd.AddCommand(queryCmd)
 }
+
+// loadOverlayQueries loads the queries to annotate a tree dump with. fileName
+// is either an oak command, whose queries are rendered with the default
+// values of its flags, or a plain tree-sitter query.
+func loadOverlayQueries(fileName string, queryName string) ([]tree_sitter.SitterQuery, error) {
+	if strings.HasSuffix(fileName, ".yaml") || strings.HasSuffix(fileName, ".yml") {
+		oak, err := loadOakCommandFromFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defaults, err := oak.GetDefaultsMap()
+		if err != nil {
+			return nil, err
+		}
+		err = oak.RenderQueriesWithData(defaults)
+		if err != nil {
+			return nil, err
+		}
+		return oak.Queries, nil
+	}
+
+	query, err := readFileOrStdin(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if queryName == "" {
+		queryName = "main"
+	}
+
+	return []tree_sitter.SitterQuery{{Name: queryName, Query: string(query)}}, nil
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	cmds2 "github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/spf13/cobra"
+)
+
+func NewLintCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [paths...]",
+		Short: "Check oak command files for invalid queries and templates",
+		Long: "Compile the queries of the given command files (or of all the command files found " +
+			"in the given directories) against their language, with the default flag values and with " +
+			"each boolean flag toggled, and check that the captures used in the templates exist.",
+		Run: func(cmd *cobra.Command, args []string) {
+			strict, err := cmd.Flags().GetBool("strict")
+			cobra.CheckErr(err)
+
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			files, err := collectCommandFiles(args)
+			cobra.CheckErr(err)
+
+			errors_, warnings := 0, 0
+			for _, file := range files {
+				issues, err := cmds2.LintCommandFile(file)
+				cobra.CheckErr(err)
+
+				for _, issue := range issues {
+					fmt.Println(issue.String())
+					if issue.Severity == cmds2.LintError {
+						errors_++
+					} else {
+						warnings++
+					}
+				}
+			}
+
+			fmt.Printf("%d errors, %d warnings\n", errors_, warnings)
+
+			if errors_ > 0 || (strict && warnings > 0) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().Bool("strict", false, "Also fail on warnings")
+
+	return cmd
+}
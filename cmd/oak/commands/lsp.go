@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"os"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg/lsp"
+	"github.com/spf13/cobra"
+)
+
+func NewLSPCommand(allCommands []glazed_cmds.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server over stdio",
+		Long: "Speak the language server protocol over stdin and stdout, providing document and " +
+			"workspace symbols, diagnostics from the rules of the loaded commands, and hovers showing " +
+			"the syntax node path at the cursor.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s := lsp.NewServer(os.Stdin, os.Stdout, allCommands)
+			err := s.Run(cmd.Context())
+			cobra.CheckErr(err)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type MetricsCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*MetricsCommand)(nil)
+
+type MetricsSettings struct {
+	Sources       []string `glazed.parameter:"sources"`
+	Root          string   `glazed.parameter:"root"`
+	MaxComplexity int      `glazed.parameter:"max-complexity"`
+	MaxNesting    int      `glazed.parameter:"max-nesting"`
+	MaxLOC        int      `glazed.parameter:"max-loc"`
+	MaxParameters int      `glazed.parameter:"max-parameters"`
+	Violations    bool     `glazed.parameter:"violations"`
+}
+
+func NewMetricsCommand() (*cobra.Command, error) {
+	glazeLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := func(name string, help string) *parameters.ParameterDefinition {
+		return parameters.NewParameterDefinition(
+			name,
+			parameters.ParameterTypeInteger,
+			parameters.WithHelp(help+", 0 for no limit"),
+			parameters.WithDefault(0),
+		)
+	}
+
+	description := cmds.NewCommandDescription("metrics",
+		cmds.WithShort("Report the size, nesting and cyclomatic complexity of functions"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"root",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Root of the repository, the paths are relative to it"),
+				parameters.WithDefault("."),
+			),
+			threshold("max-complexity", "Maximum cyclomatic complexity of a function"),
+			threshold("max-nesting", "Maximum nesting depth of a function"),
+			threshold("max-loc", "Maximum lines of code of a function"),
+			threshold("max-parameters", "Maximum number of parameters of a function"),
+			parameters.NewParameterDefinition(
+				"violations",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Only list the functions exceeding a maximum"),
+				parameters.WithDefault(false),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"sources",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Files and directories to measure (the root by default)"),
+				parameters.WithRequired(false),
+			),
+		),
+		cmds.WithLayersList(glazeLayer),
+	)
+
+	return cli.BuildCobraCommand(&MetricsCommand{CommandDescription: description})
+}
+
+// exceeded returns the metrics of f that exceed their maximum.
+func (s *MetricsSettings) exceeded(f *metrics.Function) []string {
+	ret := []string{}
+	for _, m := range []struct {
+		name       string
+		value, max int
+	}{
+		{"complexity", f.Complexity, s.MaxComplexity},
+		{"nesting", f.Nesting, s.MaxNesting},
+		{"loc", f.LOC, s.MaxLOC},
+		{"parameters", f.Parameters, s.MaxParameters},
+	} {
+		if m.max > 0 && m.value > m.max {
+			ret = append(ret, fmt.Sprintf("%s %d > %d", m.name, m.value, m.max))
+		}
+	}
+	return ret
+}
+
+func (c *MetricsCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &MetricsSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = []string{s.Root}
+	}
+	functions, err := metrics.Collect(ctx, s.Root, sources)
+	if err != nil {
+		return err
+	}
+
+	violations := 0
+	for _, f := range functions {
+		exceeded := s.exceeded(f)
+		if len(exceeded) > 0 {
+			violations++
+		} else if s.Violations {
+			continue
+		}
+
+		name := f.Name
+		if f.Container != "" {
+			name = f.Container + "." + f.Name
+		}
+		err = gp.AddRow(ctx, types.NewRow(
+			types.MRP("file", f.File),
+			types.MRP("line", int(f.NameStartPoint.Row)+1),
+			types.MRP("function", name),
+			types.MRP("kind", string(f.Kind)),
+			types.MRP("language", f.Language),
+			types.MRP("lines", f.Lines),
+			types.MRP("loc", f.LOC),
+			types.MRP("parameters", f.Parameters),
+			types.MRP("nesting", f.Nesting),
+			types.MRP("complexity", f.Complexity),
+			types.MRP("exceeded", strings.Join(exceeded, ", ")),
+		))
+		if err != nil {
+			return err
+		}
+	}
+
+	if violations > 0 {
+		// output the rows before failing
+		err = gp.Close(ctx)
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("%d functions exceed the maximum metrics", violations)
+	}
+	return nil
+}
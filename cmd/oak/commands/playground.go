@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/playground"
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/spf13/cobra"
+)
+
+func NewPlaygroundCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "playground <file>",
+		Short: "Interactively write a tree-sitter query against a source file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			language, err := cmd.Flags().GetString("language")
+			cobra.CheckErr(err)
+			queryFile, err := cmd.Flags().GetString("query-file")
+			cobra.CheckErr(err)
+			output, err := cmd.Flags().GetString("output")
+			cobra.CheckErr(err)
+			name, err := cmd.Flags().GetString("name")
+			cobra.CheckErr(err)
+
+			inputFile := args[0]
+
+			var lang *sitter.Language
+			if language != "" {
+				lang, err = pkg.LanguageNameToSitterLanguage(language)
+				cobra.CheckErr(err)
+			} else {
+				lang, err = pkg.FileNameToSitterLanguage(inputFile)
+				cobra.CheckErr(err)
+				language, err = pkg.FileNameToLanguageName(inputFile)
+				cobra.CheckErr(err)
+			}
+
+			query := ""
+			if queryFile != "" {
+				b, err := readFileOrStdin(queryFile)
+				cobra.CheckErr(err)
+				query = string(b)
+			}
+
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+			}
+
+			err = playground.Run(cmd.Context(), lang, playground.Settings{
+				FileName:    inputFile,
+				Query:       query,
+				Language:    language,
+				OutputFile:  output,
+				CommandName: name,
+			})
+			cobra.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().String("language", "", "Language name")
+	cmd.Flags().StringP("query-file", "q", "", "Initial query file")
+	cmd.Flags().StringP("output", "o", "playground.yaml", "File to save the query as an oak command to (with ctrl+s)")
+	cmd.Flags().String("name", "", "Name of the saved command (defaults to the output file name)")
+
+	return cmd
+}
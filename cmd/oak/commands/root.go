@@ -2,8 +2,9 @@ package commands
 
 import (
 	"embed"
-	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	clay "github.com/go-go-golems/clay/pkg"
 	clay_commandmeta "github.com/go-go-golems/clay/pkg/cmds/commandmeta"
@@ -17,8 +18,11 @@ import (
 	"github.com/go-go-golems/glazed/pkg/help"
 	help_cmd "github.com/go-go-golems/glazed/pkg/help/cmd"
 	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/oak/pkg/cache"
 	cmds2 "github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -41,6 +45,26 @@ func InitRootCmd(docFS embed.FS) (*help.HelpSystem, error) {
 		return nil, err
 	}
 
+	RootCmd.PersistentFlags().Bool("check-templates", false,
+		"Check the templates of the loaded commands against their queries and flags, and log the issues")
+	RootCmd.PersistentFlags().Bool("strict-templates", false,
+		"Fail on template issues when loading commands")
+	RootCmd.PersistentFlags().Bool("no-cache", false,
+		"Parse all the files, instead of using the results cached for unchanged files")
+	for _, name := range []string{"check-templates", "strict-templates", "no-cache"} {
+		err = viper.BindPFlag(name, RootCmd.PersistentFlags().Lookup(name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = parseGlobalFlags(RootCmd.PersistentFlags(), os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if !viper.GetBool("no-cache") {
+		cache.EnableDefault()
+	}
+
 	RootCmd.AddCommand(RunCommandCmd)
 	return helpSystem, nil
 }
@@ -54,7 +78,7 @@ func InitAllCommands(helpSystem *help.HelpSystem, queriesFS embed.FS) error {
 		repositoryPaths = append(repositoryPaths, os.ExpandEnv(defaultDirectory))
 	}
 
-	loader := &cmds2.OakCommandLoader{}
+	loader := NewCommandLoader()
 	repositories_ := createRepositories(repositoryPaths, loader, queriesFS)
 
 	allCommands, err := repositories.LoadRepositories(
@@ -66,6 +90,13 @@ func InitAllCommands(helpSystem *help.HelpSystem, queriesFS embed.FS) error {
 	if err != nil {
 		return err
 	}
+	if templateErrors := loader.TemplateErrors(); len(templateErrors) > 0 {
+		messages := []string{}
+		for _, err := range templateErrors {
+			messages = append(messages, err.Error())
+		}
+		return errors.Errorf("commands with invalid templates:\n%s", strings.Join(messages, "\n"))
+	}
 
 	glazeCmd := &cobra.Command{
 		Use:   "glaze",
@@ -86,6 +117,24 @@ func InitAllCommands(helpSystem *help.HelpSystem, queriesFS embed.FS) error {
 		return err
 	}
 
+	rewriteCmd := &cobra.Command{
+		Use:   "rewrite",
+		Short: "Rewrite source files with the commands that have a rewrite section",
+	}
+	RootCmd.AddCommand(rewriteCmd)
+
+	repositories_ = createRepositories(repositoryPaths, &cmds2.OakRewriteCommandLoader{}, queriesFS)
+
+	_, err = repositories.LoadRepositories(
+		helpSystem,
+		rewriteCmd,
+		repositories_,
+		cli.WithCobraShortHelpLayers(layers.DefaultSlug, cmds2.OakSlug, cmds2.RewriteSlug),
+	)
+	if err != nil {
+		return err
+	}
+
 	// Create and add the unified command management group
 	commandManagementCmd, err := clay_commandmeta.NewCommandManagementCommandGroup(
 		allCommands,
@@ -115,16 +164,48 @@ func InitAllCommands(helpSystem *help.HelpSystem, queriesFS embed.FS) error {
 		}),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to initialize command management commands: %w", err)
+		return errors.Wrap(err, "failed to initialize command management commands")
 	}
 	RootCmd.AddCommand(commandManagementCmd)
 
 	// Create and add the repositories command group
 	RootCmd.AddCommand(clay_repositories.NewRepositoriesGroupCommand())
 
+	RootCmd.AddCommand(NewServeCommand(allCommands))
+	RootCmd.AddCommand(NewLSPCommand(allCommands))
+	RootCmd.AddCommand(NewCheckCommand(allCommands))
+
 	return nil
 }
 
+// NewCommandLoader returns the loader for oak commands, checking their
+// templates when asked to with --check-templates or --strict-templates (or the
+// corresponding config file and environment variables).
+//
+// Commands are loaded before cobra parses the command line, so InitRootCmd
+// parses the global flags first, see parseGlobalFlags.
+func NewCommandLoader() *cmds2.OakCommandLoader {
+	return &cmds2.OakCommandLoader{
+		CheckTemplates: viper.GetBool("check-templates"),
+		Strict:         viper.GetBool("strict-templates"),
+	}
+}
+
+// parseGlobalFlags parses the given persistent flags of the root command out
+// of args, ignoring the other flags and arguments. Some of these flags
+// configure how commands are loaded, which happens before cobra parses the
+// command line (and parses them again).
+func parseGlobalFlags(globalFlags *pflag.FlagSet, args []string) error {
+	flags := pflag.NewFlagSet("oak", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.SetOutput(io.Discard)
+	flags.AddFlagSet(globalFlags)
+	// defined so that parsing doesn't stop at --help
+	flags.BoolP("help", "h", false, "")
+
+	return flags.Parse(args)
+}
+
 func createRepositories(repositoryPaths []string, loader loaders.CommandLoader, queriesFS embed.FS) []*repositories.Repository {
 	directories := []repositories.Directory{
 		{
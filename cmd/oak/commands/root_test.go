@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestParseGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		strict bool
+		ok     bool
+	}{
+		{name: "not set", args: []string{"go", "definitions", "file.go"}, strict: false, ok: true},
+		{name: "before the command", args: []string{"--strict-templates", "go", "definitions"}, strict: true, ok: true},
+		{name: "after the command", args: []string{"go", "definitions", "--strict-templates"}, strict: true, ok: true},
+		{name: "with a value", args: []string{"go", "definitions", "--strict-templates=true"}, strict: true, ok: true},
+		{name: "disabled", args: []string{"go", "definitions", "--strict-templates=false"}, strict: false, ok: true},
+		{
+			name:   "among command flags",
+			args:   []string{"go", "definitions", "--only-public", "--glob", "*.go", "--strict-templates", "-r", "."},
+			strict: true,
+			ok:     true,
+		},
+		{name: "after --", args: []string{"go", "definitions", "--", "--strict-templates"}, strict: false, ok: true},
+		{name: "help", args: []string{"go", "--help", "--strict-templates"}, strict: true, ok: true},
+		{name: "invalid value", args: []string{"--strict-templates=maybe"}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			strict := flags.Bool("strict-templates", false, "")
+			err := parseGlobalFlags(flags, tt.args)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, expected ok %v", err, tt.ok)
+			}
+			if tt.ok && *strict != tt.strict {
+				t.Errorf("got %v, expected %v", *strict, tt.strict)
+			}
+		})
+	}
+}
@@ -20,25 +20,8 @@ var RunCommandCmd = &cobra.Command{
 	Short: "Run a command from a file",
 	Args:  cobra.MinimumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		queryFile, err := filepath.Abs(args[0])
-		if err != nil {
-			cobra.CheckErr(err)
-		}
-
-		loader := &cmds2.OakCommandLoader{}
-		fs_, queryFile, err := loaders.FileNameToFsFilePath(queryFile)
-		if err != nil {
-			cobra.CheckErr(err)
-		}
-		cmds_, err := loader.LoadCommands(fs_, queryFile, []glazed_cmds.CommandDescriptionOption{}, []alias.Option{})
+		oak, err := loadOakCommandFromFile(args[0])
 		cobra.CheckErr(err)
-		if len(cmds_) != 1 {
-			cobra.CheckErr(fmt.Errorf("expected exactly one command"))
-		}
-		oak, ok := cmds_[0].(*cmds2.OakWriterCommand)
-		if !ok {
-			cobra.CheckErr(fmt.Errorf("expected OakWriterCommand"))
-		}
 
 		for _, inputFile := range args[1:] {
 			sourceCode, err := readFileOrStdin(inputFile)
@@ -62,6 +45,33 @@ var RunCommandCmd = &cobra.Command{
 	},
 }
 
+// loadOakCommandFromFile loads a single oak command from a YAML file.
+func loadOakCommandFromFile(fileName string) (*cmds2.OakWriterCommand, error) {
+	queryFile, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := &cmds2.OakCommandLoader{}
+	fs_, queryFile, err := loaders.FileNameToFsFilePath(queryFile)
+	if err != nil {
+		return nil, err
+	}
+	cmds_, err := loader.LoadCommands(fs_, queryFile, []glazed_cmds.CommandDescriptionOption{}, []alias.Option{})
+	if err != nil {
+		return nil, err
+	}
+	if len(cmds_) != 1 {
+		return nil, fmt.Errorf("expected exactly one command")
+	}
+	oak, ok := cmds_[0].(*cmds2.OakWriterCommand)
+	if !ok {
+		return nil, fmt.Errorf("expected OakWriterCommand")
+	}
+
+	return oak, nil
+}
+
 func readFileOrStdin(filename string) ([]byte, error) {
 	if filename == "-" {
 		return io.ReadAll(os.Stdin)
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg/server"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewServeCommand(allCommands []glazed_cmds.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the oak commands as a JSON API over HTTP",
+		Long: "Expose the loaded oak commands as POST /commands/<name>, along with POST /parse and " +
+			"POST /query endpoints mirroring oak parse and oak query. Requests pass inline source code, " +
+			"or files relative to --root.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			host, err := cmd.Flags().GetString("host")
+			cobra.CheckErr(err)
+			port, err := cmd.Flags().GetInt("port")
+			cobra.CheckErr(err)
+			root, err := cmd.Flags().GetString("root")
+			cobra.CheckErr(err)
+
+			s, err := server.NewServer(root, allCommands)
+			cobra.CheckErr(err)
+
+			httpServer := &http.Server{
+				Addr:              fmt.Sprintf("%s:%d", host, port),
+				Handler:           s.Handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			errCh := make(chan error, 1)
+			go func() {
+				log.Info().Str("address", httpServer.Addr).Str("root", s.Root).Msg("serving oak commands")
+				errCh <- httpServer.ListenAndServe()
+			}()
+
+			select {
+			case err = <-errCh:
+			case <-ctx.Done():
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				err = httpServer.Shutdown(shutdownCtx)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				cobra.CheckErr(err)
+			}
+		},
+	}
+
+	cmd.Flags().String("host", "localhost", "Host to listen on")
+	cmd.Flags().Int("port", 8080, "Port to listen on")
+	cmd.Flags().String("root", ".", "Directory the file paths in requests are relative to")
+
+	return cmd
+}
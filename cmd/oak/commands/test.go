@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cmds2 "github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/spf13/cobra"
+)
+
+func NewTestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [paths...]",
+		Short: "Run the tests defined in oak command files",
+		Long: "Run the tests section of the given command files, or of all the command files " +
+			"found in the given directories, and print a diff for each failing test.",
+		Run: func(cmd *cobra.Command, args []string) {
+			update, err := cmd.Flags().GetBool("update")
+			cobra.CheckErr(err)
+			verbose, err := cmd.Flags().GetBool("verbose")
+			cobra.CheckErr(err)
+
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			files, err := collectCommandFiles(args)
+			cobra.CheckErr(err)
+
+			passed, failed, updated := 0, 0, 0
+			for _, file := range files {
+				results, err := cmds2.RunCommandTests(cmd.Context(), file, update)
+				if err != nil {
+					fmt.Printf("ERROR %s: %v\n", file, err)
+					failed++
+					continue
+				}
+
+				for _, result := range results {
+					switch {
+					case result.Err != nil:
+						failed++
+						fmt.Printf("ERROR %s: %s: %v\n", file, result.Name, result.Err)
+					case result.Updated:
+						updated++
+						fmt.Printf("UPDATE %s: %s\n", file, result.Name)
+					case !result.Passed():
+						failed++
+						fmt.Printf("FAIL %s: %s\n%s\n", file, result.Name, result.Diff)
+					default:
+						passed++
+						if verbose {
+							fmt.Printf("PASS %s: %s\n", file, result.Name)
+						}
+					}
+				}
+			}
+
+			fmt.Printf("%d passed, %d failed", passed, failed)
+			if update {
+				fmt.Printf(", %d updated", updated)
+			}
+			fmt.Println()
+
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().Bool("update", false, "Replace the expected output and captures of failing tests with the actual ones")
+	cmd.Flags().BoolP("verbose", "v", false, "Also print passing tests")
+
+	return cmd
+}
+
+// collectCommandFiles returns the YAML files given as arguments, and all the
+// YAML files in the given directories.
+func collectCommandFiles(paths []string) ([]string, error) {
+	ret := []string{}
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			ret = append(ret, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(fileName string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (strings.HasSuffix(fileName, ".yaml") || strings.HasSuffix(fileName, ".yml")) {
+				ret = append(ret, fileName)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
 	"github.com/go-go-golems/oak/cmd/oak/commands"
-	cmds2 "github.com/go-go-golems/oak/pkg/cmds"
 	"github.com/spf13/cobra"
 )
 
@@ -26,8 +25,12 @@ func main() {
 	// because we need to load the file and then run the command itself.
 	// we need to do this before cobra, because we don't know which flags to load yet
 	if len(os.Args) >= 3 && os.Args[1] == "run" && os.Args[2] != "--help" {
+		// the global flags configure the loader
+		_, err := commands.InitRootCmd(docFS)
+		cobra.CheckErr(err)
+
 		// load the command
-		loader := &cmds2.OakCommandLoader{}
+		loader := commands.NewCommandLoader()
 
 		filePath, err := filepath.Abs(os.Args[2])
 		if err != nil {
@@ -63,9 +66,6 @@ func main() {
 			os.Exit(1)
 		}
 
-		_, err = commands.InitRootCmd(docFS)
-		cobra.CheckErr(err)
-
 		commands.RootCmd.AddCommand(cobraCommand)
 		restArgs := os.Args[3:]
 		os.Args = append([]string{os.Args[0], cobraCommand.Use}, restArgs...)
@@ -78,7 +78,41 @@ func main() {
 	}
 
 	commands.RegisterLegacyCommands(commands.RootCmd)
+	commands.RootCmd.AddCommand(commands.NewPlaygroundCommand())
+	commands.RootCmd.AddCommand(commands.NewTestCommand())
+	commands.RootCmd.AddCommand(commands.NewLintCommand())
+	commands.RootCmd.AddCommand(commands.NewCacheCommand())
+	commands.RootCmd.AddCommand(commands.NewIndexCommand())
+	commands.RootCmd.AddCommand(commands.NewExportCommand())
+
+	findSymbolCmd, err := commands.NewFindSymbolCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(findSymbolCmd)
+	findRefsCmd, err := commands.NewFindRefsCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(findRefsCmd)
+	depsCmd, err := commands.NewDepsCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(depsCmd)
+	callgraphCmd, err := commands.NewCallgraphCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(callgraphCmd)
+	metricsCmd, err := commands.NewMetricsCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(metricsCmd)
+	diffCmd, err := commands.NewDiffCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(diffCmd)
+	apiDiffCmd, err := commands.NewAPIDiffCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(apiDiffCmd)
+	dupesCmd, err := commands.NewDupesCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(dupesCmd)
+	docCoverageCmd, err := commands.NewDocCoverageCommand()
+	cobra.CheckErr(err)
+	commands.RootCmd.AddCommand(docCoverageCmd)
 
-	err := commands.RootCmd.Execute()
+	err = commands.RootCmd.Execute()
 	cobra.CheckErr(err)
 }
@@ -0,0 +1,446 @@
+// Package api extracts the API surface of source files, the exported
+// symbols found by the symbols package with their parameters and types,
+// and compares the API of two versions of a repository to find breaking
+// changes.
+package api
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Export is an exported symbol.
+type Export struct {
+	*symbols.Symbol
+	File     string
+	Language string
+	// Package is the unit the symbol is exported from: the directory of Go
+	// files, and the file for the other languages.
+	Package string
+	// QualifiedName is the names of the symbols containing this one (or its
+	// container) and its name, separated by dots.
+	QualifiedName string
+
+	// Parameters and Result are the parameters and the return type of
+	// functions and methods, ParametersText the normalized text of their
+	// parameter list.
+	Parameters     []Parameter
+	ParametersText string
+	Result         string
+	// Type is the declared type of the other symbols, like fields,
+	// variables and type aliases, empty for classes, structs and
+	// interfaces whose members are exported on their own.
+	Type string
+}
+
+// Parameter is a parameter of an exported function.
+type Parameter struct {
+	// Type is the declared type, empty for untyped parameters.
+	Type string
+	// Optional is true for parameters that can be omitted by callers:
+	// optional parameters, parameters with a default value and variadic
+	// parameters.
+	Optional bool
+}
+
+var languages = map[string]bool{
+	"go":         true,
+	"golang":     true,
+	"javascript": true,
+	"typescript": true,
+	"tsx":        true,
+	"php":        true,
+	"java":       true,
+	"python":     true,
+	"rust":       true,
+}
+
+// HasLanguage returns true if the exported symbols of the language can be
+// extracted.
+func HasLanguage(lang string) bool {
+	return languages[lang] && symbols.HasLanguage(lang)
+}
+
+// IsAPIFile returns true if the symbols of a file can be part of the API:
+// files of languages with exports, except Go tests and the Go files of
+// internal packages.
+func IsAPIFile(fileName string) bool {
+	lang, err := pkg.FileNameToLanguageName(fileName)
+	if err != nil || !HasLanguage(lang) {
+		return false
+	}
+	if lang == "go" || lang == "golang" {
+		if strings.HasSuffix(fileName, "_test.go") {
+			return false
+		}
+		for _, segment := range strings.Split(path.Dir(fileName), "/") {
+			if segment == "internal" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// diffLanguages are the languages whose APIs are compared by oak api-diff.
+// The exports of the other languages are only used for doc coverage.
+var diffLanguages = map[string]bool{
+	"go":         true,
+	"golang":     true,
+	"javascript": true,
+	"typescript": true,
+	"tsx":        true,
+	"php":        true,
+	"java":       true,
+}
+
+// IsDiffFile returns true if IsAPIFile is true for the file, and its API
+// can be compared by Compare.
+func IsDiffFile(fileName string) bool {
+	lang, err := pkg.FileNameToLanguageName(fileName)
+	return err == nil && diffLanguages[lang] && IsAPIFile(fileName)
+}
+
+// Collect extracts the exported symbols of files, given by name, skipping
+// the files for which IsAPIFile is false. Exports are sorted by file and
+// position.
+func Collect(ctx context.Context, files map[string][]byte) ([]*Export, error) {
+	names := []string{}
+	for name := range files {
+		if IsAPIFile(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ret := []*Export{}
+	for _, name := range names {
+		lang, _ := pkg.FileNameToLanguageName(name)
+		exports, err := Extract(ctx, lang, files[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not extract the exports of %s", name)
+		}
+		for _, e := range exports {
+			e.File = name
+			e.Package = name
+			if lang == "go" || lang == "golang" {
+				e.Package = path.Dir(name)
+			}
+		}
+		ret = append(ret, exports...)
+	}
+	return ret, nil
+}
+
+// Extract parses source and returns its exported symbols.
+func Extract(ctx context.Context, lang string, source []byte) ([]*Export, error) {
+	if !HasLanguage(lang) {
+		return nil, errors.Errorf("no exports for language %s", lang)
+	}
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(sitterLang)
+	tree, err := parser.ParseCtx(ctx, nil, source)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	root := tree.RootNode()
+
+	syms, err := symbols.Extract(lang, root, source)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &extractor{
+		lang:   lang,
+		source: source,
+		names:  exportedNames(lang, root, source),
+	}
+
+	type entry struct {
+		symbol   *symbols.Symbol
+		node     *sitter.Node
+		name     string
+		exported bool
+	}
+	ret := []*Export{}
+	stack := []*entry{}
+	for _, s := range syms {
+		for len(stack) > 0 && !stack[len(stack)-1].symbol.Contains(s.StartByte, s.EndByte) {
+			stack = stack[:len(stack)-1]
+		}
+		var parent *entry
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].symbol.StartByte != s.StartByte || stack[j].symbol.EndByte != s.EndByte {
+				parent = stack[j]
+				break
+			}
+		}
+
+		n := pkg.NodeForRange(root, s.StartByte, s.EndByte)
+		current := &entry{symbol: s, node: n}
+		switch {
+		case parent != nil:
+			current.name = parent.name + "." + s.Name
+			current.exported = parent.exported && !isFunction(parent.symbol.Kind) &&
+				e.isExported(s, n, parent.symbol, parent.node)
+		default:
+			current.name = s.Name
+			if s.Container != "" {
+				current.name = s.Container + "." + s.Name
+			}
+			current.exported = e.isExported(s, n, nil, root)
+		}
+		stack = append(stack, current)
+
+		if !current.exported || s.Kind == symbols.KindNamespace || s.Kind == symbols.KindModule {
+			continue
+		}
+		export := &Export{
+			Symbol:        s,
+			Language:      lang,
+			QualifiedName: current.name,
+		}
+		if isFunction(s.Kind) {
+			e.function(export, n)
+		} else {
+			export.Type = e.declaredType(s, n)
+		}
+		ret = append(ret, export)
+	}
+	return ret, nil
+}
+
+func isFunction(kind symbols.Kind) bool {
+	return kind == symbols.KindFunction || kind == symbols.KindMethod || kind == symbols.KindConstructor
+}
+
+type extractor struct {
+	lang   string
+	source []byte
+	// names are the names exported by export clauses in JavaScript and
+	// TypeScript, like export { a, b }.
+	names map[string]bool
+}
+
+func (e *extractor) text(n *sitter.Node) string {
+	if n == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(n.Content(e.source)), " ")
+}
+
+// isExported returns true if the symbol s, defined by n, is exported by
+// the symbol parent containing it (defined by parentNode), or by the file
+// if parent is nil. The parent is known to be exported.
+func (e *extractor) isExported(s *symbols.Symbol, n *sitter.Node, parent *symbols.Symbol, parentNode *sitter.Node) bool {
+	switch e.lang {
+	case "go", "golang":
+		return isGoExported(s.Name) && (s.Container == "" || isGoExported(s.Container))
+
+	case "javascript", "typescript", "tsx":
+		if parent == nil || parent.Kind == symbols.KindNamespace || parent.Kind == symbols.KindModule {
+			for p := n.Parent(); p != nil && !p.Equal(parentNode); p = p.Parent() {
+				if p.Type() == "export_statement" {
+					return true
+				}
+			}
+			return parent == nil && e.names[s.Name]
+		}
+		// members of exported classes and interfaces
+		if strings.HasPrefix(s.Name, "#") {
+			return false
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			child := n.NamedChild(i)
+			if child.Type() == "accessibility_modifier" && e.text(child) != "public" {
+				return false
+			}
+		}
+		return true
+
+	case "php":
+		// the modifiers of properties and constants are on their
+		// declaration, which can declare several ones
+		declaration := n
+		if t := n.Type(); t == "property_element" || t == "const_element" {
+			declaration = n.Parent()
+		}
+		for i := 0; i < int(declaration.NamedChildCount()); i++ {
+			child := declaration.NamedChild(i)
+			if child.Type() == "visibility_modifier" && e.text(child) != "public" {
+				return false
+			}
+		}
+		return true
+
+	case "java":
+		if parent != nil && (parent.Kind == symbols.KindInterface || s.Kind == symbols.KindEnumMember) {
+			return !hasModifier(n, "private", e.source)
+		}
+		return hasModifier(n, "public", e.source)
+
+	case "python":
+		// names starting with an underscore are private, except special
+		// methods like __init__
+		return !strings.HasPrefix(s.Name, "_") || (strings.HasPrefix(s.Name, "__") && strings.HasSuffix(s.Name, "__"))
+
+	case "rust":
+		if parent != nil && (parent.Kind == symbols.KindInterface || parent.Kind == symbols.KindEnum) {
+			return true
+		}
+		// the methods of trait implementations are as public as the trait
+		if list := n.Parent(); list != nil && list.Type() == "declaration_list" {
+			if impl := list.Parent(); impl != nil && impl.Type() == "impl_item" && impl.ChildByFieldName("trait") != nil {
+				return true
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			child := n.NamedChild(i)
+			if child.Type() == "visibility_modifier" {
+				return e.text(child) == "pub"
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func isGoExported(name string) bool {
+	for _, r := range name {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+// hasModifier returns true if the modifiers of the Java declaration n
+// contain modifier.
+func hasModifier(n *sitter.Node, modifier string, source []byte) bool {
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		if child.Type() != "modifiers" {
+			continue
+		}
+		for _, word := range strings.Fields(child.Content(source)) {
+			if word == modifier {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exportedNames returns the names exported by the top-level export clauses
+// of a JavaScript or TypeScript file, like export { a, b as c } and export
+// default a.
+func exportedNames(lang string, root *sitter.Node, source []byte) map[string]bool {
+	ret := map[string]bool{}
+	if lang != "javascript" && lang != "typescript" && lang != "tsx" {
+		return ret
+	}
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		statement := root.NamedChild(i)
+		if statement.Type() != "export_statement" || statement.ChildByFieldName("source") != nil {
+			continue
+		}
+		if value := statement.ChildByFieldName("value"); value != nil && value.Type() == "identifier" {
+			ret[value.Content(source)] = true
+		}
+		for j := 0; j < int(statement.NamedChildCount()); j++ {
+			clause := statement.NamedChild(j)
+			if clause.Type() != "export_clause" {
+				continue
+			}
+			for k := 0; k < int(clause.NamedChildCount()); k++ {
+				if name := clause.NamedChild(k).ChildByFieldName("name"); name != nil {
+					ret[name.Content(source)] = true
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// function sets the parameters and the return type of the function defined
+// by n.
+func (e *extractor) function(export *Export, n *sitter.Node) {
+	// functions assigned to a variable
+	if value := n.ChildByFieldName("value"); value != nil && n.Type() == "variable_declarator" {
+		n = value
+	}
+
+	for _, field := range []string{"result", "return_type"} {
+		if result := n.ChildByFieldName(field); result != nil {
+			export.Result = typeText(e.text(result))
+		}
+	}
+	if e.lang == "java" && n.Type() == "method_declaration" {
+		export.Result = e.text(n.ChildByFieldName("type"))
+	}
+
+	params, single := tree_sitter.FindParameters(n)
+	if single {
+		export.Parameters = []Parameter{{}}
+		export.ParametersText = e.text(n.ChildByFieldName("parameter"))
+		return
+	}
+	if params == nil {
+		return
+	}
+	export.ParametersText = e.text(params)
+	for i := 0; i < int(params.NamedChildCount()); i++ {
+		p := params.NamedChild(i)
+		t := p.Type()
+		if strings.Contains(t, "comment") || e.text(p) == "void" {
+			continue
+		}
+		variadic := strings.Contains(t, "variadic") || strings.Contains(t, "rest") ||
+			strings.Contains(t, "spread") || strings.Contains(t, "splat")
+		parameter := Parameter{
+			Type: typeText(e.text(p.ChildByFieldName("type"))),
+			Optional: variadic || strings.Contains(t, "optional") || t == "assignment_pattern" ||
+				p.ChildByFieldName("value") != nil || p.ChildByFieldName("default_value") != nil,
+		}
+		if variadic && parameter.Type != "" {
+			parameter.Type = "..." + parameter.Type
+		}
+
+		// several names declared with the same type, like a, b int in Go
+		names := tree_sitter.CountDeclaredNames(p)
+		for j := 0; j < max(names, 1); j++ {
+			export.Parameters = append(export.Parameters, parameter)
+		}
+	}
+}
+
+// declaredType returns the declared type of a symbol that isn't a
+// function.
+func (e *extractor) declaredType(s *symbols.Symbol, n *sitter.Node) string {
+	switch s.Kind {
+	case symbols.KindClass, symbols.KindStruct, symbols.KindInterface, symbols.KindEnum:
+		return ""
+	}
+	if n.Type() == "type_alias_declaration" {
+		return e.text(n.ChildByFieldName("value"))
+	}
+	return typeText(e.text(n.ChildByFieldName("type")))
+}
+
+// typeText removes the colon of TypeScript type annotations.
+func typeText(t string) string {
+	return strings.TrimSpace(strings.TrimPrefix(t, ":"))
+}
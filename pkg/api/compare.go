@@ -0,0 +1,191 @@
+package api
+
+import (
+	"sort"
+)
+
+// ChangeKind is the kind of change of an exported symbol.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeKindChanged symbols have another kind of definition, like a
+	// function replaced by a variable.
+	ChangeKindChanged ChangeKind = "kind"
+	ChangeParameters  ChangeKind = "parameters"
+	ChangeResult      ChangeKind = "result"
+	ChangeType        ChangeKind = "type"
+)
+
+// Change is a change of the API between two versions.
+type Change struct {
+	Kind ChangeKind
+	// Breaking is true for changes that can break the code using the
+	// symbol: removed symbols, changed kinds, return types and types, and
+	// parameter lists that callers can't keep using.
+	Breaking bool
+	// Old is nil for added symbols, and New for removed symbols.
+	Old *Export
+	New *Export
+}
+
+// Export returns the new version of the changed symbol, or the old one for
+// removed symbols.
+func (c *Change) Export() *Export {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// OldText and NewText return the part of the old and new symbols that
+// changed: their parameters, return type or type, or their signature.
+func (c *Change) OldText() string {
+	return c.text(c.Old)
+}
+
+func (c *Change) NewText() string {
+	return c.text(c.New)
+}
+
+func (c *Change) text(e *Export) string {
+	if e == nil {
+		return ""
+	}
+	switch c.Kind {
+	case ChangeParameters:
+		return e.ParametersText
+	case ChangeResult:
+		return e.Result
+	case ChangeType:
+		return e.Type
+	}
+	return e.Signature
+}
+
+func key(e *Export) string {
+	return e.Language + "\x00" + e.Package + "\x00" + e.QualifiedName
+}
+
+// Compare returns the changes between the exports of an old and a new
+// version, sorted by file and position, the removed symbols being sorted
+// by their old file and position.
+//
+// Symbols are matched by package and qualified name. Overloads, several
+// symbols with the same name, are matched by signature first, and the
+// remaining ones are only compared when there is one on each side.
+func Compare(oldExports, newExports []*Export) []*Change {
+	oldByKey := map[string][]*Export{}
+	newByKey := map[string][]*Export{}
+	keys := []string{}
+	for _, e := range oldExports {
+		if _, ok := oldByKey[key(e)]; !ok {
+			keys = append(keys, key(e))
+		}
+		oldByKey[key(e)] = append(oldByKey[key(e)], e)
+	}
+	for _, e := range newExports {
+		if _, ok := oldByKey[key(e)]; !ok {
+			if _, ok := newByKey[key(e)]; !ok {
+				keys = append(keys, key(e))
+			}
+		}
+		newByKey[key(e)] = append(newByKey[key(e)], e)
+	}
+
+	ret := []*Change{}
+	for _, k := range keys {
+		olds, news := oldByKey[k], newByKey[k]
+
+		// unchanged overloads
+		remainingOld := []*Export{}
+		matched := map[*Export]bool{}
+		for _, o := range olds {
+			found := false
+			for _, n := range news {
+				if !matched[n] && o.Kind == n.Kind && o.Signature == n.Signature {
+					matched[n] = true
+					found = true
+					ret = append(ret, compare(o, n)...)
+					break
+				}
+			}
+			if !found {
+				remainingOld = append(remainingOld, o)
+			}
+		}
+		remainingNew := []*Export{}
+		for _, n := range news {
+			if !matched[n] {
+				remainingNew = append(remainingNew, n)
+			}
+		}
+
+		if len(remainingOld) == 1 && len(remainingNew) == 1 {
+			ret = append(ret, compare(remainingOld[0], remainingNew[0])...)
+			continue
+		}
+		for _, o := range remainingOld {
+			ret = append(ret, &Change{Kind: ChangeRemoved, Breaking: true, Old: o})
+		}
+		for _, n := range remainingNew {
+			ret = append(ret, &Change{Kind: ChangeAdded, New: n})
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i].Export(), ret[j].Export()
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.StartByte < b.StartByte
+	})
+	return ret
+}
+
+// compare returns the changes between two versions of a symbol.
+func compare(o, n *Export) []*Change {
+	if o.Kind != n.Kind {
+		return []*Change{{Kind: ChangeKindChanged, Breaking: true, Old: o, New: n}}
+	}
+
+	ret := []*Change{}
+	if o.ParametersText != n.ParametersText {
+		ret = append(ret, &Change{
+			Kind:     ChangeParameters,
+			Breaking: breaksCallers(o.Parameters, n.Parameters),
+			Old:      o,
+			New:      n,
+		})
+	}
+	if o.Result != n.Result {
+		ret = append(ret, &Change{Kind: ChangeResult, Breaking: true, Old: o, New: n})
+	}
+	if o.Type != n.Type {
+		ret = append(ret, &Change{Kind: ChangeType, Breaking: true, Old: o, New: n})
+	}
+	return ret
+}
+
+// breaksCallers returns true if calls matching the old parameters may not
+// match the new ones: when parameters are removed, change type or become
+// required, or when required parameters are added. Renamed parameters and
+// added optional parameters don't break callers.
+func breaksCallers(olds, news []Parameter) bool {
+	if len(news) < len(olds) {
+		return true
+	}
+	for i, n := range news {
+		if i >= len(olds) {
+			if !n.Optional {
+				return true
+			}
+			continue
+		}
+		if olds[i].Type != n.Type || (olds[i].Optional && !n.Optional) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBreaksCallers(t *testing.T) {
+	required := func(typ string) Parameter { return Parameter{Type: typ} }
+	optional := func(typ string) Parameter { return Parameter{Type: typ, Optional: true} }
+
+	tests := []struct {
+		name     string
+		olds     []Parameter
+		news     []Parameter
+		breaking bool
+	}{
+		{name: "unchanged", olds: []Parameter{required("int")}, news: []Parameter{required("int")}},
+		{name: "required parameter added", olds: []Parameter{required("int")}, news: []Parameter{required("int"), required("int")}, breaking: true},
+		{name: "optional parameter added", olds: []Parameter{required("int")}, news: []Parameter{required("int"), optional("int")}},
+		{name: "parameter removed", olds: []Parameter{required("int"), optional("int")}, news: []Parameter{required("int")}, breaking: true},
+		{name: "type changed", olds: []Parameter{required("int")}, news: []Parameter{required("string")}, breaking: true},
+		{name: "becomes optional", olds: []Parameter{required("int")}, news: []Parameter{optional("int")}},
+		{name: "becomes required", olds: []Parameter{optional("int")}, news: []Parameter{required("int")}, breaking: true},
+		{name: "untyped", olds: []Parameter{required("")}, news: []Parameter{required(""), optional("")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := breaksCallers(tt.olds, tt.news); got != tt.breaking {
+				t.Errorf("got %v, expected %v", got, tt.breaking)
+			}
+		})
+	}
+}
+
+func TestCompareParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     string
+		old      string
+		new      string
+		breaking bool
+	}{
+		{
+			name:     "go name added to a shared type",
+			lang:     "go",
+			old:      "package a\n\nfunc F(b, c int) {}\n",
+			new:      "package a\n\nfunc F(b, c, d int) {}\n",
+			breaking: true,
+		},
+		{
+			name: "go parameters renamed",
+			lang: "go",
+			old:  "package a\n\nfunc F(b, c int) {}\n",
+			new:  "package a\n\nfunc F(x, y int) {}\n",
+		},
+		{
+			name:     "go names split",
+			lang:     "go",
+			old:      "package a\n\nfunc F(b, c int) {}\n",
+			new:      "package a\n\nfunc F(b int, c string) {}\n",
+			breaking: true,
+		},
+		{
+			name: "typescript optional parameter added",
+			lang: "typescript",
+			old:  "export function f(a: number) {}\n",
+			new:  "export function f(a: number, b?: string) {}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			olds, err := Extract(context.Background(), tt.lang, []byte(tt.old))
+			if err != nil {
+				t.Fatal(err)
+			}
+			news, err := Extract(context.Background(), tt.lang, []byte(tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changes := Compare(olds, news)
+			if len(changes) != 1 || changes[0].Kind != ChangeParameters {
+				t.Fatalf("expected a parameters change, got %v", changes)
+			}
+			if changes[0].Breaking != tt.breaking {
+				t.Errorf("breaking: got %v, expected %v (%s -> %s)",
+					changes[0].Breaking, tt.breaking, changes[0].OldText(), changes[0].NewText())
+			}
+		})
+	}
+}
+
+func TestIsDiffFile(t *testing.T) {
+	tests := []struct {
+		file string
+		api  bool
+		diff bool
+	}{
+		{file: "pkg/a.go", api: true, diff: true},
+		{file: "pkg/a_test.go"},
+		{file: "internal/a.go"},
+		{file: "src/a.ts", api: true, diff: true},
+		{file: "src/A.java", api: true, diff: true},
+		{file: "src/a.php", api: true, diff: true},
+		// exported for doc coverage only
+		{file: "src/a.py", api: true},
+		{file: "src/a.rs", api: true},
+		{file: "README.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := IsAPIFile(tt.file); got != tt.api {
+				t.Errorf("IsAPIFile: got %v, expected %v", got, tt.api)
+			}
+			if got := IsDiffFile(tt.file); got != tt.diff {
+				t.Errorf("IsDiffFile: got %v, expected %v", got, tt.diff)
+			}
+		})
+	}
+}
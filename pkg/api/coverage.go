@@ -0,0 +1,117 @@
+package api
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	"github.com/pkg/errors"
+)
+
+// ReadFiles reads the given files, and the files in the given directories,
+// for which IsAPIFile is true. File names are relative to root when they
+// are inside it.
+func ReadFiles(root string, sources []string) (map[string][]byte, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]byte{}
+	for _, source := range sources {
+		err = pkg.WalkSourceFiles(source, HasLanguage, func(fileName string, lang string, info os.FileInfo) error {
+			name := filepath.ToSlash(fileName)
+			if abs, err := filepath.Abs(fileName); err == nil {
+				if rel, err := filepath.Rel(absRoot, abs); err == nil && !strings.HasPrefix(rel, "..") {
+					name = filepath.ToSlash(rel)
+				}
+			}
+			if _, ok := ret[name]; ok || !IsAPIFile(name) {
+				return nil
+			}
+			content, err := os.ReadFile(fileName)
+			if err != nil {
+				return errors.Wrapf(err, "could not read file %s", fileName)
+			}
+			ret[name] = content
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// IsMember returns true for the fields, properties and enum members, which
+// are often documented by their type rather than on their own.
+func IsMember(kind symbols.Kind) bool {
+	return kind == symbols.KindField || kind == symbols.KindProperty || kind == symbols.KindEnumMember
+}
+
+// Coverage is the documentation coverage of the exported symbols of a file
+// or a package.
+type Coverage struct {
+	Name     string
+	Language string
+	Symbols  int
+	// Documented is the number of symbols with a doc comment (or a
+	// docstring), see symbols.Symbol.Doc.
+	Documented   int
+	Undocumented []*Export
+}
+
+// Percent returns the percentage of documented symbols, 100 if there are
+// no symbols.
+func (c *Coverage) Percent() float64 {
+	if c.Symbols == 0 {
+		return 100
+	}
+	return 100 * float64(c.Documented) / float64(c.Symbols)
+}
+
+func (c *Coverage) add(e *Export) {
+	c.Symbols++
+	if e.Doc != "" {
+		c.Documented++
+	} else {
+		c.Undocumented = append(c.Undocumented, e)
+	}
+}
+
+// DocCoverage returns the documentation coverage of exports per file, or per
+// package (the directory of the files) if byPackage is true, sorted by
+// name, and the total coverage.
+func DocCoverage(exports []*Export, byPackage bool) ([]*Coverage, *Coverage) {
+	total := &Coverage{Name: "total"}
+	coverages := map[string]*Coverage{}
+	for _, e := range exports {
+		name := e.File
+		if byPackage {
+			name = path.Dir(e.File)
+		}
+		c, ok := coverages[name]
+		if !ok {
+			c = &Coverage{Name: name, Language: e.Language}
+			coverages[name] = c
+		}
+		if c.Language != e.Language {
+			c.Language = "mixed"
+		}
+		c.add(e)
+		total.add(e)
+	}
+
+	ret := []*Coverage{}
+	for _, c := range coverages {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, total
+}
@@ -10,6 +10,7 @@ import (
 	"text/template"
 
 	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cache"
 	"github.com/go-go-golems/oak/pkg/tree-sitter"
 	"github.com/pkg/errors"
 	sitter "github.com/smacker/go-tree-sitter"
@@ -20,6 +21,7 @@ import (
 type QueryBuilder struct {
 	language string
 	queries  []Query
+	cache    *cache.Cache
 }
 
 // Query represents a named tree-sitter query
@@ -49,6 +51,15 @@ func WithLanguage(language string) QueryOption {
 	}
 }
 
+// WithCache stores the results of Run in c, and reuses them for files that
+// haven't changed. Without it, every file is parsed. Use cache.Default() for
+// the cache of the oak command line tool.
+func WithCache(c *cache.Cache) QueryOption {
+	return func(qb *QueryBuilder) {
+		qb.cache = c
+	}
+}
+
 // WithQuery adds a query to the builder
 func WithQuery(name, query string) QueryOption {
 	return func(qb *QueryBuilder) {
@@ -222,18 +233,18 @@ func (qb *QueryBuilder) Run(
 				return
 			}
 
-			// Create parser and parse the file
-			parser := sitter.NewParser()
-			parser.SetLanguage(lang)
-			tree, err := parser.ParseCtx(ctx, nil, content)
-			if err != nil {
-				fmt.Printf("Error parsing file %s: %s\n", file, err)
-				return
+			// Parse the file and execute the queries, unless the results
+			// are cached
+			parse := func() (*sitter.Tree, error) {
+				parser := sitter.NewParser()
+				parser.SetLanguage(lang)
+				tree, err := parser.ParseCtx(ctx, nil, content)
+				if err != nil {
+					return nil, errors.Wrapf(err, "could not parse file %s", file)
+				}
+				return tree, nil
 			}
-			defer tree.Close()
-
-			// Execute queries
-			fileResults, err := tree_sitter.ExecuteQueries(lang, tree.RootNode(), sitterQueries, content)
+			fileResults, err := qb.cache.ExecuteQueries(parse, qb.language, lang, sitterQueries, content)
 			if err != nil {
 				fmt.Printf("Error executing queries on file %s: %s\n", file, err)
 				return
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-go-golems/oak/pkg/cache"
+)
+
+func TestRunWithCache(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.go")
+	err := os.WriteFile(file, []byte("package a\n\nfunc Foo() {}\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := cache.New(t.TempDir())
+	for _, options := range [][]QueryOption{
+		{},
+		{WithCache(c)},
+	} {
+		qb := NewQueryBuilder(append([]QueryOption{
+			WithLanguage("go"),
+			WithQuery("functions", "(function_declaration name: (identifier) @name)"),
+		}, options...)...)
+		results, err := qb.Run(context.Background(), WithFiles([]string{file}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches := results[file]["functions"].Matches
+		if len(matches) != 1 || matches[0]["name"].Text != "Foo" {
+			t.Errorf("unexpected results %v", results)
+		}
+
+		stats, err := c.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only the run with WithCache writes to the cache
+		if expected := len(options); stats.Entries != expected {
+			t.Errorf("got %d cache entries, expected %d", stats.Entries, expected)
+		}
+	}
+}
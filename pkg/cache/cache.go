@@ -0,0 +1,379 @@
+// Package cache stores the results of queries on disk, so that running a
+// command again on unchanged files doesn't parse them again.
+//
+// Entries are keyed by the content of the file, the grammar of the language
+// and the (rendered) queries, so that they never need to be invalidated:
+// changing any of them changes the key. The cache is bounded by a maximum
+// size, over which the least recently used entries are removed.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// formatVersion is part of every key, and needs to be increased when the
+// stored results change, for example when fields are added to
+// tree_sitter.Capture.
+const formatVersion = "1"
+
+// DefaultMaxSize is the maximum size of a cache, unless set with
+// WithMaxSize.
+const DefaultMaxSize int64 = 256 << 20
+
+// Cache is a directory of query results. It is safe for concurrent use.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu sync.Mutex
+	// size is the size of the entries, as of the last prune plus what has
+	// been written since. It is -1 until the directory has been walked.
+	size int64
+}
+
+// Option configures a Cache created by New.
+type Option func(c *Cache)
+
+// WithMaxSize sets the size over which the least recently used entries are
+// removed. 0 means unbounded.
+func WithMaxSize(maxSize int64) Option {
+	return func(c *Cache) {
+		c.maxSize = maxSize
+	}
+}
+
+// New returns a cache storing its entries in dir, which is created on the
+// first write.
+func New(dir string, options ...Option) *Cache {
+	c := &Cache{
+		dir:     dir,
+		maxSize: DefaultMaxSize,
+		size:    -1,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// MaxSize returns the size over which entries are removed, 0 if unbounded.
+func (c *Cache) MaxSize() int64 {
+	return c.maxSize
+}
+
+// DefaultDir returns $XDG_CACHE_HOME/oak, or the oak directory in the user
+// cache directory of the platform.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "oak"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "oak"), nil
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultCache *Cache
+	enabled      atomic.Bool
+)
+
+// Default returns the cache in DefaultDir, used by the commands running
+// queries over files. It returns nil, which disables caching, unless
+// EnableDefault has been called, so that programs using oak as a library
+// don't write to the cache directory of the user without asking for it.
+func Default() *Cache {
+	if !enabled.Load() {
+		return nil
+	}
+	defaultOnce.Do(func() {
+		dir, err := DefaultDir()
+		if err != nil {
+			log.Debug().Err(err).Msg("no cache directory, caching is disabled")
+			return
+		}
+		defaultCache = New(dir)
+	})
+	return defaultCache
+}
+
+// EnableDefault makes Default return the cache in DefaultDir. The oak
+// command line tool calls it unless --no-cache is set.
+func EnableDefault() {
+	enabled.Store(true)
+}
+
+// grammarVersion identifies the version of the grammars compiled into the
+// binary, which come with the go-tree-sitter module.
+var grammarVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/smacker/go-tree-sitter" {
+			if dep.Replace != nil {
+				return dep.Replace.Path + "@" + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return info.Main.Version
+})
+
+// Key returns the key of the results of queries on source, parsed with lang
+// (whose name is langName).
+func Key(langName string, lang *sitter.Language, queries []tree_sitter.SitterQuery, source []byte) string {
+	h := sha256.New()
+	write := func(s string) {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	write(formatVersion)
+	write(langName)
+	write(grammarVersion())
+	write(fmt.Sprint(lang.SymbolCount()))
+	for _, query := range queries {
+		write(query.Name)
+		write(query.Query)
+	}
+	sourceHash := sha256.Sum256(source)
+	write(string(sourceHash[:]))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, "results", key[:2], key[2:])
+}
+
+// Get returns the results stored under key.
+func (c *Cache) Get(key string) (tree_sitter.QueryResults, bool) {
+	path := c.path(key)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	// the modification time tells which entries were used last, see prune
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	results := tree_sitter.QueryResults{}
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&results)
+	if err != nil {
+		log.Debug().Err(err).Str("key", key).Msg("could not decode cache entry")
+		return nil, false
+	}
+	return results, true
+}
+
+// Put stores results under key. The entry is written to a temporary file
+// first, so that concurrent readers never see a partial entry.
+func (c *Cache) Put(key string, results tree_sitter.QueryResults) error {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(results)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	c.grow(int64(buf.Len()))
+	return nil
+}
+
+// grow accounts for an entry of the given size, and removes the least
+// recently used entries if the cache is now over its maximum size.
+func (c *Cache) grow(size int64) {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size >= 0 && c.size+size <= c.maxSize {
+		c.size += size
+		return
+	}
+	// the size isn't known yet, or was overestimated since entries can be
+	// overwritten: walk the directory
+	size, err := c.prune(c.maxSize)
+	if err != nil {
+		log.Debug().Err(err).Msg("could not prune cache")
+		return
+	}
+	c.size = size
+}
+
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// prune removes the least recently used entries until the cache is below
+// 90% of maxSize (to not prune again on the next write), and returns the
+// size of the remaining entries.
+func (c *Cache) prune(maxSize int64) (int64, error) {
+	entries := []entry{}
+	size := int64(0)
+	err := c.walk(func(path string, info fs.FileInfo) {
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		size += info.Size()
+	})
+	if err != nil {
+		return 0, err
+	}
+	if size <= maxSize {
+		return size, nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	target := maxSize / 10 * 9
+	for _, e := range entries {
+		if size <= target {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+		size -= e.size
+	}
+	return size, nil
+}
+
+// walk calls f for every entry of the cache.
+func (c *Cache) walk(f func(path string, info fs.FileInfo)) error {
+	return filepath.WalkDir(filepath.Join(c.dir, "results"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed concurrently
+				return nil
+			}
+			return err
+		}
+		f(path, info)
+		return nil
+	})
+}
+
+// ExecuteQueries returns the results of the queries on source, from the
+// cache if possible. Otherwise, source is parsed and the results are
+// stored. A nil cache always parses source.
+func (c *Cache) ExecuteQueries(
+	parse func() (*sitter.Tree, error),
+	langName string,
+	lang *sitter.Language,
+	queries []tree_sitter.SitterQuery,
+	source []byte,
+) (tree_sitter.QueryResults, error) {
+	var key string
+	if c != nil {
+		key = Key(langName, lang, queries, source)
+		if results, ok := c.Get(key); ok {
+			return results, nil
+		}
+	}
+
+	tree, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	results, err := tree_sitter.ExecuteQueries(lang, tree.RootNode(), queries, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if c != nil {
+		err = c.Put(key, results)
+		if err != nil {
+			log.Debug().Err(err).Msg("could not write cache entry")
+		}
+	}
+	return results, nil
+}
+
+// Stats describes the content of the cache directory.
+type Stats struct {
+	Dir     string
+	Entries int
+	Size    int64
+	MaxSize int64
+}
+
+// Stats walks the cache directory.
+func (c *Cache) Stats() (*Stats, error) {
+	ret := &Stats{Dir: c.dir, MaxSize: c.maxSize}
+	err := c.walk(func(path string, info fs.FileInfo) {
+		ret.Entries++
+		ret.Size += info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Clear removes all the entries of the cache.
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = -1
+	return os.RemoveAll(filepath.Join(c.dir, "results"))
+}
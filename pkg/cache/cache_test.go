@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+func TestDefault(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if c := Default(); c != nil {
+		t.Fatalf("expected no default cache before EnableDefault, got %s", c.Dir())
+	}
+	EnableDefault()
+	c := Default()
+	if c == nil {
+		t.Fatal("expected a default cache after EnableDefault")
+	}
+	if c.Dir() != filepath.Join(os.Getenv("XDG_CACHE_HOME"), "oak") {
+		t.Errorf("unexpected directory %s", c.Dir())
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	key := func(i int) string {
+		return fmt.Sprintf("%064x", i)
+	}
+	results := tree_sitter.QueryResults{
+		"main": {QueryName: strings.Repeat("x", 1000)},
+	}
+
+	unbounded := New(dir, WithMaxSize(0))
+	for i := 1; i <= 4; i++ {
+		if err := unbounded.Put(key(i), results); err != nil {
+			t.Fatal(err)
+		}
+		// entry 1 is the oldest, entry 4 the newest
+		modTime := time.Now().Add(-time.Duration(10-i) * time.Hour)
+		if err := os.Chtimes(unbounded.path(key(i)), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// reading entry 1 makes it the most recently used
+	if _, ok := unbounded.Get(key(1)); !ok {
+		t.Fatal("entry 1 not found")
+	}
+
+	stats, err := unbounded.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entrySize := stats.Size / 4
+
+	// a fifth entry takes the cache over its maximum size, which removes the
+	// least recently used entry
+	c := New(dir, WithMaxSize(entrySize*9/2))
+	if err := c.Put(key(5), results); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		_, ok := c.Get(key(i))
+		if expected := i != 2; ok != expected {
+			t.Errorf("entry %d: got %v, expected %v", i, ok, expected)
+		}
+	}
+}
+
+func TestExecuteQueries(t *testing.T) {
+	c := New(t.TempDir())
+	source := []byte("package a\n\nfunc Foo() {}\n")
+	queries := []tree_sitter.SitterQuery{{
+		Name:  "functions",
+		Query: "(function_declaration name: (identifier) @name)",
+	}}
+
+	parses := 0
+	parse := func() (*sitter.Tree, error) {
+		parses++
+		parser := sitter.NewParser()
+		defer parser.Close()
+		parser.SetLanguage(golang.GetLanguage())
+		return parser.ParseCtx(t.Context(), nil, source)
+	}
+
+	for i := 0; i < 2; i++ {
+		results, err := c.ExecuteQueries(parse, "go", golang.GetLanguage(), queries, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches := results["functions"].Matches
+		if len(matches) != 1 || matches[0]["name"].Text != "Foo" {
+			t.Errorf("unexpected results %v", results)
+		}
+	}
+	if parses != 1 {
+		t.Errorf("source parsed %d times, expected once", parses)
+	}
+
+	// a nil cache always parses
+	var nilCache *Cache
+	_, err := nilCache.ExecuteQueries(parse, "go", golang.GetLanguage(), queries, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parses != 2 {
+		t.Errorf("source parsed %d times, expected twice", parses)
+	}
+}
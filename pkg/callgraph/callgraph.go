@@ -0,0 +1,478 @@
+// Package callgraph approximates the call graph of a repository. Calls are
+// matched with a tree-sitter query per language, and linked by name to the
+// function and method definitions of the symbols package, using the class
+// of the caller, the imports of its file and the proximity of the
+// definitions to tell apart definitions with the same name.
+//
+// The queries capture the call node as @call, the called name as @name and,
+// for method calls and qualified calls, the object, type or package the
+// function is called on as @receiver.
+package callgraph
+
+import (
+	"context"
+	"embed"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cache"
+	"github.com/go-go-golems/oak/pkg/deps"
+	"github.com/go-go-golems/oak/pkg/export"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+//go:embed queries/*.scm
+var queriesFS embed.FS
+
+// queries are the call queries, in queries/<language>.scm.
+var queries = tree_sitter.NewQueryRegistry(queriesFS, "queries", "call query", map[string]string{
+	"golang":     "go",
+	"typescript": "javascript",
+	"tsx":        "javascript",
+})
+
+// HasLanguage returns true if calls can be extracted for the language.
+func HasLanguage(lang string) bool {
+	return queries.HasLanguage(lang)
+}
+
+// Query returns the call query of the language.
+func Query(lang string) (string, error) {
+	return queries.Query(lang)
+}
+
+// Call is a call from a function or method to a definition.
+type Call struct {
+	Caller *export.Definition
+	Callee *export.Definition
+	// Name is the called name, and Receiver the text of the object, type or
+	// package it is called on, if any.
+	Name     string
+	Receiver string
+	// Line and Column are the 1-based position of the call in the file of
+	// the caller.
+	Line   int
+	Column int
+	// Candidates is the number of definitions the call is linked to. Calls
+	// with several candidates are ambiguous.
+	Candidates int
+}
+
+// Graph is the call graph of a project.
+type Graph struct {
+	Project *export.Project
+	// Calls are sorted by caller file and position.
+	Calls []*Call
+
+	callees map[*export.Definition][]*Call
+	callers map[*export.Definition][]*Call
+	// imports are the imports of each file, by path.
+	imports map[string][]*deps.Edge
+}
+
+// Build extracts the definitions and calls of the given files, and of the
+// files in the given directories, which need to be inside root, and links
+// the calls to their definitions.
+func Build(ctx context.Context, root string, sources []string) (*Graph, error) {
+	p, err := export.Collect(ctx, root, sources)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := deps.Collect(ctx, root, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{
+		Project: p,
+		callees: map[*export.Definition][]*Call{},
+		callers: map[*export.Definition][]*Call{},
+		imports: map[string][]*deps.Edge{},
+	}
+	for _, e := range edges {
+		g.imports[e.File] = append(g.imports[e.File], e)
+	}
+
+	c := cache.Default()
+	for _, f := range p.Files {
+		if !HasLanguage(f.Language) {
+			continue
+		}
+		calls, err := extract(ctx, c, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not extract the calls of %s", f.Path)
+		}
+		for _, call := range calls {
+			caller := enclosingFunction(f, call.StartByte)
+			if caller == nil {
+				continue
+			}
+			callees := g.resolve(f, caller, call.Name, call.Receiver)
+			for _, callee := range callees {
+				g.add(&Call{
+					Caller:     caller,
+					Callee:     callee,
+					Name:       call.Name,
+					Receiver:   call.Receiver,
+					Line:       int(call.StartPoint.Row) + 1,
+					Column:     int(call.StartPoint.Column) + 1,
+					Candidates: len(callees),
+				})
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *Graph) add(c *Call) {
+	g.Calls = append(g.Calls, c)
+	g.callees[c.Caller] = append(g.callees[c.Caller], c)
+	g.callers[c.Callee] = append(g.callers[c.Callee], c)
+}
+
+// Unique returns the graph without the ambiguous calls.
+func (g *Graph) Unique() *Graph {
+	ret := &Graph{
+		Project: g.Project,
+		callees: map[*export.Definition][]*Call{},
+		callers: map[*export.Definition][]*Call{},
+		imports: g.imports,
+	}
+	for _, c := range g.Calls {
+		if c.Candidates == 1 {
+			ret.add(c)
+		}
+	}
+	return ret
+}
+
+// Callees returns the calls made by the definition.
+func (g *Graph) Callees(d *export.Definition) []*Call {
+	return g.callees[d]
+}
+
+// Callers returns the calls to the definition.
+func (g *Graph) Callers(d *export.Definition) []*Call {
+	return g.callers[d]
+}
+
+// Find returns the functions and methods named symbol, or whose qualified
+// name is symbol or ends with "." followed by symbol, such as Index.Build.
+func (g *Graph) Find(symbol string) []*export.Definition {
+	ret := []*export.Definition{}
+	for _, f := range g.Project.Files {
+		for _, d := range f.Definitions {
+			if !isFunction(d.Kind) {
+				continue
+			}
+			if q := d.QualifiedName(); d.Name == symbol || q == symbol || strings.HasSuffix(q, "."+symbol) {
+				ret = append(ret, d)
+			}
+		}
+	}
+	return ret
+}
+
+type rawCall struct {
+	Name       string
+	Receiver   string
+	StartByte  uint32
+	StartPoint sitter.Point
+}
+
+func extract(ctx context.Context, c *cache.Cache, f *export.File) ([]*rawCall, error) {
+	query, err := Query(f.Language)
+	if err != nil {
+		return nil, err
+	}
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(f.Language)
+	if err != nil {
+		return nil, err
+	}
+	parse := func() (*sitter.Tree, error) {
+		parser := sitter.NewParser()
+		defer parser.Close()
+		parser.SetLanguage(sitterLang)
+		return parser.ParseCtx(ctx, nil, f.Source)
+	}
+	queries := []tree_sitter.SitterQuery{{Name: "calls", Query: query}}
+	results, err := c.ExecuteQueries(parse, f.Language, sitterLang, queries, f.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*rawCall{}
+	result, ok := results["calls"]
+	if !ok {
+		return ret, nil
+	}
+	// chained calls like a().b() start at the same byte
+	type key struct {
+		start uint32
+		name  string
+	}
+	seen := map[key]bool{}
+	for _, match := range result.Matches {
+		call, ok := match["call"]
+		name, ok2 := match["name"]
+		k := key{call.StartByte, name.Text}
+		if !ok || !ok2 || seen[k] {
+			continue
+		}
+		seen[k] = true
+		ret = append(ret, &rawCall{
+			Name:       name.Text,
+			Receiver:   match["receiver"].Text,
+			StartByte:  call.StartByte,
+			StartPoint: call.StartPoint,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].StartByte < ret[j].StartByte
+	})
+	return ret, nil
+}
+
+// sameLanguage returns true if code in one language can call code in the
+// other, like JavaScript and TypeScript.
+func sameLanguage(a, b string) bool {
+	return queries.Family(a) == queries.Family(b)
+}
+
+func isFunction(kind symbols.Kind) bool {
+	return kind == symbols.KindFunction || kind == symbols.KindMethod || kind == symbols.KindConstructor
+}
+
+// enclosingFunction returns the innermost function or method of the file
+// containing the given byte.
+func enclosingFunction(f *export.File, b uint32) *export.Definition {
+	var ret *export.Definition
+	for _, d := range f.Definitions {
+		if !isFunction(d.Kind) || !d.Contains(b, b) {
+			continue
+		}
+		if ret == nil || d.EndByte-d.StartByte < ret.EndByte-ret.StartByte {
+			ret = d
+		}
+	}
+	return ret
+}
+
+// class returns the name of the type a definition belongs to: its closest
+// parent that is a type, or its container if its parent wasn't found.
+func class(d *export.Definition) string {
+	if d.Parent == nil {
+		return d.Container
+	}
+	for p := d.Parent; p != nil; p = p.Parent {
+		if export.IsType(p.Kind) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func sameClass(a, b *export.Definition) bool {
+	c := class(a)
+	return c != "" && c == class(b) && a.File.Namespace() == b.File.Namespace()
+}
+
+var goReceiverRegexp = regexp.MustCompile(`^func\s*\(\s*(\w+)`)
+
+// isSelf returns true if the receiver is the object or type the caller is
+// a method of: this, self, Self, static, or the receiver of a Go method.
+func isSelf(caller *export.Definition, receiver string) bool {
+	switch receiver {
+	case "this", "self", "$this", "Self", "static":
+		return true
+	}
+	if m := goReceiverRegexp.FindStringSubmatch(caller.Signature); m != nil {
+		return m[1] == receiver
+	}
+	return false
+}
+
+// lastSegment returns the last segment of a qualified name, like the name
+// of a type in a Rust path or a PHP namespace.
+func lastSegment(name string) string {
+	for _, sep := range []string{"::", `\`, ".", "/"} {
+		if i := strings.LastIndex(name, sep); i >= 0 {
+			name = name[i+len(sep):]
+		}
+	}
+	return name
+}
+
+// moduleName returns the name of the module of a file: its name without
+// extension, or the name of its directory for index files like mod.rs and
+// __init__.py.
+func moduleName(file string) string {
+	base := path.Base(file)
+	name := strings.TrimSuffix(base, path.Ext(base))
+	switch name {
+	case "mod", "__init__", "index":
+		return path.Base(path.Dir(file))
+	}
+	return name
+}
+
+// importName returns the name a file refers to an import with, when it is
+// not renamed: the last segment of the Go package path, of the Python,
+// Rust, PHP or Java module, or the file name of a JavaScript module.
+func importName(e *deps.Edge) string {
+	imp := e.Import
+	if i := strings.Index(imp, "{"); i >= 0 {
+		imp = strings.TrimRight(imp[:i], ":")
+	}
+	if i := strings.Index(imp, " as "); i >= 0 {
+		imp = imp[:i]
+	}
+	name := lastSegment(strings.TrimSpace(imp))
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// inImport returns true if the definition is in the file or package an
+// import resolves to.
+func inImport(d *export.Definition, e *deps.Edge) bool {
+	if e.Kind != deps.KindLocal {
+		return false
+	}
+	if e.Language == "go" || e.Language == "golang" {
+		return d.File.Namespace() == e.To
+	}
+	return d.File.Path == e.To
+}
+
+func filter(definitions []*export.Definition, keep func(d *export.Definition) bool) []*export.Definition {
+	ret := []*export.Definition{}
+	for _, d := range definitions {
+		if keep(d) {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// resolve returns the definitions a call may refer to:
+//
+//   - calls on this, self or the receiver of a Go method are linked to the
+//     methods of the class of the caller,
+//   - calls on an imported package or module are linked to the definitions
+//     of the import, or not at all if it is external,
+//   - calls on a type, like static calls, are linked to its methods, and
+//     calls on a module, like Rust paths, to the functions of its file,
+//   - calls on other objects are linked to the methods with that name in
+//     the file, else in its namespace, else in the files it imports, but
+//     not to the caller itself,
+//   - calls without receiver are linked to the functions with that name in
+//     the file, else in its namespace, else in the files it imports, else
+//     in the project. In Java, the methods of the class of the caller come
+//     first.
+func (g *Graph) resolve(f *export.File, caller *export.Definition, name string, receiver string) []*export.Definition {
+	candidates := filter(g.Project.Definitions(name), func(d *export.Definition) bool {
+		return (isFunction(d.Kind) || d.Kind == symbols.KindClass) && sameLanguage(d.File.Language, f.Language)
+	})
+	if len(candidates) == 0 {
+		return nil
+	}
+	isMethod := func(d *export.Definition) bool {
+		return class(d) != ""
+	}
+
+	switch {
+	case receiver != "" && isSelf(caller, receiver):
+		return preferFunctions(filter(candidates, func(d *export.Definition) bool {
+			return sameClass(d, caller)
+		}))
+
+	case receiver != "":
+		for _, e := range g.imports[f.Path] {
+			if importName(e) != receiver {
+				continue
+			}
+			return preferFunctions(filter(candidates, func(d *export.Definition) bool {
+				return inImport(d, e)
+			}))
+		}
+		typeName := lastSegment(receiver)
+		if methods := filter(candidates, func(d *export.Definition) bool {
+			return class(d) == typeName
+		}); len(methods) > 0 {
+			return g.closest(f, methods, true)
+		}
+		if functions := filter(candidates, func(d *export.Definition) bool {
+			return !isMethod(d) && moduleName(d.File.Path) == typeName
+		}); len(functions) > 0 {
+			return g.closest(f, functions, true)
+		}
+		// methods delegating to the method of the same name of another
+		// object, like Close calling the Close of a field, are common,
+		// recursive calls on other objects aren't
+		candidates = filter(candidates, func(d *export.Definition) bool {
+			return isMethod(d) && d != caller
+		})
+		// the object can be of any type, including types of other
+		// packages, so only look at the definitions close to the caller
+		return g.closest(f, candidates, false)
+
+	case f.Language == "java":
+		// methods of the class are called without this
+		if methods := filter(candidates, func(d *export.Definition) bool {
+			return sameClass(d, caller)
+		}); len(methods) > 0 {
+			return preferFunctions(methods)
+		}
+		candidates = filter(candidates, func(d *export.Definition) bool {
+			return !isMethod(d) || d.Kind == symbols.KindConstructor
+		})
+
+	default:
+		candidates = filter(candidates, func(d *export.Definition) bool {
+			return !isMethod(d) || d.Kind == symbols.KindConstructor
+		})
+	}
+
+	return g.closest(f, candidates, true)
+}
+
+// closest returns the candidates in the first scope that has any: the
+// file, its namespace, the files it imports, and the project if anywhere is
+// true.
+func (g *Graph) closest(f *export.File, candidates []*export.Definition, anywhere bool) []*export.Definition {
+	for _, inScope := range []func(d *export.Definition) bool{
+		func(d *export.Definition) bool { return d.File == f },
+		func(d *export.Definition) bool { return d.File.Namespace() == f.Namespace() },
+		func(d *export.Definition) bool {
+			for _, e := range g.imports[f.Path] {
+				if inImport(d, e) {
+					return true
+				}
+			}
+			return false
+		},
+		func(d *export.Definition) bool { return anywhere },
+	} {
+		if ret := filter(candidates, inScope); len(ret) > 0 {
+			return preferFunctions(ret)
+		}
+	}
+	return nil
+}
+
+// preferFunctions drops the classes of the candidates if there are
+// functions, such as the constructors of the class.
+func preferFunctions(candidates []*export.Definition) []*export.Definition {
+	functions := filter(candidates, func(d *export.Definition) bool {
+		return isFunction(d.Kind)
+	})
+	if len(functions) > 0 {
+		return functions
+	}
+	return candidates
+}
@@ -0,0 +1,193 @@
+package callgraph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildTestGraph writes the files in a temporary directory and builds
+// their call graph.
+func buildTestGraph(t *testing.T, files map[string]string) *Graph {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := Build(context.Background(), dir, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// edges returns the calls of the graph as "caller -> callee", followed by
+// the number of candidates of ambiguous calls.
+func edges(g *Graph) []string {
+	ret := []string{}
+	for _, c := range g.Calls {
+		edge := c.Caller.QualifiedName() + " -> " + c.Callee.QualifiedName()
+		if c.Candidates > 1 {
+			edge += fmt.Sprintf(" (%d)", c.Candidates)
+		}
+		ret = append(ret, edge)
+	}
+	return ret
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name: "go",
+			files: map[string]string{
+				"go.mod": "module example.com/m\n",
+				"a/a.go": `package a
+
+import "example.com/m/b"
+
+type Server struct{ c Client }
+
+func New() *Server { return &Server{} }
+
+func (s *Server) Start() {
+	s.listen()
+	b.Run()
+	helper()
+}
+
+func (s *Server) listen() {}
+
+func helper() {}
+`,
+				"a/client.go": `package a
+
+type Client struct{}
+
+func (c *Client) Close() {}
+
+func (s *Server) Close() { s.c.Close() }
+`,
+				"b/b.go": `package b
+
+func Run() {}
+
+func helper() {}
+`,
+			},
+			expected: []string{
+				"Server.Start -> Server.listen",
+				"Server.Start -> Run",
+				"Server.Start -> helper",
+				"Server.Close -> Client.Close",
+			},
+		},
+		{
+			name: "python",
+			files: map[string]string{
+				"app.py": `from util import load
+
+
+class App:
+    def run(self):
+        self.setup()
+        load()
+
+    def setup(self):
+        pass
+
+
+def main():
+    App().run()
+`,
+				"util.py": "def load():\n    pass\n",
+			},
+			expected: []string{
+				"App.run -> App.setup",
+				"App.run -> load",
+				"main -> App",
+				"main -> App.run",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := edges(buildTestGraph(t, tt.files))
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("got calls:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	g := buildTestGraph(t, map[string]string{
+		"a.go": `package a
+
+func A() {
+	B()
+	C()
+}
+
+func B() { C() }
+
+func C() { A() }
+`,
+	})
+
+	tests := []struct {
+		name      string
+		symbol    string
+		direction Direction
+		maxDepth  int
+		expected  string
+	}{
+		{
+			name:      "callees",
+			symbol:    "A",
+			direction: DirectionCallees,
+			expected: `A (a.go:3) calls
+  B (a.go:8)
+    C (a.go:10)
+      A (a.go:3) (recursive)
+  C (a.go:10) ...
+`,
+		},
+		{
+			name:      "callers with depth",
+			symbol:    "C",
+			direction: DirectionCallers,
+			maxDepth:  1,
+			expected: `C (a.go:10) is called by
+  A (a.go:3)
+  B (a.go:8)
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := g.WriteTree(&b, g.Find(tt.symbol), tt.direction, tt.maxDepth); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.expected {
+				t.Errorf("got:\n%s\nexpected:\n%s", b.String(), tt.expected)
+			}
+		})
+	}
+}
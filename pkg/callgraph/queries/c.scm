@@ -0,0 +1,9 @@
+; Calls for C, including the calls of function pointers stored in structs.
+
+(call_expression
+  function: (identifier) @name) @call
+
+(call_expression
+  function: (field_expression
+    argument: (_) @receiver
+    field: (field_identifier) @name)) @call
@@ -0,0 +1,10 @@
+; Calls for Go. Calls of package functions and methods capture the package
+; or the receiver as @receiver.
+
+(call_expression
+  function: (identifier) @name) @call
+
+(call_expression
+  function: (selector_expression
+    operand: (_) @receiver
+    field: (field_identifier) @name)) @call
@@ -0,0 +1,17 @@
+; Calls for Java: method invocations, with or without an object, and
+; instantiations of classes.
+
+(method_invocation
+  object: (_) @receiver
+  name: (identifier) @name) @call
+
+(method_invocation
+  !object
+  name: (identifier) @name) @call
+
+(object_creation_expression
+  type: (type_identifier) @name) @call
+
+(object_creation_expression
+  type: (generic_type
+    (type_identifier) @name)) @call
@@ -0,0 +1,18 @@
+; Calls for JavaScript and TypeScript, including the instantiations of
+; classes with new.
+
+(call_expression
+  function: (identifier) @name) @call
+
+(call_expression
+  function: (member_expression
+    object: (_) @receiver
+    property: (property_identifier) @name)) @call
+
+(new_expression
+  constructor: (identifier) @name) @call
+
+(new_expression
+  constructor: (member_expression
+    object: (_) @receiver
+    property: (property_identifier) @name)) @call
@@ -0,0 +1,19 @@
+; Calls for PHP: function calls, method calls on objects ($this->a()), static
+; calls (self::a(), Foo::a()) and instantiations. The grammar parses the
+; names of functions and classes as qualified names.
+
+(function_call_expression
+  function: (qualified_name
+    (name) @name .)) @call
+
+(member_call_expression
+  object: (_) @receiver
+  name: (name) @name) @call
+
+(scoped_call_expression
+  scope: (_) @receiver
+  name: (name) @name) @call
+
+(object_creation_expression
+  (qualified_name
+    (name) @name .)) @call
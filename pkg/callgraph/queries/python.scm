@@ -0,0 +1,9 @@
+; Calls for Python. Instantiations of classes are calls of the class.
+
+(call
+  function: (identifier) @name) @call
+
+(call
+  function: (attribute
+    object: (_) @receiver
+    attribute: (identifier) @name)) @call
@@ -0,0 +1,19 @@
+; Calls for Rust: function calls, method calls (self.a()) and associated
+; functions of types and modules (Self::new(), module::a()).
+
+(call_expression
+  function: (identifier) @name) @call
+
+(call_expression
+  function: (field_expression
+    value: (_) @receiver
+    field: (field_identifier) @name)) @call
+
+(call_expression
+  function: (scoped_identifier
+    path: (_) @receiver
+    name: (identifier) @name)) @call
+
+(call_expression
+  function: (generic_function
+    function: (identifier) @name)) @call
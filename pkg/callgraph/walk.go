@@ -0,0 +1,224 @@
+package callgraph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/go-go-golems/oak/pkg/export"
+)
+
+// Direction tells which calls to follow from a definition.
+type Direction string
+
+const (
+	// DirectionCallees follows the calls made by the definitions.
+	DirectionCallees Direction = "callees"
+	// DirectionCallers follows the calls to the definitions.
+	DirectionCallers Direction = "callers"
+)
+
+// Step is a call reached while walking the graph.
+type Step struct {
+	Call      *Call
+	Direction Direction
+	// Depth is 1 for the calls of the start definitions.
+	Depth int
+}
+
+// next returns the calls to follow from d, and the definitions they lead to.
+func (g *Graph) next(d *export.Definition, direction Direction) ([]*Call, func(c *Call) *export.Definition) {
+	if direction == DirectionCallers {
+		return g.Callers(d), func(c *Call) *export.Definition { return c.Caller }
+	}
+	return g.Callees(d), func(c *Call) *export.Definition { return c.Callee }
+}
+
+// Walk returns the calls reached from the start definitions in the given
+// direction, breadth first, up to maxDepth calls away (without limit if
+// maxDepth is 0). The calls of each definition are followed once.
+func (g *Graph) Walk(start []*export.Definition, direction Direction, maxDepth int) []*Step {
+	ret := []*Step{}
+	visited := map[*export.Definition]bool{}
+	current := []*export.Definition{}
+	for _, d := range start {
+		if !visited[d] {
+			visited[d] = true
+			current = append(current, d)
+		}
+	}
+
+	for depth := 1; len(current) > 0 && (maxDepth == 0 || depth <= maxDepth); depth++ {
+		following := []*export.Definition{}
+		for _, d := range current {
+			calls, target := g.next(d, direction)
+			for _, c := range calls {
+				ret = append(ret, &Step{Call: c, Direction: direction, Depth: depth})
+				if t := target(c); !visited[t] {
+					visited[t] = true
+					following = append(following, t)
+				}
+			}
+		}
+		current = following
+	}
+	return ret
+}
+
+// Label returns the qualified name of a definition with its file and line.
+func Label(d *export.Definition) string {
+	return fmt.Sprintf("%s (%s:%d)", d.QualifiedName(), d.File.Path, d.NameStartPoint.Row+1)
+}
+
+func nodeID(d *export.Definition) string {
+	return fmt.Sprintf("%s:%d:%s", d.File.Path, d.NameStartPoint.Row+1, d.QualifiedName())
+}
+
+// WriteDOT writes the definitions linked by the calls in the DOT language
+// of graphviz, with an edge per caller and callee. Ambiguous calls are
+// dashed, and the definitions in highlighted are filled.
+func WriteDOT(w io.Writer, calls []*Call, highlighted []*export.Definition) error {
+	p := func(format string, args ...interface{}) error {
+		_, err := fmt.Fprintf(w, format, args...)
+		return err
+	}
+
+	nodes := map[string]*export.Definition{}
+	type edge struct {
+		from, to  string
+		ambiguous bool
+	}
+	edges := map[[2]string]*edge{}
+	for _, c := range calls {
+		from, to := nodeID(c.Caller), nodeID(c.Callee)
+		nodes[from] = c.Caller
+		nodes[to] = c.Callee
+		key := [2]string{from, to}
+		if e, ok := edges[key]; ok {
+			e.ambiguous = e.ambiguous && c.Candidates > 1
+			continue
+		}
+		edges[key] = &edge{from: from, to: to, ambiguous: c.Candidates > 1}
+	}
+	isHighlighted := map[string]bool{}
+	for _, d := range highlighted {
+		isHighlighted[nodeID(d)] = true
+		nodes[nodeID(d)] = d
+	}
+
+	if err := p("digraph calls {\n  rankdir=LR;\n  node [shape=box, fontname=\"monospace\"];\n"); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		d := nodes[id]
+		label := d.QualifiedName() + "\n" + fmt.Sprintf("%s:%d", d.File.Path, d.NameStartPoint.Row+1)
+		style := ""
+		if isHighlighted[id] {
+			style = ", style=filled, fillcolor=lightyellow"
+		}
+		if err := p("  %s [label=%s%s];\n", strconv.Quote(id), strconv.Quote(label), style); err != nil {
+			return err
+		}
+	}
+
+	keys := make([][2]string, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		e := edges[key]
+		style := ""
+		if e.ambiguous {
+			style = " [style=dashed]"
+		}
+		if err := p("  %s -> %s%s;\n", strconv.Quote(e.from), strconv.Quote(e.to), style); err != nil {
+			return err
+		}
+	}
+	return p("}\n")
+}
+
+// WriteTree writes the calls reached from the start definitions as an
+// indented tree, up to maxDepth calls away (without limit if maxDepth is 0).
+// Definitions whose calls were already written are marked with "...", and
+// recursive calls with "(recursive)".
+func (g *Graph) WriteTree(w io.Writer, start []*export.Definition, direction Direction, maxDepth int) error {
+	expanded := map[*export.Definition]bool{}
+	onPath := map[*export.Definition]bool{}
+
+	var write func(d *export.Definition, depth int, prefix string) error
+	write = func(d *export.Definition, depth int, prefix string) error {
+		calls, target := g.next(d, direction)
+		if maxDepth != 0 && depth > maxDepth {
+			return nil
+		}
+		expanded[d] = true
+		onPath[d] = true
+		defer delete(onPath, d)
+
+		seen := map[*export.Definition]bool{}
+		for _, c := range calls {
+			t := target(c)
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+
+			line := prefix + Label(t)
+			if c.Candidates > 1 {
+				line += " [ambiguous]"
+			}
+			next, _ := g.next(t, direction)
+			switch {
+			case onPath[t]:
+				line += " (recursive)"
+			case expanded[t] && len(next) > 0:
+				line += " ..."
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			if onPath[t] || expanded[t] {
+				continue
+			}
+			if err := write(t, depth+1, prefix+"  "); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, d := range start {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		header := Label(d)
+		if direction == DirectionCallers {
+			header += " is called by"
+		} else {
+			header += " calls"
+		}
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return err
+		}
+		if err := write(d, 1, "  "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+// Package check runs the rules of oak commands (see cmds.Rule) over source
+// files, as a linter, and reports the findings as text, GitHub workflow
+// annotations, SARIF or JUnit XML.
+package check
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cmds"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Finding is a diagnostic reported by a rule in a file.
+type Finding struct {
+	*cmds.Diagnostic
+	File string
+	// Command is the full path of the command defining the rule.
+	Command string
+
+	// Line, Column, EndLine and EndColumn locate the finding, 1-based, with
+	// columns counted in unicode code points.
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+// Result is the outcome of checking a set of files.
+type Result struct {
+	// Files are the checked files, sorted.
+	Files []string
+	// Findings are sorted by file and position.
+	Findings []*Finding
+	// Suppressed is the number of findings suppressed by oak-ignore
+	// comments.
+	Suppressed int
+	// Rules are the rules that were run, in the order of their commands.
+	Rules []*RuleInfo
+	// Fixed maps files to the number of fixes applied, see ApplyFixes.
+	Fixed map[string]int
+}
+
+// RuleInfo describes a rule, for the reports that list the rules that were
+// run.
+type RuleInfo struct {
+	*cmds.Rule
+	Command     string
+	Description string
+}
+
+// severityRanks orders severities, from the least to the most severe.
+var severityRanks = map[cmds.RuleSeverity]int{
+	cmds.SeverityHint:    1,
+	cmds.SeverityInfo:    2,
+	cmds.SeverityWarning: 3,
+	cmds.SeverityError:   4,
+}
+
+// MaxSeverity returns the highest severity of the findings, or "" if there
+// are none.
+func (r *Result) MaxSeverity() cmds.RuleSeverity {
+	var ret cmds.RuleSeverity
+	for _, f := range r.Findings {
+		if severityRanks[f.Rule.Severity] > severityRanks[ret] {
+			ret = f.Rule.Severity
+		}
+	}
+	return ret
+}
+
+// Counts returns the number of findings per severity.
+func (r *Result) Counts() map[cmds.RuleSeverity]int {
+	ret := map[cmds.RuleSeverity]int{}
+	for _, f := range r.Findings {
+		ret[f.Rule.Severity]++
+	}
+	return ret
+}
+
+// Checker runs the rules of a set of commands.
+type Checker struct {
+	commands []*cmds.OakCommand
+}
+
+// NewChecker creates a checker for the given rule commands, whose queries
+// have already been rendered, see cmds.RuleCommand.
+func NewChecker(commands []*cmds.OakCommand) *Checker {
+	return &Checker{commands: commands}
+}
+
+// Run checks the given files, and the files in the given directories with
+// the languages of the rules.
+//
+// Explicitly listed files that no rule applies to are ignored.
+func (c *Checker) Run(ctx context.Context, sources []string) (*Result, error) {
+	result := &Result{Fixed: map[string]int{}}
+
+	// group the commands by language, so that each file is parsed once
+	languages := []string{}
+	commandsByLanguage := map[string][]*cmds.OakCommand{}
+	for _, oc := range c.commands {
+		if _, ok := commandsByLanguage[oc.Language]; !ok {
+			languages = append(languages, oc.Language)
+		}
+		commandsByLanguage[oc.Language] = append(commandsByLanguage[oc.Language], oc)
+
+		for _, rule := range oc.Rules {
+			description := rule.Description
+			if description == "" && len(oc.Rules) == 1 {
+				description = oc.Short
+			}
+			result.Rules = append(result.Rules, &RuleInfo{
+				Rule:        rule,
+				Command:     oc.FullPath(),
+				Description: description,
+			})
+		}
+	}
+
+	files := map[string]bool{}
+	for _, lang := range languages {
+		globs, err := pkg.GetLanguageGlobs(lang)
+		if err != nil {
+			return nil, err
+		}
+		fileNames, err := collectFiles(sources, globs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fileName := range fileNames {
+			findings, suppressed, err := c.checkFile(ctx, fileName, commandsByLanguage[lang])
+			if err != nil {
+				return nil, err
+			}
+			files[fileName] = true
+			result.Findings = append(result.Findings, findings...)
+			result.Suppressed += suppressed
+		}
+	}
+
+	for fileName := range files {
+		result.Files = append(result.Files, fileName)
+	}
+	sort.Strings(result.Files)
+	sortFindings(result.Findings)
+
+	return result, nil
+}
+
+func sortFindings(findings []*Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.StartByte != b.StartByte {
+			return a.StartByte < b.StartByte
+		}
+		return a.Rule.ID < b.Rule.ID
+	})
+}
+
+// collectFiles returns the files in sources matching one of the globs, and
+// the files matching the globs in the directories in sources.
+func collectFiles(sources []string, globs []string) ([]string, error) {
+	ret := []string{}
+	seen := map[string]bool{}
+	anyLanguage := func(string) bool { return true }
+	for _, source := range sources {
+		err := pkg.WalkSourceFiles(source, anyLanguage, func(fileName string, lang string, info os.FileInfo) error {
+			if seen[fileName] {
+				return nil
+			}
+			for _, glob := range globs {
+				// the language globs are of the form **/*.go
+				matched, _ := path.Match(path.Base(glob), filepath.Base(fileName))
+				if matched {
+					seen[fileName] = true
+					ret = append(ret, fileName)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (c *Checker) checkFile(
+	ctx context.Context,
+	fileName string,
+	commands []*cmds.OakCommand,
+) ([]*Finding, int, error) {
+	source, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not read file %s", fileName)
+	}
+	tree, err := commands[0].Parse(ctx, nil, source)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not parse file %s", fileName)
+	}
+	suppressions_ := findSuppressions(tree.RootNode(), source)
+
+	findings := []*Finding{}
+	suppressed := 0
+	for _, oc := range commands {
+		lang, err := oc.GetLanguage()
+		if err != nil {
+			return nil, 0, err
+		}
+		results, err := tree_sitter.ExecuteQueries(lang, tree.RootNode(), oc.Queries, source)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "could not run the queries of %s on %s", oc.FullPath(), fileName)
+		}
+		diagnostics, err := oc.Diagnostics(results)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "could not run the rules of %s on %s", oc.FullPath(), fileName)
+		}
+
+		for _, d := range diagnostics {
+			if suppressions_.suppresses(d.StartPoint.Row, d.Rule.ID) {
+				log.Debug().Str("file", fileName).Str("rule", d.Rule.ID).
+					Uint32("line", d.StartPoint.Row+1).Msg("suppressed")
+				suppressed++
+				continue
+			}
+			findings = append(findings, &Finding{
+				Diagnostic: d,
+				File:       fileName,
+				Command:    oc.FullPath(),
+				Line:       int(d.StartPoint.Row) + 1,
+				Column:     pkg.Column(source, d.StartByte, d.StartPoint),
+				EndLine:    int(d.EndPoint.Row) + 1,
+				EndColumn:  pkg.Column(source, d.EndByte, d.EndPoint),
+			})
+		}
+	}
+
+	return findings, suppressed, nil
+}
+
+// ApplyFixes applies the fixes of the findings to their files, and removes
+// the fixed findings from the result. Fixes overlapping a previous fix of
+// the same file are not applied. If fixing a file introduces syntax errors,
+// the file is left unchanged.
+func (c *Checker) ApplyFixes(ctx context.Context, result *Result) error {
+	commands := map[string]*cmds.OakCommand{}
+	for _, oc := range c.commands {
+		commands[oc.FullPath()] = oc
+	}
+
+	byFile := map[string][]*Finding{}
+	for _, f := range result.Findings {
+		if f.Fix != nil {
+			byFile[f.File] = append(byFile[f.File], f)
+		}
+	}
+
+	fixed := map[*Finding]bool{}
+	for fileName, findings := range byFile {
+		all := []cmds.Edit{}
+		for _, f := range findings {
+			all = append(all, *f.Fix)
+		}
+		edits, _ := cmds.SelectEdits(all)
+
+		oc := commands[findings[0].Command]
+		source, err := os.ReadFile(fileName)
+		if err != nil {
+			return errors.Wrapf(err, "could not read file %s", fileName)
+		}
+		tree, err := oc.Parse(ctx, nil, source)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse file %s", fileName)
+		}
+		rewritten, ok, err := oc.RewriteSource(ctx, source, tree, edits)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse fixed file %s", fileName)
+		}
+		if !ok {
+			log.Warn().Str("file", fileName).Msg("fixes introduce syntax errors, leaving the file unchanged")
+			continue
+		}
+
+		fi, err := os.Stat(fileName)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fileName, rewritten, fi.Mode().Perm())
+		if err != nil {
+			return errors.Wrapf(err, "could not write file %s", fileName)
+		}
+
+		for _, f := range findings {
+			for _, edit := range edits {
+				if *f.Fix == edit {
+					fixed[f] = true
+					break
+				}
+			}
+		}
+		result.Fixed[fileName] = len(edits)
+	}
+
+	findings := []*Finding{}
+	for _, f := range result.Findings {
+		if !fixed[f] {
+			findings = append(findings, f)
+		}
+	}
+	result.Findings = findings
+
+	return nil
+}
@@ -0,0 +1,355 @@
+package check
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/pkg/errors"
+)
+
+// Formats are the output formats of WriteReport.
+var Formats = []string{"text", "github", "sarif", "junit"}
+
+// WriteReport writes the result in the given format.
+func WriteReport(w io.Writer, result *Result, format string) error {
+	switch format {
+	case "text":
+		return WriteText(w, result)
+	case "github":
+		return WriteGitHub(w, result)
+	case "sarif":
+		return WriteSARIF(w, result)
+	case "junit":
+		return WriteJUnit(w, result)
+	}
+	return errors.Errorf("unknown format %s, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// WriteText writes one line per finding, in the same format as compilers,
+// followed by a summary.
+func WriteText(w io.Writer, result *Result) error {
+	for _, f := range result.Findings {
+		_, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s [%s]\n",
+			f.File, f.Line, f.Column, f.Rule.Severity, f.Message, f.Rule.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	counts := result.Counts()
+	_, err := fmt.Fprintf(w, "%d files, %d errors, %d warnings, %d infos, %d hints",
+		len(result.Files),
+		counts[cmds.SeverityError], counts[cmds.SeverityWarning],
+		counts[cmds.SeverityInfo], counts[cmds.SeverityHint])
+	if err != nil {
+		return err
+	}
+	if result.Suppressed > 0 {
+		_, err = fmt.Fprintf(w, ", %d suppressed", result.Suppressed)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// WriteGitHub writes the findings as GitHub workflow commands, which are
+// shown as annotations on the pull request when run in a GitHub action.
+func WriteGitHub(w io.Writer, result *Result) error {
+	for _, f := range result.Findings {
+		level := "notice"
+		switch f.Rule.Severity {
+		case cmds.SeverityError:
+			level = "error"
+		case cmds.SeverityWarning:
+			level = "warning"
+		case cmds.SeverityInfo, cmds.SeverityHint:
+			level = "notice"
+		}
+
+		properties := []string{
+			"file=" + escapeGitHubProperty(filepath.ToSlash(f.File)),
+			fmt.Sprintf("line=%d", f.Line),
+			fmt.Sprintf("col=%d", f.Column),
+			fmt.Sprintf("endLine=%d", f.EndLine),
+		}
+		if f.EndLine == f.Line {
+			properties = append(properties, fmt.Sprintf("endColumn=%d", f.EndColumn))
+		}
+		properties = append(properties, "title="+escapeGitHubProperty(f.Rule.ID))
+
+		_, err := fmt.Fprintf(w, "::%s %s::%s\n", level, strings.Join(properties, ","), escapeGitHubData(f.Message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// SARIF 2.1.0, only with the properties written by oak.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                   `json:"id"`
+	ShortDescription     *sarifMessage            `json:"shortDescription,omitempty"`
+	DefaultConfiguration sarifReportingDescriptor `json:"defaultConfiguration"`
+	Properties           map[string]interface{}   `json:"properties,omitempty"`
+}
+
+type sarifReportingDescriptor struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+func sarifLevel(severity cmds.RuleSeverity) string {
+	switch severity {
+	case cmds.SeverityError:
+		return "error"
+	case cmds.SeverityWarning:
+		return "warning"
+	case cmds.SeverityInfo, cmds.SeverityHint:
+		return "note"
+	}
+	return "none"
+}
+
+// sarifRuleID returns the ID of a rule in SARIF logs, prefixed with its
+// command, since rules of different commands can have the same ID.
+func sarifRuleID(command string, id string) string {
+	return command + "/" + id
+}
+
+// WriteSARIF writes the result as a SARIF 2.1.0 log, as consumed by GitHub
+// code scanning, with the fixes of the findings.
+func WriteSARIF(w io.Writer, result *Result) error {
+	driver := sarifDriver{
+		Name:           "oak",
+		InformationURI: "https://github.com/go-go-golems/oak",
+		Rules:          []sarifRule{},
+	}
+	ruleIndices := map[string]int{}
+	for _, rule := range result.Rules {
+		id := sarifRuleID(rule.Command, rule.ID)
+		if _, ok := ruleIndices[id]; ok {
+			return errors.Errorf("duplicate rule %s in command %s", rule.ID, rule.Command)
+		}
+		ruleIndices[id] = len(driver.Rules)
+		r := sarifRule{
+			ID:                   id,
+			DefaultConfiguration: sarifReportingDescriptor{Level: sarifLevel(rule.Severity)},
+			Properties:           map[string]interface{}{"command": rule.Command},
+		}
+		if rule.Description != "" {
+			r.ShortDescription = &sarifMessage{Text: rule.Description}
+		}
+		driver.Rules = append(driver.Rules, r)
+	}
+
+	results := []sarifResult{}
+	for _, f := range result.Findings {
+		location := sarifArtifactLocation{URI: filepath.ToSlash(f.File)}
+		region := sarifRegion{
+			StartLine:   f.Line,
+			StartColumn: f.Column,
+			EndLine:     f.EndLine,
+			EndColumn:   f.EndColumn,
+		}
+		id := sarifRuleID(f.Command, f.Rule.ID)
+		r := sarifResult{
+			RuleID:    id,
+			RuleIndex: ruleIndices[id],
+			Level:     sarifLevel(f.Rule.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: location,
+					Region:           region,
+				},
+			}},
+		}
+		if f.Fix != nil {
+			r.Fixes = []sarifFix{{
+				Description: sarifMessage{Text: fmt.Sprintf("Apply the fix of %s", f.Rule.ID)},
+				ArtifactChanges: []sarifArtifactChange{{
+					ArtifactLocation: location,
+					Replacements: []sarifReplacement{{
+						DeletedRegion:   region,
+						InsertedContent: sarifMessage{Text: f.Fix.Text},
+					}},
+				}},
+			}}
+		}
+		results = append(results, r)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: driver},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	})
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the result as JUnit XML, with a test suite per checked
+// file, and a failing test case per finding. Files without findings have a
+// single passing test case.
+func WriteJUnit(w io.Writer, result *Result) error {
+	byFile := map[string][]*Finding{}
+	for _, f := range result.Findings {
+		byFile[f.File] = append(byFile[f.File], f)
+	}
+
+	suites := junitTestSuites{Name: "oak"}
+	for _, file := range result.Files {
+		suite := junitTestSuite{Name: file}
+		for _, f := range byFile[file] {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      fmt.Sprintf("%s:%d:%d %s", file, f.Line, f.Column, f.Rule.ID),
+				ClassName: f.Rule.ID,
+				Failure: &junitFailure{
+					Message: f.Message,
+					Type:    string(f.Rule.Severity),
+					Text:    fmt.Sprintf("%s:%d:%d: %s: %s [%s]", file, f.Line, f.Column, f.Rule.Severity, f.Message, f.Rule.ID),
+				},
+			})
+		}
+		if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: file, ClassName: "oak"})
+		} else {
+			suite.Failures = len(suite.Cases)
+		}
+		suite.Tests = len(suite.Cases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(&suites)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
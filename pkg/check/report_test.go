@@ -0,0 +1,69 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-go-golems/oak/pkg/cmds"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	// two commands with a rule of the same ID
+	goRule := &cmds.Rule{ID: "no-println", Severity: cmds.SeverityWarning}
+	pyRule := &cmds.Rule{ID: "no-println", Severity: cmds.SeverityError}
+	result := &Result{
+		Files: []string{"a.go", "a.py"},
+		Rules: []*RuleInfo{
+			{Rule: goRule, Command: "go/prints"},
+			{Rule: pyRule, Command: "python/prints"},
+		},
+		Findings: []*Finding{
+			{
+				Diagnostic: &cmds.Diagnostic{Rule: goRule, Message: "go"},
+				File:       "a.go", Command: "go/prints",
+				Line: 1, Column: 1, EndLine: 1, EndColumn: 2,
+			},
+			{
+				Diagnostic: &cmds.Diagnostic{Rule: pyRule, Message: "python"},
+				File:       "a.py", Command: "python/prints",
+				Line: 1, Column: 1, EndLine: 1, EndColumn: 2,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := WriteSARIF(&buf, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &sarifLog{}
+	err = json.Unmarshal(buf.Bytes(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := log.Runs[0]
+	ruleIDs := []string{}
+	for _, rule := range run.Tool.Driver.Rules {
+		ruleIDs = append(ruleIDs, rule.ID+" "+rule.DefaultConfiguration.Level)
+	}
+	expected := []string{"go/prints/no-println warning", "python/prints/no-println error"}
+	if !reflect.DeepEqual(ruleIDs, expected) {
+		t.Errorf("got rules %v, expected %v", ruleIDs, expected)
+	}
+
+	for i, r := range run.Results {
+		if r.RuleIndex != i || r.RuleID != run.Tool.Driver.Rules[i].ID {
+			t.Errorf("result %d refers to rule %s at %d", i, r.RuleID, r.RuleIndex)
+		}
+	}
+
+	// the same rule twice in a command can't be told apart
+	result.Rules = append(result.Rules, &RuleInfo{Rule: goRule, Command: "go/prints"})
+	err = WriteSARIF(&bytes.Buffer{}, result)
+	if err == nil {
+		t.Error("expected an error for a duplicate rule")
+	}
+}
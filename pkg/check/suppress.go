@@ -0,0 +1,101 @@
+package check
+
+import (
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const ignoreDirective = "oak-ignore"
+
+// suppressions maps 0-based lines to the rules suppressed on them. An empty
+// list suppresses all the rules.
+type suppressions map[uint32][]string
+
+func (s suppressions) suppresses(line uint32, ruleID string) bool {
+	ids, ok := s[line]
+	if !ok {
+		return false
+	}
+	if len(ids) == 0 {
+		return true
+	}
+	for _, id := range ids {
+		if id == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+// findSuppressions collects the oak-ignore comments of a file:
+//
+//	// oak-ignore no-println
+//	fmt.Println("suppressed")
+//	fmt.Println("suppressed") // oak-ignore no-println, no-debug
+//	fmt.Println("all rules are suppressed") // oak-ignore
+//
+// A comment on its own line applies to the next line, a trailing comment to
+// its own line. Comments are the nodes whose type contains "comment", which
+// covers the grammars supported by oak.
+func findSuppressions(root *sitter.Node, source []byte) suppressions {
+	ret := suppressions{}
+
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if strings.Contains(n.Type(), "comment") {
+			ids, ok := parseIgnoreDirective(n.Content(source))
+			if ok {
+				line := n.StartPoint().Row
+				lineStart := n.StartByte() - n.StartPoint().Column
+				if strings.TrimSpace(string(source[lineStart:n.StartByte()])) == "" {
+					line = n.EndPoint().Row + 1
+				}
+				if existing, ok := ret[line]; ok && (len(existing) == 0 || len(ids) == 0) {
+					ret[line] = []string{}
+				} else {
+					ret[line] = append(existing, ids...)
+				}
+			}
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(root)
+
+	return ret
+}
+
+// parseIgnoreDirective returns the rule ids listed after oak-ignore in a
+// comment, separated by commas or spaces. It returns false if the comment
+// has no oak-ignore directive.
+func parseIgnoreDirective(comment string) ([]string, bool) {
+	idx := strings.Index(comment, ignoreDirective)
+	for idx >= 0 {
+		rest := comment[idx+len(ignoreDirective):]
+		if rest == "" || unicode.IsSpace(rune(rest[0])) || rest[0] == ':' || rest[0] == ',' {
+			// the rest of the line, without a closing */ or -->
+			if end := strings.IndexByte(rest, '\n'); end >= 0 {
+				rest = rest[:end]
+			}
+			for _, terminator := range []string{"*/", "-->"} {
+				rest = strings.TrimSuffix(strings.TrimSpace(rest), terminator)
+			}
+
+			ids := strings.FieldsFunc(rest, func(r rune) bool {
+				return unicode.IsSpace(r) || r == ',' || r == ':'
+			})
+			return ids, true
+		}
+
+		next := strings.Index(rest, ignoreDirective)
+		if next < 0 {
+			break
+		}
+		idx += len(ignoreDirective) + next
+	}
+	return nil, false
+}
@@ -0,0 +1,95 @@
+package check
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-go-golems/oak/pkg"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+func TestParseIgnoreDirective(t *testing.T) {
+	tests := []struct {
+		comment string
+		ids     []string
+		ok      bool
+	}{
+		{comment: "// oak-ignore", ids: []string{}, ok: true},
+		{comment: "// oak-ignore no-println", ids: []string{"no-println"}, ok: true},
+		{comment: "// oak-ignore no-println, no-debug", ids: []string{"no-println", "no-debug"}, ok: true},
+		{comment: "// oak-ignore: no-println no-debug", ids: []string{"no-println", "no-debug"}, ok: true},
+		{comment: "/* oak-ignore no-println */", ids: []string{"no-println"}, ok: true},
+		{comment: "<!-- oak-ignore no-println -->", ids: []string{"no-println"}, ok: true},
+		{comment: "# see oak-ignore no-println", ids: []string{"no-println"}, ok: true},
+		{comment: "/* oak-ignore no-println\n * because */", ids: []string{"no-println"}, ok: true},
+		{comment: "// oak-ignored", ok: false},
+		{comment: "// oak-ignored, oak-ignore a", ids: []string{"a"}, ok: true},
+		{comment: "// nothing to see", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.comment, func(t *testing.T) {
+			ids, ok := parseIgnoreDirective(tt.comment)
+			if ok != tt.ok {
+				t.Fatalf("got ok %v, expected %v", ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got %q, expected %q", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestFindSuppressions(t *testing.T) {
+	source := []byte(`package a
+
+func f() {
+	// oak-ignore no-println
+	fmt.Println("line 5")
+	fmt.Println("line 6") // oak-ignore no-println, no-debug
+	fmt.Println("line 7") // oak-ignore
+	// oak-ignore no-println
+	// oak-ignore no-debug
+	fmt.Println("line 10")
+	fmt.Println("line 11")
+}
+`)
+	lang, err := pkg.LanguageNameToSitterLanguage("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(lang)
+	tree, err := parser.ParseCtx(context.Background(), nil, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := findSuppressions(tree.RootNode(), source)
+
+	tests := []struct {
+		line       uint32
+		rule       string
+		suppressed bool
+	}{
+		{line: 5, rule: "no-println", suppressed: true},
+		{line: 5, rule: "no-debug", suppressed: false},
+		{line: 6, rule: "no-println", suppressed: true},
+		{line: 6, rule: "no-debug", suppressed: true},
+		{line: 6, rule: "other", suppressed: false},
+		{line: 7, rule: "anything", suppressed: true},
+		// a comment applies to the next line only, even when it is
+		// another comment
+		{line: 10, rule: "no-println", suppressed: false},
+		{line: 10, rule: "no-debug", suppressed: true},
+		{line: 11, rule: "no-debug", suppressed: false},
+	}
+	for _, tt := range tests {
+		// suppressions are indexed by 0-based line
+		if got := s.suppresses(tt.line-1, tt.rule); got != tt.suppressed {
+			t.Errorf("line %d, rule %s: suppressed %v, expected %v", tt.line, tt.rule, got, tt.suppressed)
+		}
+	}
+}
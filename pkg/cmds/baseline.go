@@ -0,0 +1,322 @@
+package cmds
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg/symbols"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const baselineVersion = 1
+
+// Baseline records the matches of commands at some point in time, so that
+// only new matches are reported, see --baseline and --update-baseline.
+//
+// Matches are identified by a fingerprint of the command, the query, the
+// file, the capture texts (with normalized whitespace) and the enclosing
+// symbol, which doesn't change when lines are added or removed above the
+// match.
+type Baseline struct {
+	Version int              `json:"version"`
+	Matches []*BaselineMatch `json:"matches"`
+}
+
+// BaselineMatch is a recorded match. The fields besides the fingerprint are
+// there to make the baseline file reviewable.
+type BaselineMatch struct {
+	Fingerprint string `json:"fingerprint"`
+	Command     string `json:"command"`
+	Query       string `json:"query"`
+	File        string `json:"file"`
+	Symbol      string `json:"symbol,omitempty"`
+	Text        string `json:"text"`
+	// Count is the number of identical matches, for example the same call
+	// made twice in a function.
+	Count int `json:"count"`
+}
+
+// LoadBaseline reads a baseline file. A missing file is an empty baseline.
+func LoadBaseline(fileName string) (*Baseline, error) {
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return &Baseline{Version: baselineVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := &Baseline{}
+	err = json.Unmarshal(b, ret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse baseline %s", fileName)
+	}
+	if ret.Version != baselineVersion {
+		return nil, errors.Errorf("unsupported baseline version %d in %s", ret.Version, fileName)
+	}
+	return ret, nil
+}
+
+// Save writes the baseline, with the matches sorted so that the file diffs
+// well.
+func (b *Baseline) Save(fileName string) error {
+	sort.SliceStable(b.Matches, func(i, j int) bool {
+		m, n := b.Matches[i], b.Matches[j]
+		if m.Command != n.Command {
+			return m.Command < n.Command
+		}
+		if m.File != n.File {
+			return m.File < n.File
+		}
+		if m.Symbol != n.Symbol {
+			return m.Symbol < n.Symbol
+		}
+		if m.Query != n.Query {
+			return m.Query < n.Query
+		}
+		return m.Fingerprint < n.Fingerprint
+	})
+
+	out, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(out, '\n'), 0644)
+}
+
+// updateBaselineMatches returns the matches of a baseline where the
+// matches of command in the scanned files are replaced with current. The
+// matches of other commands and of files that weren't scanned are kept.
+// Identical matches in current are merged into one entry with a count.
+func updateBaselineMatches(
+	matches []*BaselineMatch,
+	command string,
+	scanned map[string]bool,
+	current []*BaselineMatch,
+) []*BaselineMatch {
+	ret := []*BaselineMatch{}
+	for _, m := range matches {
+		if m.Command != command || !scanned[m.File] {
+			ret = append(ret, m)
+		}
+	}
+	byFingerprint := map[string]*BaselineMatch{}
+	for _, m := range current {
+		if existing, ok := byFingerprint[m.Fingerprint]; ok {
+			existing.Count += m.Count
+			continue
+		}
+		byFingerprint[m.Fingerprint] = m
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// fingerprintMatches returns the baseline entries of the matches of a file,
+// indexed like its results (by query name, then match index). The tree of
+// the file is used to find the enclosing symbols of the matches, and the
+// source is only parsed again if the tree is not around anymore.
+func (oc *OakCommand) fingerprintMatches(
+	ctx context.Context,
+	relativeFileName string,
+	file *parsedFile,
+) (map[string][]*BaselineMatch, error) {
+	var symbols_ []*symbols.Symbol
+	if symbols.HasLanguage(oc.Language) {
+		var err error
+		if file.tree != nil {
+			symbols_, err = symbols.Extract(oc.Language, file.tree.RootNode(), file.source)
+		} else {
+			symbols_, err = symbols.ExtractFromSource(ctx, oc.Language, file.source)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not extract the symbols of %s", relativeFileName)
+		}
+	}
+
+	ret := map[string][]*BaselineMatch{}
+	for queryName, result := range file.results {
+		for _, match := range result.Matches {
+			names := make([]string, 0, len(match))
+			for name := range match {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			texts := []string{}
+			start, end := ^uint32(0), uint32(0)
+			for _, name := range names {
+				capture := match[name]
+				texts = append(texts, name+"="+strings.Join(strings.Fields(capture.Text), " "))
+				if capture.StartByte < start {
+					start = capture.StartByte
+				}
+				if capture.EndByte > end {
+					end = capture.EndByte
+				}
+			}
+
+			symbol := ""
+			if s := symbols.Enclosing(symbols_, start, end); s != nil {
+				symbol = s.Name
+				if s.Container != "" {
+					symbol = s.Container + "." + s.Name
+				}
+			}
+
+			text := strings.Join(texts, " ")
+			h := sha256.New()
+			for _, part := range []string{oc.FullPath(), queryName, relativeFileName, symbol, text} {
+				h.Write([]byte(part))
+				h.Write([]byte{0})
+			}
+
+			ret[queryName] = append(ret[queryName], &BaselineMatch{
+				// 64 bits keep the baseline readable, and only collide after
+				// billions of matches of a command. Since fingerprints are
+				// only compared within a command, a collision would at worst
+				// hide one new match.
+				Fingerprint: hex.EncodeToString(h.Sum(nil))[:16],
+				Command:     oc.FullPath(),
+				Query:       queryName,
+				File:        relativeFileName,
+				Symbol:      symbol,
+				Text:        text,
+				Count:       1,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+// baselineRelativePath returns the path of fileName relative to the
+// directory of the baseline, so that the baseline doesn't depend on the
+// directory oak is run from.
+func baselineRelativePath(baselineFile string, fileName string) string {
+	absBaseline, err1 := filepath.Abs(baselineFile)
+	absFile, err2 := filepath.Abs(fileName)
+	if err1 == nil && err2 == nil {
+		if rel, err := filepath.Rel(filepath.Dir(absBaseline), absFile); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(fileName))
+}
+
+// NewMatchesError is returned when matches that are not in the baseline are
+// found, so that the command exits with a non-zero status.
+type NewMatchesError struct {
+	Count    int
+	Baseline string
+}
+
+func (e *NewMatchesError) Error() string {
+	return fmt.Sprintf("%d new matches not in baseline %s", e.Count, e.Baseline)
+}
+
+// applyBaseline implements --baseline and --update-baseline. It returns the
+// results of files without the matches recorded in the baseline, and the
+// number of new matches.
+//
+// With --update-baseline, the matches of the command in the scanned files
+// are replaced with the current ones, and no match is returned.
+func (oc *OakCommand) applyBaseline(
+	ctx context.Context,
+	ss *OakSettings,
+	files map[string]*parsedFile,
+) (map[string]tree_sitter.QueryResults, int, error) {
+	resultsByFile := resultsOf(files)
+	if ss.Baseline == "" {
+		if ss.UpdateBaseline {
+			return nil, 0, errors.New("--update-baseline requires --baseline")
+		}
+		return resultsByFile, 0, nil
+	}
+
+	baseline, err := LoadBaseline(ss.Baseline)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fingerprints := map[string]map[string][]*BaselineMatch{}
+	for fileName, file := range files {
+		fingerprints[fileName], err = oc.fingerprintMatches(
+			ctx, baselineRelativePath(ss.Baseline, fileName), file)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if ss.UpdateBaseline {
+		scanned := map[string]bool{}
+		current := []*BaselineMatch{}
+		for fileName, fileFingerprints := range fingerprints {
+			scanned[baselineRelativePath(ss.Baseline, fileName)] = true
+			for _, queryFingerprints := range fileFingerprints {
+				current = append(current, queryFingerprints...)
+			}
+		}
+		matches := updateBaselineMatches(baseline.Matches, oc.FullPath(), scanned, current)
+		count := len(current)
+		baseline.Version = baselineVersion
+		baseline.Matches = matches
+		err = baseline.Save(ss.Baseline)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "could not write baseline %s", ss.Baseline)
+		}
+		log.Info().Str("baseline", ss.Baseline).Int("matches", count).Msg("updated baseline")
+
+		ret := map[string]tree_sitter.QueryResults{}
+		for fileName, results := range resultsByFile {
+			ret[fileName] = tree_sitter.QueryResults{}
+			for queryName, result := range results {
+				ret[fileName][queryName] = &tree_sitter.Result{QueryName: result.QueryName}
+			}
+		}
+		return ret, 0, nil
+	}
+
+	remaining := map[string]int{}
+	for _, m := range baseline.Matches {
+		if m.Command == oc.FullPath() {
+			remaining[m.Fingerprint] += m.Count
+		}
+	}
+
+	// iterate in a stable order, so that the same duplicates are reported
+	fileNames := make([]string, 0, len(resultsByFile))
+	for fileName := range resultsByFile {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	ret := map[string]tree_sitter.QueryResults{}
+	newMatches := 0
+	for _, fileName := range fileNames {
+		ret[fileName] = tree_sitter.QueryResults{}
+		for queryName, result := range resultsByFile[fileName] {
+			filtered := &tree_sitter.Result{QueryName: result.QueryName}
+			for i, match := range result.Matches {
+				fingerprint := fingerprints[fileName][queryName][i].Fingerprint
+				if remaining[fingerprint] > 0 {
+					remaining[fingerprint]--
+					continue
+				}
+				filtered.Matches = append(filtered.Matches, match)
+				newMatches++
+			}
+			ret[fileName][queryName] = filtered
+		}
+	}
+
+	return ret, newMatches, nil
+}
@@ -0,0 +1,192 @@
+package cmds
+
+import (
+	"context"
+	"testing"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+)
+
+const printlnQuery = `(call_expression
+  function: (selector_expression field: (field_identifier) @function)
+  arguments: (argument_list) @arguments
+  (#eq? @function "Println"))`
+
+// fingerprints runs the Println query on source, as the content of
+// fileName, and returns the baseline entries of the matches. They are
+// checked to be the same without the tree, as when the results are cached.
+func fingerprints(t *testing.T, fileName string, source string) []*BaselineMatch {
+	t.Helper()
+
+	oc := NewOakWriterCommand(
+		glazed_cmds.NewCommandDescription("prints"),
+		WithLanguage("go"),
+		WithQueries(tree_sitter.SitterQuery{Name: "calls", Query: printlnQuery}),
+	).OakCommand
+	tree, err := oc.Parse(context.Background(), nil, []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang, err := pkg.LanguageNameToSitterLanguage("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := tree_sitter.ExecuteQueries(lang, tree.RootNode(), oc.Queries, []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := oc.fingerprintMatches(context.Background(), fileName, &parsedFile{
+		source:  []byte(source),
+		tree:    tree,
+		results: results,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := oc.fingerprintMatches(context.Background(), fileName, &parsedFile{
+		source:  []byte(source),
+		results: results,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range ret["calls"] {
+		if *cached["calls"][i] != *m {
+			t.Errorf("got %v without the tree, expected %v", cached["calls"][i], m)
+		}
+	}
+
+	return ret["calls"]
+}
+
+func TestFingerprintStability(t *testing.T) {
+	original := fingerprints(t, "a.go", `package a
+
+func F() {
+	fmt.Println("hello", name)
+}
+`)
+	if len(original) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(original))
+	}
+	if original[0].Symbol != "F" {
+		t.Errorf("expected the match to be in F, got %q", original[0].Symbol)
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		source string
+		same   bool
+	}{
+		{
+			name: "lines added above",
+			file: "a.go",
+			source: `package a
+
+import "fmt"
+
+// F says hello.
+func F() {
+	fmt.Println("hello", name)
+}
+`,
+			same: true,
+		},
+		{
+			name: "whitespace changes",
+			file: "a.go",
+			source: `package a
+
+func F() {
+		fmt.Println("hello",
+			name)
+}
+`,
+			same: true,
+		},
+		{
+			name: "other function",
+			file: "a.go",
+			source: `package a
+
+func G() {
+	fmt.Println("hello", name)
+}
+`,
+		},
+		{
+			name: "other arguments",
+			file: "a.go",
+			source: `package a
+
+func F() {
+	fmt.Println("bye", name)
+}
+`,
+		},
+		{
+			name: "other file",
+			file: "b.go",
+			source: `package a
+
+func F() {
+	fmt.Println("hello", name)
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := fingerprints(t, tt.file, tt.source)
+			if len(matches) != 1 {
+				t.Fatalf("expected 1 match, got %d", len(matches))
+			}
+			same := matches[0].Fingerprint == original[0].Fingerprint
+			if same != tt.same {
+				t.Errorf("same fingerprint: %v, expected %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestUpdateBaselineMatches(t *testing.T) {
+	matches := []*BaselineMatch{
+		{Fingerprint: "1", Command: "prints", File: "x.go", Count: 1},
+		{Fingerprint: "2", Command: "prints", File: "sub/y.go", Count: 1},
+		{Fingerprint: "3", Command: "other", File: "x.go", Count: 1},
+	}
+	current := []*BaselineMatch{
+		{Fingerprint: "4", Command: "prints", File: "x.go", Count: 1},
+		{Fingerprint: "4", Command: "prints", File: "x.go", Count: 1},
+	}
+
+	updated := updateBaselineMatches(matches, "prints", map[string]bool{"x.go": true}, current)
+
+	counts := map[string]int{}
+	for _, m := range updated {
+		counts[m.Fingerprint] = m.Count
+	}
+	expected := map[string]int{
+		// sub/y.go wasn't scanned
+		"2": 1,
+		// matches of another command
+		"3": 1,
+		// two identical matches
+		"4": 2,
+	}
+	if len(counts) != len(expected) {
+		t.Fatalf("got %v, expected %v", counts, expected)
+	}
+	for fingerprint, count := range expected {
+		if counts[fingerprint] != count {
+			t.Errorf("got %v, expected %v", counts, expected)
+			break
+		}
+	}
+}
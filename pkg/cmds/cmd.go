@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -24,8 +25,10 @@ import (
 	"github.com/go-go-golems/glazed/pkg/helpers/compare"
 	"github.com/go-go-golems/glazed/pkg/helpers/templating"
 	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cache"
 	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	sitter "github.com/smacker/go-tree-sitter"
 	"gopkg.in/yaml.v3"
 )
@@ -43,6 +46,10 @@ type OakSettings struct {
 	Recurse      bool     `glazed.parameter:"recurse"`
 	PrintQueries bool     `glazed.parameter:"print-queries"`
 	Glob         []string `glazed.parameter:"glob"`
+	Watch        bool     `glazed.parameter:"watch"`
+	// Baseline is the baseline file, see Baseline.
+	Baseline       string `glazed.parameter:"baseline"`
+	UpdateBaseline bool   `glazed.parameter:"update-baseline"`
 }
 
 func NewOakParameterLayer(
@@ -61,6 +68,11 @@ type OakCommand struct {
 	Language string                    `yaml:"language,omitempty"`
 	Queries  []tree_sitter.SitterQuery `yaml:"queries"`
 	Template string                    `yaml:"template"`
+	// Rules turn the matches of queries into diagnostics, see Rule.
+	Rules []*Rule `yaml:"rules,omitempty"`
+	// Rewrite maps capture names to the templates of their replacement,
+	// used by `oak rewrite`, see RewriteEdits.
+	Rewrite map[string]string `yaml:"rewrite,omitempty"`
 
 	SitterLanguage *sitter.Language
 	*cmds.CommandDescription
@@ -70,6 +82,8 @@ type OakCommandDescription struct {
 	Language string                    `yaml:"language,omitempty"`
 	Queries  []tree_sitter.SitterQuery `yaml:"queries"`
 	Template string                    `yaml:"template,omitempty"`
+	Rules    []*Rule                   `yaml:"rules,omitempty"`
+	Rewrite  map[string]string         `yaml:"rewrite,omitempty"`
 
 	Name   string                            `yaml:"name"`
 	Short  string                            `yaml:"short"`
@@ -80,9 +94,31 @@ type OakCommandDescription struct {
 
 	Parents []string `yaml:",omitempty"`
 	Source  string   `yaml:",omitempty"`
+
+	// Tests are run by `oak test`, see CommandTest
+	Tests []*CommandTest `yaml:"tests,omitempty"`
 }
 
 type OakCommandLoader struct {
+	// CheckTemplates statically checks the template of each loaded command
+	// against its queries and flags (see CheckTemplate), and logs the issues
+	// as warnings.
+	CheckTemplates bool
+	// Strict makes template issues fail the loading of the command, instead
+	// of logging them. The errors are kept, see TemplateErrors.
+	Strict bool
+
+	mu             sync.Mutex
+	templateErrors []error
+}
+
+// TemplateErrors returns the template errors of the commands that failed to
+// load in strict mode. Repositories skip commands that fail to load, this
+// allows failing altogether instead.
+func (o *OakCommandLoader) TemplateErrors() []error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]error{}, o.templateErrors...)
 }
 
 func (o *OakCommandLoader) IsFileSupported(f fs.FS, fileName string) bool {
@@ -104,12 +140,91 @@ func (o *OakCommandLoader) LoadCommands(
 		_ = r.Close()
 	}(s)
 
-	return loaders.LoadCommandOrAliasFromReader(
+	commands, err := loaders.LoadCommandOrAliasFromReader(
 		s,
 		o.loadCommandFromReader,
 		options,
 		aliasOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.CheckTemplates || o.Strict {
+		for _, command := range commands {
+			oc, ok := command.(*OakWriterCommand)
+			if !ok {
+				continue
+			}
+			err = o.checkTemplate(f, entryName, oc.OakCommand)
+			if err != nil {
+				o.mu.Lock()
+				o.templateErrors = append(o.templateErrors, err)
+				o.mu.Unlock()
+				return nil, err
+			}
+		}
+	}
+
+	return commands, nil
+}
+
+// checkTemplate reports the issues found by CheckTemplate in the template of
+// the command loaded from entryName, as warnings, or as an error in strict
+// mode. Issues are located in the YAML file.
+func (o *OakCommandLoader) checkTemplate(f fs.FS, entryName string, oc *OakCommand) error {
+	if oc.Template == "" {
+		return nil
+	}
+
+	flags := []string{}
+	oc.GetDefaultFlags().ForEach(func(p *parameters.ParameterDefinition) {
+		flags = append(flags, p.Name)
+	})
+
+	check, err := CheckTemplate(oc.Template, oc.Queries, flags)
+	if err != nil {
+		if o.Strict {
+			return errors.Wrapf(err, "could not parse template of %s", entryName)
+		}
+		log.Warn().Err(err).Str("file", entryName).Msg("could not parse template")
+		return nil
+	}
+	if len(check.Issues) == 0 {
+		return nil
+	}
 
+	// locate the issues in the file, this is only done for broken commands
+	var lines []string
+	var templateNode *yaml.Node
+	b, err := fs.ReadFile(f, entryName)
+	if err == nil {
+		lines = strings.Split(string(b), "\n")
+		doc := &yaml.Node{}
+		if yaml.Unmarshal(b, doc) == nil && len(doc.Content) > 0 {
+			templateNode = mappingValue(doc.Content[0], "template")
+		}
+	}
+
+	messages := []string{}
+	for _, issue := range check.Issues {
+		line, column := yamlScalarLocation(lines, templateNode, issue.Line, issue.Column)
+		if o.Strict {
+			messages = append(messages, fmt.Sprintf("%s:%d:%d: %s", entryName, line, column, issue.Message))
+			continue
+		}
+		log.Warn().
+			Str("file", entryName).
+			Int("line", line).
+			Int("column", column).
+			Str("command", oc.Name).
+			Msg(issue.Message)
+	}
+
+	if o.Strict {
+		return errors.Errorf("invalid template in command %s:\n%s", oc.Name, strings.Join(messages, "\n"))
+	}
+
+	return nil
 }
 
 func (o *OakCommandLoader) loadCommandFromReader(
@@ -148,11 +263,18 @@ func (o *OakCommandLoader) loadCommandFromReader(
 	}
 	options_ = append(options_, options...)
 
+	err = initRules(ocd.Rules, ocd.Queries)
+	if err != nil {
+		return nil, err
+	}
+
 	oakCommand := NewOakWriterCommand(
 		cmds.NewCommandDescription(ocd.Name, options_...),
 		WithQueries(ocd.Queries...),
 		WithTemplate(ocd.Template),
 		WithLanguage(ocd.Language),
+		WithRules(ocd.Rules...),
+		WithRewrite(ocd.Rewrite),
 	)
 
 	return []cmds.Command{oakCommand}, nil
@@ -185,6 +307,18 @@ func WithQueries(queries ...tree_sitter.SitterQuery) OakCommandOption {
 	}
 }
 
+func WithRules(rules ...*Rule) OakCommandOption {
+	return func(cmd *OakCommand) {
+		cmd.Rules = rules
+	}
+}
+
+func WithRewrite(rewrite map[string]string) OakCommandOption {
+	return func(cmd *OakCommand) {
+		cmd.Rewrite = rewrite
+	}
+}
+
 func WithTemplate(template string) OakCommandOption {
 	return func(cmd *OakCommand) {
 		cmd.Template = template
@@ -303,8 +437,15 @@ func (oc *OakCommand) DumpTree(tree *sitter.Tree) {
 }
 
 // DumpTreeToWriter outputs the tree to the provided writer using the specified format.
-func (oc *OakCommand) DumpTreeToWriter(tree *sitter.Tree, source []byte, w io.Writer, format tree_sitter.DumpFormat, options tree_sitter.DumpOptions) error {
-	dumper := tree_sitter.NewDumper(format)
+func (oc *OakCommand) DumpTreeToWriter(
+	tree *sitter.Tree,
+	source []byte,
+	w io.Writer,
+	format tree_sitter.DumpFormat,
+	options tree_sitter.DumpOptions,
+	dumperOptions ...tree_sitter.DumperOption,
+) error {
+	dumper := tree_sitter.NewDumper(format, dumperOptions...)
 	return dumper.Dump(tree, source, w, options)
 }
 
@@ -337,7 +478,14 @@ func printNode(n *sitter.Node, depth int, name string) {
 // WARNING: This is destructive and should only be called once.
 // NOTE(manuel, 2023-06-19) This is not a great API, but it will do for now.
 func (oc *OakCommand) RenderQueries(layers *layers.ParsedLayers) error {
-	ps := layers.GetDataMap()
+	return oc.RenderQueriesWithData(layers.GetDataMap())
+}
+
+// RenderQueriesWithData is RenderQueries with the template data passed in
+// directly, for example the default values of the command flags.
+//
+// WARNING: This is destructive and should only be called once.
+func (oc *OakCommand) RenderQueriesWithData(ps map[string]interface{}) error {
 	for idx, query := range oc.Queries {
 		// we're ignoring the query because we want the index only, since we are not dealing with pointers
 		_ = query
@@ -373,6 +521,22 @@ func (oc *OakCommand) RenderQueries(layers *layers.ParsedLayers) error {
 	return nil
 }
 
+// collectSources returns the files the command should run on, expanding
+// directories with the globs from the settings, or the default globs for the
+// language of the command when recursing.
+func (oc *OakCommand) collectSources(s *RunSettings, ss *OakSettings) ([]string, error) {
+	glob_ := ss.Glob
+	if ss.Recurse && len(glob_) == 0 {
+		// use standard globs for the language of the command
+		var err error
+		glob_, err = pkg.GetLanguageGlobs(oc.Language)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return collectSources(s.Sources, glob_)
+}
+
 func collectSources(sources []string, globs []string) ([]string, error) {
 	ret := []string{}
 	// globs not empty implies recursion, if the glob patterns are recursive
@@ -427,38 +591,106 @@ func (oc *OakCommand) PrintQueries(w io.Writer) error {
 	return nil
 }
 
+// Clone returns a copy of the command with its own copy of the queries, so
+// that the queries can be rendered without modifying the original command,
+// for example when running a loaded command several times.
+func (oc *OakCommand) Clone() *OakCommand {
+	ret := *oc
+	ret.Queries = append([]tree_sitter.SitterQuery{}, oc.Queries...)
+	return &ret
+}
+
+// ParseFlagValues checks the given flag values against the flags of the
+// command and returns all the flag values, with the defaults filled in.
+func (oc *OakCommand) ParseFlagValues(values map[string]interface{}) (map[string]interface{}, error) {
+	flags := oc.GetDefaultFlags()
+	for name := range values {
+		if _, ok := flags.Get(name); !ok {
+			return nil, errors.Errorf("unknown flag %s", name)
+		}
+	}
+	parsedFlags, err := flags.GatherParametersFromMap(values, false)
+	if err != nil {
+		return nil, err
+	}
+	return parsedFlags.ToMap(), nil
+}
+
+// ExecuteQueriesOnSource parses source and runs the queries of the command
+// on it.
+func (oc *OakCommand) ExecuteQueriesOnSource(ctx context.Context, source []byte) (tree_sitter.QueryResults, error) {
+	lang, err := oc.GetLanguage()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := oc.Parse(ctx, nil, source)
+	if err != nil {
+		return nil, err
+	}
+	return tree_sitter.ExecuteQueries(lang, tree.RootNode(), oc.Queries, source)
+}
+
 // GetResultsByFile is a helper function that parses the given fileNames and
 // returns a map of results by fileName.
+//
+// The results are cached on disk by content (see cache.Default), so files
+// that haven't changed since the last run are not parsed again.
 func (oc *OakCommand) GetResultsByFile(
 	ctx context.Context,
 	fileNames []string,
 ) (
 	map[string]tree_sitter.QueryResults, error) {
-	resultsByFile := map[string]tree_sitter.QueryResults{}
+	files, err := oc.executeFiles(ctx, fileNames)
+	if err != nil {
+		return nil, err
+	}
+	return resultsOf(files), nil
+}
+
+// executeFiles reads the given files and runs the queries of the command on
+// them, like GetResultsByFile, but also returns their source. Their trees
+// are not kept, since the results may come from the cache.
+func (oc *OakCommand) executeFiles(ctx context.Context, fileNames []string) (map[string]*parsedFile, error) {
+	files := map[string]*parsedFile{}
 
 	lang, err := oc.GetLanguage()
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not get language for oak command")
 	}
 
+	c := cache.Default()
 	for _, fileName := range fileNames {
 		source, err := os.ReadFile(fileName)
 		if err != nil {
 			return nil, errors.Wrapf(err, "could not read file %s", fileName)
 		}
 
-		tree, err := oc.Parse(ctx, nil, []byte(source))
-		if err != nil {
-			return nil, errors.Wrapf(err, "could not parse file %s", fileName)
+		parse := func() (*sitter.Tree, error) {
+			tree, err := oc.Parse(ctx, nil, source)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse file %s", fileName)
+			}
+			return tree, nil
 		}
-
-		results, err := tree_sitter.ExecuteQueries(lang, tree.RootNode(), oc.Queries, source)
+		results, err := c.ExecuteQueries(parse, oc.Language, lang, oc.Queries, source)
 		if err != nil {
 			return nil, errors.Wrapf(err, "could not execute queries for file %s", fileName)
 		}
 
-		resultsByFile[fileName] = results
+		files[fileName] = &parsedFile{
+			source:  source,
+			results: results,
+		}
 	}
 
-	return resultsByFile, nil
+	return files, nil
+}
+
+// resultsOf returns the results of the given files, by file name.
+func resultsOf(files map[string]*parsedFile) map[string]tree_sitter.QueryResults {
+	ret := map[string]tree_sitter.QueryResults{}
+	for fileName, f := range files {
+		ret[fileName] = f.results
+	}
+	return ret
 }
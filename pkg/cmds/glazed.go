@@ -4,9 +4,11 @@ import (
 	"context"
 	"io"
 	"io/fs"
+	"os"
 	"strings"
 
-	"github.com/go-go-golems/oak/pkg"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 
 	"github.com/go-go-golems/glazed/pkg/cmds"
@@ -66,24 +68,79 @@ func (oc *OakGlazeCommand) RunIntoGlazeProcessor(
 		return nil
 	}
 
-	glob_ := ss.Glob
-	if ss.Recurse && len(glob_) == 0 {
-		// use standard globs for the language of the command
-		glob_, err = pkg.GetLanguageGlobs(oc.Language)
-		if err != nil {
-			return err
+	collect := func() ([]string, error) {
+		return oc.collectSources(s, ss)
+	}
+
+	if ss.Watch {
+		if ss.UpdateBaseline {
+			return errors.New("--update-baseline can't be used with --watch")
+		}
+		glazedLayer, ok := parsedLayers.Get(settings.GlazedSlug)
+		if !ok {
+			return errors.New("glazed layer not found")
 		}
+		// the rows added to gp are only output once the command returns, so
+		// every iteration gets its own processor, writing to stdout
+		return oc.watch(ctx, parsedLayers, collect, func(files map[string]*parsedFile) error {
+			gp_, err := settings.SetupTableProcessor(glazedLayer)
+			if err != nil {
+				return err
+			}
+			_, err = settings.SetupProcessorOutput(gp_, glazedLayer, os.Stdout)
+			if err != nil {
+				return err
+			}
+			resultsByFile, _, err := oc.applyBaseline(ctx, ss, files)
+			if err != nil {
+				return err
+			}
+			err = oc.addResultRows(ctx, resultsByFile, gp_)
+			if err != nil {
+				return err
+			}
+			return gp_.Close(ctx)
+		})
+	}
+
+	sources_, err := collect()
+	if err != nil {
+		return err
+	}
+
+	files, err := oc.executeFiles(ctx, sources_)
+	if err != nil {
+		return err
 	}
-	sources_, err := collectSources(s.Sources, glob_)
+
+	resultsByFile, newMatches, err := oc.applyBaseline(ctx, ss, files)
 	if err != nil {
 		return err
 	}
 
-	resultsByFile, err := oc.GetResultsByFile(ctx, sources_)
+	err = oc.addResultRows(ctx, resultsByFile, gp)
 	if err != nil {
 		return err
 	}
 
+	if newMatches > 0 {
+		// the rows are only output when the processor is closed, which the
+		// caller doesn't do when the command fails
+		err = gp.Close(ctx)
+		if err != nil {
+			return err
+		}
+		return &NewMatchesError{Count: newMatches, Baseline: ss.Baseline}
+	}
+	return nil
+}
+
+// addResultRows adds a row for each capture of each match to gp.
+func (oc *OakGlazeCommand) addResultRows(
+	ctx context.Context,
+	resultsByFile map[string]tree_sitter.QueryResults,
+	gp middlewares.Processor,
+) error {
 	for fileName, fileResults := range resultsByFile {
 		for _, result := range fileResults {
 			for _, match := range result.Matches {
@@ -104,7 +161,7 @@ func (oc *OakGlazeCommand) RunIntoGlazeProcessor(
 						types.MRP("type", capture.Type),
 						types.MRP("text", capture.Text),
 					)
-					err = gp.AddRow(ctx, row)
+					err := gp.AddRow(ctx, row)
 					if err != nil {
 						return err
 					}
@@ -198,11 +255,18 @@ func (o *OakGlazedCommandLoader) loadCommandFromReader(
 	}
 	options_ = append(options_, options...)
 
+	err = initRules(ocd.Rules, ocd.Queries)
+	if err != nil {
+		return nil, err
+	}
+
 	oakCommand := NewOakGlazedCommand(
 		cmds.NewCommandDescription(ocd.Name, options_...),
 		WithQueries(ocd.Queries...),
 		WithTemplate(ocd.Template),
 		WithLanguage(ocd.Language),
+		WithRules(ocd.Rules...),
+		WithRewrite(ocd.Rewrite),
 	)
 
 	return []cmds.Command{oakCommand}, nil
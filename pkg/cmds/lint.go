@@ -0,0 +1,406 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	"github.com/go-go-golems/oak/pkg"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+	"gopkg.in/yaml.v3"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintIssue is a problem found in a command file, located in the YAML file
+// (1-based line and column).
+type LintIssue struct {
+	File     string
+	Line     int
+	Column   int
+	Severity LintSeverity
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.File, i.Line, i.Column, i.Severity, i.Message)
+}
+
+// LintCommandFile checks the given command file without running it:
+//
+//   - every query is rendered with the default flag values, and with each
+//     boolean flag toggled, and compiled against the language of the command
+//   - the template is checked with CheckTemplate
+//   - captures that are neither used by the template nor by a predicate are
+//     reported as warnings
+//
+// YAML files that are not oak commands (no queries) return no issues.
+func LintCommandFile(fileName string) ([]LintIssue, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", fileName)
+	}
+
+	l := &commandLinter{
+		fileName: fileName,
+		lines:    strings.Split(string(b), "\n"),
+	}
+
+	doc := &yaml.Node{}
+	err = yaml.Unmarshal(b, doc)
+	if err != nil {
+		l.report(LintError, 1, 1, "could not parse YAML: %v", err)
+		return l.issues, nil
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	l.root = doc.Content[0]
+	if mappingValue(l.root, "queries") == nil {
+		return nil, nil
+	}
+
+	ocd := &OakCommandDescription{}
+	err = yaml.Unmarshal(b, ocd)
+	if err != nil {
+		l.report(LintError, 1, 1, "could not parse command: %v", err)
+		return l.issues, nil
+	}
+
+	cmds_, err := (&OakCommandLoader{}).loadCommandFromReader(bytes.NewReader(b), nil, nil)
+	if err != nil {
+		l.report(LintError, 1, 1, "could not load command: %v", err)
+		return l.issues, nil
+	}
+	flags := cmds_[0].(*OakWriterCommand).GetDefaultFlags()
+
+	lang, err := pkg.LanguageNameToSitterLanguage(ocd.Language)
+	if err != nil {
+		line, column := l.keyLocation(l.root, "language")
+		l.report(LintError, line, column, "%v", err)
+	} else {
+		err = l.lintQueries(ocd, flags, lang)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ocd.Template != "" {
+		l.lintTemplate(ocd, flags)
+	}
+
+	if len(ocd.Rewrite) > 0 {
+		_, err = parseRewriteTemplates(ocd.Rewrite, ocd.Queries)
+		if err != nil {
+			line, column := l.keyLocation(l.root, "rewrite")
+			l.report(LintError, line, column, "%v", err)
+		}
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return l.issues, nil
+}
+
+type commandLinter struct {
+	fileName string
+	lines    []string
+	root     *yaml.Node
+	issues   []LintIssue
+}
+
+func (l *commandLinter) report(severity LintSeverity, line, column int, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{
+		File:     l.fileName,
+		Line:     line,
+		Column:   column,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// flagCombination is a set of flag values the queries are rendered with.
+type flagCombination struct {
+	// label describes the combination in messages, empty for the defaults
+	label string
+	data  map[string]interface{}
+}
+
+func flagCombinations(flags *parameters.ParameterDefinitions) ([]flagCombination, error) {
+	parsed, err := flags.ParsedParametersFromDefaults()
+	if err != nil {
+		return nil, err
+	}
+	defaults := parsed.ToMap()
+
+	ret := []flagCombination{{data: defaults}}
+	flags.ForEach(func(p *parameters.ParameterDefinition) {
+		if p.Type != parameters.ParameterTypeBool {
+			return
+		}
+		value, _ := defaults[p.Name].(bool)
+		data := map[string]interface{}{}
+		for k, v := range defaults {
+			data[k] = v
+		}
+		data[p.Name] = !value
+		ret = append(ret, flagCombination{
+			label: fmt.Sprintf("--%s=%t", p.Name, !value),
+			data:  data,
+		})
+	})
+
+	return ret, nil
+}
+
+type queryIssue struct {
+	line, column int
+	message      string
+	combinations []string
+}
+
+func (l *commandLinter) lintQueries(
+	ocd *OakCommandDescription,
+	flags *parameters.ParameterDefinitions,
+	lang *sitter.Language,
+) error {
+	combinations, err := flagCombinations(flags)
+	if err != nil {
+		return err
+	}
+
+	queriesNode := mappingValue(l.root, "queries")
+
+	for idx, query := range ocd.Queries {
+		var queryNode *yaml.Node
+		if queriesNode != nil && idx < len(queriesNode.Content) {
+			queryNode = mappingValue(queriesNode.Content[idx], "query")
+		}
+
+		// the same issue is usually found with most combinations, report it once
+		issues := []*queryIssue{}
+		addIssue := func(line, column int, message string, combination flagCombination) {
+			for _, issue := range issues {
+				if issue.line == line && issue.message == message {
+					issue.combinations = append(issue.combinations, combination.label)
+					return
+				}
+			}
+			issues = append(issues, &queryIssue{
+				line:         line,
+				column:       column,
+				message:      message,
+				combinations: []string{combination.label},
+			})
+		}
+
+		for _, combination := range combinations {
+			rendered, err := renderQuery(query.Query, combination.data)
+			if err != nil {
+				addIssue(1, 1, fmt.Sprintf("could not render query %s: %v", query.Name, err), combination)
+				continue
+			}
+			if strings.TrimSpace(rendered) == "" {
+				continue
+			}
+
+			q, err := sitter.NewQuery([]byte(rendered), lang)
+			if err != nil {
+				qe, ok := err.(*sitter.QueryError)
+				if !ok {
+					addIssue(1, 1, fmt.Sprintf("query %s: %v", query.Name, err), combination)
+					continue
+				}
+				line, column, message := locateQueryError(query.Query, rendered, qe)
+				addIssue(line, column, fmt.Sprintf("query %s: %s", query.Name, message), combination)
+				continue
+			}
+			q.Close()
+		}
+
+		for _, issue := range issues {
+			message := issue.message
+			// only mention the flags if the defaults are fine
+			if issue.combinations[0] != "" {
+				message += " (with " + strings.Join(issue.combinations, ", ") + ")"
+			}
+			line, column := l.scalarLocation(queryNode, issue.line, issue.column)
+			l.report(LintError, line, column, "%s", message)
+		}
+	}
+
+	return nil
+}
+
+func renderQuery(query string, data map[string]interface{}) (string, error) {
+	tmpl, err := templating.CreateTemplate("oak").Parse(query)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_\-]*`)
+
+// locateQueryError finds the position of a query error in the query as
+// written in the command file, before rendering, by looking for the invalid
+// identifier (or the line with the syntax error) in it.
+func locateQueryError(query, rendered string, qe *sitter.QueryError) (int, int, string) {
+	offset := int(qe.Offset)
+	if offset > len(rendered) {
+		offset = len(rendered)
+	}
+	errorType := sitter.QueryErrorTypeToString(qe.Type)
+
+	// fallback: the position in the rendered query
+	line, column := offsetToLineColumn(rendered, offset)
+
+	switch qe.Type {
+	case sitter.QueryErrorNodeType, sitter.QueryErrorField, sitter.QueryErrorCapture:
+		identifier := identifierRegexp.FindString(rendered[offset:])
+		if identifier == "" {
+			return line, column, fmt.Sprintf("invalid %s", errorType)
+		}
+		message := fmt.Sprintf("invalid %s %s", errorType, identifier)
+		re := regexp.MustCompile(`(^|[^a-zA-Z0-9_\-@])` + regexp.QuoteMeta(identifier) + `($|[^a-zA-Z0-9_\-])`)
+		if loc := re.FindStringSubmatchIndex(query); loc != nil {
+			l, c := offsetToLineColumn(query, loc[3])
+			return l, c, message
+		}
+		return line, column, message
+
+	default:
+		lineStart := strings.LastIndex(rendered[:offset], "\n") + 1
+		lineEnd := strings.Index(rendered[offset:], "\n")
+		if lineEnd < 0 {
+			lineEnd = len(rendered)
+		} else {
+			lineEnd += offset
+		}
+		errorLine := strings.TrimSpace(rendered[lineStart:lineEnd])
+		message := fmt.Sprintf("invalid %s", errorType)
+		if errorLine != "" {
+			message = fmt.Sprintf("invalid %s near %q", errorType, errorLine)
+			if idx := strings.Index(query, errorLine); idx >= 0 {
+				l, c := offsetToLineColumn(query, idx)
+				return l, c, message
+			}
+		}
+		return line, column, message
+	}
+}
+
+func offsetToLineColumn(s string, offset int) (int, int) {
+	line := strings.Count(s[:offset], "\n") + 1
+	column := offset - strings.LastIndex(s[:offset], "\n")
+	return line, column
+}
+
+func (l *commandLinter) lintTemplate(ocd *OakCommandDescription, flags *parameters.ParameterDefinitions) {
+	templateNode := mappingValue(l.root, "template")
+
+	flagNames := []string{}
+	flags.ForEach(func(p *parameters.ParameterDefinition) {
+		flagNames = append(flagNames, p.Name)
+	})
+
+	check, err := CheckTemplate(ocd.Template, ocd.Queries, flagNames)
+	if err != nil {
+		line, column := l.scalarLocation(templateNode, 1, 1)
+		l.report(LintError, line, column, "could not parse template: %v", err)
+		return
+	}
+
+	for _, issue := range check.Issues {
+		line, column := l.scalarLocation(templateNode, issue.Line, issue.Column)
+		l.report(LintError, line, column, "template: %s", issue.Message)
+	}
+
+	queriesNode := mappingValue(l.root, "queries")
+	for idx, query := range ocd.Queries {
+		var queryNode *yaml.Node
+		if queriesNode != nil && idx < len(queriesNode.Content) {
+			queryNode = mappingValue(queriesNode.Content[idx], "query")
+		}
+		unused := check.UnusedCaptures(map[string][]string{
+			query.Name: QueryCaptures([]tree_sitter.SitterQuery{query})[query.Name],
+		})
+		for _, capture := range unused[query.Name] {
+			if _, ok := ocd.Rewrite[capture]; ok {
+				continue
+			}
+			line, column := 1, 1
+			if idx := strings.Index(query.Query, "@"+capture); idx >= 0 {
+				line, column = offsetToLineColumn(query.Query, idx)
+			}
+			line, column = l.scalarLocation(queryNode, line, column)
+			l.report(LintWarning, line, column, "capture @%s of query %s is not used by the template", capture, query.Name)
+		}
+	}
+}
+
+// keyLocation returns the location of the value of key in the mapping n.
+func (l *commandLinter) keyLocation(n *yaml.Node, key string) (int, int) {
+	v := mappingValue(n, key)
+	if v == nil {
+		return 1, 1
+	}
+	return v.Line, v.Column
+}
+
+// scalarLocation converts a position within the value of a YAML scalar into
+// a position in the file.
+func (l *commandLinter) scalarLocation(n *yaml.Node, line, column int) (int, int) {
+	return yamlScalarLocation(l.lines, n, line, column)
+}
+
+// yamlScalarLocation converts a position within the value of the YAML scalar
+// n into a position in the file made of lines. Positions are exact for block
+// scalars (| and >), which is how queries and templates are usually written,
+// otherwise the position of the scalar itself is returned.
+func yamlScalarLocation(lines []string, n *yaml.Node, line, column int) (int, int) {
+	if n == nil {
+		return 1, 1
+	}
+	if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		return n.Line, n.Column
+	}
+
+	// the content of a block scalar starts on the line after the indicator
+	fileLine := n.Line + line
+	if fileLine-1 >= len(lines) {
+		return n.Line, n.Column
+	}
+	// the indentation of the block is the one of its first non-empty line
+	indent := n.Column - 1
+	for _, text := range lines[n.Line:] {
+		if strings.TrimSpace(text) != "" {
+			indent = len(text) - len(strings.TrimLeft(text, " "))
+			break
+		}
+	}
+	return fileLine, indent + column
+}
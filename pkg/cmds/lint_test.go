@@ -0,0 +1,121 @@
+package cmds
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintCommandFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		issues []string
+	}{
+		{
+			name: "valid command",
+			yaml: `name: functions
+language: go
+queries:
+  - name: functions
+    query: |
+      (function_declaration name: (identifier) @name)
+template: |
+  {{ range .ResultsByFile }}{{ range .functions.Matches }}{{ .name.Text }}{{ end }}{{ end }}
+`,
+			issues: []string{},
+		},
+		{
+			name: "invalid query",
+			yaml: `name: functions
+language: go
+queries:
+  - name: functions
+    query: |
+      (function_declaration name: (unknown_node) @name)
+template: |
+  {{ range .ResultsByFile }}{{ range .functions.Matches }}{{ .name.Text }}{{ end }}{{ end }}
+`,
+			issues: []string{":6:"},
+		},
+		{
+			name: "query broken by a boolean flag",
+			yaml: `name: functions
+language: go
+flags:
+  - name: only_named
+    type: bool
+    default: true
+queries:
+  - name: functions
+    query: |
+      (function_declaration {{ if .only_named }}name: (identifier{{ end }}) @name)
+template: |
+  {{ range .ResultsByFile }}{{ range .functions.Matches }}{{ .name.Text }}{{ end }}{{ end }}
+`,
+			issues: []string{"only_named"},
+		},
+		{
+			name: "unknown capture and unused capture",
+			yaml: `name: functions
+language: go
+queries:
+  - name: functions
+    query: |
+      (function_declaration name: (identifier) @name body: (block) @body)
+template: |
+  {{ range .ResultsByFile }}{{ range .functions.Matches }}{{ .nam.Text }}{{ end }}{{ end }}
+`,
+			issues: []string{
+				"warning: capture @name of query functions is not used by the template",
+				"warning: capture @body of query functions is not used by the template",
+				"error: template: query functions has no capture @nam",
+			},
+		},
+		{
+			name: "duplicate rule",
+			yaml: `name: functions
+language: go
+queries:
+  - name: functions
+    query: |
+      (function_declaration name: (identifier) @name)
+rules:
+  - id: no-functions
+    query: functions
+    message: function {{ .name.Text }}
+  - id: no-functions
+    query: functions
+    message: another function {{ .name.Text }}
+`,
+			issues: []string{"error: could not load command: duplicate rule no-functions"},
+		},
+		{
+			name:   "not a command",
+			yaml:   "foo: bar\n",
+			issues: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "command.yaml")
+			if err := os.WriteFile(fileName, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			issues, err := LintCommandFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != len(tt.issues) {
+				t.Fatalf("got %v, expected %d issues", issues, len(tt.issues))
+			}
+			for i, issue := range issues {
+				if !strings.Contains(issue.String(), tt.issues[i]) {
+					t.Errorf("got %q, expected it to contain %q", issue.String(), tt.issues[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/aymanbagabas/go-udiff"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+//go:embed "layers/rewrite.yaml"
+var rewriteLayerYaml string
+
+const RewriteSlug = "rewrite"
+
+type RewriteSettings struct {
+	Write bool `glazed.parameter:"write"`
+}
+
+func NewRewriteParameterLayer(
+	options ...layers.ParameterLayerOptions,
+) (layers.ParameterLayer, error) {
+	return layers.NewParameterLayerFromYAML([]byte(rewriteLayerYaml), options...)
+}
+
+// Edit replaces the bytes from StartByte to EndByte of a source file with
+// Text.
+type Edit struct {
+	StartByte  uint32
+	EndByte    uint32
+	StartPoint sitter.Point
+	Text       string
+	// Capture is the name of the rewritten capture.
+	Capture string
+}
+
+// parseRewriteTemplates checks that the rewritten captures exist in the
+// queries, and parses their templates.
+func parseRewriteTemplates(
+	rewrite map[string]string,
+	queries []tree_sitter.SitterQuery,
+) (map[string]*template.Template, error) {
+	captures := map[string]bool{}
+	for _, queryCaptures := range QueryCaptures(queries) {
+		for _, capture := range queryCaptures {
+			captures[capture] = true
+		}
+	}
+
+	ret := map[string]*template.Template{}
+	for capture, text := range rewrite {
+		if !captures[capture] {
+			return nil, errors.Errorf("rewrite: no query has a capture @%s", capture)
+		}
+		tmpl, err := templating.CreateTemplate(capture).Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rewrite: invalid template for @%s", capture)
+		}
+		ret[capture] = tmpl
+	}
+	return ret, nil
+}
+
+// RewriteEdits returns the edits described by the rewrite section of the
+// command for the given results, sorted by position. The replacement
+// templates are rendered with data (usually the flags of the command) and
+// the captures of the match.
+//
+// Edits overlapping a previous edit are not applied, and returned as
+// skipped. Identical edits, for a capture matched by several queries, are
+// only applied once.
+func (oc *OakCommand) RewriteEdits(
+	data map[string]interface{},
+	results tree_sitter.QueryResults,
+) ([]Edit, []Edit, error) {
+	templates, err := parseRewriteTemplates(oc.Rewrite, oc.Queries)
+	if err != nil {
+		return nil, nil, err
+	}
+	captureNames := make([]string, 0, len(templates))
+	for capture := range templates {
+		captureNames = append(captureNames, capture)
+	}
+	sort.Strings(captureNames)
+
+	all := []Edit{}
+	for _, query := range oc.Queries {
+		result, ok := results[query.Name]
+		if !ok {
+			continue
+		}
+		for _, match := range result.Matches {
+			data_ := map[string]interface{}{}
+			for k, v := range data {
+				data_[k] = v
+			}
+			for name, capture := range match {
+				data_[name] = capture
+			}
+
+			for _, name := range captureNames {
+				capture, ok := match[name]
+				if !ok {
+					continue
+				}
+				var buf bytes.Buffer
+				err := templates[name].Execute(&buf, data_)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "could not render rewrite of @%s", name)
+				}
+				all = append(all, Edit{
+					StartByte:  capture.StartByte,
+					EndByte:    capture.EndByte,
+					StartPoint: capture.StartPoint,
+					Text:       buf.String(),
+					Capture:    name,
+				})
+			}
+		}
+	}
+
+	edits, skipped := SelectEdits(all)
+	return edits, skipped, nil
+}
+
+// SelectEdits sorts edits by position, and returns the edits that can be
+// applied together. Edits overlapping a previous edit are returned as
+// skipped, and identical edits are only kept once.
+func SelectEdits(all []Edit) ([]Edit, []Edit) {
+	all = append([]Edit{}, all...)
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].StartByte != all[j].StartByte {
+			return all[i].StartByte < all[j].StartByte
+		}
+		return all[i].EndByte > all[j].EndByte
+	})
+
+	edits, skipped := []Edit{}, []Edit{}
+	for _, edit := range all {
+		if len(edits) > 0 {
+			last := edits[len(edits)-1]
+			if last.StartByte == edit.StartByte && last.EndByte == edit.EndByte && last.Text == edit.Text {
+				continue
+			}
+			if edit.StartByte < last.EndByte || (edit.StartByte == last.StartByte && edit.StartByte == edit.EndByte) {
+				skipped = append(skipped, edit)
+				continue
+			}
+		}
+		edits = append(edits, edit)
+	}
+
+	return edits, skipped
+}
+
+// ApplyEdits applies non-overlapping edits, sorted by position, to source.
+func ApplyEdits(source []byte, edits []Edit) []byte {
+	var buf bytes.Buffer
+	offset := uint32(0)
+	for _, edit := range edits {
+		buf.Write(source[offset:edit.StartByte])
+		buf.WriteString(edit.Text)
+		offset = edit.EndByte
+	}
+	buf.Write(source[offset:])
+	return buf.Bytes()
+}
+
+// RewriteSource applies edits to source, whose parsed tree is tree, and
+// parses the result. It returns false if the rewritten source has more
+// syntax errors than the original one.
+func (oc *OakCommand) RewriteSource(
+	ctx context.Context,
+	source []byte,
+	tree *sitter.Tree,
+	edits []Edit,
+) ([]byte, bool, error) {
+	rewritten := ApplyEdits(source, edits)
+	if bytes.Equal(rewritten, source) {
+		return rewritten, true, nil
+	}
+
+	newTree, err := oc.Parse(ctx, nil, rewritten)
+	if err != nil {
+		return nil, false, err
+	}
+	if countSyntaxErrors(newTree.RootNode()) > countSyntaxErrors(tree.RootNode()) {
+		return rewritten, false, nil
+	}
+	return rewritten, true, nil
+}
+
+// countSyntaxErrors returns the number of ERROR and missing nodes in the
+// tree rooted at n.
+func countSyntaxErrors(n *sitter.Node) int {
+	if n.IsMissing() {
+		return 1
+	}
+	if !n.HasError() {
+		return 0
+	}
+	count := 0
+	if n.IsError() {
+		count++
+	}
+	for i := 0; i < int(n.ChildCount()); i++ {
+		count += countSyntaxErrors(n.Child(i))
+	}
+	return count
+}
+
+// OakRewriteCommand rewrites the source files with the rewrite section of an
+// oak command, printing a unified diff, or writing the files in place.
+type OakRewriteCommand struct {
+	*OakCommand
+}
+
+var _ cmds.WriterCommand = (*OakRewriteCommand)(nil)
+
+func NewOakRewriteCommand(d *cmds.CommandDescription, options ...OakCommandOption) *OakRewriteCommand {
+	cmd := OakRewriteCommand{
+		OakCommand: &OakCommand{
+			CommandDescription: d,
+		},
+	}
+	for _, option := range options {
+		option(cmd.OakCommand)
+	}
+	return &cmd
+}
+
+func (oc *OakRewriteCommand) RunIntoWriter(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	w io.Writer,
+) error {
+	s := &RunSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+	ss := &OakSettings{}
+	err = parsedLayers.InitializeStruct(OakSlug, ss)
+	if err != nil {
+		return err
+	}
+	rs := &RewriteSettings{}
+	err = parsedLayers.InitializeStruct(RewriteSlug, rs)
+	if err != nil {
+		return err
+	}
+
+	if ss.Watch {
+		return errors.New("--watch is not supported when rewriting")
+	}
+	if ss.Baseline != "" || ss.UpdateBaseline {
+		return errors.New("--baseline is not supported when rewriting")
+	}
+
+	err = oc.RenderQueries(parsedLayers)
+	if err != nil {
+		return err
+	}
+
+	if ss.PrintQueries {
+		return oc.PrintQueries(w)
+	}
+
+	sources_, err := oc.collectSources(s, ss)
+	if err != nil {
+		return err
+	}
+
+	data := parsedLayers.GetDataMap()
+	broken := []string{}
+	for _, fileName := range sources_ {
+		ok, err := oc.rewriteFile(ctx, fileName, data, rs.Write, w)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			broken = append(broken, fileName)
+		}
+	}
+
+	if len(broken) > 0 {
+		return errors.Errorf("rewriting introduces syntax errors in %s, the files were left unchanged",
+			strings.Join(broken, ", "))
+	}
+
+	return nil
+}
+
+// rewriteFile rewrites a single file. It returns false if the rewritten
+// file has more syntax errors than the original one, in which case it is
+// left unchanged.
+func (oc *OakRewriteCommand) rewriteFile(
+	ctx context.Context,
+	fileName string,
+	data map[string]interface{},
+	write bool,
+	w io.Writer,
+) (bool, error) {
+	source, err := os.ReadFile(fileName)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not read file %s", fileName)
+	}
+	lang, err := oc.GetLanguage()
+	if err != nil {
+		return false, err
+	}
+	tree, err := oc.Parse(ctx, nil, source)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not parse file %s", fileName)
+	}
+	results, err := tree_sitter.ExecuteQueries(lang, tree.RootNode(), oc.Queries, source)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not execute queries for file %s", fileName)
+	}
+
+	edits, skipped, err := oc.RewriteEdits(data, results)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not rewrite file %s", fileName)
+	}
+	for _, edit := range skipped {
+		log.Warn().
+			Str("file", fileName).
+			Uint32("line", edit.StartPoint.Row+1).
+			Uint32("column", edit.StartPoint.Column+1).
+			Str("capture", edit.Capture).
+			Msg("skipping rewrite overlapping a previous one")
+	}
+
+	rewritten, ok, err := oc.RewriteSource(ctx, source, tree, edits)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not parse rewritten file %s", fileName)
+	}
+	if !ok {
+		log.Error().Str("file", fileName).Msg("rewritten file has syntax errors")
+		return false, nil
+	}
+	if bytes.Equal(rewritten, source) {
+		return true, nil
+	}
+
+	if !write {
+		_, err = fmt.Fprint(w, udiff.Unified("a/"+fileName, "b/"+fileName, string(source), string(rewritten)))
+		return true, err
+	}
+
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		return false, err
+	}
+	err = os.WriteFile(fileName, rewritten, fi.Mode().Perm())
+	if err != nil {
+		return false, errors.Wrapf(err, "could not write file %s", fileName)
+	}
+	_, err = fmt.Fprintf(w, "rewrote %s (%d edits)\n", fileName, len(edits))
+	return true, err
+}
+
+// OakRewriteCommandLoader loads the commands that have a rewrite section as
+// OakRewriteCommands. Other commands are skipped.
+type OakRewriteCommandLoader struct{}
+
+var _ loaders.CommandLoader = (*OakRewriteCommandLoader)(nil)
+
+func (o *OakRewriteCommandLoader) IsFileSupported(f fs.FS, fileName string) bool {
+	return strings.HasSuffix(fileName, ".yaml") || strings.HasSuffix(fileName, ".yml")
+}
+
+func (o *OakRewriteCommandLoader) LoadCommands(
+	f fs.FS, entryName string,
+	options []cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]cmds.Command, error) {
+	r, err := f.Open(entryName)
+	if err != nil {
+		return nil, err
+	}
+	defer func(r fs.File) {
+		_ = r.Close()
+	}(r)
+
+	return loaders.LoadCommandOrAliasFromReader(
+		r,
+		o.loadCommandFromReader,
+		options,
+		aliasOptions)
+}
+
+func (o *OakRewriteCommandLoader) loadCommandFromReader(
+	s io.Reader,
+	options []cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]cmds.Command, error) {
+	commands, err := (&OakCommandLoader{}).loadCommandFromReader(s, options, aliasOptions)
+	if err != nil {
+		return nil, err
+	}
+	oc := commands[0].(*OakWriterCommand).OakCommand
+	if len(oc.Rewrite) == 0 {
+		return []cmds.Command{}, nil
+	}
+
+	_, err = parseRewriteTemplates(oc.Rewrite, oc.Queries)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid rewrite in command %s", oc.Name)
+	}
+
+	rewriteLayer, err := NewRewriteParameterLayer()
+	if err != nil {
+		return nil, err
+	}
+	oc.Layers.AppendLayers(rewriteLayer)
+
+	return []cmds.Command{&OakRewriteCommand{OakCommand: oc}}, nil
+}
+
+func (o *OakRewriteCommandLoader) LoadCommandAliasFromYAML(
+	s io.Reader,
+	options ...alias.Option,
+) ([]*alias.CommandAlias, error) {
+	return loaders.LoadCommandAliasFromYAML(s, options...)
+}
@@ -0,0 +1,94 @@
+package cmds
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectEdits(t *testing.T) {
+	tests := []struct {
+		name    string
+		edits   []Edit
+		kept    []Edit
+		skipped []Edit
+	}{
+		{
+			name:    "sorted by position",
+			edits:   []Edit{{StartByte: 4, EndByte: 5, Text: "b"}, {StartByte: 0, EndByte: 1, Text: "a"}},
+			kept:    []Edit{{StartByte: 0, EndByte: 1, Text: "a"}, {StartByte: 4, EndByte: 5, Text: "b"}},
+			skipped: []Edit{},
+		},
+		{
+			name:    "adjacent edits",
+			edits:   []Edit{{StartByte: 0, EndByte: 2, Text: "a"}, {StartByte: 2, EndByte: 4, Text: "b"}},
+			kept:    []Edit{{StartByte: 0, EndByte: 2, Text: "a"}, {StartByte: 2, EndByte: 4, Text: "b"}},
+			skipped: []Edit{},
+		},
+		{
+			name:    "child of a rewritten node",
+			edits:   []Edit{{StartByte: 2, EndByte: 3, Text: "child"}, {StartByte: 0, EndByte: 5, Text: "parent"}},
+			kept:    []Edit{{StartByte: 0, EndByte: 5, Text: "parent"}},
+			skipped: []Edit{{StartByte: 2, EndByte: 3, Text: "child"}},
+		},
+		{
+			name:    "partial overlap",
+			edits:   []Edit{{StartByte: 0, EndByte: 3, Text: "a"}, {StartByte: 2, EndByte: 5, Text: "b"}},
+			kept:    []Edit{{StartByte: 0, EndByte: 3, Text: "a"}},
+			skipped: []Edit{{StartByte: 2, EndByte: 5, Text: "b"}},
+		},
+		{
+			name:    "identical edits are kept once",
+			edits:   []Edit{{StartByte: 1, EndByte: 2, Text: "a"}, {StartByte: 1, EndByte: 2, Text: "a"}},
+			kept:    []Edit{{StartByte: 1, EndByte: 2, Text: "a"}},
+			skipped: []Edit{},
+		},
+		{
+			name:    "same range, different text",
+			edits:   []Edit{{StartByte: 1, EndByte: 2, Text: "a"}, {StartByte: 1, EndByte: 2, Text: "b"}},
+			kept:    []Edit{{StartByte: 1, EndByte: 2, Text: "a"}},
+			skipped: []Edit{{StartByte: 1, EndByte: 2, Text: "b"}},
+		},
+		{
+			name:    "two insertions at the same position",
+			edits:   []Edit{{StartByte: 3, EndByte: 3, Text: "a"}, {StartByte: 3, EndByte: 3, Text: "b"}},
+			kept:    []Edit{{StartByte: 3, EndByte: 3, Text: "a"}},
+			skipped: []Edit{{StartByte: 3, EndByte: 3, Text: "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, skipped := SelectEdits(tt.edits)
+			if !reflect.DeepEqual(kept, tt.kept) {
+				t.Errorf("kept %v, expected %v", kept, tt.kept)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped %v, expected %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	source := []byte("func Foo() { Foo() }")
+	edits, skipped := SelectEdits([]Edit{
+		{StartByte: 13, EndByte: 16, Text: "Bar"},
+		{StartByte: 5, EndByte: 8, Text: "Bar"},
+		// overlaps the name of the call, skipped
+		{StartByte: 15, EndByte: 18, Text: "nope()"},
+		// insertion
+		{StartByte: 0, EndByte: 0, Text: "// renamed\n"},
+	})
+	if len(skipped) != 1 {
+		t.Fatalf("expected 1 skipped edit, got %v", skipped)
+	}
+
+	expected := "// renamed\nfunc Bar() { Bar() }"
+	if got := string(ApplyEdits(source, edits)); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	if got := string(ApplyEdits(source, nil)); got != string(source) {
+		t.Errorf("got %q without edits", got)
+	}
+}
@@ -0,0 +1,265 @@
+package cmds
+
+import (
+	"bytes"
+	"os"
+	"text/template"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// RuleSeverity is the severity of the diagnostics reported by a rule.
+type RuleSeverity string
+
+const (
+	SeverityError   RuleSeverity = "error"
+	SeverityWarning RuleSeverity = "warning"
+	SeverityInfo    RuleSeverity = "info"
+	SeverityHint    RuleSeverity = "hint"
+)
+
+// Rule turns the matches of a query into diagnostics. Commands with rules
+// are used by `oak lsp` to report diagnostics in the editor, and by
+// `oak check` to lint source files.
+//
+//	rules:
+//	  - id: no-println
+//	    query: printlnCalls
+//	    capture: call
+//	    severity: warning
+//	    message: use the logger instead of fmt.{{ .function.Text }}
+//	    fix: log.Info().Msg({{ .args.Text }})
+type Rule struct {
+	ID string `yaml:"id"`
+	// Query is the name of the query whose matches are reported, the ID of
+	// the rule by default.
+	Query string `yaml:"query,omitempty"`
+	// Capture is the capture the diagnostic is reported on. By default, the
+	// diagnostic spans all the captures of the match.
+	Capture string `yaml:"capture,omitempty"`
+	// Severity is error, warning (the default), info or hint.
+	Severity RuleSeverity `yaml:"severity,omitempty"`
+	// Message is a template rendered with the captures of the match.
+	Message string `yaml:"message"`
+	// Description describes the rule as a whole, for reports listing the
+	// rules such as SARIF.
+	Description string `yaml:"description,omitempty"`
+	// Fix is an optional template rendered with the captures of the match,
+	// replacing the reported range.
+	Fix string `yaml:"fix,omitempty"`
+
+	messageTemplate *template.Template
+	fixTemplate     *template.Template
+}
+
+// Diagnostic is a match of a rule.
+type Diagnostic struct {
+	Rule    *Rule
+	Message string
+
+	StartByte  uint32
+	EndByte    uint32
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+
+	// Fix replaces the reported range, if the rule has a fix.
+	Fix *Edit
+}
+
+func (r *Rule) queryName() string {
+	if r.Query != "" {
+		return r.Query
+	}
+	return r.ID
+}
+
+// initRules initializes the rules of a command, whose IDs must be unique.
+func initRules(rules []*Rule, queries []tree_sitter.SitterQuery) error {
+	ids := map[string]bool{}
+	for _, rule := range rules {
+		err := rule.init(queries)
+		if err != nil {
+			return err
+		}
+		if ids[rule.ID] {
+			return errors.Errorf("duplicate rule %s", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	return nil
+}
+
+// init checks the rule against the queries of its command, fills in the
+// defaults and parses the message template.
+func (r *Rule) init(queries []tree_sitter.SitterQuery) error {
+	if r.ID == "" {
+		return errors.New("rule without id")
+	}
+	found := false
+	for _, query := range queries {
+		if query.Name == r.queryName() {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.Errorf("rule %s: unknown query %s", r.ID, r.queryName())
+	}
+
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarning
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityHint:
+	default:
+		return errors.Errorf("rule %s: unknown severity %s, expected error, warning, info or hint", r.ID, r.Severity)
+	}
+
+	if r.Message == "" {
+		return errors.Errorf("rule %s: missing message", r.ID)
+	}
+	tmpl, err := templating.CreateTemplate("rule").Parse(r.Message)
+	if err != nil {
+		return errors.Wrapf(err, "rule %s: invalid message", r.ID)
+	}
+	r.messageTemplate = tmpl
+
+	if r.Fix != "" {
+		tmpl, err = templating.CreateTemplate("fix").Parse(r.Fix)
+		if err != nil {
+			return errors.Wrapf(err, "rule %s: invalid fix", r.ID)
+		}
+		r.fixTemplate = tmpl
+	}
+
+	return nil
+}
+
+// Diagnostics returns the diagnostics of the rules of the command for the
+// given results, in the order of the rules and matches.
+func (oc *OakCommand) Diagnostics(results tree_sitter.QueryResults) ([]*Diagnostic, error) {
+	ret := []*Diagnostic{}
+	for _, rule := range oc.Rules {
+		result, ok := results[rule.queryName()]
+		if !ok {
+			continue
+		}
+		for _, match := range result.Matches {
+			d, err := rule.diagnostic(match)
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				ret = append(ret, d)
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (r *Rule) diagnostic(match tree_sitter.Match) (*Diagnostic, error) {
+	var buf bytes.Buffer
+	err := r.messageTemplate.Execute(&buf, match)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not render message of rule %s", r.ID)
+	}
+
+	d := &Diagnostic{
+		Rule:    r,
+		Message: buf.String(),
+	}
+
+	if r.Capture != "" {
+		capture, ok := match[r.Capture]
+		if !ok {
+			// the capture is optional in the query
+			return nil, nil
+		}
+		d.StartByte, d.StartPoint = capture.StartByte, capture.StartPoint
+		d.EndByte, d.EndPoint = capture.EndByte, capture.EndPoint
+	} else {
+		first := true
+		for _, capture := range match {
+			if first || capture.StartByte < d.StartByte {
+				d.StartByte, d.StartPoint = capture.StartByte, capture.StartPoint
+			}
+			if first || capture.EndByte > d.EndByte {
+				d.EndByte, d.EndPoint = capture.EndByte, capture.EndPoint
+			}
+			first = false
+		}
+	}
+
+	if r.fixTemplate != nil {
+		buf.Reset()
+		err = r.fixTemplate.Execute(&buf, match)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not render fix of rule %s", r.ID)
+		}
+		d.Fix = &Edit{
+			StartByte:  d.StartByte,
+			EndByte:    d.EndByte,
+			StartPoint: d.StartPoint,
+			Text:       buf.String(),
+			Capture:    r.Capture,
+		}
+	}
+
+	return d, nil
+}
+
+// RuleCommand returns a copy of the command with its queries rendered with
+// the default values of its flags, ready to compute diagnostics. It returns
+// nil if the command is not an oak command, or has no rules.
+func RuleCommand(command cmds.Command) (*OakCommand, error) {
+	var oc *OakCommand
+	switch c := command.(type) {
+	case *OakWriterCommand:
+		oc = c.OakCommand
+	case *OakCommand:
+		oc = c
+	}
+	if oc == nil || len(oc.Rules) == 0 {
+		return nil, nil
+	}
+
+	oc = oc.Clone()
+	defaults, err := oc.GetDefaultsMap()
+	if err != nil {
+		return nil, err
+	}
+	err = oc.RenderQueriesWithData(defaults)
+	if err != nil {
+		return nil, err
+	}
+	return oc, nil
+}
+
+// LoadRuleCommands loads the given command files, and returns the commands
+// with rules, see RuleCommand. Files without rules are skipped.
+func LoadRuleCommands(fileNames []string) ([]*OakCommand, error) {
+	ret := []*OakCommand{}
+	for _, fileName := range fileNames {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		commands, err := (&OakCommandLoader{}).loadCommandFromReader(f, nil, nil)
+		_ = f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load command file %s", fileName)
+		}
+
+		oc, err := RuleCommand(commands[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not render the queries of %s", fileName)
+		}
+		if oc != nil {
+			ret = append(ret, oc)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,414 @@
+package cmds
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+)
+
+// TemplateIssue is a problem found by CheckTemplate, located in the template
+// text (1-based line and column).
+type TemplateIssue struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// TemplateCheck is the result of CheckTemplate.
+type TemplateCheck struct {
+	Issues []TemplateIssue
+	// UsedCaptures lists, for each query, the captures referenced by the
+	// template or by the predicates of the query.
+	UsedCaptures map[string]map[string]bool
+}
+
+// UnusedCaptures returns the captures of each query that are neither used
+// by the template nor by a predicate. Captures starting with _ are
+// considered private to the query and never reported.
+func (tc *TemplateCheck) UnusedCaptures(queryCaptures map[string][]string) map[string][]string {
+	ret := map[string][]string{}
+	for query, captures := range queryCaptures {
+		for _, capture := range captures {
+			if strings.HasPrefix(capture, "_") || tc.UsedCaptures[query][capture] {
+				continue
+			}
+			ret[query] = append(ret[query], capture)
+		}
+	}
+	return ret
+}
+
+var (
+	captureRegexp   = regexp.MustCompile(`@([a-zA-Z_][a-zA-Z0-9_.\-]*)`)
+	predicateRegexp = regexp.MustCompile(`\(#[a-zA-Z_?!\-]+[^()]*\)`)
+)
+
+// QueryCaptures returns the capture names of each query, in order of first
+// appearance. The captures are extracted from the query text, before it is
+// rendered, so that captures in conditional parts of a query are included.
+func QueryCaptures(queries []tree_sitter.SitterQuery) map[string][]string {
+	ret := map[string][]string{}
+	for _, query := range queries {
+		seen := map[string]bool{}
+		captures := []string{}
+		for _, m := range captureRegexp.FindAllStringSubmatch(query.Query, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				captures = append(captures, m[1])
+			}
+		}
+		ret[query.Name] = captures
+	}
+	return ret
+}
+
+// predicateCaptures returns the captures referenced by the predicates of the
+// query, such as @name in (#eq? @name "foo").
+func predicateCaptures(query string) []string {
+	ret := []string{}
+	for _, predicate := range predicateRegexp.FindAllString(query, -1) {
+		for _, m := range captureRegexp.FindAllStringSubmatch(predicate, -1) {
+			ret = append(ret, m[1])
+		}
+	}
+	return ret
+}
+
+// CheckTemplate statically analyzes the template of an oak command and
+// cross-references its field accesses with the queries, their captures and
+// the flags of the command.
+//
+// The data passed to the template is tracked through range, with and
+// variables, so that for example the .nme in
+//
+//	{{ range .Results.functionDeclarations.Matches }}{{ .nme.Text }}{{ end }}
+//
+// is reported as a missing capture of the functionDeclarations query, which
+// would otherwise silently render as <no value>. Accesses that can't be
+// resolved statically (for example through functions) are not checked.
+func CheckTemplate(
+	templateText string,
+	queries []tree_sitter.SitterQuery,
+	flags []string,
+) (*TemplateCheck, error) {
+	tmpl, err := templating.CreateTemplate("oak").Parse(templateText)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &templateChecker{
+		text:     templateText,
+		captures: map[string]map[string]bool{},
+		flags:    map[string]bool{},
+		result: &TemplateCheck{
+			UsedCaptures: map[string]map[string]bool{},
+		},
+	}
+	for query, captures := range QueryCaptures(queries) {
+		c.captures[query] = map[string]bool{}
+		c.result.UsedCaptures[query] = map[string]bool{}
+		for _, capture := range captures {
+			c.captures[query][capture] = true
+		}
+	}
+	for _, query := range queries {
+		for _, capture := range predicateCaptures(query.Query) {
+			c.result.UsedCaptures[query.Name][capture] = true
+		}
+	}
+	for _, flag := range flags {
+		c.flags[flag] = true
+	}
+
+	if tmpl.Tree != nil && tmpl.Tree.Root != nil {
+		c.walk(tmpl.Tree.Root, templateValue{kind: kindData}, map[string]templateValue{
+			"$": {kind: kindData},
+		})
+	}
+
+	sort.SliceStable(c.result.Issues, func(i, j int) bool {
+		a, b := c.result.Issues[i], c.result.Issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return c.result, nil
+}
+
+type templateValueKind int
+
+const (
+	kindUnknown templateValueKind = iota
+	// kindData is the data passed to the template: the flags, Results and
+	// ResultsByFile.
+	kindData
+	kindResultsByFile
+	kindQueryResults
+	kindResult
+	kindMatches
+	kindMatch
+	kindCapture
+)
+
+// templateValue is what is known about a value in the template. query is
+// set for results, matches and matches of a known query.
+type templateValue struct {
+	kind  templateValueKind
+	query string
+}
+
+var captureFields = map[string]bool{
+	"Name":       true,
+	"Text":       true,
+	"Type":       true,
+	"StartByte":  true,
+	"EndByte":    true,
+	"StartPoint": true,
+	"EndPoint":   true,
+}
+
+type templateChecker struct {
+	text     string
+	captures map[string]map[string]bool
+	flags    map[string]bool
+	result   *TemplateCheck
+	reported map[string]bool
+}
+
+func (c *templateChecker) report(pos parse.Pos, format string, args ...interface{}) {
+	offset := int(pos)
+	if offset > len(c.text) {
+		offset = len(c.text)
+	}
+	line := strings.Count(c.text[:offset], "\n") + 1
+	column := offset - strings.LastIndex(c.text[:offset], "\n")
+
+	message := fmt.Sprintf(format, args...)
+	key := fmt.Sprintf("%d:%d:%s", line, column, message)
+	if c.reported == nil {
+		c.reported = map[string]bool{}
+	}
+	if c.reported[key] {
+		return
+	}
+	c.reported[key] = true
+
+	c.result.Issues = append(c.result.Issues, TemplateIssue{
+		Line:    line,
+		Column:  column,
+		Message: message,
+	})
+}
+
+func copyVars(vars map[string]templateValue) map[string]templateValue {
+	ret := make(map[string]templateValue, len(vars))
+	for k, v := range vars {
+		ret[k] = v
+	}
+	return ret
+}
+
+func (c *templateChecker) walk(node parse.Node, dot templateValue, vars map[string]templateValue) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(child, dot, vars)
+		}
+
+	case *parse.ActionNode:
+		c.evalPipe(n.Pipe, dot, vars)
+
+	case *parse.IfNode:
+		inner := copyVars(vars)
+		c.evalPipe(n.Pipe, dot, inner)
+		c.walk(n.List, dot, inner)
+		c.walk(n.ElseList, dot, copyVars(vars))
+
+	case *parse.WithNode:
+		inner := copyVars(vars)
+		v := c.evalPipe(n.Pipe, dot, inner)
+		c.walk(n.List, v, inner)
+		c.walk(n.ElseList, dot, copyVars(vars))
+
+	case *parse.RangeNode:
+		inner := copyVars(vars)
+		// the declared variables get the key and element, not the pipeline value
+		decl := n.Pipe.Decl
+		n.Pipe.Decl = nil
+		v := c.evalPipe(n.Pipe, dot, inner)
+		n.Pipe.Decl = decl
+
+		elem := c.elementOf(v)
+		switch len(decl) {
+		case 1:
+			inner[decl[0].Ident[0]] = elem
+		case 2:
+			inner[decl[0].Ident[0]] = templateValue{}
+			inner[decl[1].Ident[0]] = elem
+		}
+		c.walk(n.List, elem, inner)
+		c.walk(n.ElseList, dot, copyVars(vars))
+
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			c.evalPipe(n.Pipe, dot, vars)
+		}
+	}
+}
+
+// evalPipe checks the commands of the pipeline, assigns declared variables
+// and returns the value of the pipeline.
+func (c *templateChecker) evalPipe(pipe *parse.PipeNode, dot templateValue, vars map[string]templateValue) templateValue {
+	if pipe == nil {
+		return templateValue{}
+	}
+
+	var v templateValue
+	for i, cmd := range pipe.Cmds {
+		v = c.evalCommand(cmd, dot, vars)
+		if i > 0 {
+			// piping into a function
+			v = templateValue{}
+		}
+	}
+
+	for _, decl := range pipe.Decl {
+		vars[decl.Ident[0]] = v
+	}
+
+	return v
+}
+
+func (c *templateChecker) evalCommand(cmd *parse.CommandNode, dot templateValue, vars map[string]templateValue) templateValue {
+	if len(cmd.Args) == 0 {
+		return templateValue{}
+	}
+	for _, arg := range cmd.Args[1:] {
+		c.evalArg(arg, dot, vars)
+	}
+	if _, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		// function call
+		return templateValue{}
+	}
+	return c.evalArg(cmd.Args[0], dot, vars)
+}
+
+func (c *templateChecker) evalArg(arg parse.Node, dot templateValue, vars map[string]templateValue) templateValue {
+	switch n := arg.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.resolve(dot, n.Ident, n.Position())
+	case *parse.VariableNode:
+		v, ok := vars[n.Ident[0]]
+		if !ok {
+			return templateValue{}
+		}
+		return c.resolve(v, n.Ident[1:], n.Position())
+	case *parse.ChainNode:
+		v := c.evalArg(n.Node, dot, vars)
+		return c.resolve(v, n.Field, n.Position())
+	case *parse.PipeNode:
+		return c.evalPipe(n, dot, copyVars(vars))
+	}
+	return templateValue{}
+}
+
+func (c *templateChecker) resolve(v templateValue, fields []string, pos parse.Pos) templateValue {
+	for _, field := range fields {
+		v = c.field(v, field, pos)
+	}
+	return v
+}
+
+func (c *templateChecker) field(v templateValue, name string, pos parse.Pos) templateValue {
+	switch v.kind {
+	case kindData:
+		switch {
+		case name == "Results":
+			return templateValue{kind: kindQueryResults}
+		case name == "ResultsByFile":
+			return templateValue{kind: kindResultsByFile}
+		case c.flags[name]:
+			return templateValue{}
+		case c.captures[name] != nil:
+			// results are also passed by query name when rendering a single file
+			return templateValue{kind: kindResult, query: name}
+		}
+		c.report(pos, "unknown field .%s, it is neither a flag, a query, Results nor ResultsByFile", name)
+
+	case kindQueryResults:
+		if c.captures[name] != nil {
+			return templateValue{kind: kindResult, query: name}
+		}
+		c.report(pos, "unknown query %s", name)
+
+	case kindResult:
+		switch name {
+		case "Matches":
+			return templateValue{kind: kindMatches, query: v.query}
+		case "QueryName":
+			return templateValue{}
+		}
+		c.report(pos, "unknown field .%s of query results, expected Matches or QueryName", name)
+
+	case kindMatch:
+		if v.query != "" {
+			if c.captures[v.query][name] {
+				c.result.UsedCaptures[v.query][name] = true
+				return templateValue{kind: kindCapture}
+			}
+			c.report(pos, "query %s has no capture @%s", v.query, name)
+			return templateValue{}
+		}
+		found := false
+		for query, captures := range c.captures {
+			if captures[name] {
+				c.result.UsedCaptures[query][name] = true
+				found = true
+			}
+		}
+		if found {
+			return templateValue{kind: kindCapture}
+		}
+		c.report(pos, "no query has a capture @%s", name)
+
+	case kindCapture:
+		if !captureFields[name] {
+			c.report(pos, "unknown field .%s of capture", name)
+		}
+	}
+
+	return templateValue{}
+}
+
+// elementOf returns the value of the elements when ranging over v.
+func (c *templateChecker) elementOf(v templateValue) templateValue {
+	switch v.kind {
+	case kindResultsByFile:
+		return templateValue{kind: kindQueryResults}
+	case kindQueryResults:
+		return templateValue{kind: kindResult}
+	case kindMatches:
+		return templateValue{kind: kindMatch, query: v.query}
+	case kindMatch:
+		// ranging over all the captures of a match uses all of them
+		if v.query != "" {
+			for capture := range c.captures[v.query] {
+				c.result.UsedCaptures[v.query][capture] = true
+			}
+		}
+		return templateValue{kind: kindCapture}
+	}
+	return templateValue{}
+}
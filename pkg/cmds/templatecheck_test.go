@@ -0,0 +1,80 @@
+package cmds
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+)
+
+const brokenTemplateCommand = `name: functions
+language: go
+flags:
+  - name: verbose
+    type: bool
+queries:
+  - name: functions
+    query: |
+      (function_declaration name: (identifier) @name)
+template: |
+  {{ range .ResultsByFile }}{{ range .functions.Matches }}{{ .nam.Text }}{{ end }}{{ end }}
+  {{ if .verbos }}verbose{{ end }}
+`
+
+func TestOakCommandLoaderTemplates(t *testing.T) {
+	f := fstest.MapFS{
+		"broken.yaml": {Data: []byte(brokenTemplateCommand)},
+		"valid.yaml": {Data: []byte(strings.ReplaceAll(
+			strings.ReplaceAll(brokenTemplateCommand, ".nam.", ".name."), ".verbos ", ".verbose "))},
+	}
+
+	tests := []struct {
+		name     string
+		loader   *OakCommandLoader
+		file     string
+		ok       bool
+		messages []string
+	}{
+		{name: "not checked", loader: &OakCommandLoader{}, file: "broken.yaml", ok: true},
+		{name: "checked", loader: &OakCommandLoader{CheckTemplates: true}, file: "broken.yaml", ok: true},
+		{
+			name:   "strict",
+			loader: &OakCommandLoader{Strict: true},
+			file:   "broken.yaml",
+			ok:     false,
+			messages: []string{
+				"broken.yaml:11:66: query functions has no capture @nam",
+				"broken.yaml:12:9: unknown field .verbos",
+			},
+		},
+		{name: "strict, valid command", loader: &OakCommandLoader{Strict: true}, file: "valid.yaml", ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := tt.loader.LoadCommands(f, tt.file, []glazed_cmds.CommandDescriptionOption{}, []alias.Option{})
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, expected ok %v", err, tt.ok)
+			}
+			if tt.ok {
+				if len(commands) != 1 {
+					t.Errorf("expected 1 command, got %d", len(commands))
+				}
+				if len(tt.loader.TemplateErrors()) != 0 {
+					t.Errorf("unexpected template errors %v", tt.loader.TemplateErrors())
+				}
+				return
+			}
+			if len(tt.loader.TemplateErrors()) != 1 {
+				t.Errorf("expected the error to be kept, got %v", tt.loader.TemplateErrors())
+			}
+			for _, message := range tt.messages {
+				if !strings.Contains(err.Error(), message) {
+					t.Errorf("missing %q in %q", message, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymanbagabas/go-udiff"
+	"github.com/go-go-golems/oak/pkg"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// CommandTest is a test case listed in the tests section of an oak command
+// file. It runs the command on an input file (or inline source) with the
+// given flags, and compares the rendered output and/or the captures to the
+// expected ones.
+//
+//	tests:
+//	  - name: public constants
+//	    source: |
+//	      package foo
+//	      const Foo = "foo"
+//	    flags:
+//	      only_public: true
+//	    output: |
+//	      const Foo = "foo"
+type CommandTest struct {
+	Name string `yaml:"name"`
+	// File is the input file, relative to the command file. When Source is
+	// set, it is only used as the file name passed to the template.
+	File string `yaml:"file,omitempty"`
+	// Source is the inline source code to run the command on
+	Source string `yaml:"source,omitempty"`
+	// Flags are the flag values to run the command with
+	Flags map[string]interface{} `yaml:"flags,omitempty"`
+
+	// Output is the expected rendered output
+	Output *string `yaml:"output,omitempty"`
+	// Golden is a file, relative to the command file, containing the expected
+	// rendered output
+	Golden string `yaml:"golden,omitempty"`
+	// Captures are the expected captured texts, by query name, then for each
+	// match by capture name
+	Captures map[string][]map[string]string `yaml:"captures,omitempty"`
+}
+
+// CommandTestResult is the result of running a single CommandTest.
+type CommandTestResult struct {
+	// File is the command file the test is defined in
+	File string
+	Name string
+	// Diff is a unified diff between the expected and actual output and
+	// captures. It is empty if the test passed.
+	Diff string
+	// Err is set if the test could not be run
+	Err error
+	// Updated is true if the expectations of the test have been rewritten
+	Updated bool
+}
+
+func (r *CommandTestResult) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// RunCommandTests runs the tests of the given command file. Files without a
+// tests section return no results.
+//
+// If update is true, the expected output and captures of failing tests are
+// replaced with the actual ones, either in the command file itself or in
+// their golden file. Tests without any expectation get their output (or
+// their captures, for commands without a template) filled in.
+func RunCommandTests(ctx context.Context, fileName string, update bool) ([]*CommandTestResult, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", fileName)
+	}
+
+	ocd := &OakCommandDescription{}
+	err = yaml.Unmarshal(b, ocd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse command file %s", fileName)
+	}
+
+	dir := filepath.Dir(fileName)
+	ret := []*CommandTestResult{}
+	updateFile := false
+	updated := make([]bool, len(ocd.Tests))
+	for i, test := range ocd.Tests {
+		result := &CommandTestResult{
+			File: fileName,
+			Name: test.Name,
+		}
+		updated[i] = runCommandTest(ctx, b, dir, test, update, result)
+		updateFile = updateFile || updated[i]
+		ret = append(ret, result)
+	}
+
+	if updateFile {
+		b, err = updateCommandTests(b, ocd.Tests, updated)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not update tests in %s", fileName)
+		}
+		err = os.WriteFile(fileName, b, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// runCommandTest runs a single test and fills in result. It returns true if
+// the inline expectations of the test have been updated, and the command
+// file needs to be rewritten.
+func runCommandTest(
+	ctx context.Context,
+	commandYAML []byte,
+	dir string,
+	test *CommandTest,
+	update bool,
+	result *CommandTestResult,
+) bool {
+	output, captures, err := runTestCommand(ctx, commandYAML, dir, test)
+	if err != nil {
+		result.Err = err
+		return false
+	}
+
+	hasTemplate := output != ""
+	if test.Output == nil && test.Golden == "" && test.Captures == nil {
+		if !update {
+			result.Err = errors.New("test has no expected output or captures")
+			return false
+		}
+		if hasTemplate {
+			test.Output = &output
+		} else {
+			test.Captures = captures
+		}
+		result.Updated = true
+		return true
+	}
+
+	updateFile := false
+	var diffs []string
+
+	if test.Output != nil || test.Golden != "" {
+		if !hasTemplate {
+			result.Err = errors.New("command has no template, only captures can be tested")
+			return false
+		}
+	}
+
+	if test.Output != nil {
+		expected := normalizeOutput(*test.Output)
+		if expected != output {
+			diffs = append(diffs, udiff.Unified("expected output", "actual output", expected, output))
+			if update {
+				test.Output = &output
+				result.Updated = true
+				updateFile = true
+			}
+		}
+	}
+
+	if test.Golden != "" {
+		golden := filepath.Join(dir, test.Golden)
+		b, err := os.ReadFile(golden)
+		if err != nil && !(update && os.IsNotExist(err)) {
+			result.Err = errors.Wrapf(err, "could not read golden file %s", golden)
+			return false
+		}
+		expected := normalizeOutput(string(b))
+		if expected != output {
+			diffs = append(diffs, udiff.Unified(golden, "actual output", expected, output))
+			if update {
+				err = os.WriteFile(golden, []byte(output), 0644)
+				if err != nil {
+					result.Err = err
+					return false
+				}
+				result.Updated = true
+			}
+		}
+	}
+
+	if test.Captures != nil {
+		expected, err := capturesToYAML(test.Captures)
+		if err != nil {
+			result.Err = err
+			return false
+		}
+		actual, err := capturesToYAML(captures)
+		if err != nil {
+			result.Err = err
+			return false
+		}
+		if expected != actual {
+			diffs = append(diffs, udiff.Unified("expected captures", "actual captures", expected, actual))
+			if update {
+				test.Captures = captures
+				result.Updated = true
+				updateFile = true
+			}
+		}
+	}
+
+	result.Diff = strings.Join(diffs, "\n")
+	return updateFile
+}
+
+// runTestCommand loads a fresh command from commandYAML, since rendering the
+// queries modifies the command, and runs it for the given test. It returns
+// the rendered output (empty if the command has no template) and the
+// captured texts.
+func runTestCommand(
+	ctx context.Context,
+	commandYAML []byte,
+	dir string,
+	test *CommandTest,
+) (string, map[string][]map[string]string, error) {
+	cmds_, err := (&OakCommandLoader{}).loadCommandFromReader(bytes.NewReader(commandYAML), nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	oc := cmds_[0].(*OakWriterCommand).OakCommand
+
+	data, err := oc.ParseFlagValues(test.Flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var source []byte
+	fileName := test.File
+	switch {
+	case test.Source != "":
+		source = []byte(test.Source)
+		if fileName == "" {
+			fileName = "source"
+		}
+	case test.File != "":
+		source, err = os.ReadFile(filepath.Join(dir, test.File))
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "could not read input file %s", test.File)
+		}
+	default:
+		return "", nil, errors.New("test has neither a file nor a source")
+	}
+
+	if oc.Language == "" {
+		oc.Language, err = pkg.FileNameToLanguageName(fileName)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	err = oc.RenderQueriesWithData(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	results, err := oc.ExecuteQueriesOnSource(ctx, source)
+	if err != nil {
+		return "", nil, err
+	}
+
+	captures := map[string][]map[string]string{}
+	for queryName, result := range results {
+		matches := []map[string]string{}
+		for _, match := range result.Matches {
+			m := map[string]string{}
+			for captureName, capture := range match {
+				m[captureName] = capture.Text
+			}
+			matches = append(matches, m)
+		}
+		captures[queryName] = matches
+	}
+
+	if oc.Template == "" {
+		return "", captures, nil
+	}
+
+	output, err := oc.RenderResultsByFile(data, map[string]tree_sitter.QueryResults{
+		fileName: results,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return output, captures, nil
+}
+
+// normalizeOutput trims the expected output the same way the rendered output
+// of a command is trimmed.
+func normalizeOutput(s string) string {
+	return strings.TrimSpace(s) + "\n"
+}
+
+func capturesToYAML(captures map[string][]map[string]string) (string, error) {
+	b, err := yaml.Marshal(captures)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// updateCommandTests replaces the output and captures of the tests in the
+// given command YAML. Only the lines of the updated tests are rewritten, so
+// that the formatting of the rest of the file is kept as is.
+func updateCommandTests(commandYAML []byte, tests []*CommandTest, updated []bool) ([]byte, error) {
+	doc := &yaml.Node{}
+	err := yaml.Unmarshal(commandYAML, doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("command file is not a mapping")
+	}
+	root := doc.Content[0]
+
+	testsNode := mappingValue(root, "tests")
+	if testsNode == nil || testsNode.Kind != yaml.SequenceNode || len(testsNode.Content) != len(tests) {
+		return nil, errors.New("could not find tests section")
+	}
+
+	lines := strings.SplitAfter(string(commandYAML), "\n")
+
+	// the tests section ends at the next top-level key, or the end of the file
+	sectionEnd := len(lines)
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if key.Line > testsNode.Line && key.Line-1 < sectionEnd {
+			sectionEnd = key.Line - 1
+		}
+	}
+
+	// replace from the last test, so that line numbers of earlier tests stay valid
+	for i := len(tests) - 1; i >= 0; i-- {
+		if !updated[i] {
+			continue
+		}
+		test, testNode := tests[i], testsNode.Content[i]
+
+		if test.Output != nil {
+			setMappingValue(testNode, "output", &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!str",
+				Style: yaml.LiteralStyle,
+				Value: *test.Output,
+			})
+		}
+		if test.Captures != nil {
+			capturesNode := &yaml.Node{}
+			err = capturesNode.Encode(test.Captures)
+			if err != nil {
+				return nil, err
+			}
+			setMappingValue(testNode, "captures", capturesNode)
+		}
+
+		start := testNode.Line - 1
+		end := sectionEnd
+		if i+1 < len(tests) {
+			end = testsNode.Content[i+1].Line - 1
+		}
+		// keep blank lines and comments between tests
+		for end > start+1 && isBlankOrComment(lines[end-1]) {
+			end--
+		}
+
+		// comments around the test are kept in lines, don't output them twice
+		testNode.HeadComment = ""
+		testNode.FootComment = ""
+		for _, n := range testNode.Content[max(len(testNode.Content)-2, 0):] {
+			n.FootComment = ""
+		}
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{testNode}})
+		if err != nil {
+			return nil, err
+		}
+		err = enc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// the "- " of the item is 2 columns before its first key
+		indent := strings.Repeat(" ", testNode.Column-3)
+		replacement := []string{}
+		for _, line := range strings.SplitAfter(buf.String(), "\n") {
+			if line == "" {
+				continue
+			}
+			if strings.TrimSpace(line) == "" {
+				replacement = append(replacement, line)
+				continue
+			}
+			replacement = append(replacement, indent+line)
+		}
+
+		lines = append(lines[:start], append(replacement, lines[end:]...)...)
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
+
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(n *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = value
+			return
+		}
+	}
+	n.Content = append(n.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
@@ -0,0 +1,95 @@
+package cmds
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testedCommand = `name: functions
+short: List functions
+language: go
+flags:
+  - name: name
+    type: string
+    default: ""
+queries:
+  - name: functions
+    query: |
+      (function_declaration name: (identifier) @name
+        {{ if .name }}(#eq? @name "{{ .name }}"){{ end }})
+template: |
+  {{ range $file, $results := .ResultsByFile -}}
+  {{ range $results.functions.Matches -}}
+  {{ .name.Text }}
+  {{ end -}}
+  {{ end -}}
+tests:
+  - name: all functions
+    source: |
+      package a
+      func Foo() {}
+      func Bar() {}
+    output: |
+      Foo
+      Bar
+  - name: filtered by flag
+    source: |
+      package a
+      func Foo() {}
+      func Bar() {}
+    flags:
+      name: Bar
+    output: |
+      Foo
+  - name: no expectation
+    source: |
+      package a
+      func Baz() {}
+`
+
+func TestRunCommandTests(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "functions.yaml")
+	if err := os.WriteFile(fileName, []byte(testedCommand), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunCommandTests(context.Background(), fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !results[0].Passed() {
+		t.Errorf("expected %s to pass, got %v %s", results[0].Name, results[0].Err, results[0].Diff)
+	}
+	if results[1].Passed() || !strings.Contains(results[1].Diff, "-Foo") || !strings.Contains(results[1].Diff, "+Bar") {
+		t.Errorf("expected %s to fail with a diff, got %v %q", results[1].Name, results[1].Err, results[1].Diff)
+	}
+
+	// updating rewrites the failing and missing expectations
+	_, err = RunCommandTests(context.Background(), fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = RunCommandTests(context.Background(), fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if !result.Passed() {
+			t.Errorf("expected %s to pass after updating, got %v %s", result.Name, result.Err, result.Diff)
+		}
+	}
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "short: List functions\n") || !strings.Contains(string(b), "Baz") {
+		t.Errorf("unexpected updated file:\n%s", b)
+	}
+}
@@ -0,0 +1,346 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+	"gopkg.in/yaml.v3"
+)
+
+// watchDebounce is how long to wait for more file events before rerunning
+// the command, since editors often write files in several steps.
+const watchDebounce = 100 * time.Millisecond
+
+// parsedFile keeps the source and tree of a file around, so that it can be
+// re-parsed incrementally when it changes. tree is nil when the results were
+// read from the cache.
+type parsedFile struct {
+	source  []byte
+	tree    *sitter.Tree
+	results tree_sitter.QueryResults
+}
+
+// ResultsRenderer renders the results of a watch iteration.
+type ResultsRenderer func(resultsByFile map[string]tree_sitter.QueryResults) error
+
+// SourceCollector returns the list of files the command runs on. It is
+// called on every watch iteration, so that new files are picked up.
+type SourceCollector func() ([]string, error)
+
+// Watch runs the command on the files returned by collect, renders the
+// results, and then keeps rerunning it whenever one of the files, or the
+// YAML file the command was loaded from, changes.
+//
+// Only the modified files are re-parsed, incrementally, using their previous
+// tree. When the command file changes, its queries and template are reloaded
+// and the queries rerun on all files. Flags can't be changed by reloading.
+//
+// Errors happening while rerunning are logged, and watching continues until
+// ctx is cancelled.
+func (oc *OakCommand) Watch(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	collect SourceCollector,
+	render ResultsRenderer,
+) error {
+	return oc.watch(ctx, parsedLayers, collect, func(files map[string]*parsedFile) error {
+		return render(resultsOf(files))
+	})
+}
+
+// watch implements Watch, rendering the parsed files instead of only their
+// results, so that their trees can be reused.
+func (oc *OakCommand) watch(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	collect SourceCollector,
+	render func(files map[string]*parsedFile) error,
+) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "could not create file watcher")
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	commandFile := oc.commandFile()
+	// files and sources are keyed by absolute path, so that they can be
+	// looked up with the names of file events
+	files := map[string]*parsedFile{}
+	sources := map[string]bool{}
+	watchedDirectories := map[string]bool{}
+
+	watchDirectory := func(dir string) {
+		if watchedDirectories[dir] {
+			return
+		}
+		if err := watcher.Add(dir); err != nil {
+			log.Warn().Err(err).Str("directory", dir).Msg("could not watch directory")
+			return
+		}
+		watchedDirectories[dir] = true
+	}
+
+	run := func(changed map[string]bool, reloadQueries bool) error {
+		fileNames, err := collect()
+		if err != nil {
+			return err
+		}
+
+		lang, err := oc.GetLanguage()
+		if err != nil {
+			return err
+		}
+
+		parsedFiles := map[string]*parsedFile{}
+		sources = map[string]bool{}
+		for _, fileName := range fileNames {
+			key := watchPath(fileName)
+			sources[key] = true
+			watchDirectory(filepath.Dir(key))
+
+			pf, ok := files[key]
+			if !ok || changed[key] {
+				pf, err = oc.parseFile(ctx, fileName, pf)
+				if err != nil {
+					return err
+				}
+				pf.results = nil
+				files[key] = pf
+			}
+
+			if pf.results == nil || reloadQueries {
+				pf.results, err = tree_sitter.ExecuteQueries(lang, pf.tree.RootNode(), oc.Queries, pf.source)
+				if err != nil {
+					return errors.Wrapf(err, "could not execute queries for file %s", fileName)
+				}
+			}
+			parsedFiles[fileName] = pf
+		}
+
+		// forget about files that have been removed
+		for key := range files {
+			if !sources[key] {
+				delete(files, key)
+			}
+		}
+
+		return render(parsedFiles)
+	}
+
+	if commandFile != "" {
+		watchDirectory(filepath.Dir(watchPath(commandFile)))
+	}
+
+	err = run(nil, false)
+	if err != nil {
+		return err
+	}
+
+	changed := map[string]bool{}
+	reloadQueries := false
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn().Err(err).Msg("file watcher error")
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) &&
+				!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			fileName := watchPath(event.Name)
+			switch {
+			case commandFile != "" && sameFile(fileName, commandFile):
+				reloadQueries = true
+			case sources[fileName]:
+				changed[fileName] = true
+			case isDirectory(fileName):
+				// watch new directories, in case we are recursing
+				watchDirectory(fileName)
+				changed[fileName] = true
+			case event.Has(fsnotify.Create) && isNewSource(collect, fileName):
+				changed[fileName] = true
+			default:
+				// not a source, for example output redirected into a
+				// watched directory
+				continue
+			}
+			timer = time.After(watchDebounce)
+
+		case <-timer:
+			timer = nil
+
+			if reloadQueries {
+				language := oc.Language
+				err := oc.reload(commandFile, parsedLayers)
+				if err != nil {
+					log.Error().Err(err).Str("file", commandFile).Msg("could not reload command")
+					reloadQueries = false
+					continue
+				}
+				// trees of another language can't be reused
+				if oc.Language != language {
+					files = map[string]*parsedFile{}
+				}
+			}
+
+			err := run(changed, reloadQueries)
+			if err != nil {
+				log.Error().Err(err).Msg("could not run command")
+			}
+			changed = map[string]bool{}
+			reloadQueries = false
+		}
+	}
+}
+
+// commandFile returns the YAML file the command was loaded from, if it was
+// loaded from disk.
+func (oc *OakCommand) commandFile() string {
+	if oc.CommandDescription == nil {
+		return ""
+	}
+	source := strings.TrimPrefix(oc.Source, "file:")
+	if source == "" || !filepath.IsAbs(source) {
+		return ""
+	}
+	if _, err := os.Stat(source); err != nil {
+		return ""
+	}
+	return filepath.Clean(source)
+}
+
+// reload replaces the queries, template and language of the command with
+// those in the given file, and renders the queries again. The command is
+// left untouched if the file can't be loaded, so that watching goes on with
+// the last working version.
+func (oc *OakCommand) reload(fileName string, parsedLayers *layers.ParsedLayers) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	ocd := &OakCommandDescription{}
+	err = yaml.NewDecoder(f).Decode(ocd)
+	if err != nil {
+		return err
+	}
+
+	// RenderQueries modifies the queries in place, so render them on a
+	// separate command
+	rendered := &OakCommand{Queries: ocd.Queries}
+	err = rendered.RenderQueries(parsedLayers)
+	if err != nil {
+		return err
+	}
+
+	oc.Queries = rendered.Queries
+	oc.Template = ocd.Template
+	if ocd.Language != oc.Language {
+		oc.Language = ocd.Language
+		oc.SitterLanguage = nil
+	}
+
+	return nil
+}
+
+// parseFile reads and parses the given file. If previous is not nil, the
+// file is re-parsed incrementally, reusing the previous tree.
+func (oc *OakCommand) parseFile(ctx context.Context, fileName string, previous *parsedFile) (*parsedFile, error) {
+	source, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", fileName)
+	}
+
+	var oldTree *sitter.Tree
+	if previous != nil && previous.tree != nil {
+		if bytes.Equal(previous.source, source) {
+			return previous, nil
+		}
+		oldTree = previous.tree
+		oldTree.Edit(tree_sitter.ComputeEdit(previous.source, source))
+	}
+
+	tree, err := oc.Parse(ctx, oldTree, source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse file %s", fileName)
+	}
+
+	return &parsedFile{
+		source: source,
+		tree:   tree,
+	}, nil
+}
+
+// watchPath returns the absolute, cleaned path of fileName, so that the
+// paths given on the command line and those of file events can be
+// compared.
+func watchPath(fileName string) string {
+	if abs, err := filepath.Abs(fileName); err == nil {
+		return abs
+	}
+	return filepath.Clean(fileName)
+}
+
+func isDirectory(fileName string) bool {
+	fi, err := os.Stat(fileName)
+	return err == nil && fi.IsDir()
+}
+
+// isNewSource returns true if the created file fileName (an absolute path)
+// is one of the files the command runs on, for example because it matches
+// the globs of a directory given on the command line.
+func isNewSource(collect SourceCollector, fileName string) bool {
+	fileNames, err := collect()
+	if err != nil {
+		return false
+	}
+	for _, f := range fileNames {
+		if watchPath(f) == fileName {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
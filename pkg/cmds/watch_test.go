@@ -0,0 +1,91 @@
+package cmds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+)
+
+func TestReload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "command.yaml")
+	oc := &OakCommand{
+		Language: "go",
+		Queries: []tree_sitter.SitterQuery{
+			{Name: "functions", Query: "(function_declaration) @function", Rendered: true},
+		},
+		Template: "old",
+	}
+
+	tests := []struct {
+		name     string
+		yaml     string
+		ok       bool
+		query    string
+		template string
+	}{
+		{
+			name: "template error",
+			yaml: `language: go
+queries:
+  - name: functions
+    query: "{{ if .foo }}(function_declaration) @function"
+template: new
+`,
+			ok:       false,
+			query:    "(function_declaration) @function",
+			template: "old",
+		},
+		{
+			name:     "invalid yaml",
+			yaml:     "queries: [",
+			ok:       false,
+			query:    "(function_declaration) @function",
+			template: "old",
+		},
+		{
+			name: "valid command",
+			yaml: `language: go
+queries:
+  - name: functions
+    query: "(function_declaration name: (identifier) @name)"
+template: new
+`,
+			ok:       true,
+			query:    "(function_declaration name: (identifier) @name)",
+			template: "new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(fileName, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := oc.reload(fileName, layers.NewParsedLayers())
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, expected ok %v", err, tt.ok)
+			}
+			if len(oc.Queries) != 1 || oc.Queries[0].Query != tt.query || !oc.Queries[0].Rendered {
+				t.Errorf("got queries %v, expected %q", oc.Queries, tt.query)
+			}
+			if oc.Template != tt.template {
+				t.Errorf("got template %q, expected %q", oc.Template, tt.template)
+			}
+		})
+	}
+}
+
+func TestWatchPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	if watchPath("./x.go") != filepath.Join(dir, "x.go") {
+		t.Errorf("got %s for ./x.go", watchPath("./x.go"))
+	}
+	if watchPath("sub/../x.go") != watchPath(filepath.Join(dir, "x.go")) {
+		t.Errorf("got %s for sub/../x.go", watchPath("sub/../x.go"))
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
+	"fmt"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/helpers/templating"
-	"github.com/go-go-golems/oak/pkg"
 	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -60,32 +63,89 @@ func (oc *OakWriterCommand) RunIntoWriter(
 		return nil
 	}
 
-	sources_ := s.Sources
-
-	glob_ := ss.Glob
+	collect := func() ([]string, error) {
+		return oc.collectSources(s, ss)
+	}
 
-	if ss.Recurse && len(glob_) == 0 {
-		// use standard globs for the language of the command
-		glob_, err = pkg.GetLanguageGlobs(oc.Language)
-		if err != nil {
-			return err
+	if ss.Watch {
+		if ss.UpdateBaseline {
+			return errors.New("--update-baseline can't be used with --watch")
+		}
+		isTerminal := false
+		if f, ok := w.(*os.File); ok {
+			isTerminal = isatty.IsTerminal(f.Fd())
 		}
+		return oc.watch(ctx, parsedLayers, collect, func(files map[string]*parsedFile) error {
+			if isTerminal {
+				// clear the screen before rendering the new output
+				_, _ = fmt.Fprint(w, "\033[H\033[2J")
+			}
+			resultsByFile, _, err := oc.applyBaseline(ctx, ss, files)
+			if err != nil {
+				return err
+			}
+			return oc.renderResults(parsedLayers, resultsByFile, w)
+		})
 	}
-	sources_, err = collectSources(sources_, glob_)
+
+	sources_, err := collect()
 	if err != nil {
 		return err
 	}
 
-	resultsByFile, err := oc.GetResultsByFile(ctx, sources_)
+	files, err := oc.executeFiles(ctx, sources_)
 	if err != nil {
 		return err
 	}
 
-	tmpl, err := templating.CreateTemplate("oak").Parse(oc.Template)
+	resultsByFile, newMatches, err := oc.applyBaseline(ctx, ss, files)
+	if err != nil {
+		return err
+	}
+
+	err = oc.renderResults(parsedLayers, resultsByFile, w)
 	if err != nil {
 		return err
 	}
 
+	if newMatches > 0 {
+		return &NewMatchesError{Count: newMatches, Baseline: ss.Baseline}
+	}
+	return nil
+}
+
+// renderResults renders the template of the command with the results of all
+// files, and writes the output to w.
+func (oc *OakWriterCommand) renderResults(
+	parsedLayers *layers.ParsedLayers,
+	resultsByFile map[string]tree_sitter.QueryResults,
+	w io.Writer,
+) error {
+	s_, err := oc.RenderResultsByFile(parsedLayers.GetDataMap(), resultsByFile)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(([]byte)(s_))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RenderResultsByFile renders the template of the command with the given data
+// (usually the parsed flags), the results by file as ResultsByFile and the
+// results of all files merged as Results.
+func (oc *OakCommand) RenderResultsByFile(
+	data map[string]interface{},
+	resultsByFile map[string]tree_sitter.QueryResults,
+) (string, error) {
+	tmpl, err := templating.CreateTemplate("oak").Parse(oc.Template)
+	if err != nil {
+		return "", err
+	}
+
 	allResults := tree_sitter.QueryResults{}
 
 	for _, fileResults := range resultsByFile {
@@ -100,24 +160,18 @@ func (oc *OakWriterCommand) RunIntoWriter(
 		}
 	}
 
-	data := parsedLayers.GetDataMap()
 	data["ResultsByFile"] = resultsByFile
 	data["Results"] = allResults
 
 	var buf bytes.Buffer
 	err = tmpl.Execute(&buf, data)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	s_ := buf.String()
 	// trim left and right
 	s_ = strings.TrimSpace(s_) + "\n"
 
-	_, err = w.Write(([]byte)(s_))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s_, nil
 }
@@ -0,0 +1,223 @@
+// Package deps extracts the imports of source files with a tree-sitter query
+// per language, resolves them to the files and packages of the repository
+// where possible, and finds the import cycles.
+//
+// The queries capture the imported module, path or name as @import. PHP
+// group uses capture the common prefix as @prefix, PHP includes capture the
+// path expression as @path, and Rust `mod a;` declarations capture the
+// module name as @module.
+package deps
+
+import (
+	"context"
+	"embed"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cache"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+//go:embed queries/*.scm
+var queriesFS embed.FS
+
+// queries are the import queries, in queries/<language>.scm.
+var queries = tree_sitter.NewQueryRegistry(queriesFS, "queries", "import query", map[string]string{
+	"golang":     "go",
+	"typescript": "javascript",
+	"tsx":        "javascript",
+})
+
+// Kind tells what an import was resolved to.
+type Kind string
+
+const (
+	// KindLocal imports are resolved to a file or package of the
+	// repository.
+	KindLocal Kind = "local"
+	// KindExternal imports refer to a package that is not part of the
+	// repository, such as the standard library or a dependency.
+	KindExternal Kind = "external"
+	// KindUnresolved imports look local (relative paths, modules of the
+	// repository) but don't resolve to an existing file.
+	KindUnresolved Kind = "unresolved"
+)
+
+// Edge is an import.
+type Edge struct {
+	// From is the node of the importing file: its package directory for Go,
+	// the file itself for the other languages.
+	From string
+	// To is the node of the imported file or package for local imports,
+	// the package name for external imports, and the import itself for
+	// unresolved imports.
+	To   string
+	Kind Kind
+	// Import is the imported module, path or name, as written.
+	Import   string
+	File     string
+	Line     int
+	Language string
+}
+
+// HasLanguage returns true if imports can be extracted for the language.
+func HasLanguage(lang string) bool {
+	return queries.HasLanguage(lang)
+}
+
+// Query returns the import query of the language.
+func Query(lang string) (string, error) {
+	return queries.Query(lang)
+}
+
+// Collect extracts and resolves the imports of the given files, and of the
+// files in the given directories, which need to be inside root. Paths in
+// the edges are relative to root, with forward slashes. Edges are sorted by
+// file and line.
+func Collect(ctx context.Context, root string, sources []string) ([]*Edge, error) {
+	r, err := NewResolver(root)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := []*Edge{}
+	seen := map[string]bool{}
+	c := cache.Default()
+	for _, source := range sources {
+		err = pkg.WalkSourceFiles(source, HasLanguage, func(fileName string, lang string, info os.FileInfo) error {
+			rel, err := r.relativePath(fileName)
+			if err != nil {
+				return err
+			}
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+
+			imports, err := extract(ctx, c, fileName, lang)
+			if err != nil {
+				return err
+			}
+			for _, imp := range imports {
+				edges = append(edges, r.Resolve(rel, lang, imp)...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].File != edges[j].File {
+			return edges[i].File < edges[j].File
+		}
+		return edges[i].Line < edges[j].Line
+	})
+	return edges, nil
+}
+
+// Import is an import statement found by the query of a language.
+type Import struct {
+	// Capture is the name of the capture: import, path, module, or name
+	// for the names imported by Python from imports.
+	Capture string
+	// Text is the text of the capture, with the @prefix of PHP group uses
+	// and the @from module of Python from imports.
+	Text string
+	Line int
+}
+
+func extract(ctx context.Context, c *cache.Cache, fileName string, lang string) ([]Import, error) {
+	query, err := Query(lang)
+	if err != nil {
+		return nil, err
+	}
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	source, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", fileName)
+	}
+
+	parse := func() (*sitter.Tree, error) {
+		parser := sitter.NewParser()
+		defer parser.Close()
+		parser.SetLanguage(sitterLang)
+		tree, err := parser.ParseCtx(ctx, nil, source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse file %s", fileName)
+		}
+		return tree, nil
+	}
+	queries := []tree_sitter.SitterQuery{{Name: "imports", Query: query}}
+	results, err := c.ExecuteQueries(parse, lang, sitterLang, queries, source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not extract the imports of %s", fileName)
+	}
+
+	ret := []Import{}
+	result, ok := results["imports"]
+	if !ok {
+		return ret, nil
+	}
+	for _, match := range result.Matches {
+		for _, name := range []string{"import", "path", "module", "name"} {
+			capture, ok := match[name]
+			if !ok {
+				continue
+			}
+			text := capture.Text
+			if prefix, ok := match["prefix"]; ok {
+				text = prefix.Text + `\` + text
+			}
+			if from, ok := match["from"]; ok {
+				// from . import b is .b, from a import b is a.b
+				if strings.HasSuffix(from.Text, ".") {
+					text = from.Text + text
+				} else {
+					text = from.Text + "." + text
+				}
+			}
+			ret = append(ret, Import{Capture: name, Text: text, Line: int(capture.StartPoint.Row) + 1})
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Line < ret[j].Line
+	})
+	return ret, nil
+}
+
+// unquote removes the quotes of a string literal.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && strings.ContainsRune(`"'`+"`", rune(s[0])) && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// relativePath returns the path of fileName relative to the root, with
+// forward slashes.
+func (r *Resolver) relativePath(fileName string) (string, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.root, abs)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", errors.Errorf("%s is not inside the root %s", fileName, r.root)
+	}
+	return rel, nil
+}
@@ -0,0 +1,224 @@
+package deps
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// graph returns the deduplicated local edges as sorted adjacency lists.
+func graph(edges []*Edge) ([]string, map[string][]string) {
+	seen := map[[2]string]bool{}
+	adjacency := map[string][]string{}
+	nodes := map[string]bool{}
+	for _, e := range edges {
+		if e.Kind != KindLocal {
+			continue
+		}
+		nodes[e.From] = true
+		nodes[e.To] = true
+		key := [2]string{e.From, e.To}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		adjacency[e.From] = append(adjacency[e.From], e.To)
+	}
+
+	sortedNodes := make([]string, 0, len(nodes))
+	for n := range nodes {
+		sortedNodes = append(sortedNodes, n)
+	}
+	sort.Strings(sortedNodes)
+	for _, targets := range adjacency {
+		sort.Strings(targets)
+	}
+	return sortedNodes, adjacency
+}
+
+// Cycle is a set of nodes importing each other.
+type Cycle struct {
+	// Nodes are the nodes of the strongly connected component, sorted.
+	Nodes []string
+	// Path is an example cycle going through all the nodes, starting and
+	// ending with the first node.
+	Path []string
+}
+
+// Cycles returns the import cycles between the local edges: the strongly
+// connected components of the import graph with more than one node, and
+// the nodes importing themselves. Cycles are sorted by first node.
+func Cycles(edges []*Edge) []*Cycle {
+	nodes, adjacency := graph(edges)
+
+	// Tarjan's strongly connected components algorithm
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	components := [][]string{}
+
+	var visit func(n string)
+	visit = func(n string) {
+		index[n] = len(index)
+		lowLink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, m := range adjacency[n] {
+			if _, ok := index[m]; !ok {
+				visit(m)
+				lowLink[n] = min(lowLink[n], lowLink[m])
+			} else if onStack[m] {
+				lowLink[n] = min(lowLink[n], index[m])
+			}
+		}
+
+		if lowLink[n] == index[n] {
+			component := []string{}
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				component = append(component, m)
+				if m == n {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+
+	ret := []*Cycle{}
+	for _, component := range components {
+		sort.Strings(component)
+		if len(component) == 1 && !contains(adjacency[component[0]], component[0]) {
+			continue
+		}
+		ret = append(ret, &Cycle{
+			Nodes: component,
+			Path:  cyclePath(component, adjacency),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Nodes[0] < ret[j].Nodes[0]
+	})
+	return ret
+}
+
+// cyclePath returns a shortest cycle from the first node of a strongly
+// connected component back to itself.
+func cyclePath(component []string, adjacency map[string][]string) []string {
+	start := component[0]
+	inComponent := map[string]bool{}
+	for _, n := range component {
+		inComponent[n] = true
+	}
+
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range adjacency[n] {
+			if !inComponent[m] {
+				continue
+			}
+			if m == start {
+				path := []string{start}
+				for p := n; p != start; p = previous[p] {
+					path = append(path, p)
+				}
+				path = append(path, start)
+				// the path was built backwards
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, ok := previous[m]; !ok {
+				previous[m] = n
+				queue = append(queue, m)
+			}
+		}
+	}
+	return component
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteDOT writes the import graph in the DOT language of graphviz. Local
+// edges are drawn between files (or packages), and, if withExternal is
+// true, external packages are drawn as gray boxes. Unresolved imports are
+// left out.
+func WriteDOT(w io.Writer, edges []*Edge, withExternal bool) error {
+	nodes, adjacency := graph(edges)
+
+	externals := map[string][]string{}
+	externalNodes := map[string]bool{}
+	if withExternal {
+		seen := map[[2]string]bool{}
+		for _, e := range edges {
+			key := [2]string{e.From, e.To}
+			if e.Kind != KindExternal || seen[key] {
+				continue
+			}
+			seen[key] = true
+			externals[e.From] = append(externals[e.From], e.To)
+			externalNodes[e.To] = true
+			if !contains(nodes, e.From) {
+				nodes = append(nodes, e.From)
+			}
+		}
+		sort.Strings(nodes)
+		for _, targets := range externals {
+			sort.Strings(targets)
+		}
+	}
+
+	p := func(format string, args ...interface{}) error {
+		_, err := fmt.Fprintf(w, format, args...)
+		return err
+	}
+
+	if err := p("digraph imports {\n  rankdir=LR;\n  node [shape=box, fontname=\"monospace\"];\n"); err != nil {
+		return err
+	}
+	sortedExternals := make([]string, 0, len(externalNodes))
+	for n := range externalNodes {
+		sortedExternals = append(sortedExternals, n)
+	}
+	sort.Strings(sortedExternals)
+	for _, n := range sortedExternals {
+		if err := p("  %s [label=%s, style=filled, fillcolor=lightgray];\n",
+			strconv.Quote("ext:"+n), strconv.Quote(n)); err != nil {
+			return err
+		}
+	}
+	for _, n := range nodes {
+		for _, m := range adjacency[n] {
+			if err := p("  %s -> %s;\n", strconv.Quote(n), strconv.Quote(m)); err != nil {
+				return err
+			}
+		}
+		for _, m := range externals[n] {
+			if err := p("  %s -> %s;\n", strconv.Quote(n), strconv.Quote("ext:"+m)); err != nil {
+				return err
+			}
+		}
+	}
+	return p("}\n")
+}
@@ -0,0 +1,2 @@
+; import "fmt", import x "example.com/m/x", and the specs of import blocks
+(import_spec path: (_) @import)
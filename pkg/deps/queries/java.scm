@@ -0,0 +1,3 @@
+; import a.b.C, import a.b.* and import static a.b.C.f
+(import_declaration (scoped_identifier) @import)
+(import_declaration (identifier) @import)
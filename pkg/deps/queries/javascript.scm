@@ -0,0 +1,13 @@
+; import ... from "x", import "x" and export ... from "x"
+(import_statement source: (string) @import)
+(export_statement source: (string) @import)
+
+; require("x")
+(call_expression
+  function: (identifier) @_require (#eq? @_require "require")
+  arguments: (arguments . (string) @import))
+
+; import("x")
+(call_expression
+  function: (import)
+  arguments: (arguments . (string) @import))
@@ -0,0 +1,14 @@
+; use A\B, use function A\f and use A\B as C
+(namespace_use_clause . (_) @import)
+
+; use A\{B, C}
+(namespace_use_declaration
+  (namespace_name) @prefix
+  (namespace_use_group (namespace_use_group_clause (namespace_name) @import)))
+
+; require, require_once, include and include_once, with a string or an
+; expression such as __DIR__ . '/x.php'
+(require_expression (_) @path)
+(require_once_expression (_) @path)
+(include_expression (_) @path)
+(include_once_expression (_) @path)
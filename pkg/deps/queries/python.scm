@@ -0,0 +1,11 @@
+; import a, b.c and import a as b
+(import_statement (dotted_name) @import)
+(import_statement (aliased_import name: (dotted_name) @import))
+
+; from a import b and from ..a import b
+(import_from_statement module_name: (_) @import)
+
+; the names of from a import b, which can be submodules of a
+(import_from_statement
+  module_name: (_) @from
+  name: [(dotted_name) @name (aliased_import name: (dotted_name) @name)])
@@ -0,0 +1,5 @@
+; use a::b, use a::{b, c} and use a::b as c
+(use_declaration argument: (_) @import)
+
+; mod a; (modules declared in another file)
+(mod_item !body name: (identifier) @module)
@@ -0,0 +1,773 @@
+package deps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/rs/zerolog/log"
+)
+
+// Resolver resolves imports to the files of a repository. It reads the
+// go.mod, tsconfig.json (or jsconfig.json), composer.json and Cargo.toml
+// files closest to the importing files.
+type Resolver struct {
+	root string
+
+	goModules  map[string]*goModule
+	tsConfigs  map[string]*tsConfig
+	composers  map[string]*composerConfig
+	cargoRoots map[string]string
+	// javaFiles maps the path suffixes of the java files of the repository
+	// (User.java, model/User.java, ...) to their paths.
+	javaFiles map[string][]string
+}
+
+// NewResolver creates a resolver for the repository in root.
+func NewResolver(root string) (*Resolver, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{
+		root:       abs,
+		goModules:  map[string]*goModule{},
+		tsConfigs:  map[string]*tsConfig{},
+		composers:  map[string]*composerConfig{},
+		cargoRoots: map[string]string{},
+	}, nil
+}
+
+// Node returns the node of a file in the import graph: its directory for
+// Go, where packages are directories, and the file itself otherwise.
+func Node(file string, lang string) string {
+	if lang == "go" || lang == "golang" {
+		return path.Dir(file)
+	}
+	return file
+}
+
+func (r *Resolver) abs(rel string) string {
+	return filepath.Join(r.root, filepath.FromSlash(rel))
+}
+
+func (r *Resolver) isFile(rel string) bool {
+	fi, err := os.Stat(r.abs(rel))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+func (r *Resolver) isDir(rel string) bool {
+	fi, err := os.Stat(r.abs(rel))
+	return err == nil && fi.IsDir()
+}
+
+// inside returns true if the cleaned relative path doesn't leave the root.
+func inside(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, "../") && !path.IsAbs(rel)
+}
+
+// firstFile returns the first of the candidates that is a file.
+func (r *Resolver) firstFile(candidates ...string) (string, bool) {
+	for _, c := range candidates {
+		c = path.Clean(c)
+		if inside(c) && r.isFile(c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// findUp returns the directory of the closest file named name, in dir or
+// one of its parents inside the root.
+func (r *Resolver) findUp(dir string, names ...string) (string, string, bool) {
+	for {
+		for _, name := range names {
+			if r.isFile(path.Join(dir, name)) {
+				return dir, name, true
+			}
+		}
+		if dir == "." || dir == "/" || dir == "" {
+			return "", "", false
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// Resolve returns the edges of an import of file.
+func (r *Resolver) Resolve(file string, lang string, imp Import) []*Edge {
+	edge := &Edge{
+		From:     Node(file, lang),
+		Import:   imp.Text,
+		File:     file,
+		Line:     imp.Line,
+		Language: lang,
+	}
+	// target is a file, or a package directory for Go
+	local := func(target string) []*Edge {
+		edge.Kind = KindLocal
+		edge.To = target
+		if lang != "go" && lang != "golang" {
+			edge.To = Node(target, lang)
+		}
+		// like from . import b in a package's __init__.py
+		if edge.To == edge.From {
+			return nil
+		}
+		return []*Edge{edge}
+	}
+	external := func(name string) []*Edge {
+		edge.Kind = KindExternal
+		edge.To = name
+		return []*Edge{edge}
+	}
+	unresolved := func() []*Edge {
+		edge.Kind = KindUnresolved
+		edge.To = edge.Import
+		return []*Edge{edge}
+	}
+
+	switch lang {
+	case "go", "golang":
+		edge.Import = unquote(imp.Text)
+		if target, ok, isLocal := r.resolveGo(file, edge.Import); ok {
+			return local(target)
+		} else if isLocal {
+			return unresolved()
+		}
+		return external(edge.Import)
+
+	case "javascript", "typescript", "tsx":
+		edge.Import = unquote(imp.Text)
+		if target, ok, isLocal := r.resolveJS(file, edge.Import); ok {
+			return local(target)
+		} else if isLocal {
+			return unresolved()
+		}
+		return external(npmPackage(edge.Import))
+
+	case "python":
+		if imp.Capture == "name" {
+			// only names that are submodules are imports of their own,
+			// the other ones are covered by the edge of the module
+			if target, ok, _ := r.resolvePython(file, imp.Text); ok {
+				return local(target)
+			}
+			return nil
+		}
+		if target, ok, isLocal := r.resolvePython(file, imp.Text); ok {
+			return local(target)
+		} else if isLocal {
+			return unresolved()
+		}
+		return external(strings.Split(imp.Text, ".")[0])
+
+	case "php":
+		if imp.Capture == "path" {
+			if target, ok := r.resolvePHPPath(file, imp.Text); ok {
+				return local(target)
+			}
+			return unresolved()
+		}
+		edge.Import = strings.TrimPrefix(imp.Text, `\`)
+		if target, ok, isLocal := r.resolvePHPUse(file, edge.Import); ok {
+			return local(target)
+		} else if isLocal {
+			return unresolved()
+		}
+		return external(strings.Split(edge.Import, `\`)[0])
+
+	case "rust":
+		if imp.Capture == "module" {
+			edge.Import = "mod " + imp.Text
+			dir := rustModuleDir(file)
+			if target, ok := r.firstFile(path.Join(dir, imp.Text+".rs"), path.Join(dir, imp.Text, "mod.rs")); ok {
+				return local(target)
+			}
+			return unresolved()
+		}
+		target, ok, isLocal, crate := r.resolveRust(file, imp.Text)
+		if ok {
+			return local(target)
+		} else if isLocal {
+			return unresolved()
+		} else if crate == "" {
+			return nil
+		}
+		return external(crate)
+
+	case "java":
+		targets, isExternal := r.resolveJava(imp.Text)
+		if len(targets) == 0 {
+			segments := strings.Split(imp.Text, ".")
+			if len(segments) > 2 {
+				segments = segments[:2]
+			}
+			if isExternal {
+				return external(strings.Join(segments, "."))
+			}
+			return unresolved()
+		}
+		ret := []*Edge{}
+		for _, target := range targets {
+			e := *edge
+			e.Kind = KindLocal
+			e.To = target
+			ret = append(ret, &e)
+		}
+		return ret
+	}
+
+	return unresolved()
+}
+
+// Go
+
+type goModule struct {
+	dir  string
+	path string
+}
+
+// goModule returns the module of the closest go.mod, or nil.
+func (r *Resolver) goModule(dir string) *goModule {
+	if m, ok := r.goModules[dir]; ok {
+		return m
+	}
+	var m *goModule
+	if modDir, _, ok := r.findUp(dir, "go.mod"); ok {
+		if modulePath := readGoModulePath(r.abs(path.Join(modDir, "go.mod"))); modulePath != "" {
+			m = &goModule{dir: modDir, path: modulePath}
+		}
+	}
+	r.goModules[dir] = m
+	return m
+}
+
+func readGoModulePath(fileName string) string {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") || strings.HasPrefix(line, "module\t") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "module"))
+			if i := strings.Index(line, "//"); i >= 0 {
+				line = strings.TrimSpace(line[:i])
+			}
+			return unquote(line)
+		}
+	}
+	return ""
+}
+
+// resolveGo returns the package directory of an import of the module of
+// file. isLocal is true for imports of the module that don't resolve.
+func (r *Resolver) resolveGo(file string, importPath string) (string, bool, bool) {
+	m := r.goModule(path.Dir(file))
+	if m == nil || (importPath != m.path && !strings.HasPrefix(importPath, m.path+"/")) {
+		return "", false, false
+	}
+	dir := path.Join(m.dir, strings.TrimPrefix(importPath, m.path))
+	if !r.isDir(dir) {
+		return "", false, true
+	}
+	return dir, true, true
+}
+
+// JavaScript and TypeScript
+
+var jsExtensions = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs", ".json"}
+
+type tsConfig struct {
+	// base is the directory the paths are relative to.
+	base       string
+	hasBaseURL bool
+	patterns   []tsPathPattern
+}
+
+type tsPathPattern struct {
+	prefix, suffix string
+	wildcard       bool
+	targets        []string
+}
+
+func (r *Resolver) tsConfig(dir string) *tsConfig {
+	if c, ok := r.tsConfigs[dir]; ok {
+		return c
+	}
+	var c *tsConfig
+	if configDir, name, ok := r.findUp(dir, "tsconfig.json", "jsconfig.json"); ok {
+		fileName := r.abs(path.Join(configDir, name))
+		var err error
+		c, err = readTSConfig(fileName, configDir)
+		if err != nil {
+			log.Warn().Err(err).Str("file", fileName).Msg("could not read the paths of the typescript configuration")
+		}
+	}
+	r.tsConfigs[dir] = c
+	return c
+}
+
+func readTSConfig(fileName string, dir string) (*tsConfig, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	config := struct {
+		CompilerOptions struct {
+			BaseURL string              `json:"baseUrl"`
+			Paths   map[string][]string `json:"paths"`
+		} `json:"compilerOptions"`
+	}{}
+	err = json.Unmarshal(stripJSONC(b), &config)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &tsConfig{base: dir}
+	if config.CompilerOptions.BaseURL != "" {
+		ret.base = path.Join(dir, config.CompilerOptions.BaseURL)
+		ret.hasBaseURL = true
+	}
+	for pattern, targets := range config.CompilerOptions.Paths {
+		p := tsPathPattern{prefix: pattern, targets: targets}
+		if i := strings.Index(pattern, "*"); i >= 0 {
+			p.prefix, p.suffix, p.wildcard = pattern[:i], pattern[i+1:], true
+		}
+		ret.patterns = append(ret.patterns, p)
+	}
+	// like typescript, prefer the longest prefix
+	sort.Slice(ret.patterns, func(i, j int) bool {
+		if len(ret.patterns[i].prefix) != len(ret.patterns[j].prefix) {
+			return len(ret.patterns[i].prefix) > len(ret.patterns[j].prefix)
+		}
+		return ret.patterns[i].prefix < ret.patterns[j].prefix
+	})
+	return ret, nil
+}
+
+// stripJSONC removes the comments and trailing commas of the JSON with
+// comments used by tsconfig.json.
+func stripJSONC(b []byte) []byte {
+	ret := make([]byte, 0, len(b))
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case inString:
+			ret = append(ret, c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				ret = append(ret, b[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			ret = append(ret, c)
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			ret = append(ret, '\n')
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			i += 2
+			for i+1 < len(b) && (b[i] != '*' || b[i+1] != '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// drop the comma if the next significant character closes the
+			// object or array
+			j := i + 1
+			for j < len(b) && (b[j] == ' ' || b[j] == '\t' || b[j] == '\n' || b[j] == '\r') {
+				j++
+			}
+			if j < len(b) && (b[j] == '}' || b[j] == ']') {
+				continue
+			}
+			ret = append(ret, c)
+		default:
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// jsFile returns the file imported as base: base itself, base with one of
+// the extensions, or the index file of the directory base.
+func (r *Resolver) jsFile(base string) (string, bool) {
+	candidates := []string{base}
+	// typescript sources importing the compiled files
+	for _, ext := range []string{".js", ".jsx", ".mjs", ".cjs"} {
+		if strings.HasSuffix(base, ext) {
+			trimmed := strings.TrimSuffix(base, ext)
+			candidates = append(candidates, trimmed+".ts", trimmed+".tsx")
+		}
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, path.Join(base, "index"+ext))
+	}
+	return r.firstFile(candidates...)
+}
+
+// resolveJS resolves relative imports, and imports mapped by the paths and
+// the baseUrl of the typescript configuration. isLocal is true for relative
+// and mapped imports that don't resolve.
+func (r *Resolver) resolveJS(file string, spec string) (string, bool, bool) {
+	if spec == "." || spec == ".." || strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		target, ok := r.jsFile(path.Join(path.Dir(file), spec))
+		return target, ok, true
+	}
+
+	c := r.tsConfig(path.Dir(file))
+	if c == nil {
+		return "", false, false
+	}
+	for _, p := range c.patterns {
+		var star string
+		switch {
+		case p.wildcard && len(spec) >= len(p.prefix)+len(p.suffix) &&
+			strings.HasPrefix(spec, p.prefix) && strings.HasSuffix(spec, p.suffix):
+			star = spec[len(p.prefix) : len(spec)-len(p.suffix)]
+		case !p.wildcard && spec == p.prefix:
+		default:
+			continue
+		}
+		for _, target := range p.targets {
+			if t, ok := r.jsFile(path.Join(c.base, strings.Replace(target, "*", star, 1))); ok {
+				return t, true, true
+			}
+		}
+		return "", false, true
+	}
+	if c.hasBaseURL {
+		if t, ok := r.jsFile(path.Join(c.base, spec)); ok {
+			return t, true, true
+		}
+	}
+	return "", false, false
+}
+
+// npmPackage returns the package of a bare import: its first segment, or
+// its first two segments for scoped packages.
+func npmPackage(spec string) string {
+	segments := strings.Split(spec, "/")
+	if strings.HasPrefix(spec, "@") && len(segments) > 1 {
+		return segments[0] + "/" + segments[1]
+	}
+	return segments[0]
+}
+
+// Python
+
+// resolvePython resolves relative imports from the package of file, and
+// absolute imports from the directories containing file, the root and its
+// src directory. isLocal is true for relative imports that don't resolve.
+func (r *Resolver) resolvePython(file string, module string) (string, bool, bool) {
+	pythonFile := func(base string) (string, bool) {
+		return r.firstFile(base+".py", path.Join(base, "__init__.py"))
+	}
+
+	if strings.HasPrefix(module, ".") {
+		rest := strings.TrimLeft(module, ".")
+		dir := path.Dir(file)
+		for i := 1; i < len(module)-len(rest); i++ {
+			dir = path.Dir(dir)
+		}
+		if rest == "" {
+			target, ok := r.firstFile(path.Join(dir, "__init__.py"))
+			return target, ok, true
+		}
+		target, ok := pythonFile(path.Join(dir, strings.ReplaceAll(rest, ".", "/")))
+		return target, ok, true
+	}
+
+	modulePath := strings.ReplaceAll(module, ".", "/")
+	roots := []string{}
+	for dir := path.Dir(file); ; dir = path.Dir(dir) {
+		roots = append(roots, dir)
+		if dir == "." {
+			break
+		}
+	}
+	roots = append(roots, "src")
+	for _, root := range roots {
+		if target, ok := pythonFile(path.Join(root, modulePath)); ok {
+			return target, true, true
+		}
+	}
+	return "", false, false
+}
+
+// PHP
+
+type composerConfig struct {
+	dir string
+	// psr4 maps namespace prefixes (with a trailing backslash) to
+	// directories, sorted by decreasing prefix length.
+	psr4 []psr4Mapping
+}
+
+type psr4Mapping struct {
+	prefix string
+	dirs   []string
+}
+
+func (r *Resolver) composer(dir string) *composerConfig {
+	if c, ok := r.composers[dir]; ok {
+		return c
+	}
+	var c *composerConfig
+	if composerDir, _, ok := r.findUp(dir, "composer.json"); ok {
+		fileName := r.abs(path.Join(composerDir, "composer.json"))
+		var err error
+		c, err = readComposer(fileName, composerDir)
+		if err != nil {
+			log.Warn().Err(err).Str("file", fileName).Msg("could not read the autoload configuration")
+		}
+	}
+	r.composers[dir] = c
+	return c
+}
+
+func readComposer(fileName string, dir string) (*composerConfig, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	type autoload struct {
+		PSR4 map[string]interface{} `json:"psr-4"`
+	}
+	config := struct {
+		Autoload    autoload `json:"autoload"`
+		AutoloadDev autoload `json:"autoload-dev"`
+	}{}
+	err = json.Unmarshal(b, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &composerConfig{dir: dir}
+	for _, a := range []autoload{config.Autoload, config.AutoloadDev} {
+		for prefix, dirs := range a.PSR4 {
+			m := psr4Mapping{prefix: prefix}
+			switch v := dirs.(type) {
+			case string:
+				m.dirs = []string{v}
+			case []interface{}:
+				for _, d := range v {
+					if s, ok := d.(string); ok {
+						m.dirs = append(m.dirs, s)
+					}
+				}
+			}
+			ret.psr4 = append(ret.psr4, m)
+		}
+	}
+	sort.SliceStable(ret.psr4, func(i, j int) bool {
+		return len(ret.psr4[i].prefix) > len(ret.psr4[j].prefix)
+	})
+	return ret, nil
+}
+
+// resolvePHPUse resolves a used class with the PSR-4 autoload mappings of
+// composer.json. isLocal is true for names in a mapped namespace that don't
+// resolve, such as functions.
+func (r *Resolver) resolvePHPUse(file string, name string) (string, bool, bool) {
+	c := r.composer(path.Dir(file))
+	if c == nil {
+		return "", false, false
+	}
+	for _, m := range c.psr4 {
+		if !strings.HasPrefix(name+`\`, m.prefix) {
+			continue
+		}
+		rest := strings.ReplaceAll(strings.TrimPrefix(name, m.prefix), `\`, "/")
+		for _, dir := range m.dirs {
+			if target, ok := r.firstFile(path.Join(c.dir, dir, rest+".php")); ok {
+				return target, true, true
+			}
+		}
+		return "", false, true
+	}
+	return "", false, false
+}
+
+var phpStringRegexp = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+// resolvePHPPath resolves the path of an include, concatenating the string
+// literals of the expression, relative to the directory of file, then to
+// the root.
+func (r *Resolver) resolvePHPPath(file string, expression string) (string, bool) {
+	included := ""
+	for _, m := range phpStringRegexp.FindAllStringSubmatch(expression, -1) {
+		included += m[1] + m[2]
+	}
+	if included == "" {
+		return "", false
+	}
+	return r.firstFile(
+		path.Join(path.Dir(file), included),
+		strings.TrimPrefix(included, "/"),
+	)
+}
+
+// Rust
+
+// rustModuleDir returns the directory of the submodules of the module of
+// file: its directory for crate roots and mod.rs files, and the directory
+// named like the file otherwise.
+func rustModuleDir(file string) string {
+	switch path.Base(file) {
+	case "main.rs", "lib.rs", "mod.rs":
+		return path.Dir(file)
+	}
+	return strings.TrimSuffix(file, ".rs")
+}
+
+// rustCrateDir returns the src directory of the crate of file.
+func (r *Resolver) rustCrateDir(file string) string {
+	dir := path.Dir(file)
+	if d, ok := r.cargoRoots[dir]; ok {
+		return d
+	}
+	ret := dir
+	if cargoDir, _, ok := r.findUp(dir, "Cargo.toml"); ok {
+		ret = path.Join(cargoDir, "src")
+	}
+	r.cargoRoots[dir] = ret
+	return ret
+}
+
+// resolveRust resolves a use declaration to the file of the longest module
+// prefix of its path. Paths starting with crate, self and super are local
+// (isLocal). Other paths are tried as submodules of the module of file,
+// and are otherwise from the returned external crate.
+func (r *Resolver) resolveRust(file string, use string) (string, bool, bool, string) {
+	if i := strings.Index(use, "{"); i >= 0 {
+		use = use[:i]
+	}
+	if i := strings.Index(use, " as "); i >= 0 {
+		use = use[:i]
+	}
+	use = strings.Trim(strings.TrimSpace(use), ":")
+	if use == "" {
+		return "", false, false, ""
+	}
+	segments := strings.Split(use, "::")
+
+	base := rustModuleDir(file)
+	isLocal := true
+	switch segments[0] {
+	case "crate":
+		base = r.rustCrateDir(file)
+		segments = segments[1:]
+	case "self":
+		segments = segments[1:]
+	case "super":
+		for len(segments) > 0 && segments[0] == "super" {
+			base = path.Dir(base)
+			segments = segments[1:]
+		}
+	default:
+		isLocal = false
+	}
+
+	for i := len(segments); i >= 1; i-- {
+		modulePath := path.Join(base, path.Join(segments[:i]...))
+		if target, ok := r.firstFile(modulePath+".rs", path.Join(modulePath, "mod.rs")); ok {
+			return target, true, true, ""
+		}
+	}
+	if !isLocal {
+		return "", false, false, segments[0]
+	}
+	target, ok := r.firstFile(base+".rs", path.Join(base, "mod.rs"), path.Join(base, "lib.rs"), path.Join(base, "main.rs"))
+	return target, ok, true, ""
+}
+
+// Java
+
+func (r *Resolver) buildJavaIndex() {
+	r.javaFiles = map[string][]string{}
+	isJava := func(lang string) bool { return lang == "java" }
+	err := pkg.WalkSourceFiles(r.root, isJava, func(fileName string, lang string, info os.FileInfo) error {
+		rel, err := r.relativePath(fileName)
+		if err != nil {
+			return err
+		}
+		segments := strings.Split(rel, "/")
+		for i := range segments {
+			suffix := strings.Join(segments[i:], "/")
+			r.javaFiles[suffix] = append(r.javaFiles[suffix], rel)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("could not list the java files")
+	}
+}
+
+// resolveJava resolves an imported class (or the class of a static import)
+// to its file, and a wildcard import to the files of the package. Imports
+// are matched by path suffix, so that any source directory layout works.
+// isExternal is true if no file of the repository is in the package.
+func (r *Resolver) resolveJava(name string) ([]string, bool) {
+	if r.javaFiles == nil {
+		r.buildJavaIndex()
+	}
+	segments := strings.Split(name, ".")
+	for i := len(segments); i >= 2; i-- {
+		if files := r.javaFiles[strings.Join(segments[:i], "/")+".java"]; len(files) > 0 {
+			return files[:1], false
+		}
+	}
+
+	// wildcard imports
+	dir := strings.Join(segments, "/")
+	ret := []string{}
+	for suffix, files := range r.javaFiles {
+		if !strings.Contains(suffix, "/") {
+			continue
+		}
+		if path.Dir(suffix) == dir {
+			ret = append(ret, files...)
+		}
+	}
+	sort.Strings(ret)
+	ret = dedupe(ret)
+	if len(ret) > 0 {
+		return ret, false
+	}
+
+	// imports of missing classes of a package of the repository
+	packageDir := path.Dir(dir)
+	for suffix := range r.javaFiles {
+		if path.Dir(suffix) == packageDir {
+			return nil, false
+		}
+	}
+	return nil, true
+}
+
+func dedupe(sorted []string) []string {
+	ret := []string{}
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,220 @@
+package deps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestResolver writes files (name to content) to a temporary directory,
+// and returns a resolver for it.
+func newTestResolver(t *testing.T, files map[string]string) *Resolver {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		fileName := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewResolver(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestResolvePython(t *testing.T) {
+	r := newTestResolver(t, map[string]string{
+		"main.py":             "",
+		"pkg/__init__.py":     "",
+		"pkg/c.py":            "",
+		"pkg/d.py":            "",
+		"pkg/sub/__init__.py": "",
+		"pkg/sub/e.py":        "",
+		"src/lib/__init__.py": "",
+	})
+
+	tests := []struct {
+		name    string
+		file    string
+		module  string
+		target  string
+		ok      bool
+		isLocal bool
+	}{
+		{name: "module", file: "main.py", module: "pkg.c", target: "pkg/c.py", ok: true, isLocal: true},
+		{name: "package", file: "main.py", module: "pkg", target: "pkg/__init__.py", ok: true, isLocal: true},
+		{name: "src directory", file: "main.py", module: "lib", target: "src/lib/__init__.py", ok: true, isLocal: true},
+		{name: "external", file: "main.py", module: "os.path", ok: false, isLocal: false},
+		{name: "relative module", file: "pkg/c.py", module: ".d", target: "pkg/d.py", ok: true, isLocal: true},
+		{name: "relative package", file: "pkg/c.py", module: ".", target: "pkg/__init__.py", ok: true, isLocal: true},
+		{name: "parent package", file: "pkg/sub/e.py", module: "..c", target: "pkg/c.py", ok: true, isLocal: true},
+		{name: "missing relative", file: "pkg/c.py", module: ".missing", ok: false, isLocal: true},
+		{name: "absolute from a package", file: "pkg/sub/e.py", module: "pkg.d", target: "pkg/d.py", ok: true, isLocal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok, isLocal := r.resolvePython(tt.file, tt.module)
+			if target != tt.target || ok != tt.ok || isLocal != tt.isLocal {
+				t.Errorf("got (%q, %v, %v), expected (%q, %v, %v)",
+					target, ok, isLocal, tt.target, tt.ok, tt.isLocal)
+			}
+		})
+	}
+}
+
+func TestResolvePythonImportedNames(t *testing.T) {
+	r := newTestResolver(t, map[string]string{
+		"main.py":         "",
+		"pkg/__init__.py": "",
+		"pkg/c.py":        "",
+		"pkg/d.py":        "",
+	})
+
+	tests := []struct {
+		name string
+		file string
+		imp  Import
+		to   []string
+	}{
+		{
+			name: "submodule of the own package",
+			file: "pkg/__init__.py",
+			imp:  Import{Capture: "name", Text: ".c"},
+			to:   []string{"pkg/c.py"},
+		},
+		{
+			name: "no self edge",
+			file: "pkg/__init__.py",
+			imp:  Import{Capture: "import", Text: "."},
+			to:   []string{},
+		},
+		{
+			name: "submodule of another package",
+			file: "main.py",
+			imp:  Import{Capture: "name", Text: "pkg.d"},
+			to:   []string{"pkg/d.py"},
+		},
+		{
+			name: "name that is not a module",
+			file: "main.py",
+			imp:  Import{Capture: "name", Text: "pkg.helper"},
+			to:   []string{},
+		},
+		{
+			name: "module of a from import",
+			file: "main.py",
+			imp:  Import{Capture: "import", Text: "pkg"},
+			to:   []string{"pkg/__init__.py"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := []string{}
+			for _, e := range r.Resolve(tt.file, "python", tt.imp) {
+				if e.Kind != KindLocal {
+					t.Errorf("unexpected %s edge to %s", e.Kind, e.To)
+				}
+				to = append(to, e.To)
+			}
+			if len(to) != len(tt.to) || (len(to) > 0 && to[0] != tt.to[0]) {
+				t.Errorf("got edges to %v, expected %v", to, tt.to)
+			}
+		})
+	}
+}
+
+func TestResolveJS(t *testing.T) {
+	r := newTestResolver(t, map[string]string{
+		"web/tsconfig.json": `{
+  // comments and trailing commas are allowed
+  "compilerOptions": {
+    "baseUrl": "src",
+    "paths": {
+      "@app/*": ["app/*"],
+      "@app/special": ["special/index.ts"],
+      "@shared/*": ["missing/*", "../shared/*"],
+    },
+  },
+}`,
+		"web/src/main.ts":            "",
+		"web/src/util.ts":            "",
+		"web/src/app/button.tsx":     "",
+		"web/src/app/forms/index.ts": "",
+		"web/src/special/index.ts":   "",
+		"web/src/lib/compiled.ts":    "",
+		"web/shared/api.ts":          "",
+		"other/plain.js":             "",
+	})
+
+	tests := []struct {
+		name    string
+		file    string
+		spec    string
+		target  string
+		ok      bool
+		isLocal bool
+	}{
+		{name: "relative", file: "web/src/main.ts", spec: "./util", target: "web/src/util.ts", ok: true, isLocal: true},
+		{name: "index file", file: "web/src/main.ts", spec: "./app/forms", target: "web/src/app/forms/index.ts", ok: true, isLocal: true},
+		{name: "compiled extension", file: "web/src/main.ts", spec: "./lib/compiled.js", target: "web/src/lib/compiled.ts", ok: true, isLocal: true},
+		{name: "missing relative", file: "web/src/main.ts", spec: "./missing", ok: false, isLocal: true},
+		{name: "wildcard path", file: "web/src/main.ts", spec: "@app/button", target: "web/src/app/button.tsx", ok: true, isLocal: true},
+		{name: "longest prefix", file: "web/src/main.ts", spec: "@app/special", target: "web/src/special/index.ts", ok: true, isLocal: true},
+		{name: "second target", file: "web/src/main.ts", spec: "@shared/api", target: "web/shared/api.ts", ok: true, isLocal: true},
+		{name: "mapped but missing", file: "web/src/main.ts", spec: "@app/missing", ok: false, isLocal: true},
+		{name: "base url", file: "web/src/app/button.tsx", spec: "util", target: "web/src/util.ts", ok: true, isLocal: true},
+		{name: "package", file: "web/src/main.ts", spec: "react", ok: false, isLocal: false},
+		{name: "no configuration", file: "other/plain.js", spec: "util", ok: false, isLocal: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok, isLocal := r.resolveJS(tt.file, tt.spec)
+			if target != tt.target || ok != tt.ok || isLocal != tt.isLocal {
+				t.Errorf("got (%q, %v, %v), expected (%q, %v, %v)",
+					target, ok, isLocal, tt.target, tt.ok, tt.isLocal)
+			}
+		})
+	}
+}
+
+func TestExtractPythonImportedNames(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "__init__.py")
+	source := "from . import c, d as dd\nfrom .c import helper\nfrom pkg import e\nimport os\n"
+	if err := os.WriteFile(fileName, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	imports, err := extract(context.Background(), nil, fileName, "python")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, imp := range imports {
+		got[imp.Capture+" "+imp.Text] = true
+	}
+	expected := []string{
+		"import .", "name .c", "name .d",
+		"import .c", "name .c.helper",
+		"import pkg", "name pkg.e",
+		"import os",
+	}
+	if len(got) != len(expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+	for _, e := range expected {
+		if !got[e] {
+			t.Errorf("missing %q in %v", e, got)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Package diff compares two versions of a source file by their definitions
+// instead of their lines. Definitions are extracted by the symbols package
+// and matched by kind and qualified name, so that reformatted code is not
+// reported and moved code is reported as moved.
+package diff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aymanbagabas/go-udiff"
+	"github.com/go-go-golems/oak/pkg/symbols"
+)
+
+// Status tells how a definition changed.
+type Status string
+
+const (
+	StatusAdded    Status = "added"
+	StatusRemoved  Status = "removed"
+	StatusModified Status = "modified"
+	// StatusMoved definitions have another position among their siblings,
+	// or another parent (like a method moved to another class), with or
+	// without modifications.
+	StatusMoved Status = "moved"
+	// StatusRenamed definitions are removed and added definitions of the
+	// same kind with similar code: they are likely, but not certainly,
+	// renamed.
+	StatusRenamed Status = "renamed"
+)
+
+// Options configures Compare.
+type Options struct {
+	// Similarity is the minimum similarity, between 0 and 1, of the code of
+	// a removed and an added definition to report them as renamed.
+	Similarity float64
+	// Context is the number of context lines of the diffs.
+	Context int
+}
+
+// DefaultOptions are the options used by oak diff.
+var DefaultOptions = Options{Similarity: 0.6, Context: 3}
+
+// Definition is a symbol of one of the versions, with its parent and its
+// code.
+type Definition struct {
+	*symbols.Symbol
+	Parent *Definition
+	// QualifiedName is the names of the parents (or the container) and the
+	// name of the definition, separated by dots.
+	QualifiedName string
+	// Text is the code of the definition, with the code of its children
+	// replaced by a placeholder line, so that a class doesn't change when
+	// only one of its methods changes.
+	Text string
+
+	normalized string
+	index      int
+}
+
+// Change is a definition that differs between the two versions.
+type Change struct {
+	Status Status
+	Kind   symbols.Kind
+	// Old is nil for added definitions, and New for removed definitions.
+	Old *Definition
+	New *Definition
+	// Similarity is the similarity of the code of renamed and moved
+	// definitions, between 0 and 1.
+	Similarity float64
+	// Diff is the unified diff of the code of the definition, empty for
+	// definitions that are only moved.
+	Diff string
+}
+
+// Name returns the qualified name of the definition in the new version, or
+// in the old version for removed definitions.
+func (c *Change) Name() string {
+	if c.New != nil {
+		return c.New.QualifiedName
+	}
+	return c.Old.QualifiedName
+}
+
+// Compare extracts the definitions of both versions of a file, in the given
+// language, and returns the definitions that differ, sorted by position in
+// the new version, then in the old version for removed definitions. The
+// labels name the versions in the diffs.
+func Compare(
+	ctx context.Context,
+	lang string,
+	oldSource, newSource []byte,
+	oldLabel, newLabel string,
+	options Options,
+) ([]*Change, error) {
+	oldDefinitions, err := definitions(ctx, lang, oldSource)
+	if err != nil {
+		return nil, err
+	}
+	newDefinitions, err := definitions(ctx, lang, newSource)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &comparison{
+		oldLabel: oldLabel,
+		newLabel: newLabel,
+		options:  options,
+	}
+	return c.compare(oldDefinitions, newDefinitions), nil
+}
+
+func definitions(ctx context.Context, lang string, source []byte) ([]*Definition, error) {
+	if len(source) == 0 {
+		return nil, nil
+	}
+	syms, err := symbols.ExtractFromSource(ctx, lang, source)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Definition{}
+	stack := []*Definition{}
+	for i, s := range syms {
+		d := &Definition{Symbol: s, index: i}
+		for len(stack) > 0 && !stack[len(stack)-1].Contains(s.StartByte, s.EndByte) {
+			stack = stack[:len(stack)-1]
+		}
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].StartByte != s.StartByte || stack[j].EndByte != s.EndByte {
+				d.Parent = stack[j]
+				break
+			}
+		}
+		stack = append(stack, d)
+
+		switch {
+		case d.Parent != nil:
+			d.QualifiedName = d.Parent.QualifiedName + "." + s.Name
+		case s.Container != "":
+			d.QualifiedName = s.Container + "." + s.Name
+		default:
+			d.QualifiedName = s.Name
+		}
+		ret = append(ret, d)
+	}
+
+	children := map[*Definition][]*Definition{}
+	for _, d := range ret {
+		if d.Parent != nil {
+			children[d.Parent] = append(children[d.Parent], d)
+		}
+	}
+	for _, d := range ret {
+		d.Text = text(d, children[d], source)
+		d.normalized = strings.Join(strings.Fields(d.Text), " ")
+	}
+	return ret, nil
+}
+
+// text returns the code of d, from the start of its first line, with the
+// code of its children replaced by a placeholder.
+func text(d *Definition, children []*Definition, source []byte) string {
+	start := int(d.StartByte)
+	for start > 0 && source[start-1] != '\n' && (source[start-1] == ' ' || source[start-1] == '\t') {
+		start--
+	}
+
+	var b strings.Builder
+	position := start
+	for _, child := range children {
+		if int(child.StartByte) < position {
+			continue
+		}
+		b.Write(source[position:child.StartByte])
+		fmt.Fprintf(&b, "%s %s …", child.Kind, child.Name)
+		position = int(child.EndByte)
+	}
+	b.Write(source[position:d.EndByte])
+	ret := b.String()
+	if !strings.HasSuffix(ret, "\n") {
+		ret += "\n"
+	}
+	return ret
+}
+
+type comparison struct {
+	oldLabel string
+	newLabel string
+	options  Options
+}
+
+func key(d *Definition) string {
+	return string(d.Kind) + "\x00" + d.QualifiedName
+}
+
+func (c *comparison) diff(old, updated *Definition) string {
+	oldText, newText := "", ""
+	oldLabel, newLabel := c.oldLabel, c.newLabel
+	if old != nil {
+		oldText = old.Text
+		oldLabel = fmt.Sprintf("%s:%d (%s)", c.oldLabel, old.StartPoint.Row+1, old.QualifiedName)
+	}
+	if updated != nil {
+		newText = updated.Text
+		newLabel = fmt.Sprintf("%s:%d (%s)", c.newLabel, updated.StartPoint.Row+1, updated.QualifiedName)
+	}
+	edits := udiff.Strings(oldText, newText)
+	ret, err := udiff.ToUnified(oldLabel, newLabel, oldText, edits, c.options.Context)
+	if err != nil {
+		return ""
+	}
+	return ret
+}
+
+func (c *comparison) compare(oldDefinitions, newDefinitions []*Definition) []*Change {
+	changes := []*Change{}
+
+	// match the definitions with the same kind and qualified name, in order
+	oldByKey := map[string][]*Definition{}
+	for _, d := range oldDefinitions {
+		oldByKey[key(d)] = append(oldByKey[key(d)], d)
+	}
+	matched := map[*Definition]*Definition{}
+	pairs := [][2]*Definition{}
+	unmatchedNew := []*Definition{}
+	for _, d := range newDefinitions {
+		k := key(d)
+		if candidates := oldByKey[k]; len(candidates) > 0 {
+			matched[candidates[0]] = d
+			pairs = append(pairs, [2]*Definition{candidates[0], d})
+			oldByKey[k] = candidates[1:]
+			continue
+		}
+		unmatchedNew = append(unmatchedNew, d)
+	}
+	unmatchedOld := []*Definition{}
+	for _, d := range oldDefinitions {
+		if _, ok := matched[d]; !ok {
+			unmatchedOld = append(unmatchedOld, d)
+		}
+	}
+
+	moved := reordered(pairs)
+	for _, p := range pairs {
+		switch {
+		case p[0].normalized != p[1].normalized:
+			changes = append(changes, &Change{
+				Status: StatusModified, Kind: p[1].Kind, Old: p[0], New: p[1],
+				Similarity: similarity(p[0], p[1]),
+				Diff:       c.diff(p[0], p[1]),
+			})
+		case moved[p[1]]:
+			changes = append(changes, &Change{
+				Status: StatusMoved, Kind: p[1].Kind, Old: p[0], New: p[1], Similarity: 1,
+			})
+		}
+	}
+
+	// pair the remaining definitions: same name in another parent first,
+	// then similar code with another name
+	for _, sameName := range []bool{true, false} {
+		type candidate struct {
+			old, updated *Definition
+			similarity   float64
+		}
+		candidates := []candidate{}
+		for _, o := range unmatchedOld {
+			for _, n := range unmatchedNew {
+				if o.Kind != n.Kind || (o.Name == n.Name) != sameName {
+					continue
+				}
+				s := similarity(o, n)
+				if (sameName && s >= c.options.Similarity/2) || s >= c.options.Similarity {
+					candidates = append(candidates, candidate{o, n, s})
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].similarity > candidates[j].similarity
+		})
+		pairedOld := map[*Definition]bool{}
+		pairedNew := map[*Definition]bool{}
+		for _, cand := range candidates {
+			if pairedOld[cand.old] || pairedNew[cand.updated] {
+				continue
+			}
+			pairedOld[cand.old] = true
+			pairedNew[cand.updated] = true
+			change := &Change{
+				Status: StatusRenamed, Kind: cand.updated.Kind, Old: cand.old, New: cand.updated,
+				Similarity: cand.similarity,
+			}
+			if sameName {
+				change.Status = StatusMoved
+			}
+			if cand.old.normalized != cand.updated.normalized {
+				change.Diff = c.diff(cand.old, cand.updated)
+			}
+			changes = append(changes, change)
+		}
+		unmatchedOld = remove(unmatchedOld, pairedOld)
+		unmatchedNew = remove(unmatchedNew, pairedNew)
+	}
+
+	for _, d := range unmatchedNew {
+		changes = append(changes, &Change{Status: StatusAdded, Kind: d.Kind, New: d, Diff: c.diff(nil, d)})
+	}
+	for _, d := range unmatchedOld {
+		changes = append(changes, &Change{Status: StatusRemoved, Kind: d.Kind, Old: d, Diff: c.diff(d, nil)})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if (a.New == nil) != (b.New == nil) {
+			return a.New != nil
+		}
+		if a.New != nil {
+			return a.New.index < b.New.index
+		}
+		return a.Old.index < b.Old.index
+	})
+	return changes
+}
+
+func remove(definitions []*Definition, removed map[*Definition]bool) []*Definition {
+	ret := []*Definition{}
+	for _, d := range definitions {
+		if !removed[d] {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// reordered returns the new definitions of the pairs whose position among
+// the matched definitions with the same parent changed: the definitions
+// outside of the largest sequence of siblings keeping their order.
+func reordered(pairs [][2]*Definition) map[*Definition]bool {
+	siblings := map[string][][2]*Definition{}
+	for _, p := range pairs {
+		parent := ""
+		if p[1].Parent != nil {
+			parent = key(p[1].Parent)
+		}
+		siblings[parent] = append(siblings[parent], p)
+	}
+
+	ret := map[*Definition]bool{}
+	for _, group := range siblings {
+		// group is in the order of the new version, find the increasing
+		// subsequence of the old positions with the most code, so that a
+		// small definition moved above a large one is reported, and not the
+		// large one
+		n := len(group)
+		sizes := make([]int, n)
+		previous := make([]int, n)
+		best := -1
+		for i, p := range group {
+			size := int(p[1].EndByte - p[1].StartByte)
+			sizes[i], previous[i] = size, -1
+			for j := 0; j < i; j++ {
+				if group[j][0].index < group[i][0].index && sizes[j]+size > sizes[i] {
+					sizes[i], previous[i] = sizes[j]+size, j
+				}
+			}
+			if best == -1 || sizes[i] > sizes[best] {
+				best = i
+			}
+		}
+		inOrder := map[int]bool{}
+		for i := best; i >= 0; i = previous[i] {
+			inOrder[i] = true
+		}
+		for i, p := range group {
+			if !inOrder[i] {
+				ret[p[1]] = true
+			}
+		}
+	}
+	return ret
+}
+
+// similarity returns the Dice coefficient of the words (identifiers,
+// keywords and numbers) of the code of two definitions, ignoring their names
+// and punctuation, which would make any two small definitions similar.
+func similarity(a, b *Definition) float64 {
+	ta, tb := tokens(a), tokens(b)
+	total := 0
+	for _, n := range ta {
+		total += n
+	}
+	for _, n := range tb {
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	common := 0
+	for t, n := range ta {
+		common += min(n, tb[t])
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+func tokens(d *Definition) map[string]int {
+	ret := map[string]int{}
+	token := strings.Builder{}
+	flush := func() {
+		if token.Len() > 0 && token.String() != d.Name {
+			ret[token.String()]++
+		}
+		token.Reset()
+	}
+	for _, r := range d.normalized {
+		switch {
+		case r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127:
+			token.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return ret
+}
@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const oldSource = `package a
+
+type Server struct{}
+
+func (s *Server) Start() {
+	s.listen()
+}
+
+func (s *Server) listen() {}
+
+func Gone() {}
+
+func compute(a, b int) int {
+	sum := a + b
+	sum = sum * 2
+	return sum
+}
+`
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		new      string
+		expected []string
+		diff     string
+	}{
+		{
+			name: "reformatted",
+			new:  strings.ReplaceAll(oldSource, "\t", "    "),
+		},
+		{
+			name:     "added and removed",
+			new:      strings.Replace(oldSource, "func Gone() {}\n", "func Added(names []string) string {\n\treturn strings.Join(names, \",\")\n}\n", 1),
+			expected: []string{"added function Added", "removed function Gone"},
+		},
+		{
+			name:     "modified",
+			new:      strings.Replace(oldSource, "sum * 2", "sum * 3", 1),
+			expected: []string{"modified function compute"},
+			diff: `--- old.go:13 (compute)
++++ new.go:13 (compute)
+@@ -1,5 +1,5 @@
+ func compute(a, b int) int {
+ 	sum := a + b
+-	sum = sum * 2
++	sum = sum * 3
+ 	return sum
+ }
+`,
+		},
+		{
+			name:     "renamed",
+			new:      strings.Replace(oldSource, "compute(", "calculate(", 1),
+			expected: []string{"renamed function calculate from compute"},
+		},
+		{
+			name: "moved",
+			new: strings.Replace(
+				strings.Replace(oldSource, "func Gone() {}\n\n", "", 1),
+				"type Server struct{}\n", "type Server struct{}\n\nfunc Gone() {}\n", 1),
+			expected: []string{"moved function Gone"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Compare(context.Background(), "go",
+				[]byte(oldSource), []byte(tt.new), "old.go", "new.go", DefaultOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, c := range changes {
+				s := fmt.Sprintf("%s %s %s", c.Status, c.Kind, c.Name())
+				if c.Status == StatusRenamed {
+					s += " from " + c.Old.QualifiedName
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Fatalf("got changes:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+			if tt.diff != "" && changes[0].Diff != tt.diff {
+				t.Errorf("got diff:\n%s\nexpected:\n%s", changes[0].Diff, tt.diff)
+			}
+		})
+	}
+}
+
+func TestParseRevisions(t *testing.T) {
+	tests := []struct {
+		revisions string
+		from      string
+		to        string
+		err       bool
+	}{
+		{revisions: "main..HEAD", from: "main", to: "HEAD"},
+		{revisions: "v1.0.0", from: "v1.0.0"},
+		{revisions: "main..", err: true},
+		{revisions: "..HEAD", err: true},
+		{revisions: "main...HEAD", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.revisions, func(t *testing.T) {
+			from, to, err := ParseRevisions(tt.revisions)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %s..%s", from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != tt.from || to != tt.to {
+				t.Errorf("got %s..%s, expected %s..%s", from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	"github.com/pkg/errors"
+)
+
+// File holds the changes of a file.
+type File struct {
+	// Name is the path of the file, or the paths of the old and new files
+	// separated by " -> " when comparing two different files.
+	Name     string
+	Language string
+	Changes  []*Change
+}
+
+// CompareFiles compares two files on disk.
+func CompareFiles(ctx context.Context, oldFile, newFile string, options Options) (*File, error) {
+	lang, err := pkg.FileNameToLanguageName(newFile)
+	if err != nil {
+		return nil, err
+	}
+	if !symbols.HasLanguage(lang) {
+		return nil, errors.Errorf("no symbol queries for language %s", lang)
+	}
+	oldSource, err := os.ReadFile(oldFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", oldFile)
+	}
+	newSource, err := os.ReadFile(newFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", newFile)
+	}
+
+	changes, err := Compare(ctx, lang, oldSource, newSource, oldFile, newFile, options)
+	if err != nil {
+		return nil, err
+	}
+	name := newFile
+	if oldFile != newFile {
+		name = oldFile + " -> " + newFile
+	}
+	return &File{Name: name, Language: lang, Changes: changes}, nil
+}
+
+// ParseRevisions splits a revision range "A..B" into its revisions. A single
+// revision is compared with the working tree, which is returned as an empty
+// revision.
+func ParseRevisions(revisions string) (string, string, error) {
+	from, to, found := strings.Cut(revisions, "..")
+	if from == "" || (found && to == "") || strings.HasPrefix(to, ".") {
+		return "", "", errors.Errorf("invalid revision range %s, expected A..B or A", revisions)
+	}
+	return from, to, nil
+}
+
+// CompareRevisions compares the files changed between two git revisions, in
+// the given paths (the current directory by default), for the languages
+// with symbol queries. An empty to revision is the working tree. git is run
+// in the current directory, and the file names are relative to it.
+func CompareRevisions(ctx context.Context, from, to string, paths []string, options Options) ([]*File, error) {
+	args := []string{"diff", "--name-only", "--relative", "--no-renames", from}
+	if to != "" {
+		args = append(args, to)
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	out, err := git(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*File{}
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name == "" {
+			continue
+		}
+		lang, err := pkg.FileNameToLanguageName(name)
+		if err != nil || !symbols.HasLanguage(lang) {
+			continue
+		}
+
+		oldSource, err := show(ctx, from, name)
+		if err != nil {
+			return nil, err
+		}
+		var newSource []byte
+		if to == "" {
+			newSource, err = os.ReadFile(name)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, errors.Wrapf(err, "could not read file %s", name)
+			}
+		} else {
+			newSource, err = show(ctx, to, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		toLabel := to
+		if toLabel == "" {
+			toLabel = "working tree"
+		}
+
+		changes, err := Compare(ctx, lang, oldSource, newSource,
+			from+":"+name, toLabel+":"+name, options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not compare %s", name)
+		}
+		ret = append(ret, &File{Name: filepath.ToSlash(name), Language: lang, Changes: changes})
+	}
+	return ret, nil
+}
+
+// ReadTree returns the content of the files of a revision in the given
+// paths (the current directory by default) accepted by accept, read from
+// the git object store. The file names are relative to the current
+// directory.
+func ReadTree(ctx context.Context, revision string, paths []string, accept func(name string) bool) (map[string][]byte, error) {
+	args := append([]string{"ls-tree", "-r", "-z", revision, "--"}, paths...)
+	out, err := git(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	objects := []string{}
+	for _, entry := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> TAB <file>
+		info, name, found := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 3 || fields[1] != "blob" || !accept(name) {
+			continue
+		}
+		names = append(names, filepath.ToSlash(name))
+		objects = append(objects, fields[2])
+	}
+
+	ret := map[string][]byte{}
+	if len(objects) == 0 {
+		return ret, nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "could not run git cat-file")
+	}
+	r := bufio.NewReader(stdout)
+	for i, name := range names {
+		// <object> SP <type> SP <size> LF <content> LF
+		var object, kind string
+		var size int
+		_, err := fmt.Fscanf(r, "%s %s %d\n", &object, &kind, &size)
+		if err != nil {
+			_ = cmd.Wait()
+			return nil, errors.Wrapf(err, "could not read object %s", objects[i])
+		}
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(r, content); err != nil {
+			_ = cmd.Wait()
+			return nil, errors.Wrapf(err, "could not read object %s", objects[i])
+		}
+		ret[name] = content[:size]
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, errors.Wrap(err, "git cat-file failed")
+	}
+	return ret, nil
+}
+
+// show returns the content of a file at a revision, or nil if the file
+// doesn't exist at that revision.
+func show(ctx context.Context, revision string, name string) ([]byte, error) {
+	exists, err := git(ctx, "ls-tree", "--name-only", revision, "--", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(exists)) == 0 {
+		return nil, nil
+	}
+	return git(ctx, "show", revision+":./"+filepath.ToSlash(name))
+}
+
+func git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
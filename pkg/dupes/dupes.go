@@ -0,0 +1,493 @@
+// Package dupes finds duplicated code: subtrees of the parse trees whose
+// tokens are the same once identifiers and literals are normalized, and
+// functions whose normalized tokens are similar.
+//
+// The normalized tokens of a file are its leaves, with identifiers replaced
+// by $id, literals by $lit and comments removed, so that code copied and
+// then renamed is still found. Keywords and punctuation are kept as they
+// are, which makes code in different languages with the same structure
+// similar.
+package dupes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Options configures the detection.
+type Options struct {
+	// MinTokens and MinLines are the minimum size of the duplicated
+	// fragments.
+	MinTokens int
+	MinLines  int
+	// Similarity is the minimum similarity, between 0 and 1, of near
+	// duplicate functions. 1 only finds exact duplicates.
+	Similarity float64
+}
+
+// DefaultOptions are the options used by oak dupes.
+var DefaultOptions = Options{MinTokens: 50, MinLines: 5, Similarity: 0.8}
+
+// Fragment is a duplicated subtree.
+type Fragment struct {
+	File     string
+	Language string
+	// Type is the node type of the subtree, and Name the name of the
+	// function for near duplicates.
+	Type       string
+	Name       string
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+	Tokens     int
+
+	startByte, endByte uint32
+	// first and last are the indexes of the first and after the last
+	// normalized token of the fragment in its file.
+	first, last int
+	file        *file
+}
+
+// Cluster is a group of duplicated fragments.
+type Cluster struct {
+	// Exact is true for fragments with the same normalized tokens.
+	Exact bool
+	// Similarity is the lowest similarity of the fragments linked in a
+	// cluster of near duplicates, 1 for exact duplicates.
+	Similarity float64
+	Fragments  []*Fragment
+}
+
+// HasLanguage returns true if duplicates can be searched in the language.
+func HasLanguage(lang string) bool {
+	return symbols.HasLanguage(lang)
+}
+
+type file struct {
+	name   string
+	lang   string
+	tokens []uint32
+	// hashes are the polynomial hashes of the prefixes of tokens
+	hashes []uint64
+}
+
+// Find searches the given files, and the files in the given directories,
+// for duplicated code. File paths are relative to root when they are inside
+// it. Clusters are sorted by decreasing size of their fragments.
+func Find(ctx context.Context, root string, sources []string, options Options) ([]*Cluster, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &detector{
+		options: options,
+		tokenIDs: map[string]uint32{
+			// reserve the ids of the normalized tokens
+			"$id":  0,
+			"$lit": 1,
+		},
+	}
+	seen := map[string]bool{}
+	for _, source := range sources {
+		err = pkg.WalkSourceFiles(source, HasLanguage, func(fileName string, lang string, info os.FileInfo) error {
+			name := fileName
+			if abs, err := filepath.Abs(fileName); err == nil {
+				if rel, err := filepath.Rel(absRoot, abs); err == nil && !strings.HasPrefix(rel, "..") {
+					name = filepath.ToSlash(rel)
+				}
+			}
+			if seen[name] {
+				return nil
+			}
+			seen[name] = true
+
+			content, err := os.ReadFile(fileName)
+			if err != nil {
+				return errors.Wrapf(err, "could not read file %s", fileName)
+			}
+			err = d.addFile(ctx, name, lang, content)
+			if err != nil {
+				return errors.Wrapf(err, "could not parse %s", fileName)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clusters := d.exact()
+	if options.Similarity < 1 {
+		clusters = append(clusters, d.near()...)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Fragments[0].Tokens > clusters[j].Fragments[0].Tokens
+	})
+	return clusters, nil
+}
+
+type detector struct {
+	options   Options
+	tokenIDs  map[string]uint32
+	fragments []*Fragment
+	// functions are the fragments of the functions, compared for near
+	// duplicates
+	functions []*Fragment
+	// reported are the fragments of the clusters found, by file
+	reported map[*file][]*Fragment
+	// powers are the powers of hashBase, to compute the hashes of token
+	// ranges
+	powers []uint64
+}
+
+const hashBase = 1000003
+
+func (d *detector) tokenID(token string) uint32 {
+	id, ok := d.tokenIDs[token]
+	if !ok {
+		id = uint32(len(d.tokenIDs))
+		d.tokenIDs[token] = id
+	}
+	return id
+}
+
+// addFile parses a file, and adds its normalized tokens, its subtrees
+// large enough to be duplicates, and its functions.
+func (d *detector) addFile(ctx context.Context, name string, lang string, source []byte) error {
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return err
+	}
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(sitterLang)
+	tree, err := parser.ParseCtx(ctx, nil, source)
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+
+	f := &file{name: name, lang: lang}
+	fragments := map[[2]uint32]*Fragment{}
+
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		first := len(f.tokens)
+		if token, ok := normalize(n, source); ok {
+			if token != "" {
+				f.tokens = append(f.tokens, d.tokenID(token))
+			}
+		} else {
+			for i := 0; i < int(n.ChildCount()); i++ {
+				walk(n.Child(i))
+			}
+		}
+
+		tokens := len(f.tokens) - first
+		lines := int(n.EndPoint().Row-n.StartPoint().Row) + 1
+		if !n.IsNamed() || tokens < d.options.MinTokens || lines < d.options.MinLines {
+			return
+		}
+		// keep the outermost node of the nodes spanning the same range
+		key := [2]uint32{n.StartByte(), n.EndByte()}
+		fragments[key] = &Fragment{
+			File:       name,
+			Language:   lang,
+			Type:       n.Type(),
+			StartPoint: n.StartPoint(),
+			EndPoint:   n.EndPoint(),
+			Tokens:     tokens,
+			startByte:  n.StartByte(),
+			endByte:    n.EndByte(),
+			first:      first,
+			last:       len(f.tokens),
+			file:       f,
+		}
+	}
+	walk(tree.RootNode())
+
+	f.hashes = make([]uint64, len(f.tokens)+1)
+	for i, t := range f.tokens {
+		f.hashes[i+1] = f.hashes[i]*hashBase + uint64(t) + 1
+	}
+	for _, fragment := range fragments {
+		d.fragments = append(d.fragments, fragment)
+	}
+
+	syms, err := symbols.Extract(lang, tree.RootNode(), source)
+	if err != nil {
+		return err
+	}
+	for _, s := range syms {
+		if s.Kind != symbols.KindFunction && s.Kind != symbols.KindMethod && s.Kind != symbols.KindConstructor {
+			continue
+		}
+		fragment, ok := fragments[[2]uint32{s.StartByte, s.EndByte}]
+		if !ok {
+			continue
+		}
+		fragment.Name = s.Name
+		if s.Container != "" {
+			fragment.Name = s.Container + "." + s.Name
+		}
+		d.functions = append(d.functions, fragment)
+	}
+	return nil
+}
+
+// normalize returns the normalized token of n if it is a leaf or a
+// literal, and false for the other nodes. Comments are normalized to no
+// token.
+func normalize(n *sitter.Node, source []byte) (string, bool) {
+	t := n.Type()
+	switch {
+	case strings.Contains(t, "comment"):
+		return "", true
+	case !n.IsNamed():
+		if n.ChildCount() == 0 {
+			return t, true
+		}
+		return "", false
+	case isLiteral(t):
+		return "$lit", true
+	case n.ChildCount() > 0:
+		return "", false
+	case strings.Contains(t, "identifier") || t == "name" || t == "constant":
+		return "$id", true
+	default:
+		// named leaves like primitive types, this or self
+		return n.Content(source), true
+	}
+}
+
+// isLiteral returns true for the node types of literals, but not for
+// function literals (func_literal in Go) and composite literals, which
+// contain code.
+func isLiteral(t string) bool {
+	switch t {
+	case "char", "true", "false", "nil", "null", "none", "undefined":
+		return true
+	case "func_literal", "composite_literal", "literal_value":
+		return false
+	}
+	return strings.Contains(t, "string") || strings.HasSuffix(t, "literal") ||
+		strings.Contains(t, "number") || strings.Contains(t, "integer") || strings.Contains(t, "float")
+}
+
+// hash returns the hash of the tokens from first to last of f.
+func (d *detector) hash(f *file, first, last int) uint64 {
+	for len(d.powers) <= last-first {
+		if len(d.powers) == 0 {
+			d.powers = append(d.powers, 1)
+			continue
+		}
+		d.powers = append(d.powers, d.powers[len(d.powers)-1]*hashBase)
+	}
+	return f.hashes[last] - f.hashes[first]*d.powers[last-first]
+}
+
+func (f *Fragment) tokens() []uint32 {
+	return f.file.tokens[f.first:f.last]
+}
+
+func (f *Fragment) contains(other *Fragment) bool {
+	return f.file == other.file && f.startByte <= other.startByte && other.endByte <= f.endByte
+}
+
+func equal(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// exact returns the clusters of fragments with the same normalized tokens,
+// largest first, skipping the clusters whose fragments are all contained in
+// the fragments of larger clusters.
+func (d *detector) exact() []*Cluster {
+	groups := map[[2]uint64][]*Fragment{}
+	for _, f := range d.fragments {
+		k := [2]uint64{d.hash(f.file, f.first, f.last), uint64(f.Tokens)}
+		groups[k] = append(groups[k], f)
+	}
+	candidates := [][]*Fragment{}
+	for _, group := range groups {
+		if len(group) > 1 {
+			sortFragments(group)
+			candidates = append(candidates, group)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i][0], candidates[j][0]
+		if a.Tokens != b.Tokens {
+			return a.Tokens > b.Tokens
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.startByte < b.startByte
+	})
+
+	ret := []*Cluster{}
+	d.reported = map[*file][]*Fragment{}
+	for _, group := range candidates {
+		fragments := []*Fragment{}
+		unreported := 0
+		for _, f := range group {
+			if !equal(f.tokens(), group[0].tokens()) {
+				continue
+			}
+			// nodes with the same tokens containing each other, like a file
+			// with a single function, keeping the innermost one
+			if len(fragments) > 0 {
+				last := fragments[len(fragments)-1]
+				if last.contains(f) || f.contains(last) {
+					continue
+				}
+			}
+			fragments = append(fragments, f)
+			if !d.isReported(f) {
+				unreported++
+			}
+		}
+		// the fragments of a cluster already reported, like the blocks of
+		// duplicated functions, are only reported again with new ones
+		if len(fragments) < 2 || unreported == 0 {
+			continue
+		}
+		for _, f := range fragments {
+			d.reported[f.file] = append(d.reported[f.file], f)
+		}
+		ret = append(ret, &Cluster{Exact: true, Similarity: 1, Fragments: fragments})
+	}
+	return ret
+}
+
+func (d *detector) isReported(f *Fragment) bool {
+	for _, r := range d.reported[f.file] {
+		if r.contains(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortFragments(fragments []*Fragment) {
+	sort.Slice(fragments, func(i, j int) bool {
+		a, b := fragments[i], fragments[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.startByte != b.startByte {
+			return a.startByte < b.startByte
+		}
+		return a.endByte < b.endByte
+	})
+}
+
+// shingleSize is the number of tokens of the shingles compared to find
+// near duplicates.
+const shingleSize = 5
+
+// near returns the clusters of functions, not already reported as exact
+// duplicates, whose sets of shingles (sequences of shingleSize normalized
+// tokens) have a Jaccard similarity of at least options.Similarity.
+func (d *detector) near() []*Cluster {
+	units := []*Fragment{}
+	for _, f := range d.functions {
+		if !d.isReported(f) {
+			units = append(units, f)
+		}
+	}
+	sortFragments(units)
+
+	shingles := make([]map[uint64]bool, len(units))
+	index := map[uint64][]int{}
+	for i, u := range units {
+		shingles[i] = map[uint64]bool{}
+		for first := u.first; first+shingleSize <= u.last; first++ {
+			shingles[i][d.hash(u.file, first, first+shingleSize)] = true
+		}
+		for h := range shingles[i] {
+			index[h] = append(index[h], i)
+		}
+	}
+
+	parents := make([]int, len(units))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	type edge struct {
+		a, b       int
+		similarity float64
+	}
+	edges := []edge{}
+	for i := range units {
+		shared := map[int]int{}
+		for h := range shingles[i] {
+			for _, j := range index[h] {
+				if j > i {
+					shared[j]++
+				}
+			}
+		}
+		for j, n := range shared {
+			similarity := float64(n) / float64(len(shingles[i])+len(shingles[j])-n)
+			if similarity < d.options.Similarity || units[i].contains(units[j]) || units[j].contains(units[i]) {
+				continue
+			}
+			edges = append(edges, edge{i, j, similarity})
+			parents[find(i)] = find(j)
+		}
+	}
+
+	clusters := map[int]*Cluster{}
+	for _, e := range edges {
+		root := find(e.a)
+		c, ok := clusters[root]
+		if !ok {
+			c = &Cluster{Similarity: 1}
+			clusters[root] = c
+		}
+		c.Similarity = min(c.Similarity, e.similarity)
+	}
+	for i, u := range units {
+		if c, ok := clusters[find(i)]; ok {
+			c.Fragments = append(c.Fragments, u)
+		}
+	}
+
+	ret := []*Cluster{}
+	for _, c := range clusters {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i].Fragments[0], ret[j].Fragments[0]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.startByte < b.startByte
+	})
+	return ret
+}
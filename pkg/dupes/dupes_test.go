@@ -0,0 +1,108 @@
+package dupes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sumSource = `package a
+
+func Sum(values []int, limit int) int {
+	total := 0
+	for i, v := range values {
+		if i >= limit {
+			break
+		}
+		total += v * 2
+	}
+	return total
+}
+`
+
+func TestFind(t *testing.T) {
+	renamed := strings.NewReplacer(
+		"package a", "package b",
+		"Sum", "Add",
+		"values", "xs",
+		"total", "acc",
+		"2", "3",
+	).Replace(sumSource) + "\nfunc Other() string { return \"\" }\n"
+	modified := strings.NewReplacer(
+		"package a", "package c",
+		"Sum", "Count",
+		"break\n", "break\n\t\t}\n\t\tif v < 0 {\n\t\t\tcontinue\n",
+	).Replace(sumSource)
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		options  Options
+		expected []string
+	}{
+		{
+			name:     "renamed copy",
+			files:    map[string]string{"a/a.go": sumSource, "b/b.go": renamed},
+			options:  Options{MinTokens: 20, MinLines: 3, Similarity: 1},
+			expected: []string{"exact 1.00 function_declaration a/a.go:3 function_declaration b/b.go:3"},
+		},
+		{
+			name:     "modified copy",
+			files:    map[string]string{"a/a.go": sumSource, "c/c.go": modified},
+			options:  Options{MinTokens: 20, MinLines: 3, Similarity: 0.75},
+			expected: []string{"near 0.79 Sum a/a.go:3 Count c/c.go:3"},
+		},
+		{
+			name:    "modified copy below the similarity",
+			files:   map[string]string{"a/a.go": sumSource, "c/c.go": modified},
+			options: Options{MinTokens: 20, MinLines: 3, Similarity: 0.8},
+		},
+		{
+			name:    "too small",
+			files:   map[string]string{"a/a.go": sumSource, "b/b.go": renamed},
+			options: Options{MinTokens: 200, MinLines: 3, Similarity: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clusters, err := Find(context.Background(), dir, []string{dir}, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, c := range clusters {
+				s := "near"
+				if c.Exact {
+					s = "exact"
+				}
+				s += fmt.Sprintf(" %.2f", c.Similarity)
+				for _, f := range c.Fragments {
+					name := f.Type
+					if !c.Exact {
+						name = f.Name
+					}
+					s += fmt.Sprintf(" %s %s:%d", name, f.File, f.StartPoint.Row+1)
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("got clusters:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
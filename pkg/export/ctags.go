@@ -0,0 +1,168 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg/symbols"
+)
+
+// ctagsLanguages maps the oak language names to the Universal Ctags ones.
+var ctagsLanguages = map[string]string{
+	"c":          "C",
+	"cpp":        "C++",
+	"csharp":     "C#",
+	"go":         "Go",
+	"golang":     "Go",
+	"java":       "Java",
+	"javascript": "JavaScript",
+	"php":        "PHP",
+	"python":     "Python",
+	"ruby":       "Ruby",
+	"rust":       "Rust",
+	"typescript": "TypeScript",
+	"tsx":        "TypeScript",
+}
+
+var ctagsHeader = []string{
+	"!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/",
+	"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/",
+	"!_TAG_OUTPUT_MODE\tu-ctags\t/u-ctags or e-ctags/",
+	"!_TAG_PROGRAM_NAME\toak\t//",
+	"!_TAG_PROGRAM_URL\thttps://github.com/go-go-golems/oak\t//",
+}
+
+type tag struct {
+	name string
+	file string
+	line int
+	text string
+}
+
+// WriteCtags writes the definitions of the project as a tags file in the
+// extended format of Universal Ctags, sorted by name, with the kind, line,
+// language, end, scope and signature fields. File names are relative to the
+// root of the project.
+func WriteCtags(w io.Writer, p *Project) error {
+	tags := []tag{}
+	for _, f := range p.Files {
+		lines := bytes.Split(f.Source, []byte("\n"))
+		language := ctagsLanguages[f.Language]
+		if language == "" {
+			language = f.Language
+		}
+
+		for _, d := range f.Definitions {
+			line := int(d.NameStartPoint.Row) + 1
+			fields := []string{
+				"kind:" + string(d.Kind),
+				fmt.Sprintf("line:%d", line),
+				"language:" + language,
+			}
+			if scope := ctagsScope(d); scope != "" {
+				fields = append(fields, "scope:"+scope)
+			}
+			if signature := parameters(d); signature != "" {
+				fields = append(fields, "signature:"+escapeCtagsField(signature))
+			}
+			fields = append(fields, fmt.Sprintf("end:%d", d.EndPoint.Row+1))
+
+			pattern := ""
+			if int(d.NameStartPoint.Row) < len(lines) {
+				pattern = strings.TrimSuffix(string(lines[d.NameStartPoint.Row]), "\r")
+			}
+			tags = append(tags, tag{
+				name: d.Name,
+				file: f.Path,
+				line: line,
+				text: fmt.Sprintf("%s\t%s\t/^%s$/;\"\t%s",
+					d.Name, f.Path, escapeCtagsPattern(pattern), strings.Join(fields, "\t")),
+			})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].name != tags[j].name {
+			return tags[i].name < tags[j].name
+		}
+		if tags[i].file != tags[j].file {
+			return tags[i].file < tags[j].file
+		}
+		return tags[i].line < tags[j].line
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, line := range ctagsHeader {
+		_, _ = bw.WriteString(line + "\n")
+	}
+	for _, t := range tags {
+		_, _ = bw.WriteString(t.text + "\n")
+	}
+	return bw.Flush()
+}
+
+// ctagsScope returns the scope field of a definition, the kind and the
+// qualified name of its parent. When the parent isn't found, the container
+// is reported as a type.
+func ctagsScope(d *Definition) string {
+	if d.Parent != nil {
+		return string(d.Parent.Kind) + ":" + d.Parent.QualifiedName()
+	}
+	if d.Container != "" {
+		return string(symbols.KindType) + ":" + d.Container
+	}
+	return ""
+}
+
+// parameters returns the parameter list of a function or method, the first
+// parenthesized group following its name in its signature.
+func parameters(d *Definition) string {
+	if d.Kind != symbols.KindFunction && d.Kind != symbols.KindMethod && d.Kind != symbols.KindConstructor {
+		return ""
+	}
+
+	start := strings.Index(d.Signature, d.Name+"(")
+	if start < 0 {
+		start = strings.Index(d.Signature, d.Name)
+	}
+	if start < 0 {
+		return ""
+	}
+	open := strings.IndexByte(d.Signature[start+len(d.Name):], '(')
+	if open < 0 {
+		return ""
+	}
+	open += start + len(d.Name)
+
+	depth := 0
+	for i := open; i < len(d.Signature); i++ {
+		switch d.Signature[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				// signatures spanning several lines can have a space after
+				// the parenthesis, and a trailing comma
+				ret := strings.Replace(d.Signature[open:i+1], "( ", "(", 1)
+				if strings.HasSuffix(ret, " )") {
+					ret = strings.TrimSuffix(strings.TrimSuffix(ret, " )"), ",") + ")"
+				}
+				return ret
+			}
+		}
+	}
+	return ""
+}
+
+func escapeCtagsPattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(s)
+}
+
+func escapeCtagsField(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\t", `\t`).Replace(s)
+}
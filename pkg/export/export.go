@@ -0,0 +1,220 @@
+// Package export writes the symbols of a repository, as extracted by the
+// symbols package, in the formats of code navigation tools: Universal Ctags
+// tags files and SCIP indexes.
+package export
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg/symbols"
+	"github.com/pkg/errors"
+)
+
+// Project is the set of files to export.
+type Project struct {
+	// Root is the absolute path of the directory the paths of the files
+	// are relative to.
+	Root string
+	// Files are sorted by path.
+	Files []*File
+
+	byName map[string][]*Definition
+}
+
+// File is a source file with its definitions and references.
+type File struct {
+	// Path is relative to the root of the project, with forward slashes.
+	Path        string
+	Language    string
+	Source      []byte
+	Definitions []*Definition
+	References  []*symbols.Reference
+}
+
+// Definition is a symbol with the definition it is nested in, or belongs to.
+type Definition struct {
+	*symbols.Symbol
+	File *File
+	// Parent is the definition containing this one, or the definition named
+	// like the container of the symbol (such as the receiver type of a Go
+	// method) in the same namespace. It is nil for top-level definitions,
+	// and when the container isn't found.
+	Parent *Definition
+}
+
+// Collect parses the given files, and the files in the given directories,
+// which need to be inside root.
+func Collect(ctx context.Context, root string, sources []string) (*Project, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	p := &Project{Root: absRoot, byName: map[string][]*Definition{}}
+
+	seen := map[string]bool{}
+	for _, source := range sources {
+		err = symbols.WalkFiles(source, func(fileName string, lang string, info os.FileInfo) error {
+			abs, err := filepath.Abs(fileName)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(absRoot, abs)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == ".." || strings.HasPrefix(rel, "../") {
+				return errors.Errorf("%s is not inside the root %s", fileName, absRoot)
+			}
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+
+			content, err := os.ReadFile(fileName)
+			if err != nil {
+				return errors.Wrapf(err, "could not read file %s", fileName)
+			}
+			symbols_, references, err := symbols.ExtractWithReferences(ctx, lang, content)
+			if err != nil {
+				return errors.Wrapf(err, "could not extract the symbols of %s", fileName)
+			}
+
+			f := &File{
+				Path:       rel,
+				Language:   lang,
+				Source:     content,
+				References: references,
+			}
+			for _, s := range symbols_ {
+				f.Definitions = append(f.Definitions, &Definition{Symbol: s, File: f})
+			}
+			p.Files = append(p.Files, f)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(p.Files, func(i, j int) bool {
+		return p.Files[i].Path < p.Files[j].Path
+	})
+	for _, f := range p.Files {
+		for _, d := range f.Definitions {
+			p.byName[d.Name] = append(p.byName[d.Name], d)
+		}
+	}
+	for _, f := range p.Files {
+		p.resolveParents(f)
+	}
+
+	return p, nil
+}
+
+// Namespace returns the namespace of the definitions of a file: the
+// package directory for Go, where definitions are shared by the files of a
+// directory, and the file itself for the other languages.
+func (f *File) Namespace() string {
+	if f.Language == "go" || f.Language == "golang" {
+		return path.Dir(f.Path)
+	}
+	return f.Path
+}
+
+func (p *Project) resolveParents(f *File) {
+	stack := []*Definition{}
+	for _, d := range f.Definitions {
+		for len(stack) > 0 && !stack[len(stack)-1].Contains(d.StartByte, d.EndByte) {
+			stack = stack[:len(stack)-1]
+		}
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].StartByte != d.StartByte || stack[j].EndByte != d.EndByte {
+				d.Parent = stack[j]
+				break
+			}
+		}
+		stack = append(stack, d)
+
+		if d.Parent != nil || d.Container == "" {
+			continue
+		}
+		for _, candidate := range p.byName[d.Container] {
+			if candidate != d && candidate.File.Namespace() == f.Namespace() && IsType(candidate.Kind) {
+				d.Parent = candidate
+				break
+			}
+		}
+	}
+}
+
+// IsType returns true for the kinds of the definitions of types.
+func IsType(kind symbols.Kind) bool {
+	switch kind {
+	case symbols.KindClass, symbols.KindStruct, symbols.KindInterface, symbols.KindEnum, symbols.KindType:
+		return true
+	case symbols.KindFunction, symbols.KindMethod, symbols.KindConstructor, symbols.KindEnumMember,
+		symbols.KindField, symbols.KindProperty, symbols.KindConstant, symbols.KindVariable,
+		symbols.KindModule, symbols.KindNamespace:
+		return false
+	}
+	return false
+}
+
+// Resolve returns the definition a reference of a file refers to, by name:
+// the only definition with that name in the file, else in the namespace of
+// the file, else in the project. Ambiguous references are not resolved.
+func (p *Project) Resolve(f *File, r *symbols.Reference) *Definition {
+	candidates := p.byName[r.Name]
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	for _, inScope := range []func(d *Definition) bool{
+		func(d *Definition) bool { return d.File == f },
+		func(d *Definition) bool { return d.File.Namespace() == f.Namespace() },
+		func(d *Definition) bool { return true },
+	} {
+		var found *Definition
+		count := 0
+		for _, d := range candidates {
+			if inScope(d) {
+				found = d
+				count++
+			}
+		}
+		if count == 1 {
+			return found
+		}
+		if count > 1 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Definitions returns the definitions with the given name.
+func (p *Project) Definitions(name string) []*Definition {
+	return p.byName[name]
+}
+
+// QualifiedName returns the names of the parents of the definition and its
+// name, separated by dots. The container is used when the parent isn't
+// found.
+func (d *Definition) QualifiedName() string {
+	names := []string{d.Name}
+	top := d
+	for parent := d.Parent; parent != nil; parent = parent.Parent {
+		names = append([]string{parent.Name}, names...)
+		top = parent
+	}
+	if top.Container != "" {
+		names = append([]string{top.Container}, names...)
+	}
+	return strings.Join(names, ".")
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const testSource = `package a
+
+type T struct{}
+
+func (t T) M(n int) {}
+
+func F() { T{}.M(1) }
+`
+
+func collectTestProject(t *testing.T) *Project {
+	t.Helper()
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "a", "a.go"), []byte(testSource), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Collect(context.Background(), dir, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestWriteCtags(t *testing.T) {
+	p := collectTestProject(t)
+
+	var b bytes.Buffer
+	if err := WriteCtags(&b, p); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "!_TAG_") {
+			tags = append(tags, line)
+		}
+	}
+	expected := []string{
+		"F\ta/a.go\t/^func F() { T{}.M(1) }$/;\"\tkind:function\tline:7\tlanguage:Go\tsignature:()\tend:7",
+		"M\ta/a.go\t/^func (t T) M(n int) {}$/;\"\tkind:method\tline:5\tlanguage:Go\tscope:struct:T\tsignature:(n int)\tend:5",
+		"T\ta/a.go\t/^type T struct{}$/;\"\tkind:struct\tline:3\tlanguage:Go\tend:3",
+	}
+	if strings.Join(tags, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got tags:\n%s\nexpected:\n%s", strings.Join(tags, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestWriteSCIP(t *testing.T) {
+	p := collectTestProject(t)
+
+	var b bytes.Buffer
+	if err := WriteSCIP(&b, p, SCIPOptions{Package: "p"}); err != nil {
+		t.Fatal(err)
+	}
+
+	documents := fieldsOf(t, b.Bytes(), scipIndexDocuments)
+	if len(documents) != 1 {
+		t.Fatalf("got %d documents, expected 1", len(documents))
+	}
+	document := documents[0]
+	if path := string(fieldsOf(t, document, scipDocumentRelativePath)[0]); path != "a/a.go" {
+		t.Errorf("got path %s, expected a/a.go", path)
+	}
+
+	// occurrences as "line:start-end symbol roles"
+	occurrences := []string{}
+	for _, occurrence := range fieldsOf(t, document, scipDocumentOccurrences) {
+		r := fieldsOf(t, occurrence, scipOccurrenceRange)[0]
+		values := []uint64{}
+		for len(r) > 0 {
+			v, n := protowire.ConsumeVarint(r)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			values = append(values, v)
+			r = r[n:]
+		}
+		roles := uint64(0)
+		if role := fieldsOf(t, occurrence, scipOccurrenceSymbolRoles); len(role) > 0 {
+			roles, _ = protowire.ConsumeVarint(role[0])
+		}
+		occurrences = append(occurrences, fmt.Sprintf("%v %s %d",
+			values, fieldsOf(t, occurrence, scipOccurrenceSymbol)[0], roles))
+	}
+	expected := []string{
+		"[2 5 6] oak . p . a/T# 1",
+		"[4 8 9] oak . p . a/T# 0",
+		"[4 11 12] oak . p . a/T#M(). 1",
+		"[6 5 6] oak . p . a/F(). 1",
+		"[6 11 12] oak . p . a/T# 0",
+		"[6 15 16] oak . p . a/T#M(). 0",
+	}
+	if strings.Join(occurrences, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got occurrences:\n%s\nexpected:\n%s", strings.Join(occurrences, "\n"), strings.Join(expected, "\n"))
+	}
+
+	symbols := []string{}
+	for _, information := range fieldsOf(t, document, scipDocumentSymbols) {
+		symbol := string(fieldsOf(t, information, scipSymbolInformationSymbol)[0])
+		if enclosing := fieldsOf(t, information, scipSymbolInformationEnclosingSymbol); len(enclosing) > 0 {
+			symbol += " in " + string(enclosing[0])
+		}
+		symbols = append(symbols, symbol)
+	}
+	expected = []string{
+		"oak . p . a/T#",
+		"oak . p . a/T#M(). in oak . p . a/T#",
+		"oak . p . a/F().",
+	}
+	if strings.Join(symbols, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got symbols:\n%s\nexpected:\n%s", strings.Join(symbols, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+// fieldsOf returns the values of the length-delimited fields num of a
+// protobuf message.
+func fieldsOf(t *testing.T, message []byte, num protowire.Number) [][]byte {
+	t.Helper()
+	ret := [][]byte{}
+	for len(message) > 0 {
+		n, typ, l := protowire.ConsumeTag(message)
+		if l < 0 {
+			t.Fatal(protowire.ParseError(l))
+		}
+		message = message[l:]
+		if typ == protowire.BytesType {
+			v, l := protowire.ConsumeBytes(message)
+			if l < 0 {
+				t.Fatal(protowire.ParseError(l))
+			}
+			if n == num {
+				ret = append(ret, v)
+			}
+			message = message[l:]
+			continue
+		}
+		l = protowire.ConsumeFieldValue(n, typ, message)
+		if l < 0 {
+			t.Fatal(protowire.ParseError(l))
+		}
+		if n == num {
+			ret = append(ret, message[:l])
+		}
+		message = message[l:]
+	}
+	return ret
+}
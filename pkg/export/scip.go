@@ -0,0 +1,326 @@
+package export
+
+import (
+	"io"
+	"path"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg/symbols"
+	sitter "github.com/smacker/go-tree-sitter"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The SCIP index is encoded by hand, with the field numbers of scip.proto,
+// see https://github.com/sourcegraph/scip/blob/main/scip.proto. Only the
+// fields written by oak are listed.
+const (
+	scipIndexMetadata  = 1
+	scipIndexDocuments = 2
+
+	scipMetadataToolInfo             = 2
+	scipMetadataProjectRoot          = 3
+	scipMetadataTextDocumentEncoding = 4
+
+	scipToolInfoName      = 1
+	scipToolInfoVersion   = 2
+	scipToolInfoArguments = 3
+
+	scipDocumentRelativePath     = 1
+	scipDocumentOccurrences      = 2
+	scipDocumentSymbols          = 3
+	scipDocumentLanguage         = 4
+	scipDocumentPositionEncoding = 6
+
+	scipOccurrenceRange          = 1
+	scipOccurrenceSymbol         = 2
+	scipOccurrenceSymbolRoles    = 3
+	scipOccurrenceEnclosingRange = 7
+
+	scipSymbolInformationSymbol          = 1
+	scipSymbolInformationDocumentation   = 3
+	scipSymbolInformationDisplayName     = 6
+	scipSymbolInformationEnclosingSymbol = 8
+
+	scipTextEncodingUTF8                    = 1
+	scipPositionEncodingUTF8CodeUnitOffset  = 1
+	scipSymbolRoleDefinition                = 1
+	scipSymbolScheme                        = "oak"
+	scipSymbolPackageManagerAndVersionEmpty = "."
+)
+
+// scipLanguages maps the oak language names to the names of the Language
+// enum of SCIP.
+var scipLanguages = map[string]string{
+	"c":          "C",
+	"cpp":        "CPP",
+	"csharp":     "CSharp",
+	"go":         "Go",
+	"golang":     "Go",
+	"java":       "Java",
+	"javascript": "JavaScript",
+	"php":        "PHP",
+	"python":     "Python",
+	"ruby":       "Ruby",
+	"rust":       "Rust",
+	"typescript": "TypeScript",
+	"tsx":        "TypeScriptReact",
+}
+
+// SCIPOptions are the metadata of a SCIP index.
+type SCIPOptions struct {
+	// Package is the name of the package in the symbols, the name of the
+	// root directory by default.
+	Package string
+	// Arguments are the arguments oak was run with.
+	Arguments []string
+}
+
+type scipOccurrence struct {
+	start, end     sitter.Point
+	enclosingStart sitter.Point
+	enclosingEnd   sitter.Point
+	symbol         string
+	roles          int
+	enclosing      bool
+}
+
+// WriteSCIP writes the project as a SCIP index, with a document per file.
+// Definitions are global symbols named after their namespace (see
+// File.namespace) and parents, and references are the identifiers resolved
+// to a single definition by Project.Resolve. Positions are in UTF-8 code
+// units.
+func WriteSCIP(w io.Writer, p *Project, options SCIPOptions) error {
+	if options.Package == "" {
+		options.Package = path.Base(strings.ReplaceAll(p.Root, "\\", "/"))
+	}
+	names := p.scipSymbols(options.Package)
+
+	toolInfo := appendString(nil, scipToolInfoName, "oak")
+	toolInfo = appendString(toolInfo, scipToolInfoVersion, version())
+	for _, arg := range options.Arguments {
+		toolInfo = appendString(toolInfo, scipToolInfoArguments, arg)
+	}
+	metadata := appendMessage(nil, scipMetadataToolInfo, toolInfo)
+	metadata = appendString(metadata, scipMetadataProjectRoot, "file://"+strings.ReplaceAll(p.Root, "\\", "/"))
+	metadata = appendVarint(metadata, scipMetadataTextDocumentEncoding, scipTextEncodingUTF8)
+
+	index := appendMessage(nil, scipIndexMetadata, metadata)
+	for _, f := range p.Files {
+		index = appendMessage(index, scipIndexDocuments, p.scipDocument(f, names))
+	}
+
+	_, err := w.Write(index)
+	return err
+}
+
+func (p *Project) scipDocument(f *File, names map[*Definition]string) []byte {
+	language := scipLanguages[f.Language]
+	if language == "" {
+		language = f.Language
+	}
+
+	occurrences := []scipOccurrence{}
+	for _, d := range f.Definitions {
+		occurrences = append(occurrences, scipOccurrence{
+			start:          d.NameStartPoint,
+			end:            d.NameEndPoint,
+			enclosingStart: d.StartPoint,
+			enclosingEnd:   d.EndPoint,
+			symbol:         names[d],
+			roles:          scipSymbolRoleDefinition,
+			enclosing:      true,
+		})
+	}
+	for _, r := range f.References {
+		d := p.Resolve(f, r)
+		if d == nil {
+			continue
+		}
+		occurrences = append(occurrences, scipOccurrence{
+			start:  r.StartPoint,
+			end:    r.EndPoint,
+			symbol: names[d],
+		})
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		a, b := occurrences[i].start, occurrences[j].start
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		return a.Column < b.Column
+	})
+
+	document := appendString(nil, scipDocumentRelativePath, f.Path)
+	for _, o := range occurrences {
+		occurrence := appendRange(nil, scipOccurrenceRange, o.start, o.end)
+		occurrence = appendString(occurrence, scipOccurrenceSymbol, o.symbol)
+		if o.roles != 0 {
+			occurrence = appendVarint(occurrence, scipOccurrenceSymbolRoles, uint64(o.roles))
+		}
+		if o.enclosing {
+			occurrence = appendRange(occurrence, scipOccurrenceEnclosingRange, o.enclosingStart, o.enclosingEnd)
+		}
+		document = appendMessage(document, scipDocumentOccurrences, occurrence)
+	}
+
+	seen := map[string]bool{}
+	for _, d := range f.Definitions {
+		name := names[d]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		information := appendString(nil, scipSymbolInformationSymbol, name)
+		if d.Signature != "" {
+			information = appendString(information, scipSymbolInformationDocumentation,
+				"```"+strings.ToLower(language)+"\n"+d.Signature+"\n```")
+		}
+		if d.Doc != "" {
+			information = appendString(information, scipSymbolInformationDocumentation, d.Doc)
+		}
+		information = appendString(information, scipSymbolInformationDisplayName, d.Name)
+		if d.Parent != nil {
+			information = appendString(information, scipSymbolInformationEnclosingSymbol, names[d.Parent])
+		}
+		document = appendMessage(document, scipDocumentSymbols, information)
+	}
+
+	document = appendString(document, scipDocumentLanguage, language)
+	document = appendVarint(document, scipDocumentPositionEncoding, scipPositionEncodingUTF8CodeUnitOffset)
+	return document
+}
+
+// scipSymbols returns the SCIP symbols of the definitions of the project.
+// Methods defined more than once in a namespace, such as overloads, get a
+// disambiguator.
+func (p *Project) scipSymbols(pkg string) map[*Definition]string {
+	prefix := scipSymbolScheme + " " +
+		scipSymbolPackageManagerAndVersionEmpty + " " +
+		strings.ReplaceAll(pkg, " ", "  ") + " " +
+		scipSymbolPackageManagerAndVersionEmpty + " "
+
+	ret := map[*Definition]string{}
+	used := map[string]*Definition{}
+	var descriptors func(d *Definition) string
+	descriptors = func(d *Definition) string {
+		if name, ok := ret[d]; ok {
+			return strings.TrimPrefix(name, prefix)
+		}
+
+		var parent string
+		if d.Parent != nil {
+			parent = descriptors(d.Parent)
+		} else {
+			for _, segment := range strings.Split(d.File.Namespace(), "/") {
+				if segment != "." && segment != "" {
+					parent += escapeDescriptor(segment) + "/"
+				}
+			}
+			if d.Container != "" {
+				parent += escapeDescriptor(d.Container) + "#"
+			}
+		}
+
+		descriptor := ""
+		for n := 0; ; n++ {
+			descriptor = parent + scipDescriptor(d, n)
+			if other, ok := used[descriptor]; !ok || other == d || !isCallable(d.Kind) {
+				break
+			}
+		}
+		used[descriptor] = d
+		ret[d] = prefix + descriptor
+		return descriptor
+	}
+
+	for _, f := range p.Files {
+		for _, d := range f.Definitions {
+			descriptors(d)
+		}
+	}
+	return ret
+}
+
+func isCallable(kind symbols.Kind) bool {
+	return kind == symbols.KindFunction || kind == symbols.KindMethod || kind == symbols.KindConstructor
+}
+
+// scipDescriptor returns the descriptor of a definition: a method for
+// functions, a type for classes and types, a namespace for modules and a
+// term for the others. n is the disambiguator of methods.
+func scipDescriptor(d *Definition, n int) string {
+	name := escapeDescriptor(d.Name)
+	switch {
+	case isCallable(d.Kind):
+		if n > 0 {
+			return name + "(+" + strconv.Itoa(n) + ")."
+		}
+		return name + "()."
+	case IsType(d.Kind):
+		return name + "#"
+	case d.Kind == symbols.KindModule || d.Kind == symbols.KindNamespace:
+		return name + "/"
+	}
+	return name + "."
+}
+
+// escapeDescriptor escapes names with characters other than letters,
+// digits, _, +, - and $ with backticks.
+func escapeDescriptor(name string) string {
+	simple := name != ""
+	for _, r := range name {
+		if !(r == '_' || r == '+' || r == '-' || r == '$' ||
+			('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')) {
+			simple = false
+			break
+		}
+	}
+	if simple {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "dev"
+	}
+	return info.Main.Version
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendRange appends a SCIP range, as a packed list of 0-based
+// [startLine, startCharacter, endLine, endCharacter], without the end line
+// when it is the start line.
+func appendRange(b []byte, num protowire.Number, start, end sitter.Point) []byte {
+	values := []uint32{start.Row, start.Column}
+	if end.Row != start.Row {
+		values = append(values, end.Row)
+	}
+	values = append(values, end.Column)
+
+	packed := []byte{}
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, uint64(v))
+	}
+	return appendMessage(b, num, packed)
+}
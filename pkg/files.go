@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MaxFileSize is the size above which WalkSourceFiles skips files, as they
+// are usually generated or minified.
+const MaxFileSize = 1 << 20
+
+// WalkSourceFiles calls fn for source if it is a file, or for the files in
+// the directory source, whose language (see FileNameToLanguageName) is
+// accepted by accept. Hidden and dependency directories, and files larger
+// than MaxFileSize, are skipped.
+func WalkSourceFiles(
+	source string,
+	accept func(lang string) bool,
+	fn func(fileName string, lang string, info os.FileInfo) error,
+) error {
+	visit := func(fileName string, info os.FileInfo) error {
+		lang, err := FileNameToLanguageName(fileName)
+		if err != nil || !accept(lang) {
+			return nil
+		}
+		if info.Size() > MaxFileSize {
+			log.Debug().Str("file", fileName).Int64("size", info.Size()).Msg("skipping large file")
+			return nil
+		}
+		return fn(fileName, lang, info)
+	}
+
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return visit(source, fi)
+	}
+
+	return filepath.WalkDir(source, func(fileName string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if fileName != source && IsSkippedDirectory(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return visit(fileName, info)
+	})
+}
+
+// IsSkippedDirectory returns true for hidden and dependency directories.
+func IsSkippedDirectory(name string) bool {
+	return (len(name) > 1 && name[0] == '.') || name == "node_modules" || name == "vendor"
+}
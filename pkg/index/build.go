@@ -0,0 +1,277 @@
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// BuildStats describes what Build did.
+type BuildStats struct {
+	// Files is the number of files found with a supported language.
+	Files int
+	// Indexed is the number of new or changed files that were parsed.
+	Indexed int
+	// Removed is the number of files removed from the index because they
+	// don't exist anymore.
+	Removed    int
+	Symbols    int
+	References int
+}
+
+type fileRow struct {
+	id    int64
+	mtime int64
+	size  int64
+	hash  string
+}
+
+// Build updates the index with the files in the given files and
+// directories, which need to be inside the root of the index. Files whose
+// modification time and size didn't change are skipped, as are files whose
+// content hash didn't change. Indexed files under the given directories that
+// don't exist anymore are removed.
+func (i *Index) Build(ctx context.Context, sources []string) (*BuildStats, error) {
+	stats := &BuildStats{}
+
+	existing := map[string]*fileRow{}
+	rows, err := i.db.QueryContext(ctx, "SELECT id, path, mtime, size, hash FROM files")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var path string
+		f := &fileRow{}
+		err = rows.Scan(&f.id, &path, &f.mtime, &f.size, &f.hash)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		existing[path] = f
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	seen := map[string]bool{}
+	prefixes := []string{}
+	for _, source := range sources {
+		rel, err := i.relativePath(source)
+		if err != nil {
+			return nil, err
+		}
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, errors.Errorf("%s is not inside the root of the index %s", source, i.root)
+		}
+		prefixes = append(prefixes, rel)
+
+		err = symbols.WalkFiles(source, func(fileName string, lang string, info os.FileInfo) error {
+			path, err := i.relativePath(fileName)
+			if err != nil {
+				return err
+			}
+			if seen[path] {
+				return nil
+			}
+			seen[path] = true
+			stats.Files++
+
+			f := existing[path]
+			if f != nil && f.mtime == info.ModTime().UnixNano() && f.size == info.Size() {
+				return nil
+			}
+
+			content, err := os.ReadFile(fileName)
+			if err != nil {
+				return errors.Wrapf(err, "could not read file %s", fileName)
+			}
+			sum := sha256.Sum256(content)
+			hash := hex.EncodeToString(sum[:])
+
+			if f != nil && f.hash == hash {
+				_, err = tx.ExecContext(ctx, "UPDATE files SET mtime = ?, size = ? WHERE id = ?",
+					info.ModTime().UnixNano(), info.Size(), f.id)
+				return err
+			}
+
+			if f != nil {
+				_, err = tx.ExecContext(ctx, "DELETE FROM files WHERE id = ?", f.id)
+				if err != nil {
+					return err
+				}
+			}
+			log.Debug().Str("file", path).Msg("indexing")
+			symbolCount, referenceCount, err := indexFile(ctx, tx, path, lang, info, hash, content)
+			if err != nil {
+				return errors.Wrapf(err, "could not index file %s", fileName)
+			}
+			stats.Indexed++
+			stats.Symbols += symbolCount
+			stats.References += referenceCount
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for path, f := range existing {
+		if seen[path] || !underAny(path, prefixes) {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM files WHERE id = ?", f.id)
+		if err != nil {
+			return nil, err
+		}
+		stats.Removed++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// underAny returns true if path is one of prefixes, or is in one of the
+// directories in prefixes.
+func underAny(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix == "." || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFile parses source and inserts the file, its symbols and its
+// references.
+func indexFile(
+	ctx context.Context,
+	tx *sql.Tx,
+	path string,
+	lang string,
+	info os.FileInfo,
+	hash string,
+	source []byte,
+) (int, int, error) {
+	symbols_, references, err := symbols.ExtractWithReferences(ctx, lang, source)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO files (path, language, mtime, size, hash) VALUES (?, ?, ?, ?, ?)",
+		path, lang, info.ModTime().UnixNano(), info.Size(), hash)
+	if err != nil {
+		return 0, 0, err
+	}
+	fileID, err := res.LastInsertId()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	insertSymbol, err := tx.PrepareContext(ctx, `INSERT INTO symbols (
+		file_id, parent_id, name, kind, container, name_line, name_column,
+		start_line, start_column, end_line, end_column, start_byte, end_byte,
+		signature, doc
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = insertSymbol.Close()
+	}()
+
+	ids := map[*symbols.Symbol]int64{}
+	stack := []*symbols.Symbol{}
+	for _, s := range symbols_ {
+		for len(stack) > 0 && !stack[len(stack)-1].Contains(s.StartByte, s.EndByte) {
+			stack = stack[:len(stack)-1]
+		}
+		parent := parentSymbol(symbols_, stack, s)
+		stack = append(stack, s)
+
+		var parentID sql.NullInt64
+		if parent != nil {
+			parentID = sql.NullInt64{Int64: ids[parent], Valid: ids[parent] != 0}
+		}
+		res, err := insertSymbol.ExecContext(ctx,
+			fileID, parentID, s.Name, string(s.Kind), s.Container,
+			s.NameStartPoint.Row+1, pkg.Column(source, s.NameStartByte, s.NameStartPoint),
+			s.StartPoint.Row+1, pkg.Column(source, s.StartByte, s.StartPoint),
+			s.EndPoint.Row+1, pkg.Column(source, s.EndByte, s.EndPoint),
+			s.StartByte, s.EndByte,
+			s.Signature, s.Doc)
+		if err != nil {
+			return 0, 0, err
+		}
+		ids[s], err = res.LastInsertId()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	insertReference, err := tx.PrepareContext(ctx, `INSERT INTO refs (
+		file_id, symbol_id, name, type, start_line, start_column, start_byte, end_byte
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = insertReference.Close()
+	}()
+
+	for _, r := range references {
+		var symbolID sql.NullInt64
+		if s := symbols.Enclosing(symbols_, r.StartByte, r.EndByte); s != nil {
+			symbolID = sql.NullInt64{Int64: ids[s], Valid: true}
+		}
+		_, err = insertReference.ExecContext(ctx,
+			fileID, symbolID, r.Name, r.Type,
+			r.StartPoint.Row+1, pkg.Column(source, r.StartByte, r.StartPoint),
+			r.StartByte, r.EndByte)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(symbols_), len(references), nil
+}
+
+// parentSymbol returns the symbol s is nested in, or, for symbols declared
+// outside of their container such as Go methods, the symbol of the file
+// named like the container.
+func parentSymbol(all []*symbols.Symbol, stack []*symbols.Symbol, s *symbols.Symbol) *symbols.Symbol {
+	for j := len(stack) - 1; j >= 0; j-- {
+		if stack[j].StartByte != s.StartByte || stack[j].EndByte != s.EndByte {
+			return stack[j]
+		}
+	}
+	if s.Container == "" {
+		return nil
+	}
+	for _, other := range all {
+		if other != s && other.Name == s.Container && other.StartByte < s.StartByte {
+			return other
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package index stores the definitions and references of the files of a
+// repository in a SQLite database, extracted with the symbols package, for
+// fast cross-file navigation without a language server.
+//
+// Paths are stored relative to the root of the index, the directory
+// containing the .oak directory of the database (or the directory of the
+// database, if it is not in a .oak directory).
+package index
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath is the default location of the index, relative to the root
+// of the repository.
+const DefaultPath = ".oak/index.db"
+
+// schemaVersion needs to be increased when the schema or the extracted data
+// change. Databases with another version are rebuilt from scratch.
+const schemaVersion = "1"
+
+const schema = `
+CREATE TABLE meta (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE files (
+	id INTEGER PRIMARY KEY,
+	path TEXT NOT NULL UNIQUE,
+	language TEXT NOT NULL,
+	mtime INTEGER NOT NULL,
+	size INTEGER NOT NULL,
+	hash TEXT NOT NULL
+);
+
+CREATE TABLE symbols (
+	id INTEGER PRIMARY KEY,
+	file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	parent_id INTEGER REFERENCES symbols(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	container TEXT NOT NULL,
+	name_line INTEGER NOT NULL,
+	name_column INTEGER NOT NULL,
+	start_line INTEGER NOT NULL,
+	start_column INTEGER NOT NULL,
+	end_line INTEGER NOT NULL,
+	end_column INTEGER NOT NULL,
+	start_byte INTEGER NOT NULL,
+	end_byte INTEGER NOT NULL,
+	signature TEXT NOT NULL,
+	doc TEXT NOT NULL
+);
+CREATE INDEX symbols_name ON symbols(name);
+CREATE INDEX symbols_file ON symbols(file_id);
+
+CREATE TABLE refs (
+	id INTEGER PRIMARY KEY,
+	file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	symbol_id INTEGER REFERENCES symbols(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	type TEXT NOT NULL,
+	start_line INTEGER NOT NULL,
+	start_column INTEGER NOT NULL,
+	start_byte INTEGER NOT NULL,
+	end_byte INTEGER NOT NULL
+);
+CREATE INDEX refs_name ON refs(name);
+CREATE INDEX refs_file ON refs(file_id);
+`
+
+// Index is an open symbol index.
+type Index struct {
+	db   *sql.DB
+	root string
+}
+
+// Root returns the absolute path of the directory the paths of the index are
+// relative to.
+func (i *Index) Root() string {
+	return i.root
+}
+
+// RootForPath returns the root of an index stored at path.
+func RootForPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(abs)
+	if filepath.Base(dir) == ".oak" {
+		return filepath.Dir(dir), nil
+	}
+	return dir, nil
+}
+
+// Locate returns the path of the index to open for path. When path is the
+// relative DefaultPath and doesn't exist, the parents of the current
+// directory are searched for it, so that the index of a repository can be
+// used from any of its subdirectories.
+func Locate(path string) string {
+	if path != DefaultPath {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	for {
+		candidate := filepath.Join(dir, DefaultPath)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
+
+// Open opens the index at path, creating it if create is true. Indexes
+// created by another version of oak are emptied, and need to be built
+// again.
+func Open(path string, create bool) (*Index, error) {
+	root, err := RootForPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) || !create {
+			return nil, errors.Wrapf(err, "could not open index %s (run oak index build)", path)
+		}
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	i := &Index{db: db, root: root}
+
+	err = i.migrate()
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Wrapf(err, "could not initialize index %s", path)
+	}
+	return i, nil
+}
+
+func (i *Index) migrate() error {
+	var version string
+	err := i.db.QueryRow("SELECT value FROM meta WHERE key = 'version'").Scan(&version)
+	if err == nil && version == schemaVersion {
+		return nil
+	}
+
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	for _, table := range []string{"refs", "symbols", "files", "meta"} {
+		_, err = tx.Exec("DROP TABLE IF EXISTS " + table)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(schema)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO meta (key, value) VALUES ('version', ?)", schemaVersion)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database.
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// relativePath returns the path of fileName relative to the root of the
+// index, with forward slashes.
+func (i *Index) relativePath(fileName string) (string, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(i.root, abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// DisplayPath returns a path of the index relative to the current
+// directory, for output.
+func (i *Index) DisplayPath(path string) string {
+	abs := filepath.Join(i.root, filepath.FromSlash(path))
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return abs
+	}
+	return rel
+}
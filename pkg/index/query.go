@@ -0,0 +1,199 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Match is how names are matched by FindSymbols and FindReferences. Matching
+// is case-sensitive.
+type Match string
+
+const (
+	MatchExact    Match = "exact"
+	MatchPrefix   Match = "prefix"
+	MatchContains Match = "contains"
+)
+
+// Matches are the valid values of Match.
+var Matches = []string{string(MatchExact), string(MatchPrefix), string(MatchContains)}
+
+// Filter restricts the results of FindSymbols and FindReferences. Empty
+// fields don't restrict anything.
+type Filter struct {
+	Name  string
+	Match Match
+	// Kind is only used by FindSymbols.
+	Kind     string
+	Language string
+	// Path is a file or directory, relative to the current directory.
+	Path string
+}
+
+// SymbolResult is a definition found by FindSymbols.
+type SymbolResult struct {
+	Name      string
+	Kind      string
+	Container string
+	// Parent is the name of the symbol the definition is nested in, or
+	// belongs to, if it is in the same file.
+	Parent   string
+	File     string
+	Language string
+
+	// Line and Column locate the name of the definition, StartLine,
+	// StartColumn, EndLine and EndColumn the whole definition. They are
+	// 1-based, with columns counted in unicode code points.
+	Line        int
+	Column      int
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+
+	Signature string
+	Doc       string
+}
+
+// ReferenceResult is a use of an identifier found by FindReferences.
+type ReferenceResult struct {
+	Name string
+	// Type is the type of the identifier node, such as field_identifier.
+	Type     string
+	File     string
+	Language string
+	Line     int
+	Column   int
+	// Symbol is the innermost definition containing the reference, prefixed
+	// with its container, or "" at the top level.
+	Symbol string
+}
+
+// where builds the conditions of the filter, for tables aliased as t (with
+// the name and kind columns) and f (files).
+func (i *Index) where(filter Filter) (string, []interface{}, error) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if filter.Name != "" {
+		switch filter.Match {
+		case MatchExact, "":
+			conditions = append(conditions, "t.name = ?")
+			args = append(args, filter.Name)
+		case MatchPrefix:
+			conditions = append(conditions, "substr(t.name, 1, length(?)) = ?")
+			args = append(args, filter.Name, filter.Name)
+		case MatchContains:
+			conditions = append(conditions, "instr(t.name, ?) > 0")
+			args = append(args, filter.Name)
+		default:
+			return "", nil, errors.Errorf("unknown match %s, expected one of %s",
+				filter.Match, strings.Join(Matches, ", "))
+		}
+	}
+	if filter.Kind != "" {
+		conditions = append(conditions, "t.kind = ?")
+		args = append(args, filter.Kind)
+	}
+	if filter.Language != "" {
+		conditions = append(conditions, "f.language = ?")
+		args = append(args, filter.Language)
+	}
+	if filter.Path != "" {
+		path, err := i.relativePath(filter.Path)
+		if err != nil {
+			return "", nil, err
+		}
+		if path != "." {
+			conditions = append(conditions, "(f.path = ? OR substr(f.path, 1, length(?) + 1) = ? || '/')")
+			args = append(args, path, path, path)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// FindSymbols returns the definitions matching filter, sorted by file and
+// position.
+func (i *Index) FindSymbols(ctx context.Context, filter Filter) ([]*SymbolResult, error) {
+	where, args, err := i.where(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT t.name, t.kind, t.container, COALESCE(p.name, ''), f.path, f.language,
+			t.name_line, t.name_column, t.start_line, t.start_column, t.end_line, t.end_column,
+			t.signature, t.doc
+		FROM symbols t
+		JOIN files f ON f.id = t.file_id
+		LEFT JOIN symbols p ON p.id = t.parent_id
+		`+where+`
+		ORDER BY f.path, t.start_byte`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	ret := []*SymbolResult{}
+	for rows.Next() {
+		s := &SymbolResult{}
+		err = rows.Scan(&s.Name, &s.Kind, &s.Container, &s.Parent, &s.File, &s.Language,
+			&s.Line, &s.Column, &s.StartLine, &s.StartColumn, &s.EndLine, &s.EndColumn,
+			&s.Signature, &s.Doc)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, rows.Err()
+}
+
+// FindReferences returns the references matching filter, sorted by file and
+// position. References are matched by name only, see symbols.Reference.
+func (i *Index) FindReferences(ctx context.Context, filter Filter) ([]*ReferenceResult, error) {
+	if filter.Kind != "" {
+		return nil, errors.New("references can't be filtered by kind")
+	}
+	where, args, err := i.where(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT t.name, t.type, f.path, f.language, t.start_line, t.start_column,
+			s.name, s.container
+		FROM refs t
+		JOIN files f ON f.id = t.file_id
+		LEFT JOIN symbols s ON s.id = t.symbol_id
+		`+where+`
+		ORDER BY f.path, t.start_byte`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	ret := []*ReferenceResult{}
+	for rows.Next() {
+		r := &ReferenceResult{}
+		var symbol, container sql.NullString
+		err = rows.Scan(&r.Name, &r.Type, &r.File, &r.Language, &r.Line, &r.Column, &symbol, &container)
+		if err != nil {
+			return nil, err
+		}
+		r.Symbol = symbol.String
+		if container.String != "" {
+			r.Symbol = container.String + "." + symbol.String
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
@@ -158,16 +158,26 @@ func GetLanguageGlobs(lang string) ([]string, error) {
 }
 
 func FileNameToSitterLanguage(filename string) (*sitter.Language, error) {
+	name, err := FileNameToLanguageName(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LanguageNameToSitterLanguage(name)
+}
+
+// FileNameToLanguageName returns the name of the language of the given file,
+// based on its file ending.
+func FileNameToLanguageName(filename string) (string, error) {
 	baseName := path.Base(filename)
 	for ending, name := range fileEndingToLanguageName {
 		// use glob
 		matched, err := path.Match(ending, baseName)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		if matched {
-			return LanguageNameToSitterLanguage(name[0])
+			return name[0], nil
 		}
 	}
-	return nil, errors.Errorf("unsupported file name: %s", filename)
+	return "", errors.Errorf("unsupported file name: %s", filename)
 }
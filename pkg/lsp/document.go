@@ -0,0 +1,259 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// text is the content of a document, along with the offsets of its lines,
+// to convert between LSP positions (in UTF-16 code units) and byte offsets.
+type text struct {
+	content []byte
+	lines   []int
+}
+
+func newText(content []byte) *text {
+	lines := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &text{content: content, lines: lines}
+}
+
+func (t *text) lineEnd(line int) int {
+	if line+1 < len(t.lines) {
+		return t.lines[line+1] - 1
+	}
+	return len(t.content)
+}
+
+// offset returns the byte offset of a position, clamped to the content.
+func (t *text) offset(p Position) int {
+	if int(p.Line) >= len(t.lines) {
+		return len(t.content)
+	}
+	offset, end := t.lines[p.Line], t.lineEnd(int(p.Line))
+	for units := uint32(0); offset < end && units < p.Character; {
+		r, size := utf8.DecodeRune(t.content[offset:end])
+		units += uint32(utf16RuneLen(r))
+		offset += size
+	}
+	return offset
+}
+
+// pointPosition converts a tree-sitter point (with a column in bytes) to a
+// position.
+func (t *text) pointPosition(p sitter.Point) Position {
+	if int(p.Row) >= len(t.lines) {
+		return Position{Line: uint32(len(t.lines) - 1), Character: 0}
+	}
+	start := t.lines[p.Row]
+	end := start + int(p.Column)
+	if end > len(t.content) {
+		end = len(t.content)
+	}
+	character := 0
+	for _, r := range string(t.content[start:end]) {
+		character += utf16RuneLen(r)
+	}
+	return Position{Line: p.Row, Character: uint32(character)}
+}
+
+func (t *text) pointRange(start, end sitter.Point) Range {
+	return Range{Start: t.pointPosition(start), End: t.pointPosition(end)}
+}
+
+// point converts a position to a tree-sitter point.
+func (t *text) point(p Position) sitter.Point {
+	offset := t.offset(p)
+	return tree_sitter.PointAt(t.content, offset)
+}
+
+func utf16RuneLen(r rune) int {
+	if n := utf16.RuneLen(r); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// languageIDs maps the LSP language identifiers that differ from the oak
+// language names.
+var languageIDs = map[string]string{
+	"javascriptreact": "javascript",
+	"typescriptreact": "tsx",
+	"shellscript":     "bash",
+	"terraform":       "hcl",
+	"proto":           "protobuf",
+}
+
+// documentLanguage returns the oak language of a document, from its file
+// name or its LSP language identifier, or "" if it is not supported.
+func documentLanguage(path string, languageID string) string {
+	if lang, err := pkg.FileNameToLanguageName(path); err == nil {
+		return lang
+	}
+	if lang, ok := languageIDs[languageID]; ok {
+		return lang
+	}
+	if _, err := pkg.LanguageNameToSitterLanguage(languageID); err == nil {
+		return languageID
+	}
+	return ""
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// document is an open text document, kept parsed as it is edited.
+type document struct {
+	uri      string
+	path     string
+	language string
+	version  int
+	text     *text
+
+	oc   *cmds.OakCommand
+	tree *sitter.Tree
+	// symbols are extracted on demand, and reset when the document changes
+	symbols []*symbols.Symbol
+}
+
+func newDocument(ctx context.Context, item TextDocumentItem) (*document, error) {
+	d := &document{
+		uri:     item.URI,
+		path:    uriToPath(item.URI),
+		version: item.Version,
+		text:    newText([]byte(item.Text)),
+	}
+	d.language = documentLanguage(d.path, item.LanguageID)
+	if d.language == "" {
+		return d, nil
+	}
+
+	d.oc = cmds.NewOakWriterCommand(
+		glazed_cmds.NewCommandDescription("lsp"),
+		cmds.WithLanguage(d.language)).OakCommand
+	tree, err := d.oc.Parse(ctx, nil, d.text.content)
+	if err != nil {
+		return nil, err
+	}
+	d.tree = tree
+	return d, nil
+}
+
+// update applies the changes to the document, and re-parses it
+// incrementally.
+func (d *document) update(ctx context.Context, version int, changes []TextDocumentContentChangeEvent) error {
+	old := d.text
+	for _, change := range changes {
+		if change.Range == nil {
+			d.text = newText([]byte(change.Text))
+			continue
+		}
+		start, end := d.text.offset(change.Range.Start), d.text.offset(change.Range.End)
+		if end < start {
+			start, end = end, start
+		}
+		content := make([]byte, 0, len(d.text.content)-(end-start)+len(change.Text))
+		content = append(content, d.text.content[:start]...)
+		content = append(content, change.Text...)
+		content = append(content, d.text.content[end:]...)
+		d.text = newText(content)
+	}
+	d.version = version
+	d.symbols = nil
+
+	if d.tree == nil {
+		return nil
+	}
+	d.tree.Edit(tree_sitter.ComputeEdit(old.content, d.text.content))
+	tree, err := d.oc.Parse(ctx, d.tree, d.text.content)
+	if err != nil {
+		return err
+	}
+	d.tree = tree
+	return nil
+}
+
+func (d *document) getSymbols() ([]*symbols.Symbol, error) {
+	if d.tree == nil || !symbols.HasLanguage(d.language) {
+		return nil, nil
+	}
+	if d.symbols == nil {
+		symbols_, err := symbols.Extract(d.language, d.tree.RootNode(), d.text.content)
+		if err != nil {
+			return nil, err
+		}
+		d.symbols = symbols_
+	}
+	return d.symbols, nil
+}
+
+// nodePath returns the smallest named node at the position, and the path of
+// node types (prefixed with their field name) leading to it from the root.
+func (d *document) nodePath(p Position) (*sitter.Node, []string) {
+	if d.tree == nil {
+		return nil, nil
+	}
+	point := d.text.point(p)
+	node := d.tree.RootNode().NamedDescendantForPointRange(point, point)
+	if node == nil {
+		return nil, nil
+	}
+
+	path := []string{}
+	for n := node; n != nil; n = n.Parent() {
+		step := n.Type()
+		if parent := n.Parent(); parent != nil {
+			fieldNames := tree_sitter.ChildFieldNames(parent)
+			for i := 0; i < int(parent.ChildCount()); i++ {
+				if parent.Child(i).Equal(n) {
+					if field := fieldNames[i]; field != "" {
+						step = fmt.Sprintf("%s: %s", field, step)
+					}
+					break
+				}
+			}
+		}
+		path = append(path, step)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return node, path
+}
+
+func hoverContent(path []string) string {
+	var b strings.Builder
+	b.WriteString("```\n")
+	for i, step := range path {
+		b.WriteString(strings.Repeat("  ", i))
+		b.WriteString(step)
+		b.WriteString("\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
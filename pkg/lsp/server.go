@@ -0,0 +1,352 @@
+// Package lsp implements a language server on top of oak: document and
+// workspace symbols extracted with the queries of the symbols package,
+// diagnostics reported by the rules of oak commands (see cmds.Rule), and
+// hovers showing the path of syntax nodes at the cursor.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Server is a language server communicating over a single connection,
+// usually stdin and stdout. Messages are handled one at a time, in order.
+type Server struct {
+	conn *conn
+
+	// rules are the commands with rules, with their queries rendered with
+	// the default values of their flags.
+	rules []*cmds.OakCommand
+
+	initialized  bool
+	shutdown     bool
+	hierarchical bool
+	documents    map[string]*document
+	workspace    *workspace
+}
+
+// NewServer creates a language server reading requests from r and writing
+// responses to w. The commands with rules are used to compute diagnostics,
+// the other ones are ignored.
+func NewServer(r io.Reader, w io.Writer, commands []glazed_cmds.Command) *Server {
+	s := &Server{
+		conn:      newConn(r, w),
+		documents: map[string]*document{},
+	}
+
+	for _, command := range commands {
+		oc, err := cmds.RuleCommand(command)
+		if err != nil {
+			log.Warn().Err(err).Str("command", command.Description().FullPath()).Msg("could not render rule queries")
+			continue
+		}
+		if oc != nil {
+			s.rules = append(s.rules, oc)
+		}
+	}
+
+	return s
+}
+
+// Run handles messages until the client sends exit, or closes the
+// connection.
+func (s *Server) Run(ctx context.Context) error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var rpcErr *rpcError
+			if errors.As(err, &rpcErr) {
+				_ = s.conn.reply(nil, nil, rpcErr)
+				continue
+			}
+			return err
+		}
+
+		switch {
+		case msg.Method == "exit":
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+
+		case msg.ID != nil && msg.Method != "":
+			result, err := s.handleRequest(ctx, msg.Method, msg.Params)
+			if err != nil {
+				log.Debug().Err(err).Str("method", msg.Method).Msg("request failed")
+			}
+			err = s.conn.reply(msg.ID, result, err)
+			if err != nil {
+				return err
+			}
+
+		case msg.Method != "":
+			err = s.handleNotification(ctx, msg.Method, msg.Params)
+			if err != nil {
+				log.Warn().Err(err).Str("method", msg.Method).Msg("could not handle notification")
+			}
+		}
+	}
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	err := json.Unmarshal(params, v)
+	if err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) handleRequest(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	if !s.initialized && method != "initialize" {
+		return nil, &rpcError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	}
+
+	switch method {
+	case "initialize":
+		p := &InitializeParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		return s.initialize(p), nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		p := &DocumentSymbolParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		return s.documentSymbol(p)
+
+	case "workspace/symbol":
+		p := &WorkspaceSymbolParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		return s.workspaceSymbol(ctx, p)
+
+	case "textDocument/hover":
+		p := &HoverParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		return s.hover(p), nil
+	}
+
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
+
+func (s *Server) handleNotification(ctx context.Context, method string, params json.RawMessage) error {
+	switch method {
+	case "textDocument/didOpen":
+		p := &DidOpenTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		d, err := newDocument(ctx, p.TextDocument)
+		if err != nil {
+			return err
+		}
+		s.documents[d.uri] = d
+		return s.publishDiagnostics(d)
+
+	case "textDocument/didChange":
+		p := &DidChangeTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		d, ok := s.documents[p.TextDocument.URI]
+		if !ok {
+			return errors.Errorf("document %s is not open", p.TextDocument.URI)
+		}
+		err := d.update(ctx, p.TextDocument.Version, p.ContentChanges)
+		if err != nil {
+			return err
+		}
+		return s.publishDiagnostics(d)
+
+	case "textDocument/didClose":
+		p := &DidCloseTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		delete(s.documents, p.TextDocument.URI)
+		return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	}
+
+	// initialized, didSave, $/cancelRequest, ... are ignored
+	return nil
+}
+
+func (s *Server) initialize(p *InitializeParams) interface{} {
+	s.initialized = true
+	s.hierarchical = p.Capabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport
+
+	roots := []string{}
+	for _, folder := range p.WorkspaceFolders {
+		roots = append(roots, uriToPath(folder.URI))
+	}
+	if len(roots) == 0 && p.RootURI != "" {
+		roots = append(roots, uriToPath(p.RootURI))
+	}
+	if len(roots) == 0 && p.RootPath != "" {
+		roots = append(roots, filepath.Clean(p.RootPath))
+	}
+	s.workspace = newWorkspace(roots)
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				// incremental
+				"change": 2,
+			},
+			"documentSymbolProvider":  true,
+			"workspaceSymbolProvider": true,
+			"hoverProvider":           true,
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "oak",
+		},
+	}
+}
+
+func (s *Server) documentSymbol(p *DocumentSymbolParams) (interface{}, error) {
+	d, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "document is not open: " + p.TextDocument.URI}
+	}
+	symbols_, err := d.getSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.hierarchical {
+		return symbolInformation(d.uri, d.text, symbols_), nil
+	}
+
+	// symbols are sorted by position, with enclosing symbols first
+	ret := []*DocumentSymbol{}
+	type entry struct {
+		symbol *symbols.Symbol
+		ds     *DocumentSymbol
+	}
+	stack := []entry{}
+	for _, symbol := range symbols_ {
+		ds := &DocumentSymbol{
+			Name:           symbol.Name,
+			Detail:         string(symbol.Kind),
+			Kind:           symbolKind(symbol.Kind),
+			Range:          d.text.pointRange(symbol.StartPoint, symbol.EndPoint),
+			SelectionRange: d.text.pointRange(symbol.NameStartPoint, symbol.NameEndPoint),
+		}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1].symbol
+			if top.Contains(symbol.StartByte, symbol.EndByte) &&
+				(top.StartByte != symbol.StartByte || top.EndByte != symbol.EndByte) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1].ds
+			parent.Children = append(parent.Children, ds)
+		} else {
+			ret = append(ret, ds)
+		}
+		stack = append(stack, entry{symbol: symbol, ds: ds})
+	}
+	return ret, nil
+}
+
+func (s *Server) workspaceSymbol(ctx context.Context, p *WorkspaceSymbolParams) (interface{}, error) {
+	s.workspace.update(ctx)
+
+	open := map[string][]SymbolInformation{}
+	for _, d := range s.documents {
+		symbols_, err := d.getSymbols()
+		if err != nil {
+			return nil, err
+		}
+		open[d.path] = symbolInformation(d.uri, d.text, symbols_)
+	}
+
+	return s.workspace.search(p.Query, open), nil
+}
+
+func (s *Server) hover(p *HoverParams) interface{} {
+	d, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	node, path := d.nodePath(p.Position)
+	if node == nil {
+		return nil
+	}
+	range_ := d.text.pointRange(node.StartPoint(), node.EndPoint())
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: hoverContent(path)},
+		Range:    &range_,
+	}
+}
+
+// publishDiagnostics runs the rules for the language of the document, and
+// sends the resulting diagnostics to the client.
+func (s *Server) publishDiagnostics(d *document) error {
+	diagnostics := []Diagnostic{}
+
+	if d.tree != nil {
+		for _, oc := range s.rules {
+			if oc.Language != d.language {
+				continue
+			}
+			lang, err := oc.GetLanguage()
+			if err != nil {
+				return err
+			}
+			results, err := tree_sitter.ExecuteQueries(lang, d.tree.RootNode(), oc.Queries, d.text.content)
+			if err != nil {
+				log.Warn().Err(err).Str("command", oc.FullPath()).Msg("could not run rule queries")
+				continue
+			}
+			diagnostics_, err := oc.Diagnostics(results)
+			if err != nil {
+				log.Warn().Err(err).Str("command", oc.FullPath()).Msg("could not compute diagnostics")
+				continue
+			}
+			for _, diagnostic := range diagnostics_ {
+				diagnostics = append(diagnostics, Diagnostic{
+					Range:    d.text.pointRange(diagnostic.StartPoint, diagnostic.EndPoint),
+					Severity: diagnosticSeverities[diagnostic.Rule.Severity],
+					Code:     diagnostic.Rule.ID,
+					Source:   "oak",
+					Message:  diagnostic.Message,
+				})
+			}
+		}
+	}
+
+	version := d.version
+	return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     &version,
+		Diagnostics: diagnostics,
+	})
+}
@@ -0,0 +1,136 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-go-golems/oak/pkg/cmds"
+	"github.com/pkg/errors"
+)
+
+const printlnCommand = `name: prints
+language: go
+queries:
+  - name: printlnCalls
+    query: |
+      (call_expression
+        function: (selector_expression
+          operand: (identifier) @package
+          field: (field_identifier) @function)
+        (#eq? @package "fmt")
+        (#eq? @function "Println")) @call
+rules:
+  - id: no-println
+    query: printlnCalls
+    capture: call
+    severity: error
+    message: use the logger instead of fmt.{{ .function.Text }}
+`
+
+// session sends the given messages to a server with the prints command,
+// and returns the messages it sent back.
+func session(t *testing.T, messages ...*message) []*message {
+	t.Helper()
+
+	fsys := fstest.MapFS{"prints.yaml": {Data: []byte(printlnCommand)}}
+	commands, err := (&cmds.OakCommandLoader{}).LoadCommands(fsys, "prints.yaml", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var in, out bytes.Buffer
+	c := newConn(nil, &in)
+	for _, msg := range messages {
+		if err := c.write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = NewServer(&in, &out, commands).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret := []*message{}
+	c = newConn(&out, nil)
+	for {
+		msg, err := c.read()
+		if errors.Is(err, io.EOF) {
+			return ret
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, msg)
+	}
+}
+
+func request(t *testing.T, id int, method string, params interface{}) *message {
+	t.Helper()
+	b, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawID := json.RawMessage(fmt.Sprint(id))
+	return &message{ID: &rawID, Method: method, Params: b}
+}
+
+func notification(t *testing.T, method string, params interface{}) *message {
+	t.Helper()
+	b, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &message{Method: method, Params: b}
+}
+
+func TestServer(t *testing.T) {
+	uri := "file:///tmp/a.go"
+	source := "package a\n\nimport \"fmt\"\n\nfunc F() {\n\tfmt.Println(\"hello\")\n}\n"
+
+	responses := session(t,
+		request(t, 1, "initialize", &InitializeParams{}),
+		notification(t, "initialized", struct{}{}),
+		notification(t, "textDocument/didOpen", &DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: source},
+		}),
+		request(t, 2, "textDocument/documentSymbol", &DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		}),
+		request(t, 3, "shutdown", nil),
+		notification(t, "exit", nil),
+	)
+	if len(responses) != 4 {
+		t.Fatalf("got %d messages, expected 4", len(responses))
+	}
+
+	diagnostics := &PublishDiagnosticsParams{}
+	if err := json.Unmarshal(responses[1].Params, diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	expected := Diagnostic{
+		Range:    Range{Start: Position{Line: 5, Character: 1}, End: Position{Line: 5, Character: 21}},
+		Severity: SeverityError,
+		Code:     "no-println",
+		Source:   "oak",
+		Message:  "use the logger instead of fmt.Println",
+	}
+	if responses[1].Method != "textDocument/publishDiagnostics" ||
+		len(diagnostics.Diagnostics) != 1 || diagnostics.Diagnostics[0] != expected {
+		t.Errorf("got diagnostics %+v, expected %+v", diagnostics.Diagnostics, expected)
+	}
+
+	symbols := []SymbolInformation{}
+	if err := json.Unmarshal(responses[2].Result, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 1 || symbols[0].Name != "F" || symbols[0].Kind != SymbolKindFunction ||
+		symbols[0].Location.Range.Start.Line != 4 {
+		t.Errorf("unexpected symbols %+v", symbols)
+	}
+}
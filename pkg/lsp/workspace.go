@@ -0,0 +1,187 @@
+package lsp
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxWorkspaceFileSize is the size above which files are not indexed,
+	// as they are most likely generated.
+	maxWorkspaceFileSize = 1 << 20
+	// maxWorkspaceSymbols is the maximum number of symbols returned by
+	// workspace/symbol.
+	maxWorkspaceSymbols = 1000
+)
+
+// skippedDirectories are not indexed, in addition to hidden directories.
+var skippedDirectories = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+type workspaceFile struct {
+	modTime time.Time
+	size    int64
+	symbols []SymbolInformation
+}
+
+// workspace indexes the symbols of the files in the workspace folders. Files
+// are re-indexed when their modification time or size change.
+type workspace struct {
+	roots []string
+	files map[string]*workspaceFile
+}
+
+func newWorkspace(roots []string) *workspace {
+	return &workspace{roots: roots, files: map[string]*workspaceFile{}}
+}
+
+// update walks the workspace folders and indexes the files that changed
+// since the last update.
+func (w *workspace) update(ctx context.Context) {
+	seen := map[string]bool{}
+	for _, root := range w.roots {
+		_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if entry.IsDir() {
+				name := entry.Name()
+				if path != root && (strings.HasPrefix(name, ".") || skippedDirectories[name]) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			lang, err := pkg.FileNameToLanguageName(path)
+			if err != nil || !symbols.HasLanguage(lang) {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || info.Size() > maxWorkspaceFileSize {
+				return nil
+			}
+			seen[path] = true
+
+			f, ok := w.files[path]
+			if ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+				return nil
+			}
+
+			symbols_, err := indexFile(ctx, path, lang)
+			if err != nil {
+				log.Debug().Err(err).Str("file", path).Msg("could not index file")
+				return nil
+			}
+			w.files[path] = &workspaceFile{
+				modTime: info.ModTime(),
+				size:    info.Size(),
+				symbols: symbols_,
+			}
+			return nil
+		})
+	}
+
+	for path := range w.files {
+		if !seen[path] {
+			delete(w.files, path)
+		}
+	}
+}
+
+func indexFile(ctx context.Context, path string, lang string) ([]SymbolInformation, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	symbols_, err := symbols.ExtractFromSource(ctx, lang, content)
+	if err != nil {
+		return nil, err
+	}
+	return symbolInformation(pathToURI(path), newText(content), symbols_), nil
+}
+
+func symbolInformation(uri string, t *text, symbols_ []*symbols.Symbol) []SymbolInformation {
+	ret := make([]SymbolInformation, 0, len(symbols_))
+	for _, s := range symbols_ {
+		ret = append(ret, SymbolInformation{
+			Name:          s.Name,
+			Kind:          symbolKind(s.Kind),
+			ContainerName: s.Container,
+			Location: Location{
+				URI:   uri,
+				Range: t.pointRange(s.NameStartPoint, s.NameEndPoint),
+			},
+		})
+	}
+	return ret
+}
+
+// matchesQuery returns true if the characters of query appear in name, in
+// order, ignoring case, so that "hdlreq" matches "handleRequest".
+func matchesQuery(name string, query string) bool {
+	name, query = strings.ToLower(name), strings.ToLower(query)
+	for _, r := range query {
+		idx := strings.IndexRune(name, r)
+		if idx < 0 {
+			return false
+		}
+		name = name[idx+len(string(r)):]
+	}
+	return true
+}
+
+// search returns the symbols matching the query, from the indexed files
+// and the open documents, which take precedence over the files on disk.
+func (w *workspace) search(query string, open map[string][]SymbolInformation) []SymbolInformation {
+	ret := []SymbolInformation{}
+	add := func(symbols_ []SymbolInformation) {
+		for _, s := range symbols_ {
+			if matchesQuery(s.Name, query) {
+				ret = append(ret, s)
+			}
+		}
+	}
+
+	for path, f := range w.files {
+		if _, ok := open[path]; ok {
+			continue
+		}
+		add(f.symbols)
+	}
+	for _, symbols_ := range open {
+		add(symbols_)
+	}
+
+	// exact and shorter matches first
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if (a.Name == query) != (b.Name == query) {
+			return a.Name == query
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Location.URI < b.Location.URI
+	})
+	if len(ret) > maxWorkspaceSymbols {
+		ret = ret[:maxWorkspaceSymbols]
+	}
+	return ret
+}
@@ -0,0 +1,256 @@
+// Package metrics computes the size, nesting depth and cyclomatic complexity
+// of the functions and methods found by the symbols package, counting the
+// node types listed for each language by pkg.GetLanguageSyntax.
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/symbols"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Function holds the metrics of a function or method.
+type Function struct {
+	*symbols.Symbol
+	File     string
+	Language string
+
+	// Lines is the number of lines of the definition, and LOC the number of
+	// those lines that have code, not only comments or whitespace.
+	Lines int
+	LOC   int
+	// Parameters is the number of declared parameters, including self in
+	// Python and Rust, but not the receiver of Go methods.
+	Parameters int
+	// Nesting is the maximum nesting depth of the code of the function, 0 if
+	// it has no conditional, loop, ...
+	Nesting int
+	// Complexity is the cyclomatic complexity: 1 plus the number of
+	// branches of the function.
+	Complexity int
+}
+
+// HasLanguage returns true if metrics can be computed for the language.
+func HasLanguage(lang string) bool {
+	_, ok := pkg.GetLanguageSyntax(lang)
+	return ok && symbols.HasLanguage(lang)
+}
+
+// Collect computes the metrics of the functions of the given files, and of
+// the files in the given directories. File paths are relative to root when
+// they are inside it. Functions are sorted by file and position.
+func Collect(ctx context.Context, root string, sources []string) ([]*Function, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Function{}
+	seen := map[string]bool{}
+	for _, source := range sources {
+		err = pkg.WalkSourceFiles(source, HasLanguage, func(fileName string, lang string, info os.FileInfo) error {
+			name := fileName
+			if abs, err := filepath.Abs(fileName); err == nil {
+				if rel, err := filepath.Rel(absRoot, abs); err == nil && !strings.HasPrefix(rel, "..") {
+					name = filepath.ToSlash(rel)
+				}
+			}
+			if seen[name] {
+				return nil
+			}
+			seen[name] = true
+
+			content, err := os.ReadFile(fileName)
+			if err != nil {
+				return errors.Wrapf(err, "could not read file %s", fileName)
+			}
+			functions, err := Compute(ctx, lang, content)
+			if err != nil {
+				return errors.Wrapf(err, "could not compute the metrics of %s", fileName)
+			}
+			for _, f := range functions {
+				f.File = name
+			}
+			ret = append(ret, functions...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].File != ret[j].File {
+			return ret[i].File < ret[j].File
+		}
+		return ret[i].StartByte < ret[j].StartByte
+	})
+	return ret, nil
+}
+
+// Compute parses source and returns the metrics of its functions and
+// methods.
+func Compute(ctx context.Context, lang string, source []byte) ([]*Function, error) {
+	syntax, ok := pkg.GetLanguageSyntax(lang)
+	if !ok {
+		return nil, errors.Errorf("no metrics for language %s", lang)
+	}
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(sitterLang)
+	tree, err := parser.ParseCtx(ctx, nil, source)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	syms, err := symbols.Extract(lang, tree.RootNode(), source)
+	if err != nil {
+		return nil, err
+	}
+
+	// nested functions are measured on their own, and not as part of the
+	// function containing them
+	functionRanges := map[[2]uint32]bool{}
+	for _, s := range syms {
+		if isFunction(s.Kind) {
+			functionRanges[[2]uint32{s.StartByte, s.EndByte}] = true
+		}
+	}
+
+	c := &counter{
+		branches:  set(syntax.Branches),
+		operators: set(syntax.Operators),
+		nesting:   set(syntax.Nesting),
+		skip:      functionRanges,
+	}
+	ret := []*Function{}
+	for _, s := range syms {
+		if !isFunction(s.Kind) {
+			continue
+		}
+		n := pkg.NodeForRange(tree.RootNode(), s.StartByte, s.EndByte)
+		f := &Function{
+			Symbol:     s,
+			Language:   lang,
+			Lines:      int(s.EndPoint.Row-s.StartPoint.Row) + 1,
+			Parameters: countParameters(n, source),
+		}
+		c.measure(n, f)
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+func isFunction(kind symbols.Kind) bool {
+	return kind == symbols.KindFunction || kind == symbols.KindMethod || kind == symbols.KindConstructor
+}
+
+func set(values []string) map[string]bool {
+	ret := map[string]bool{}
+	for _, v := range values {
+		ret[v] = true
+	}
+	return ret
+}
+
+type counter struct {
+	branches  map[string]bool
+	operators map[string]bool
+	nesting   map[string]bool
+	skip      map[[2]uint32]bool
+}
+
+// measure walks the definition node of a function, and sets its LOC,
+// nesting depth and complexity.
+func (c *counter) measure(root *sitter.Node, f *Function) {
+	codeLines := map[uint32]bool{}
+	f.Complexity = 1
+
+	var walk func(n *sitter.Node, depth int)
+	walk = func(n *sitter.Node, depth int) {
+		if n != root && c.skip[[2]uint32{n.StartByte(), n.EndByte()}] {
+			return
+		}
+		t := n.Type()
+		if n.ChildCount() == 0 && !strings.Contains(t, "comment") {
+			for row := n.StartPoint().Row; row <= n.EndPoint().Row; row++ {
+				codeLines[row] = true
+			}
+		}
+		// keywords are anonymous nodes named like the statements
+		if (n.IsNamed() && c.branches[t]) || (!n.IsNamed() && c.operators[t]) {
+			f.Complexity++
+		}
+		if n.IsNamed() && c.nesting[t] && !isElseIf(n) {
+			depth++
+			if depth > f.Nesting {
+				f.Nesting = depth
+			}
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			walk(n.Child(i), depth)
+		}
+	}
+	walk(root, 0)
+
+	f.LOC = len(codeLines)
+}
+
+// isElseIf returns true for an if that is the else branch of another if,
+// directly or through an else clause.
+func isElseIf(n *sitter.Node) bool {
+	parent := n.Parent()
+	if parent == nil {
+		return false
+	}
+	if parent.Type() == n.Type() {
+		return true
+	}
+	if strings.Contains(parent.Type(), "else") {
+		grandParent := parent.Parent()
+		return grandParent != nil && grandParent.Type() == n.Type()
+	}
+	return false
+}
+
+// countParameters returns the number of parameters of the function
+// defined by n: the named children of the first parameters field found
+// outside of the body, counting each name of parameters declaring several
+// ones, like `a, b int` in Go.
+func countParameters(n *sitter.Node, source []byte) int {
+	params, single := tree_sitter.FindParameters(n)
+	if single {
+		return 1
+	}
+	if params == nil {
+		return 0
+	}
+	count := 0
+	for i := 0; i < int(params.NamedChildCount()); i++ {
+		p := params.NamedChild(i)
+		// f(void) in C
+		if strings.Contains(p.Type(), "comment") || p.Content(source) == "void" {
+			continue
+		}
+		names := tree_sitter.CountDeclaredNames(p)
+		if names > 1 {
+			count += names
+		} else {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCountParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		lang       string
+		source     string
+		parameters map[string]int
+	}{
+		{
+			name: "go names sharing a type",
+			lang: "go",
+			source: `package a
+
+func a(b, c, d int) {}
+func e(f int, g, h string, i ...int) {}
+func (r *R) m(x int) {}
+func n() {}
+`,
+			parameters: map[string]int{"a": 3, "e": 4, "m": 1, "n": 0},
+		},
+		{
+			name: "python",
+			lang: "python",
+			source: `def f(self, a, b=1, *args, **kwargs):
+    pass
+`,
+			parameters: map[string]int{"f": 5},
+		},
+		{
+			name:       "typescript",
+			lang:       "typescript",
+			source:     "function f(a: number, b?: string, ...rest: number[]) {}\nconst g = x => x;\n",
+			parameters: map[string]int{"f": 3, "g": 1},
+		},
+		{
+			name:       "c void",
+			lang:       "c",
+			source:     "int f(void) { return 0; }\nint g(int a, char *b) { return a; }\n",
+			parameters: map[string]int{"f": 0, "g": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			functions, err := Compute(context.Background(), tt.lang, []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for _, f := range functions {
+				got[f.Name] = f.Parameters
+			}
+			for name, expected := range tt.parameters {
+				if n, ok := got[name]; !ok || n != expected {
+					t.Errorf("%s: got %d parameters (found: %v), expected %d", name, n, ok, expected)
+				}
+			}
+		})
+	}
+}
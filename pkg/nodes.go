@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// NodeForRange returns the outermost node spanning exactly the given byte
+// range, or the innermost node containing it.
+func NodeForRange(root *sitter.Node, start, end uint32) *sitter.Node {
+	n := root
+	for n.StartByte() != start || n.EndByte() != end {
+		var next *sitter.Node
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			if child.StartByte() <= start && child.EndByte() >= end {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return n
+		}
+		n = next
+	}
+	return n
+}
+
+// Column returns the 1-based column of the given position, in unicode code
+// points.
+func Column(source []byte, offset uint32, point sitter.Point) int {
+	lineStart := offset - point.Column
+	return utf8.RuneCount(source[lineStart:offset]) + 1
+}
@@ -0,0 +1,124 @@
+package playground
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// predicateRegexp matches predicates comparing a capture to a string literal,
+// for example (#eq? @name "foo").
+var predicateRegexp = regexp.MustCompile(`\(#(eq|not-eq|match|not-match)\?\s+@([\w.-]+)\s+"((?:[^"\\]|\\.)*)"\s*\)`)
+
+// identifierRegexp matches capture names that can be accessed as template fields.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// captureRegexp matches capture names in a query.
+var captureRegexp = regexp.MustCompile(`@([\w.-]+)`)
+
+// CommandYAML turns a query written in the playground into an oak YAML
+// command.
+//
+// Every predicate comparing a capture to a string literal is turned into a
+// flag (named after the capture, defaulting to the literal), so that the
+// command can be reused with other values, and a verbose flag is added to
+// dump all the results, as in the commands of the oak repository. The
+// template lists the captures of each match, per file.
+func CommandYAML(name string, language string, queryName string, query string) string {
+	type flag struct {
+		name     string
+		default_ string
+	}
+	flags := []flag{}
+	flagsByCapture := map[string]string{}
+
+	query = predicateRegexp.ReplaceAllStringFunc(query, func(s string) string {
+		m := predicateRegexp.FindStringSubmatch(s)
+		operator, capture, value := m[1], m[2], m[3]
+		flagName, ok := flagsByCapture[capture]
+		if !ok {
+			flagName = snakeCase(capture)
+			flagsByCapture[capture] = flagName
+			flags = append(flags, flag{name: flagName, default_: value})
+		}
+		return fmt.Sprintf(`{{ if .%s }}(#%s? @%s "{{ .%s }}"){{ end }}`, flagName, operator, capture, flagName)
+	})
+
+	captures := []string{}
+	seen := map[string]bool{}
+	for _, m := range captureRegexp.FindAllStringSubmatch(query, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			captures = append(captures, m[1])
+		}
+	}
+	sort.Strings(captures)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\n", name)
+	fmt.Fprintf(&b, "short: Generated with oak playground\n\n")
+
+	b.WriteString("flags:\n")
+	b.WriteString("  - name: verbose\n    type: bool\n    help: Output all results\n    default: false\n")
+	for _, f := range flags {
+		fmt.Fprintf(&b, "  - name: %s\n    type: string\n    help: Only output matches where %s is the given value\n    default: %q\n",
+			f.name, f.name, f.default_)
+	}
+
+	fmt.Fprintf(&b, "\nlanguage: %s\n", language)
+	b.WriteString("queries:\n")
+	fmt.Fprintf(&b, "  - name: %s\n    query: |\n%s\n", queryName, indentLines(strings.TrimRight(query, "\n"), "      "))
+
+	b.WriteString("\ntemplate: |\n")
+	b.WriteString("  {{ range $file, $results := .ResultsByFile -}}\n")
+	b.WriteString("  File: {{ $file }}\n")
+	fmt.Fprintf(&b, "  {{ range $results.%s.Matches }}\n", queryName)
+	for i, capture := range captures {
+		prefix := "  "
+		if i == 0 {
+			prefix = " -"
+		}
+		if identifierRegexp.MatchString(capture) {
+			fmt.Fprintf(&b, "  %s %s: {{ .%s.Text }}\n", prefix, capture, capture)
+		} else {
+			fmt.Fprintf(&b, "  %s %s: {{ (index . %q).Text }}\n", prefix, capture, capture)
+		}
+	}
+	b.WriteString("  {{ end }}\n")
+	b.WriteString("  {{ end -}}\n\n")
+
+	b.WriteString("  {{ if .verbose -}}\n")
+	b.WriteString("  Results:{{ range $v := .Results }}\n")
+	b.WriteString("    {{ $v.QueryName }}: {{ range $match := $v.Matches }}{{ range $captureName, $captureValue := $match }}\n")
+	b.WriteString("       {{ $captureName }}: {{ $captureValue.Text }}{{ end }}\n")
+	b.WriteString("    {{end}}{{ end }}\n")
+	b.WriteString("  {{ end -}}\n")
+
+	return b.String()
+}
+
+func indentLines(s string, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// snakeCase converts a camelCase capture name to a snake_case flag name, as
+// used by the flags in the oak repository.
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '.' || r == '-':
+			b.WriteRune('_')
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
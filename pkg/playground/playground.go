@@ -0,0 +1,343 @@
+package playground
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// QueryName is the name of the query written in the playground.
+const QueryName = "main"
+
+type pane int
+
+const (
+	paneQuery pane = iota
+	paneSource
+	paneTree
+)
+
+var (
+	borderStyle        = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240"))
+	focusedBorderStyle = borderStyle.BorderForeground(lipgloss.Color("33"))
+	titleStyle         = lipgloss.NewStyle().Bold(true)
+	lineNumberStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	captureStyle       = lipgloss.NewStyle().Background(lipgloss.Color("22")).Foreground(lipgloss.Color("15"))
+	partialStyle       = lipgloss.NewStyle().Background(lipgloss.Color("136")).Foreground(lipgloss.Color("0"))
+	errorStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	statusStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+)
+
+// Settings configure the playground.
+type Settings struct {
+	// FileName is the file shown in the source pane
+	FileName string
+	// Query is the initial query
+	Query string
+	// Language is the language name used in the saved command
+	Language string
+	// OutputFile is where the command is saved
+	OutputFile string
+	// CommandName is the name of the saved command
+	CommandName string
+}
+
+// Model is the bubbletea model of the query playground. It shows the source,
+// its parse tree and a query editor. The query is recompiled on every
+// keystroke, and its captures highlighted in the source and the tree.
+type Model struct {
+	settings Settings
+	lang     *sitter.Language
+	source   []byte
+	tree     *sitter.Tree
+
+	query      textarea.Model
+	sourceView viewport.Model
+	treeView   viewport.Model
+	focus      pane
+
+	lastQuery   string
+	annotations tree_sitter.DumpAnnotations
+	matches     int
+	err         error
+	status      string
+
+	width  int
+	height int
+}
+
+// NewModel parses the source file and creates the playground model.
+func NewModel(ctx context.Context, lang *sitter.Language, settings Settings) (*Model, error) {
+	source, err := os.ReadFile(settings.FileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %s", settings.FileName)
+	}
+
+	parser := sitter.NewParser()
+	parser.SetLanguage(lang)
+	tree, err := parser.ParseCtx(ctx, nil, source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse file %s", settings.FileName)
+	}
+
+	query := textarea.New()
+	query.Placeholder = "(function_declaration name: (identifier) @name)"
+	query.ShowLineNumbers = false
+	query.SetValue(settings.Query)
+	query.Focus()
+
+	m := &Model{
+		settings:   settings,
+		lang:       lang,
+		source:     source,
+		tree:       tree,
+		query:      query,
+		sourceView: viewport.New(0, 0),
+		treeView:   viewport.New(0, 0),
+		focus:      paneQuery,
+	}
+	m.recompile()
+
+	return m, nil
+}
+
+// Run starts the playground in the terminal.
+func Run(ctx context.Context, lang *sitter.Language, settings Settings) error {
+	m, err := NewModel(ctx, lang, settings)
+	if err != nil {
+		return err
+	}
+	_, err = tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	return err
+}
+
+func (m *Model) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.resize()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "tab":
+			m.setFocus((m.focus + 1) % 3)
+			return m, nil
+		case "shift+tab":
+			m.setFocus((m.focus + 2) % 3)
+			return m, nil
+		case "ctrl+s":
+			m.save()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	switch m.focus {
+	case paneQuery:
+		m.query, cmd = m.query.Update(msg)
+		if m.query.Value() != m.lastQuery {
+			m.recompile()
+		}
+	case paneSource:
+		m.sourceView, cmd = m.sourceView.Update(msg)
+	case paneTree:
+		m.treeView, cmd = m.treeView.Update(msg)
+	}
+	cmds = append(cmds, cmd)
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *Model) View() string {
+	if m.width == 0 {
+		return "loading..."
+	}
+
+	style := func(p pane) lipgloss.Style {
+		if m.focus == p {
+			return focusedBorderStyle
+		}
+		return borderStyle
+	}
+
+	sourcePane := style(paneSource).Render(
+		titleStyle.Render(m.settings.FileName) + "\n" + m.sourceView.View())
+	treePane := style(paneTree).Render(
+		titleStyle.Render("Parse tree") + "\n" + m.treeView.View())
+
+	queryStatus := statusStyle.Render(fmt.Sprintf("%d matches", m.matches))
+	if m.err != nil {
+		queryStatus = errorStyle.Render(m.err.Error())
+	}
+	queryPane := style(paneQuery).Render(
+		titleStyle.Render("Query") + "\n" + m.query.View() + "\n" + queryStatus)
+
+	help := "tab: switch pane • ctrl+s: save as " + m.settings.OutputFile + " • esc: quit"
+	if m.status != "" {
+		help = m.status + " • " + help
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, sourcePane, treePane),
+		queryPane,
+		statusStyle.Render(help),
+	)
+}
+
+func (m *Model) setFocus(p pane) {
+	m.focus = p
+	if p == paneQuery {
+		m.query.Focus()
+	} else {
+		m.query.Blur()
+	}
+}
+
+// resize lays out the panes: source and tree side by side on top, the query
+// editor below.
+func (m *Model) resize() {
+	// borders take 2 columns and rows, titles one row
+	queryHeight := m.height / 3
+	topHeight := m.height - queryHeight - 1
+
+	paneWidth := m.width/2 - 2
+	m.sourceView.Width = paneWidth
+	m.sourceView.Height = topHeight - 3
+	m.treeView.Width = m.width - paneWidth - 4
+	m.treeView.Height = topHeight - 3
+
+	m.query.SetWidth(m.width - 2)
+	m.query.SetHeight(queryHeight - 4)
+
+	m.render()
+}
+
+// recompile runs the current query against the source and updates the
+// highlighted source and tree. Compile errors are shown below the editor.
+func (m *Model) recompile() {
+	m.lastQuery = m.query.Value()
+	m.err = nil
+	m.matches = 0
+
+	var annotations tree_sitter.DumpAnnotations
+	if strings.TrimSpace(m.lastQuery) != "" {
+		queries := []tree_sitter.SitterQuery{{Name: QueryName, Query: m.lastQuery}}
+		var results tree_sitter.QueryResults
+		results, annotations, m.err = tree_sitter.ExecuteAndAnnotateQueries(m.lang, m.tree.RootNode(), queries, m.source)
+		if m.err == nil {
+			m.matches = len(results[QueryName].Matches)
+		}
+	}
+
+	m.annotations = annotations
+	m.render()
+}
+
+func (m *Model) render() {
+	m.sourceView.SetContent(highlightSource(m.source, m.annotations))
+
+	var buf bytes.Buffer
+	err := tree_sitter.DumpTree(m.tree, m.source, &buf, tree_sitter.FormatText, tree_sitter.DumpOptions{
+		ShowContent:    true,
+		SkipWhitespace: true,
+		Annotations:    m.annotations,
+	})
+	if err != nil {
+		m.treeView.SetContent(errorStyle.Render(err.Error()))
+		return
+	}
+	m.treeView.SetContent(buf.String())
+}
+
+// save writes the query as an oak command, refusing queries that don't compile.
+func (m *Model) save() {
+	if m.err != nil {
+		m.status = errorStyle.Render("not saving, the query has errors")
+		return
+	}
+	if strings.TrimSpace(m.lastQuery) == "" {
+		m.status = errorStyle.Render("not saving, the query is empty")
+		return
+	}
+
+	s := CommandYAML(m.settings.CommandName, m.settings.Language, QueryName, m.lastQuery)
+	err := os.WriteFile(m.settings.OutputFile, []byte(s), 0644)
+	if err != nil {
+		m.status = errorStyle.Render(err.Error())
+		return
+	}
+	m.status = fmt.Sprintf("saved %s", m.settings.OutputFile)
+}
+
+// highlightSource renders the source with line numbers, highlighting the
+// captured ranges.
+func highlightSource(source []byte, annotations tree_sitter.DumpAnnotations) string {
+	const (
+		none = iota
+		partial
+		captured
+	)
+	classes := make([]int, len(source))
+	for key, nodeAnnotations := range annotations {
+		class := partial
+		for _, annotation := range nodeAnnotations {
+			if !annotation.Partial {
+				class = captured
+			}
+		}
+		for i := key.StartByte; i < key.EndByte && int(i) < len(source); i++ {
+			if classes[i] < class {
+				classes[i] = class
+			}
+		}
+	}
+
+	render := func(s []byte, class int) string {
+		text := strings.ReplaceAll(string(s), "\t", "    ")
+		switch class {
+		case captured:
+			return captureStyle.Render(text)
+		case partial:
+			return partialStyle.Render(text)
+		default:
+			return text
+		}
+	}
+
+	var b strings.Builder
+	lines := bytes.Split(source, []byte("\n"))
+	offset := 0
+	for lineIdx, line := range lines {
+		b.WriteString(lineNumberStyle.Render(fmt.Sprintf("%4d ", lineIdx+1)))
+		start := 0
+		for i := 1; i <= len(line); i++ {
+			if i == len(line) || classes[offset+i] != classes[offset+start] {
+				b.WriteString(render(line[start:i], classes[offset+start]))
+				start = i
+			}
+		}
+		b.WriteString("\n")
+		offset += len(line) + 1
+	}
+
+	return b.String()
+}
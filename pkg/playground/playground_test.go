@@ -0,0 +1,74 @@
+package playground
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+func TestRecompile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "a.go")
+	source := "package a\n\nfunc Foo() {}\n\nfunc bar() {}\n"
+	if err := os.WriteFile(fileName, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewModel(context.Background(), golang.GetLanguage(), Settings{
+		FileName: fileName,
+		Query:    `(function_declaration name: (identifier) @name (#match? @name "^[A-Z]"))`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if m.matches != 1 {
+		t.Errorf("expected 1 match, got %d", m.matches)
+	}
+
+	captured, partial := 0, 0
+	for _, annotations := range m.annotations {
+		for _, annotation := range annotations {
+			if annotation.Partial {
+				partial++
+			} else {
+				captured++
+			}
+		}
+	}
+	if captured != 1 || partial != 1 {
+		t.Errorf("expected 1 capture and 1 partial capture, got %d and %d", captured, partial)
+	}
+
+	// the query is recompiled on every change, showing errors
+	m.query.SetValue("(function_declaration")
+	m.recompile()
+	if m.err == nil {
+		t.Errorf("expected an error for an invalid query")
+	}
+	if m.matches != 0 || len(m.annotations) != 0 {
+		t.Errorf("expected no matches for an invalid query")
+	}
+}
+
+func TestCommandYAML(t *testing.T) {
+	s := CommandYAML("find-foo", "go", "main",
+		`(function_declaration name: (identifier) @name (#eq? @name "Foo"))`)
+
+	for _, expected := range []string{
+		"name: find-foo\n",
+		"language: go\n",
+		"  - name: name\n    type: string\n",
+		`default: "Foo"`,
+		`{{ if .name }}(#eq? @name "{{ .name }}"){{ end }}`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("missing %q in:\n%s", expected, s)
+		}
+	}
+}
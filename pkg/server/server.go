@@ -0,0 +1,334 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cmds"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRequestSize is the maximum size of a request body, including inline
+// source code.
+const maxRequestSize = 32 << 20
+
+// Server exposes oak commands, as well as parsing and querying source code,
+// as a JSON API over HTTP:
+//
+//   - GET /commands lists the commands and their flags
+//   - POST /commands/<name> runs a command, for example /commands/go/definitions
+//   - POST /parse dumps the syntax tree of the source, like oak parse
+//   - POST /query runs a plain tree-sitter query, like oak query
+//
+// Requests either pass inline source code or the paths of files, which are
+// resolved relative to Root. Files outside of Root can't be accessed.
+type Server struct {
+	Root     string
+	commands map[string]*cmds.OakCommand
+}
+
+// NewServer creates a server for the given commands, usually the commands
+// loaded from the oak repositories. Commands that are not oak commands
+// (for example aliases) are skipped.
+func NewServer(root string, commands []glazed_cmds.Command) (*Server, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not resolve root directory %s", root)
+	}
+
+	s := &Server{
+		Root:     root,
+		commands: map[string]*cmds.OakCommand{},
+	}
+	for _, command := range commands {
+		switch c := command.(type) {
+		case *cmds.OakWriterCommand:
+			s.commands[c.FullPath()] = c.OakCommand
+		case *cmds.OakCommand:
+			s.commands[c.FullPath()] = c
+		default:
+			log.Debug().Str("command", command.Description().FullPath()).Msg("skipping command that is not an oak command")
+		}
+	}
+
+	return s, nil
+}
+
+// Handler returns the HTTP handler serving the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /commands", s.handleListCommands)
+	mux.HandleFunc("POST /commands/{name...}", s.handleRunCommand)
+	mux.HandleFunc("POST /parse", s.handleParse)
+	mux.HandleFunc("POST /query", s.handleQuery)
+	return mux
+}
+
+// Input is the source code a request runs on, either inline or as files
+// relative to the root of the server.
+type Input struct {
+	Files  []string `json:"files,omitempty"`
+	Source string   `json:"source,omitempty"`
+	// FileName is the name of the inline source, used in the results and to
+	// determine its language. It defaults to "source".
+	FileName string `json:"fileName,omitempty"`
+}
+
+type sourceFile struct {
+	name    string
+	content []byte
+}
+
+// FlagInfo describes a flag of a command.
+type FlagInfo struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Help    string      `json:"help,omitempty"`
+	Default interface{} `json:"default,omitempty"`
+	Choices []string    `json:"choices,omitempty"`
+}
+
+// CommandInfo describes a command, as returned by GET /commands.
+type CommandInfo struct {
+	Name        string     `json:"name"`
+	Short       string     `json:"short,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	HasTemplate bool       `json:"hasTemplate"`
+	Flags       []FlagInfo `json:"flags"`
+}
+
+// CommandRequest is the body of POST /commands/<name>.
+type CommandRequest struct {
+	Input
+	// Flags are the flag values to run the command with. Flags that are not
+	// given use their default values.
+	Flags map[string]interface{} `json:"flags,omitempty"`
+	// Format is either "output", to render the template of the command (the
+	// default for commands with a template), or "captures", to return the
+	// results of the queries by file.
+	Format string `json:"format,omitempty"`
+}
+
+// CommandResponse is the response of POST /commands/<name>.
+type CommandResponse struct {
+	Output  *string                             `json:"output,omitempty"`
+	Results map[string]tree_sitter.QueryResults `json:"results,omitempty"`
+}
+
+func (s *Server) handleListCommands(w http.ResponseWriter, r *http.Request) {
+	ret := []CommandInfo{}
+	for name, oc := range s.commands {
+		info := CommandInfo{
+			Name:        name,
+			Short:       oc.Short,
+			Language:    oc.Language,
+			HasTemplate: oc.Template != "",
+			Flags:       []FlagInfo{},
+		}
+		oc.GetDefaultFlags().ForEach(func(p *parameters.ParameterDefinition) {
+			flag := FlagInfo{
+				Name:    p.Name,
+				Type:    string(p.Type),
+				Help:    p.Help,
+				Choices: p.Choices,
+			}
+			if p.Default != nil {
+				flag.Default = *p.Default
+			}
+			info.Flags = append(info.Flags, flag)
+		})
+		ret = append(ret, info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (s *Server) handleRunCommand(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	command, ok := s.commands[name]
+	if !ok {
+		writeError(w, &requestError{status: http.StatusNotFound, err: errors.Errorf("unknown command %s", name)})
+		return
+	}
+
+	req := &CommandRequest{}
+	if err := decodeRequest(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = "captures"
+		if command.Template != "" {
+			format = "output"
+		}
+	}
+	if format != "output" && format != "captures" {
+		writeError(w, badRequest(errors.Errorf("unknown format %s, expected output or captures", format)))
+		return
+	}
+	if format == "output" && command.Template == "" {
+		writeError(w, badRequest(errors.Errorf("command %s has no template, only captures can be returned", name)))
+		return
+	}
+
+	sources, err := s.loadInput(&req.Input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// rendering the queries modifies the command
+	oc := command.Clone()
+	data, err := oc.ParseFlagValues(req.Flags)
+	if err != nil {
+		writeError(w, badRequest(err))
+		return
+	}
+	if oc.Language == "" {
+		oc.Language, err = pkg.FileNameToLanguageName(sources[0].name)
+		if err != nil {
+			writeError(w, badRequest(err))
+			return
+		}
+	}
+	err = oc.RenderQueriesWithData(data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resultsByFile := map[string]tree_sitter.QueryResults{}
+	for _, source := range sources {
+		results, err := oc.ExecuteQueriesOnSource(r.Context(), source.content)
+		if err != nil {
+			writeError(w, errors.Wrapf(err, "could not run command on %s", source.name))
+			return
+		}
+		resultsByFile[source.name] = results
+	}
+
+	if format == "captures" {
+		writeJSON(w, http.StatusOK, &CommandResponse{Results: resultsByFile})
+		return
+	}
+
+	output, err := oc.RenderResultsByFile(data, resultsByFile)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &CommandResponse{Output: &output})
+}
+
+// loadInput returns the source files of a request.
+func (s *Server) loadInput(input *Input) ([]sourceFile, error) {
+	switch {
+	case input.Source != "" && len(input.Files) > 0:
+		return nil, badRequest(errors.New("only one of source and files can be given"))
+	case input.Source != "":
+		name := input.FileName
+		if name == "" {
+			name = "source"
+		}
+		return []sourceFile{{name: name, content: []byte(input.Source)}}, nil
+	case len(input.Files) == 0:
+		return nil, badRequest(errors.New("either source or files is required"))
+	}
+
+	ret := []sourceFile{}
+	for _, file := range input.Files {
+		path, err := s.resolvePath(file)
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read file %s", file)
+		}
+		ret = append(ret, sourceFile{name: file, content: content})
+	}
+	return ret, nil
+}
+
+// resolvePath returns the path of a file relative to the root of the server,
+// making sure that it doesn't point outside of the root, including through
+// symlinks.
+func (s *Server) resolvePath(file string) (string, error) {
+	path, err := filepath.EvalSymlinks(filepath.Join(s.Root, filepath.FromSlash(file)))
+	if err != nil {
+		return "", badRequest(errors.Errorf("could not access file %s", file))
+	}
+	rel, err := filepath.Rel(s.Root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", badRequest(errors.Errorf("file %s is outside of the root directory", file))
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", badRequest(errors.Errorf("could not access file %s", file))
+	}
+	if fi.IsDir() {
+		return "", badRequest(errors.Errorf("%s is a directory", file))
+	}
+	return path, nil
+}
+
+// requestError is an error caused by the request, reported with the given
+// status instead of 500.
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return &requestError{status: http.StatusBadRequest, err: err}
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		return badRequest(errors.Wrap(err, "invalid request"))
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		status = requestErr.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not write response")
+	}
+}
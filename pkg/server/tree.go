@@ -0,0 +1,338 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/go-go-golems/oak/pkg/cmds"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ParseRequest is the body of POST /parse. The options are the same as the
+// flags of oak parse.
+type ParseRequest struct {
+	Input
+	// Language is the language of the source, determined from the file name
+	// if not given.
+	Language string `json:"language,omitempty"`
+	// Format is the dump format (json, text, xml, yaml, sexpr, dot, mermaid
+	// or html), json by default.
+	Format         string `json:"format,omitempty"`
+	ShowBytes      bool   `json:"showBytes,omitempty"`
+	ShowContent    *bool  `json:"showContent,omitempty"`
+	ShowAttributes *bool  `json:"showAttributes,omitempty"`
+	SkipWhitespace *bool  `json:"skipWhitespace,omitempty"`
+	QuerySkeleton  bool   `json:"querySkeleton,omitempty"`
+	At             string `json:"at,omitempty"`
+	Bytes          string `json:"bytes,omitempty"`
+	NodeType       string `json:"nodeType,omitempty"`
+	MaxDepth       int    `json:"maxDepth,omitempty"`
+	// Query annotates the dumped nodes with its captures.
+	Query     string `json:"query,omitempty"`
+	QueryName string `json:"queryName,omitempty"`
+	// Command annotates the dumped nodes with the captures of the queries of
+	// a command, rendered with the default values of its flags.
+	Command string `json:"command,omitempty"`
+}
+
+// ParseResponse is the response of POST /parse.
+type ParseResponse struct {
+	// Trees contains the dumped trees by file, when using the json format.
+	Trees map[string]json.RawMessage `json:"trees,omitempty"`
+	// Outputs contains the dumped trees by file, for all other formats.
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// QueryRequest is the body of POST /query.
+type QueryRequest struct {
+	Input
+	// Language is the language of the source, determined from the file name
+	// if not given.
+	Language  string `json:"language,omitempty"`
+	Query     string `json:"query"`
+	QueryName string `json:"queryName,omitempty"`
+	// Template is rendered for each file with the results of the query. The
+	// results are returned if no template is given. Templates have the usual
+	// functions, except those giving access to the environment of the
+	// server (see queryTemplateFuncs).
+	Template string `json:"template,omitempty"`
+}
+
+// QueryResponse is the response of POST /query.
+type QueryResponse struct {
+	Outputs map[string]string                   `json:"outputs,omitempty"`
+	Results map[string]tree_sitter.QueryResults `json:"results,omitempty"`
+}
+
+var dumpFormats = map[string]tree_sitter.DumpFormat{
+	"text":    tree_sitter.FormatText,
+	"xml":     tree_sitter.FormatXML,
+	"json":    tree_sitter.FormatJSON,
+	"yaml":    tree_sitter.FormatYAML,
+	"sexpr":   tree_sitter.FormatSExpr,
+	"dot":     tree_sitter.FormatDOT,
+	"mermaid": tree_sitter.FormatMermaid,
+	"html":    tree_sitter.FormatHTML,
+}
+
+func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	req := &ParseRequest{}
+	if err := decodeRequest(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	formatName := req.Format
+	if formatName == "" {
+		formatName = "json"
+	}
+	format, ok := dumpFormats[formatName]
+	if !ok {
+		writeError(w, badRequest(errors.Errorf("unknown format %s", formatName)))
+		return
+	}
+
+	options := tree_sitter.DumpOptions{
+		ShowBytes:      req.ShowBytes,
+		ShowContent:    boolOrDefault(req.ShowContent, true),
+		ShowAttributes: boolOrDefault(req.ShowAttributes, true),
+		SkipWhitespace: boolOrDefault(req.SkipWhitespace, true),
+		QuerySkeleton:  req.QuerySkeleton,
+		NodeType:       req.NodeType,
+		MaxDepth:       req.MaxDepth,
+	}
+	if req.At != "" {
+		p, err := tree_sitter.ParsePoint(req.At)
+		if err != nil {
+			writeError(w, badRequest(err))
+			return
+		}
+		options.At = &p
+	}
+	if req.Bytes != "" {
+		br, err := tree_sitter.ParseByteRange(req.Bytes)
+		if err != nil {
+			writeError(w, badRequest(err))
+			return
+		}
+		options.Bytes = &br
+	}
+
+	overlayQueries, err := s.overlayQueries(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sources, err := s.loadInput(&req.Input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ret := &ParseResponse{}
+	for _, source := range sources {
+		lang, err := sourceLanguage(req.Language, source.name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		oak := cmds.NewOakWriterCommand(
+			glazed_cmds.NewCommandDescription("parse"),
+			cmds.WithSitterLanguage(lang))
+		tree, err := oak.Parse(r.Context(), nil, source.content)
+		if err != nil {
+			writeError(w, errors.Wrapf(err, "could not parse %s", source.name))
+			return
+		}
+
+		options_ := options
+		if overlayQueries != nil {
+			options_.Annotations, err = tree_sitter.AnnotateQueries(lang, tree.RootNode(), overlayQueries, source.content)
+			if err != nil {
+				writeError(w, badRequest(err))
+				return
+			}
+		}
+
+		var buf bytes.Buffer
+		err = oak.DumpTreeToWriter(tree, source.content, &buf, format, options_,
+			tree_sitter.WithDumpTitle(source.name))
+		if err != nil {
+			writeError(w, badRequest(errors.Wrapf(err, "could not dump %s", source.name)))
+			return
+		}
+
+		if format == tree_sitter.FormatJSON {
+			if ret.Trees == nil {
+				ret.Trees = map[string]json.RawMessage{}
+			}
+			ret.Trees[source.name] = json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+		} else {
+			if ret.Outputs == nil {
+				ret.Outputs = map[string]string{}
+			}
+			ret.Outputs[source.name] = buf.String()
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// overlayQueries returns the queries to annotate the tree dump with, if any.
+func (s *Server) overlayQueries(req *ParseRequest) ([]tree_sitter.SitterQuery, error) {
+	switch {
+	case req.Query != "" && req.Command != "":
+		return nil, badRequest(errors.New("only one of query and command can be given"))
+	case req.Query != "":
+		return []tree_sitter.SitterQuery{{Name: queryNameOrDefault(req.QueryName), Query: req.Query}}, nil
+	case req.Command != "":
+		command, ok := s.commands[req.Command]
+		if !ok {
+			return nil, badRequest(errors.Errorf("unknown command %s", req.Command))
+		}
+		oc := command.Clone()
+		defaults, err := oc.GetDefaultsMap()
+		if err != nil {
+			return nil, err
+		}
+		err = oc.RenderQueriesWithData(defaults)
+		if err != nil {
+			return nil, err
+		}
+		return oc.Queries, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	req := &QueryRequest{}
+	if err := decodeRequest(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Query == "" {
+		writeError(w, badRequest(errors.New("query is required")))
+		return
+	}
+
+	sources, err := s.loadInput(&req.Input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var tmpl *template.Template
+	if req.Template != "" {
+		tmpl, err = template.New("query").Funcs(queryTemplateFuncs()).Parse(req.Template)
+		if err != nil {
+			writeError(w, badRequest(errors.Wrap(err, "could not parse template")))
+			return
+		}
+	}
+
+	ret := &QueryResponse{}
+	for _, source := range sources {
+		lang, err := sourceLanguage(req.Language, source.name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		oak := cmds.NewOakWriterCommand(
+			glazed_cmds.NewCommandDescription("query"),
+			cmds.WithQueries(tree_sitter.SitterQuery{
+				Name:  queryNameOrDefault(req.QueryName),
+				Query: req.Query,
+			}),
+			cmds.WithSitterLanguage(lang))
+
+		results, err := oak.ExecuteQueriesOnSource(r.Context(), source.content)
+		if err != nil {
+			writeError(w, badRequest(errors.Wrapf(err, "could not run query on %s", source.name)))
+			return
+		}
+
+		if tmpl == nil {
+			if ret.Results == nil {
+				ret.Results = map[string]tree_sitter.QueryResults{}
+			}
+			ret.Results[source.name] = results
+			continue
+		}
+
+		output, err := oak.RenderWithTemplate(results, tmpl)
+		if err != nil {
+			writeError(w, badRequest(errors.Wrapf(err, "could not render template for %s", source.name)))
+			return
+		}
+		if ret.Outputs == nil {
+			ret.Outputs = map[string]string{}
+		}
+		ret.Outputs[source.name] = output
+	}
+
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// restrictedTemplateFuncs are the template functions that the templates of
+// POST /query can't use, because they expose the environment and the network
+// of the server to clients.
+var restrictedTemplateFuncs = []string{
+	"env", "expandenv", "getHostByName",
+	"base", "dir", "clean", "ext", "isAbs",
+}
+
+// queryTemplateFuncs returns the functions of the templates of oak commands
+// (see templating.CreateTemplate), without restrictedTemplateFuncs.
+func queryTemplateFuncs() template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, f := range sprig.TxtFuncMap() {
+		funcs[name] = f
+	}
+	for name, f := range templating.TemplateFuncs {
+		funcs[name] = f
+	}
+	for _, name := range restrictedTemplateFuncs {
+		delete(funcs, name)
+	}
+	return funcs
+}
+
+// sourceLanguage returns the given language, or the language of the file
+// otherwise.
+func sourceLanguage(language string, fileName string) (*sitter.Language, error) {
+	if language != "" {
+		lang, err := pkg.LanguageNameToSitterLanguage(language)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		return lang, nil
+	}
+	lang, err := pkg.FileNameToSitterLanguage(fileName)
+	if err != nil {
+		return nil, badRequest(errors.Wrapf(err, "could not determine the language of %s", fileName))
+	}
+	return lang, nil
+}
+
+func queryNameOrDefault(name string) string {
+	if name == "" {
+		return "main"
+	}
+	return name
+}
+
+func boolOrDefault(b *bool, default_ bool) bool {
+	if b == nil {
+		return default_
+	}
+	return *b
+}
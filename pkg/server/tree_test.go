@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQueryTemplate(t *testing.T) {
+	s, err := NewServer(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		status   int
+		output   string
+	}{
+		{
+			name:     "template",
+			template: `{{ range .main.Matches }}{{ .name.Text | upper }} {{ end }}`,
+			status:   http.StatusOK,
+			output:   "FOO BAR ",
+		},
+		{
+			name:     "env is not available",
+			template: `{{ env "HOME" }}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "expandenv is not available",
+			template: `{{ expandenv "$HOME" }}`,
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(&QueryRequest{
+				Input: Input{
+					Source:   "package a\n\nfunc foo() {}\n\nfunc bar() {}\n",
+					FileName: "a.go",
+				},
+				Query:    "(function_declaration name: (identifier) @name)",
+				Template: tt.template,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/query", strings.NewReader(string(body)))
+			s.Handler().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, expected %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if home := os.Getenv("HOME"); home != "" && strings.Contains(w.Body.String(), home) {
+				t.Errorf("response leaks HOME: %s", w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			ret := &QueryResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), ret); err != nil {
+				t.Fatal(err)
+			}
+			if ret.Outputs["a.go"] != tt.output {
+				t.Errorf("got %q, expected %q", ret.Outputs["a.go"], tt.output)
+			}
+		})
+	}
+}
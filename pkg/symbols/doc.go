@@ -0,0 +1,245 @@
+package symbols
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/go-go-golems/oak/pkg"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// maxSignatureLength is the length (in runes) above which signatures are
+// truncated.
+const maxSignatureLength = 200
+
+// signature returns the text of a definition up to its body (the "body"
+// field, in most grammars), or its first line, with normalized whitespace
+// and without the trailing { or :.
+func signature(definition *sitter.Node, source []byte) string {
+	end := definition.EndByte()
+	if body := definition.ChildByFieldName("body"); body != nil && body.StartByte() > definition.StartByte() {
+		end = body.StartByte()
+	}
+	text := string(source[definition.StartByte():end])
+	if idx := strings.IndexByte(text, '\n'); idx >= 0 && end == definition.EndByte() {
+		text = text[:idx]
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	text = strings.TrimSpace(strings.TrimRight(text, "{:"))
+	if utf8.RuneCountInString(text) > maxSignatureLength {
+		text = string([]rune(text)[:maxSignatureLength]) + "…"
+	}
+	return text
+}
+
+// isComment returns true for the comment nodes of the supported grammars
+// (comment, line_comment, block_comment, ...).
+func isComment(n *sitter.Node) bool {
+	return strings.Contains(n.Type(), "comment")
+}
+
+// isAnnotation returns true for the nodes that can be found between a doc
+// comment and its definition, such as Rust attributes.
+func isAnnotation(n *sitter.Node) bool {
+	t := n.Type()
+	return strings.Contains(t, "attribute") || strings.Contains(t, "decorator") || strings.Contains(t, "annotation")
+}
+
+var (
+	docQueriesMu sync.Mutex
+	docQueries   = map[string]*sitter.Query{}
+)
+
+// docQuery returns the query matching the comments right before a node,
+// the `(comment)* @comment .` pattern of the query files, with the comment
+// node types of the language (line_comment and block_comment in Rust and
+// Java, for example).
+func docQuery(lang string) (*sitter.Query, error) {
+	docQueriesMu.Lock()
+	defer docQueriesMu.Unlock()
+
+	if q, ok := docQueries[lang]; ok {
+		return q, nil
+	}
+
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	types := map[string]bool{}
+	for i := uint32(0); i < sitterLang.SymbolCount(); i++ {
+		symbol := sitter.Symbol(i)
+		name := sitterLang.SymbolName(symbol)
+		if sitterLang.SymbolType(symbol) == sitter.SymbolTypeRegular && strings.Contains(name, "comment") {
+			types[name] = true
+		}
+	}
+	if len(types) == 0 {
+		return nil, errors.Errorf("no comments in language %s", lang)
+	}
+	patterns := []string{}
+	for t := range types {
+		patterns = append(patterns, "("+t+")")
+	}
+	sort.Strings(patterns)
+
+	query := "([" + strings.Join(patterns, " ") + "]+ @comment . (_) @documented)"
+	q, err := sitter.NewQuery([]byte(query), sitterLang)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid doc comment query for language %s", lang)
+	}
+	docQueries[lang] = q
+	return q, nil
+}
+
+type nodeRange struct {
+	start, end uint32
+}
+
+func rangeOf(n *sitter.Node) nodeRange {
+	return nodeRange{n.StartByte(), n.EndByte()}
+}
+
+// precedingComments returns the comments right before each node of the
+// tree rooted at root that has some, indexed by the range of the node.
+func precedingComments(lang string, root *sitter.Node, source []byte) (map[nodeRange][]*sitter.Node, error) {
+	q, err := docQuery(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[nodeRange][]*sitter.Node{}
+	qc := sitter.NewQueryCursor()
+	defer qc.Close()
+	qc.Exec(q, root)
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			break
+		}
+		var documented *sitter.Node
+		comments := []*sitter.Node{}
+		for _, c := range m.Captures {
+			if q.CaptureNameForId(c.Index) == "documented" {
+				documented = c.Node
+			} else {
+				comments = append(comments, c.Node)
+			}
+		}
+		if documented == nil || isComment(documented) {
+			continue
+		}
+		// a node can be matched with the last comments only, keep the
+		// longest run
+		r := rangeOf(documented)
+		if len(comments) > len(ret[r]) {
+			ret[r] = comments
+		}
+	}
+	return ret, nil
+}
+
+// docComment returns the documentation of a definition: the comments right
+// above it (or above the declaration it is part of, such as a Go const
+// block on a single line), or a Python docstring. comments are the
+// comments preceding the nodes of the tree, see precedingComments.
+func docComment(definition *sitter.Node, source []byte, comments map[nodeRange][]*sitter.Node) string {
+	if doc := docstring(definition, source); doc != "" {
+		return doc
+	}
+
+	n := definition
+	for {
+		parent := n.Parent()
+		if parent == nil || parent.Parent() == nil {
+			break
+		}
+		// climb to the declaration the definition starts, but not to
+		// another definition on the same line, such as a struct whose
+		// first field is declared inline
+		startsParent := parent.StartPoint().Row == n.StartPoint().Row && n.PrevNamedSibling() == nil
+		if !startsParent && parent.Type() != "decorated_definition" {
+			break
+		}
+		n = parent
+	}
+	// the comments are above the attributes of the definition
+	for prev := n.PrevNamedSibling(); prev != nil && isAnnotation(prev); prev = prev.PrevNamedSibling() {
+		n = prev
+	}
+
+	preceding := comments[rangeOf(n)]
+	row := n.StartPoint().Row
+	first := len(preceding)
+	for i := len(preceding) - 1; i >= 0; i-- {
+		// a trailing comment documents the code before it on its line
+		c := preceding[i]
+		if c.EndPoint().Row+1 < row || !startsLine(c, source) {
+			break
+		}
+		first = i
+		row = c.StartPoint().Row
+	}
+
+	lines := []string{}
+	for _, c := range preceding[first:] {
+		lines = append(lines, cleanComment(c.Content(source))...)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// startsLine returns true if only whitespace precedes n on its line.
+func startsLine(n *sitter.Node, source []byte) bool {
+	for i := int(n.StartByte()) - 1; i >= 0 && source[i] != '\n'; i-- {
+		if source[i] != ' ' && source[i] != '\t' && source[i] != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// docstring returns the docstring of a Python function or class, the string
+// that is the first statement of its body.
+func docstring(definition *sitter.Node, source []byte) string {
+	body := definition.ChildByFieldName("body")
+	if body == nil || body.NamedChildCount() == 0 {
+		return ""
+	}
+	first := body.NamedChild(0)
+	if first.Type() != "expression_statement" || first.NamedChildCount() == 0 || first.NamedChild(0).Type() != "string" {
+		return ""
+	}
+	text := first.NamedChild(0).Content(source)
+	for _, quote := range []string{`"""`, `'''`, `"`, `'`} {
+		if strings.HasPrefix(text, quote) && strings.HasSuffix(text, quote) && len(text) >= 2*len(quote) {
+			text = text[len(quote) : len(text)-len(quote)]
+			break
+		}
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// cleanComment returns the lines of a comment without the comment markers.
+func cleanComment(comment string) []string {
+	ret := []string{}
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSuffix(line, "*/")
+		for _, prefix := range []string{"///", "//!", "//", "/**", "/*", "*", "#", "--"} {
+			if strings.HasPrefix(line, prefix) {
+				line = line[len(prefix):]
+				break
+			}
+		}
+		ret = append(ret, strings.TrimSpace(line))
+	}
+	return ret
+}
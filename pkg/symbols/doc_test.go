@@ -0,0 +1,129 @@
+package symbols
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDocComment(t *testing.T) {
+	tests := []struct {
+		name   string
+		lang   string
+		source string
+		docs   map[string]string
+	}{
+		{
+			name: "go",
+			lang: "go",
+			source: `package a
+
+var X = 1 // trailing comment of X
+func F() {}
+
+// G is detached by a blank line.
+
+func G() {}
+
+// H is documented
+// on two lines.
+func H() {}
+
+// Y is in a const block on one line.
+const Y = 2
+
+type S struct {
+	// A is a field.
+	A int
+	B int // trailing comment of B
+	C int
+}
+`,
+			docs: map[string]string{
+				"X": "",
+				"F": "",
+				"G": "",
+				"H": "H is documented\non two lines.",
+				"Y": "Y is in a const block on one line.",
+				"A": "A is a field.",
+				"B": "",
+				"C": "",
+			},
+		},
+		{
+			name: "rust attributes",
+			lang: "rust",
+			source: `/// S is documented.
+#[derive(Debug)]
+pub struct S;
+
+// not documenting f
+
+pub fn f() {}
+`,
+			docs: map[string]string{"S": "S is documented.", "f": ""},
+		},
+		{
+			name: "python comments, decorators and docstrings",
+			lang: "python",
+			source: `x = 1  # trailing comment of x
+def f():
+    pass
+
+# g is decorated
+@decorator
+def g():
+    pass
+
+def h():
+    """h has a docstring"""
+`,
+			docs: map[string]string{"f": "", "g": "g is decorated", "h": "h has a docstring"},
+		},
+		{
+			name: "java block comments",
+			lang: "java",
+			source: `public class A {
+    /**
+     * m is documented.
+     */
+    public void m() {}
+    int x; /* trailing */ public void n() {}
+}
+`,
+			docs: map[string]string{"m": "m is documented.", "n": ""},
+		},
+		{
+			name: "typescript",
+			lang: "typescript",
+			source: `/** f is documented */
+export function f() {}
+export const x = 1; // trailing comment of x
+export function g() {}
+`,
+			docs: map[string]string{"f": "f is documented", "g": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols, err := ExtractFromSource(context.Background(), tt.lang, []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs := map[string]string{}
+			for _, s := range symbols {
+				docs[s.Name] = s.Doc
+			}
+			for name, expected := range tt.docs {
+				doc, ok := docs[name]
+				if !ok {
+					t.Errorf("%s not found in %v", name, docs)
+					continue
+				}
+				if doc != expected {
+					t.Errorf("%s: got doc %q, expected %q", name, doc, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package symbols
+
+import (
+	"context"
+	"os"
+
+	"github.com/go-go-golems/oak/pkg"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// WalkFiles calls fn for source if it is a file, or for the files in the
+// directory source, whose language has symbol definitions, see
+// pkg.WalkSourceFiles.
+func WalkFiles(source string, fn func(fileName string, lang string, info os.FileInfo) error) error {
+	return pkg.WalkSourceFiles(source, HasLanguage, fn)
+}
+
+// ExtractWithReferences parses source and returns its symbols and
+// references.
+func ExtractWithReferences(ctx context.Context, lang string, source []byte) ([]*Symbol, []*Reference, error) {
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, nil, err
+	}
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(sitterLang)
+	tree, err := parser.ParseCtx(ctx, nil, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tree.Close()
+
+	symbols, err := Extract(lang, tree.RootNode(), source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return symbols, ExtractReferences(tree.RootNode(), source, symbols), nil
+}
@@ -0,0 +1,38 @@
+; Definitions for C. Only struct, union and enum specifiers with a body are
+; definitions.
+
+(function_definition
+  declarator: (function_declarator
+    declarator: (identifier) @name)) @definition.function
+
+(function_definition
+  declarator: (pointer_declarator
+    declarator: (function_declarator
+      declarator: (identifier) @name))) @definition.function
+
+(struct_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.struct
+
+(union_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.struct
+
+(enum_specifier
+  name: (type_identifier) @name
+  body: (enumerator_list)) @definition.enum
+
+(enumerator
+  name: (identifier) @name) @definition.enummember
+
+(type_definition
+  declarator: (type_identifier) @name) @definition.type
+
+(field_declaration
+  declarator: (field_identifier) @name) @definition.field
+
+(preproc_def
+  name: (identifier) @name) @definition.constant
+
+(preproc_function_def
+  name: (identifier) @name) @definition.function
@@ -0,0 +1,74 @@
+; Definitions for C++. Functions defined outside of their class use the
+; qualifying scope as container.
+
+(function_definition
+  declarator: (function_declarator
+    declarator: (qualified_identifier
+      scope: (namespace_identifier) @container
+      name: (identifier) @name))) @definition.method
+
+(field_declaration_list
+  (function_definition
+    declarator: (function_declarator
+      declarator: [(field_identifier) (identifier) (destructor_name)] @name)) @definition.method)
+
+(field_declaration_list
+  (declaration
+    declarator: (function_declarator
+      declarator: [(field_identifier) (identifier)] @name)) @definition.method)
+
+(field_declaration_list
+  (field_declaration
+    declarator: (function_declarator
+      declarator: (field_identifier) @name)) @definition.method)
+
+(function_definition
+  declarator: (function_declarator
+    declarator: (identifier) @name)) @definition.function
+
+(function_definition
+  declarator: (pointer_declarator
+    declarator: (function_declarator
+      declarator: (identifier) @name))) @definition.function
+
+(function_definition
+  declarator: (reference_declarator
+    (function_declarator
+      declarator: (identifier) @name))) @definition.function
+
+(class_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.class
+
+(struct_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.struct
+
+(union_specifier
+  name: (type_identifier) @name
+  body: (field_declaration_list)) @definition.struct
+
+(enum_specifier
+  name: (type_identifier) @name
+  body: (enumerator_list)) @definition.enum
+
+(enumerator
+  name: (identifier) @name) @definition.enummember
+
+(namespace_definition
+  name: (identifier) @name) @definition.namespace
+
+(type_definition
+  declarator: (type_identifier) @name) @definition.type
+
+(alias_declaration
+  name: (type_identifier) @name) @definition.type
+
+(field_declaration
+  declarator: (field_identifier) @name) @definition.field
+
+(preproc_def
+  name: (identifier) @name) @definition.constant
+
+(preproc_function_def
+  name: (identifier) @name) @definition.function
@@ -0,0 +1,36 @@
+; Definitions for C#.
+
+(namespace_declaration
+  name: [(identifier) (qualified_name)] @name) @definition.namespace
+
+(class_declaration
+  name: (identifier) @name) @definition.class
+
+(record_declaration
+  name: (identifier) @name) @definition.class
+
+(struct_declaration
+  name: (identifier) @name) @definition.struct
+
+(interface_declaration
+  name: (identifier) @name) @definition.interface
+
+(enum_declaration
+  name: (identifier) @name) @definition.enum
+
+(enum_member_declaration
+  name: (identifier) @name) @definition.enummember
+
+(method_declaration
+  name: (identifier) @name) @definition.method
+
+(constructor_declaration
+  name: (identifier) @name) @definition.constructor
+
+(property_declaration
+  name: (identifier) @name) @definition.property
+
+(field_declaration
+  (variable_declaration
+    (variable_declarator
+      (identifier) @name))) @definition.field
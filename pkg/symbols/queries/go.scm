@@ -0,0 +1,47 @@
+; Definitions for Go. Methods use the type of their receiver as container.
+; The names of const and var specs are matched without their field, so that
+; all the names of `var a, b = 1, 2` are matched.
+
+(function_declaration
+  name: (identifier) @name) @definition.function
+
+(method_declaration
+  receiver: (parameter_list
+    (parameter_declaration
+      type: [
+        (type_identifier) @container
+        (pointer_type (type_identifier) @container)
+        (generic_type type: (type_identifier) @container)
+        (pointer_type (generic_type type: (type_identifier) @container))
+      ]))
+  name: (field_identifier) @name) @definition.method
+
+(type_spec
+  name: (type_identifier) @name
+  type: (struct_type)) @definition.struct
+
+(type_spec
+  name: (type_identifier) @name
+  type: (interface_type)) @definition.interface
+
+(type_spec
+  name: (type_identifier) @name) @definition.type
+
+(type_alias
+  name: (type_identifier) @name) @definition.type
+
+(field_declaration
+  name: (field_identifier) @name) @definition.field
+
+(method_spec
+  name: (field_identifier) @name) @definition.method
+
+(source_file
+  (const_declaration
+    (const_spec
+      (identifier) @name) @definition.constant))
+
+(source_file
+  (var_declaration
+    (var_spec
+      (identifier) @name) @definition.variable))
@@ -0,0 +1,26 @@
+; Definitions for Java.
+
+(class_declaration
+  name: (identifier) @name) @definition.class
+
+(interface_declaration
+  name: (identifier) @name) @definition.interface
+
+(enum_declaration
+  name: (identifier) @name) @definition.enum
+
+(annotation_type_declaration
+  name: (identifier) @name) @definition.interface
+
+(method_declaration
+  name: (identifier) @name) @definition.method
+
+(constructor_declaration
+  name: (identifier) @name) @definition.constructor
+
+(field_declaration
+  declarator: (variable_declarator
+    name: (identifier) @name)) @definition.field
+
+(enum_constant
+  name: (identifier) @name) @definition.enummember
@@ -0,0 +1,34 @@
+; Definitions for JavaScript. Variables initialized with a function are
+; functions.
+
+(function_declaration
+  name: (identifier) @name) @definition.function
+
+(generator_function_declaration
+  name: (identifier) @name) @definition.function
+
+(class_declaration
+  name: (identifier) @name) @definition.class
+
+(method_definition
+  name: (property_identifier) @name) @definition.method
+
+(variable_declarator
+  name: (identifier) @name
+  value: [(arrow_function) (function) (generator_function)]) @definition.function
+
+(program
+  (lexical_declaration
+    (variable_declarator
+      name: (identifier) @name) @definition.variable))
+
+(program
+  (variable_declaration
+    (variable_declarator
+      name: (identifier) @name) @definition.variable))
+
+(program
+  (export_statement
+    declaration: (lexical_declaration
+      (variable_declarator
+        name: (identifier) @name) @definition.variable)))
@@ -0,0 +1,25 @@
+; Definitions for PHP.
+
+(namespace_definition
+  name: (namespace_name) @name) @definition.namespace
+
+(class_declaration
+  name: (name) @name) @definition.class
+
+(interface_declaration
+  name: (name) @name) @definition.interface
+
+(trait_declaration
+  name: (name) @name) @definition.class
+
+(function_definition
+  name: (name) @name) @definition.function
+
+(method_declaration
+  name: (name) @name) @definition.method
+
+(property_element
+  (variable_name) @name) @definition.field
+
+(const_element
+  (name) @name) @definition.constant
@@ -0,0 +1,23 @@
+; Definitions for Python. Functions defined in the body of a class are methods.
+
+(class_definition
+  body: (block
+    (function_definition
+      name: (identifier) @name) @definition.method))
+
+(class_definition
+  body: (block
+    (decorated_definition
+      definition: (function_definition
+        name: (identifier) @name) @definition.method)))
+
+(class_definition
+  name: (identifier) @name) @definition.class
+
+(function_definition
+  name: (identifier) @name) @definition.function
+
+(module
+  (expression_statement
+    (assignment
+      left: (identifier) @name) @definition.variable))
@@ -0,0 +1,16 @@
+; Definitions for Ruby.
+
+(class
+  name: [(constant) (scope_resolution)] @name) @definition.class
+
+(module
+  name: [(constant) (scope_resolution)] @name) @definition.module
+
+(method
+  name: (_) @name) @definition.method
+
+(singleton_method
+  name: (_) @name) @definition.method
+
+(assignment
+  left: (constant) @name) @definition.constant
@@ -0,0 +1,54 @@
+; Definitions for Rust. Functions in an impl block are methods of the
+; implemented type.
+
+(impl_item
+  type: [
+    (type_identifier) @container
+    (generic_type type: (type_identifier) @container)
+  ]
+  body: (declaration_list
+    (function_item
+      name: (identifier) @name) @definition.method))
+
+(trait_item
+  body: (declaration_list
+    [
+      (function_item name: (identifier) @name)
+      (function_signature_item name: (identifier) @name)
+    ] @definition.method))
+
+(function_item
+  name: (identifier) @name) @definition.function
+
+(struct_item
+  name: (type_identifier) @name) @definition.struct
+
+(enum_item
+  name: (type_identifier) @name) @definition.enum
+
+(union_item
+  name: (type_identifier) @name) @definition.struct
+
+(trait_item
+  name: (type_identifier) @name) @definition.interface
+
+(type_item
+  name: (type_identifier) @name) @definition.type
+
+(const_item
+  name: (identifier) @name) @definition.constant
+
+(static_item
+  name: (identifier) @name) @definition.variable
+
+(mod_item
+  name: (identifier) @name) @definition.module
+
+(macro_definition
+  name: (identifier) @name) @definition.function
+
+(field_declaration
+  name: (field_identifier) @name) @definition.field
+
+(enum_variant
+  name: (identifier) @name) @definition.enummember
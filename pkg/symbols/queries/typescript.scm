@@ -0,0 +1,64 @@
+; Definitions for TypeScript (and TSX). Variables initialized with a function
+; are functions.
+
+(function_declaration
+  name: (identifier) @name) @definition.function
+
+(function_signature
+  name: (identifier) @name) @definition.function
+
+(generator_function_declaration
+  name: (identifier) @name) @definition.function
+
+(class_declaration
+  name: (type_identifier) @name) @definition.class
+
+(abstract_class_declaration
+  name: (type_identifier) @name) @definition.class
+
+(interface_declaration
+  name: (type_identifier) @name) @definition.interface
+
+(type_alias_declaration
+  name: (type_identifier) @name) @definition.type
+
+(enum_declaration
+  name: (identifier) @name) @definition.enum
+
+(internal_module
+  name: (identifier) @name) @definition.namespace
+
+(method_definition
+  name: (property_identifier) @name) @definition.method
+
+(method_signature
+  name: (property_identifier) @name) @definition.method
+
+(abstract_method_signature
+  name: (property_identifier) @name) @definition.method
+
+(public_field_definition
+  name: (property_identifier) @name) @definition.field
+
+(property_signature
+  name: (property_identifier) @name) @definition.field
+
+(variable_declarator
+  name: (identifier) @name
+  value: [(arrow_function) (function) (generator_function)]) @definition.function
+
+(program
+  (lexical_declaration
+    (variable_declarator
+      name: (identifier) @name) @definition.variable))
+
+(program
+  (variable_declaration
+    (variable_declarator
+      name: (identifier) @name) @definition.variable))
+
+(program
+  (export_statement
+    declaration: (lexical_declaration
+      (variable_declarator
+        name: (identifier) @name) @definition.variable)))
@@ -0,0 +1,66 @@
+package symbols
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Reference is an identifier used in a source file, other than the name of
+// a definition. References are found syntactically: they are not resolved
+// to the definition they refer to.
+type Reference struct {
+	Name string
+	// Type is the type of the identifier node, such as field_identifier.
+	Type string
+
+	StartByte  uint32
+	EndByte    uint32
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+}
+
+// isIdentifier returns true for the identifier nodes of the supported
+// grammars (identifier, type_identifier, property_identifier, PHP names,
+// Ruby constants, ...).
+func isIdentifier(n *sitter.Node) bool {
+	t := n.Type()
+	return strings.Contains(t, "identifier") || t == "name" || t == "constant"
+}
+
+// ExtractReferences returns the identifiers of the tree rooted at root that
+// are not the names of the given definitions, sorted by position.
+func ExtractReferences(root *sitter.Node, source []byte, definitions []*Symbol) []*Reference {
+	names := map[uint32]bool{}
+	for _, s := range definitions {
+		names[s.NameStartByte] = true
+	}
+
+	ret := []*Reference{}
+	cursor := sitter.NewTreeCursor(root)
+	defer cursor.Close()
+	for {
+		n := cursor.CurrentNode()
+		// identifiers are leaves, apart from qualified names in some
+		// grammars, which are reported through their parts
+		if n.IsNamed() && isIdentifier(n) && n.NamedChildCount() == 0 && !names[n.StartByte()] {
+			ret = append(ret, &Reference{
+				Name:       n.Content(source),
+				Type:       n.Type(),
+				StartByte:  n.StartByte(),
+				EndByte:    n.EndByte(),
+				StartPoint: n.StartPoint(),
+				EndPoint:   n.EndPoint(),
+			})
+		}
+
+		if cursor.GoToFirstChild() {
+			continue
+		}
+		for !cursor.GoToNextSibling() {
+			if !cursor.GoToParent() {
+				return ret
+			}
+		}
+	}
+}
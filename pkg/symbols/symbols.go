@@ -0,0 +1,245 @@
+// Package symbols extracts the definitions (functions, types, methods, ...)
+// of source files, using a tree-sitter query per language.
+//
+// The queries follow the conventions of tree-sitter tags queries: the
+// definition node is captured as @definition.<kind> and its name as @name.
+// A query can also capture @container, the name of the symbol the definition
+// belongs to when it is not nested in it, such as the receiver type of a Go
+// method. When several patterns match the same name, the first pattern of
+// the query wins, so more specific patterns (methods) come before generic
+// ones (functions).
+package symbols
+
+import (
+	"context"
+	"embed"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg"
+	tree_sitter "github.com/go-go-golems/oak/pkg/tree-sitter"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+//go:embed queries/*.scm
+var queriesFS embed.FS
+
+// Kind is the kind of a symbol, the suffix of its @definition.<kind>
+// capture.
+type Kind string
+
+const (
+	KindFunction    Kind = "function"
+	KindMethod      Kind = "method"
+	KindConstructor Kind = "constructor"
+	KindClass       Kind = "class"
+	KindStruct      Kind = "struct"
+	KindInterface   Kind = "interface"
+	KindEnum        Kind = "enum"
+	KindEnumMember  Kind = "enummember"
+	KindType        Kind = "type"
+	KindField       Kind = "field"
+	KindProperty    Kind = "property"
+	KindConstant    Kind = "constant"
+	KindVariable    Kind = "variable"
+	KindModule      Kind = "module"
+	KindNamespace   Kind = "namespace"
+)
+
+// Symbol is a definition in a source file.
+type Symbol struct {
+	Name string
+	Kind Kind
+	// Container is the name of the symbol this one belongs to, either the
+	// innermost symbol it is nested in, or the captured @container.
+	Container string
+
+	// StartByte, EndByte, StartPoint and EndPoint span the whole definition.
+	StartByte  uint32
+	EndByte    uint32
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+
+	// NameStartByte, NameEndByte, NameStartPoint and NameEndPoint span the
+	// name of the definition.
+	NameStartByte  uint32
+	NameEndByte    uint32
+	NameStartPoint sitter.Point
+	NameEndPoint   sitter.Point
+
+	// Signature is the text of the definition up to its body, with
+	// normalized whitespace, see signature.
+	Signature string
+	// Doc is the documentation of the definition, without comment markers,
+	// see docComment.
+	Doc string
+}
+
+// Contains returns true if the definition of s contains the given byte range.
+func (s *Symbol) Contains(start, end uint32) bool {
+	return s.StartByte <= start && end <= s.EndByte
+}
+
+// queries are the definition queries, in queries/<language>.scm.
+var queries = tree_sitter.NewQueryRegistry(queriesFS, "queries", "symbol definitions", map[string]string{
+	"golang": "go",
+	"tsx":    "typescript",
+})
+
+// Languages returns the languages that have a definition query.
+func Languages() []string {
+	return queries.Languages()
+}
+
+// HasLanguage returns true if symbols can be extracted for the language.
+func HasLanguage(lang string) bool {
+	return queries.HasLanguage(lang)
+}
+
+// Query returns the definition query of the language.
+func Query(lang string) (string, error) {
+	return queries.Query(lang)
+}
+
+func compiledQuery(lang string) (*sitter.Query, error) {
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	return queries.CompiledQuery(lang, sitterLang)
+}
+
+// Extract returns the symbols defined in the tree rooted at root, sorted by
+// position.
+func Extract(lang string, root *sitter.Node, source []byte) ([]*Symbol, error) {
+	q, err := compiledQuery(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		symbol  *Symbol
+		pattern uint16
+	}
+	byName := map[uint32]*candidate{}
+
+	comments, err := precedingComments(lang, root, source)
+	if err != nil {
+		return nil, err
+	}
+
+	qc := sitter.NewQueryCursor()
+	defer qc.Close()
+	qc.Exec(q, root)
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			break
+		}
+		m = qc.FilterPredicates(m, source)
+
+		var definition, name *sitter.Node
+		var kind Kind
+		container := ""
+		for _, c := range m.Captures {
+			captureName := q.CaptureNameForId(c.Index)
+			switch {
+			case captureName == "name":
+				name = c.Node
+			case captureName == "container":
+				container = c.Node.Content(source)
+			case strings.HasPrefix(captureName, "definition."):
+				definition = c.Node
+				kind = Kind(strings.TrimPrefix(captureName, "definition."))
+			}
+		}
+		if definition == nil || name == nil {
+			continue
+		}
+
+		if existing, ok := byName[name.StartByte()]; ok && existing.pattern <= m.PatternIndex {
+			continue
+		}
+		byName[name.StartByte()] = &candidate{
+			pattern: m.PatternIndex,
+			symbol: &Symbol{
+				Name:           name.Content(source),
+				Kind:           kind,
+				Container:      container,
+				StartByte:      definition.StartByte(),
+				EndByte:        definition.EndByte(),
+				StartPoint:     definition.StartPoint(),
+				EndPoint:       definition.EndPoint(),
+				NameStartByte:  name.StartByte(),
+				NameEndByte:    name.EndByte(),
+				NameStartPoint: name.StartPoint(),
+				NameEndPoint:   name.EndPoint(),
+				Signature:      signature(definition, source),
+				Doc:            docComment(definition, source, comments),
+			},
+		}
+	}
+
+	ret := make([]*Symbol, 0, len(byName))
+	for _, c := range byName {
+		ret = append(ret, c.symbol)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].StartByte != ret[j].StartByte {
+			return ret[i].StartByte < ret[j].StartByte
+		}
+		return ret[i].EndByte > ret[j].EndByte
+	})
+
+	// fill in the containers of nested symbols
+	stack := []*Symbol{}
+	for _, s := range ret {
+		for len(stack) > 0 && !stack[len(stack)-1].Contains(s.StartByte, s.EndByte) {
+			stack = stack[:len(stack)-1]
+		}
+		if s.Container == "" && len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			if parent.StartByte == s.StartByte && parent.EndByte == s.EndByte {
+				// several names defined by the same node, as in var a, b = 1, 2
+				s.Container = parent.Container
+			} else {
+				s.Container = parent.Name
+			}
+		}
+		stack = append(stack, s)
+	}
+
+	return ret, nil
+}
+
+// ExtractFromSource parses source and returns its symbols.
+func ExtractFromSource(ctx context.Context, lang string, source []byte) ([]*Symbol, error) {
+	sitterLang, err := pkg.LanguageNameToSitterLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	parser := sitter.NewParser()
+	parser.SetLanguage(sitterLang)
+	tree, err := parser.ParseCtx(ctx, nil, source)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	return Extract(lang, tree.RootNode(), source)
+}
+
+// Enclosing returns the innermost symbol containing the given byte range,
+// or nil. symbols must be sorted as returned by Extract.
+func Enclosing(symbols []*Symbol, start, end uint32) *Symbol {
+	var ret *Symbol
+	for _, s := range symbols {
+		if s.StartByte > start {
+			break
+		}
+		if s.Contains(start, end) {
+			ret = s
+		}
+	}
+	return ret
+}
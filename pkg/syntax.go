@@ -0,0 +1,141 @@
+package pkg
+
+// LanguageSyntax lists node types of the grammar of a language, for
+// computing code metrics.
+type LanguageSyntax struct {
+	// Branches are the named node types adding a path through a function
+	// for the cyclomatic complexity: conditionals, loops, cases, catch
+	// clauses and conditional expressions.
+	Branches []string
+	// Operators are the anonymous node types of the short-circuit operators,
+	// which also add a path, like "&&".
+	Operators []string
+	// Nesting are the node types increasing the nesting depth of the code
+	// they contain: conditionals, loops, switches, try blocks and
+	// anonymous functions. An else if doesn't increase the depth.
+	Nesting []string
+}
+
+var cLikeBranches = []string{
+	"if_statement", "for_statement", "while_statement", "do_statement",
+	"case_statement", "conditional_expression",
+}
+
+var cLikeOperators = []string{"&&", "||"}
+
+var cLikeNesting = []string{
+	"if_statement", "for_statement", "while_statement", "do_statement", "switch_statement",
+}
+
+var javascriptSyntax = &LanguageSyntax{
+	Branches: []string{
+		"if_statement", "for_statement", "for_in_statement", "while_statement", "do_statement",
+		"switch_case", "catch_clause", "ternary_expression",
+	},
+	Operators: []string{"&&", "||", "??"},
+	Nesting: []string{
+		"if_statement", "for_statement", "for_in_statement", "while_statement", "do_statement",
+		"switch_statement", "try_statement", "arrow_function", "function", "function_expression",
+	},
+}
+
+var languageSyntaxes = map[string]*LanguageSyntax{
+	"c": {
+		Branches:  cLikeBranches,
+		Operators: cLikeOperators,
+		Nesting:   cLikeNesting,
+	},
+	"cpp": {
+		Branches:  append([]string{"for_range_loop", "catch_clause"}, cLikeBranches...),
+		Operators: cLikeOperators,
+		Nesting:   append([]string{"for_range_loop", "try_statement", "lambda_expression"}, cLikeNesting...),
+	},
+	"csharp": {
+		Branches: []string{
+			"if_statement", "for_statement", "for_each_statement", "while_statement", "do_statement",
+			"switch_section", "catch_clause", "conditional_expression",
+		},
+		Operators: []string{"&&", "||", "??"},
+		Nesting: []string{
+			"if_statement", "for_statement", "for_each_statement", "while_statement", "do_statement",
+			"switch_statement", "try_statement", "lambda_expression", "anonymous_method_expression",
+		},
+	},
+	"go": {
+		Branches: []string{
+			"if_statement", "for_statement", "expression_case", "type_case", "communication_case",
+		},
+		Operators: []string{"&&", "||"},
+		Nesting: []string{
+			"if_statement", "for_statement", "expression_switch_statement", "type_switch_statement",
+			"select_statement", "func_literal",
+		},
+	},
+	"java": {
+		Branches: []string{
+			"if_statement", "for_statement", "enhanced_for_statement", "while_statement", "do_statement",
+			"switch_label", "catch_clause", "ternary_expression",
+		},
+		Operators: []string{"&&", "||"},
+		Nesting: []string{
+			"if_statement", "for_statement", "enhanced_for_statement", "while_statement", "do_statement",
+			"switch_expression", "switch_statement", "try_statement", "try_with_resources_statement",
+			"lambda_expression",
+		},
+	},
+	"javascript": javascriptSyntax,
+	"typescript": javascriptSyntax,
+	"tsx":        javascriptSyntax,
+	"php": {
+		Branches: []string{
+			"if_statement", "else_if_clause", "for_statement", "foreach_statement", "while_statement",
+			"do_statement", "case_statement", "catch_clause", "conditional_expression",
+		},
+		Operators: []string{"&&", "||", "and", "or", "??"},
+		Nesting: []string{
+			"if_statement", "for_statement", "foreach_statement", "while_statement", "do_statement",
+			"switch_statement", "try_statement", "anonymous_function_creation_expression", "arrow_function",
+		},
+	},
+	"python": {
+		Branches: []string{
+			"if_statement", "elif_clause", "for_statement", "while_statement", "except_clause",
+			"conditional_expression", "boolean_operator", "case_clause", "for_in_clause", "if_clause",
+		},
+		Nesting: []string{
+			"if_statement", "for_statement", "while_statement", "try_statement", "with_statement",
+			"match_statement", "lambda",
+		},
+	},
+	"ruby": {
+		Branches: []string{
+			"if", "elsif", "unless", "while", "until", "for", "when", "rescue", "conditional",
+			"if_modifier", "unless_modifier", "while_modifier", "until_modifier",
+		},
+		Operators: []string{"&&", "||", "and", "or"},
+		Nesting: []string{
+			"if", "unless", "while", "until", "for", "case", "begin", "block", "do_block", "lambda",
+		},
+	},
+	"rust": {
+		Branches: []string{
+			"if_expression", "if_let_expression", "while_expression", "while_let_expression",
+			"loop_expression", "for_expression", "match_arm",
+		},
+		Operators: []string{"&&", "||"},
+		Nesting: []string{
+			"if_expression", "if_let_expression", "match_expression", "while_expression",
+			"while_let_expression", "loop_expression", "for_expression", "closure_expression",
+		},
+	},
+}
+
+// GetLanguageSyntax returns the node types used by the code metrics of a
+// language.
+func GetLanguageSyntax(lang string) (*LanguageSyntax, bool) {
+	if lang == "golang" {
+		lang = "go"
+	}
+	s, ok := languageSyntaxes[lang]
+	return s, ok
+}
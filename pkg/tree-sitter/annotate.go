@@ -0,0 +1,301 @@
+package tree_sitter
+
+import (
+	"strings"
+
+	"github.com/go-go-golems/oak/pkg/tree-sitter/dump"
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// DumpAnnotations maps nodes to the query captures that matched them
+type DumpAnnotations = dump.Annotations
+
+// AnnotateQueries runs the given queries on the given tree and records, for
+// each captured node, which query and capture matched it. This is used to
+// overlay query results onto tree dumps.
+//
+// Two kinds of partial matches are recorded as well, which helps figuring
+// out why a query doesn't match:
+//   - matches that are rejected by the predicates of their pattern keep
+//     their captures, marked as partial.
+//   - nodes that have the type of the root of a pattern, but that the rest
+//     of the pattern didn't match, are marked as partial without a capture
+//     name.
+func AnnotateQueries(
+	lang *sitter.Language,
+	tree *sitter.Node,
+	queries []SitterQuery,
+	sourceCode []byte,
+) (DumpAnnotations, error) {
+	_, annotations, err := ExecuteAndAnnotateQueries(lang, tree, queries, sourceCode)
+	return annotations, err
+}
+
+// ExecuteAndAnnotateQueries runs the given queries once, and returns both
+// their results, as ExecuteQueries does, and the annotations of
+// AnnotateQueries.
+func ExecuteAndAnnotateQueries(
+	lang *sitter.Language,
+	tree *sitter.Node,
+	queries []SitterQuery,
+	sourceCode []byte,
+) (QueryResults, DumpAnnotations, error) {
+	results := QueryResults{}
+	annotations := DumpAnnotations{}
+
+	for _, query := range queries {
+		q, err := sitter.NewQuery([]byte(query.Query), lang)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error parsing query %s", query.Name)
+		}
+
+		roots := newPatternRoots(query.Query, q.PatternCount())
+		matches := []Match{}
+
+		qc := sitter.NewQueryCursor()
+		qc.Exec(q, tree)
+		for {
+			m, ok := qc.NextMatch()
+			if !ok {
+				break
+			}
+			roots.addMatch(m)
+
+			filtered := qc.FilterPredicates(m, sourceCode)
+			partial := len(filtered.Captures) == 0
+			if !partial {
+				matches = append(matches, newMatch(q, filtered, sourceCode))
+			}
+			for _, c := range m.Captures {
+				annotations.Add(c.Node, dump.Annotation{
+					Query:   query.Name,
+					Capture: q.CaptureNameForId(c.Index),
+					Partial: partial,
+				})
+			}
+		}
+
+		for _, n := range roots.unmatched(tree) {
+			annotations.Add(n, dump.Annotation{
+				Query:   query.Name,
+				Partial: true,
+			})
+		}
+
+		results[query.Name] = &Result{
+			QueryName: query.Name,
+			Matches:   matches,
+		}
+
+		qc.Close()
+		q.Close()
+	}
+
+	return results, annotations, nil
+}
+
+// patternRoots keeps track of which nodes of the root types of the patterns
+// of a query were matched.
+type patternRoots struct {
+	// types are the named node types the root of each pattern can have. A
+	// nil entry means the root type is unknown (a wildcard, an anonymous
+	// node, or a sequence of sibling patterns), and the pattern is ignored.
+	types [][]string
+	// matched are the root nodes of the matches of each pattern.
+	matched []map[dump.NodeKey]bool
+}
+
+func newPatternRoots(query string, patternCount uint32) *patternRoots {
+	patterns := splitPatterns(query)
+	if len(patterns) != int(patternCount) {
+		// the query couldn't be split into its patterns, ignore them all
+		patterns = make([]string, patternCount)
+	}
+
+	ret := &patternRoots{
+		types:   make([][]string, patternCount),
+		matched: make([]map[dump.NodeKey]bool, patternCount),
+	}
+	for i, pattern := range patterns {
+		ret.types[i] = patternRootTypes(pattern)
+		ret.matched[i] = map[dump.NodeKey]bool{}
+	}
+	return ret
+}
+
+// addMatch records the root node of m, which is the closest ancestor of its
+// captures that has one of the root types of the pattern.
+func (p *patternRoots) addMatch(m *sitter.QueryMatch) {
+	i := int(m.PatternIndex)
+	if i >= len(p.types) || p.types[i] == nil {
+		return
+	}
+	if len(m.Captures) == 0 {
+		// without captures, the root of the match can't be found
+		p.types[i] = nil
+		return
+	}
+	for _, c := range m.Captures {
+		for n := c.Node; n != nil && !n.IsNull(); n = n.Parent() {
+			if hasType(n, p.types[i]) {
+				p.matched[i][dump.NewNodeKey(n)] = true
+				break
+			}
+		}
+	}
+}
+
+// unmatched returns the nodes of the tree that have the root type of a
+// pattern, but weren't matched by it.
+func (p *patternRoots) unmatched(tree *sitter.Node) []*sitter.Node {
+	ret := []*sitter.Node{}
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		for i, types := range p.types {
+			if types != nil && hasType(n, types) && !p.matched[i][dump.NewNodeKey(n)] {
+				ret = append(ret, n)
+				break
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			visit(n.NamedChild(i))
+		}
+	}
+	visit(tree)
+	return ret
+}
+
+func hasType(n *sitter.Node, types []string) bool {
+	if !n.IsNamed() {
+		return false
+	}
+	for _, t := range types {
+		if n.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPatterns splits a query into its top-level patterns, leaving out
+// comments. Captures and quantifiers stay with the pattern they apply to.
+func splitPatterns(query string) []string {
+	ret := []string{}
+	depth := 0
+	start := -1
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if depth == 0 && (c == '(' || c == '[' || c == '"' || c == '_') {
+			if start >= 0 {
+				ret = append(ret, query[start:i])
+			}
+			start = i
+		}
+
+		switch c {
+		case ';':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case '"':
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+		case '@':
+			for i+1 < len(query) && !strings.ContainsRune(" \t\r\n()[]", rune(query[i+1])) {
+				i++
+			}
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+			if depth < 0 {
+				return nil
+			}
+		}
+	}
+	if start >= 0 {
+		ret = append(ret, query[start:])
+	}
+	return ret
+}
+
+// patternRootTypes returns the named node types the root of the given
+// pattern can have, or nil if it can't be told.
+func patternRootTypes(pattern string) []string {
+	pattern = strings.TrimSpace(pattern)
+	switch {
+	case strings.HasPrefix(pattern, "["):
+		ret := []string{}
+		for _, alternative := range splitPatterns(innerPattern(pattern)) {
+			types := patternRootTypes(alternative)
+			if types == nil {
+				return nil
+			}
+			ret = append(ret, types...)
+		}
+		if len(ret) == 0 {
+			return nil
+		}
+		return ret
+
+	case strings.HasPrefix(pattern, "("):
+		inner := strings.TrimSpace(innerPattern(pattern))
+		if strings.HasPrefix(inner, "(") || strings.HasPrefix(inner, "[") {
+			// a group, which has a root only if it is a single pattern
+			// with predicates
+			children := []string{}
+			for _, child := range splitPatterns(inner) {
+				if !strings.HasPrefix(strings.TrimSpace(child), "(#") {
+					children = append(children, child)
+				}
+			}
+			if len(children) != 1 {
+				return nil
+			}
+			return patternRootTypes(children[0])
+		}
+		end := strings.IndexAny(inner, " \t\r\n()[]@:")
+		if end < 0 {
+			end = len(inner)
+		}
+		nodeType := inner[:end]
+		if nodeType == "" || nodeType == "_" || nodeType == "MISSING" || strings.HasPrefix(nodeType, "#") {
+			return nil
+		}
+		return []string{nodeType}
+	}
+
+	return nil
+}
+
+// innerPattern returns the content of a parenthesized or bracketed pattern,
+// without the delimiters and what follows them (captures, quantifiers).
+func innerPattern(pattern string) string {
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '"':
+			for i++; i < len(pattern) && pattern[i] != '"'; i++ {
+				if pattern[i] == '\\' {
+					i++
+				}
+			}
+		case ';':
+			for i < len(pattern) && pattern[i] != '\n' {
+				i++
+			}
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+			if depth == 0 {
+				return pattern[1:i]
+			}
+		}
+	}
+	return pattern[1:]
+}
@@ -0,0 +1,106 @@
+package tree_sitter
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+func TestAnnotateQueries(t *testing.T) {
+	source := []byte(`package a
+
+func Foo(a int) {}
+
+func Bar() {}
+
+func baz(b string) {}
+`)
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(golang.GetLanguage())
+	tree, err := parser.ParseCtx(context.Background(), nil, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []SitterQuery{{
+		Name: "main",
+		Query: `(function_declaration
+  name: (identifier) @name
+  parameters: (parameter_list (parameter_declaration)) @parameters
+  (#match? @name "^[A-Z]"))`,
+	}}
+	annotations, err := AnnotateQueries(golang.GetLanguage(), tree.RootNode(), queries, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]string{}
+	for key, nodeAnnotations := range annotations {
+		text := string(source[key.StartByte:key.EndByte])
+		if key.Type == "function_declaration" {
+			text = text[:len("func xxx")]
+		}
+		for _, annotation := range nodeAnnotations {
+			got[text] = append(got[text], annotation.String())
+		}
+		sort.Strings(got[text])
+	}
+
+	expected := map[string][]string{
+		// full match
+		"Foo":     {"@main.name"},
+		"(a int)": {"@main.parameters"},
+		// rejected by the predicate
+		"baz":        {"@main.name?"},
+		"(b string)": {"@main.parameters?"},
+		// the root type matched, but the parameters didn't
+		"func Bar": {"@main?"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestPatternRootTypes(t *testing.T) {
+	tests := []struct {
+		query string
+		types [][]string
+	}{
+		{
+			query: `(function_declaration name: (identifier) @name) @fn`,
+			types: [][]string{{"function_declaration"}},
+		},
+		{
+			query: `; a comment (with parentheses)
+((identifier) @id (#eq? @id "a"))
+[(call_expression) (selector_expression)] @expr
+(comment)* @comment_block`,
+			types: [][]string{{"identifier"}, {"call_expression", "selector_expression"}, {"comment"}},
+		},
+		{
+			query: `(_ name: (identifier)) "func" @keyword ((comment) . (function_declaration))`,
+			types: [][]string{nil, nil, nil},
+		},
+		{
+			query: `(call_expression arguments: (argument_list (interpreted_string_literal) @s (#match? @s "[()]")))`,
+			types: [][]string{{"call_expression"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			types := [][]string{}
+			for _, pattern := range splitPatterns(tt.query) {
+				types = append(types, patternRootTypes(pattern))
+			}
+			if !reflect.DeepEqual(types, tt.types) {
+				t.Errorf("got %q, expected %q", types, tt.types)
+			}
+		})
+	}
+}
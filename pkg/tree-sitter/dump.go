@@ -13,20 +13,48 @@ type DumpFormat = dump.Format
 // DumpOptions contains settings for tree dumping
 type DumpOptions = dump.Options
 
+// DumpByteRange is a byte range used to select a subtree to dump
+type DumpByteRange = dump.ByteRange
+
 // Dumper is the interface that all tree dumpers must implement
 type Dumper = dump.Dumper
 
+// HTMLDumper renders the tree as a standalone HTML explorer page
+type HTMLDumper = dump.HTMLDumper
+
+// DumperOption configures a dumper created by NewDumper
+type DumperOption = dump.DumperOption
+
 // Format constants
 const (
-	FormatText DumpFormat = dump.FormatText
-	FormatXML  DumpFormat = dump.FormatXML
-	FormatJSON DumpFormat = dump.FormatJSON
-	FormatYAML DumpFormat = dump.FormatYAML
+	FormatText    DumpFormat = dump.FormatText
+	FormatXML     DumpFormat = dump.FormatXML
+	FormatJSON    DumpFormat = dump.FormatJSON
+	FormatYAML    DumpFormat = dump.FormatYAML
+	FormatSExpr   DumpFormat = dump.FormatSExpr
+	FormatDOT     DumpFormat = dump.FormatDOT
+	FormatMermaid DumpFormat = dump.FormatMermaid
+	FormatHTML    DumpFormat = dump.FormatHTML
 )
 
+// ParsePoint parses a 1-based "line:col" string into a tree-sitter point
+func ParsePoint(s string) (sitter.Point, error) {
+	return dump.ParsePoint(s)
+}
+
+// ParseByteRange parses a "start-end" string into a byte range
+func ParseByteRange(s string) (DumpByteRange, error) {
+	return dump.ParseByteRange(s)
+}
+
+// WithDumpTitle sets the title of the formats that show one, like html
+func WithDumpTitle(title string) DumperOption {
+	return dump.WithTitle(title)
+}
+
 // NewDumper creates a new tree dumper for the specified format
-func NewDumper(format DumpFormat) Dumper {
-	return dump.NewDumper(format)
+func NewDumper(format DumpFormat, options ...DumperOption) Dumper {
+	return dump.NewDumper(format, options...)
 }
 
 // DumpTree dumps a tree to the specified writer using the given format and options
@@ -0,0 +1,98 @@
+package dump
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Annotation records that a node was captured by a query.
+type Annotation struct {
+	// Query is the name of the query
+	Query string
+	// Capture is the name of the capture, without the leading @
+	Capture string
+	// Partial is true if the node was captured by a match that was then
+	// rejected by the predicates of the pattern (#eq?, #match?, ...), or if
+	// the node has the type of the root of a pattern that didn't match it
+	// (in which case Capture is empty).
+	Partial bool
+}
+
+// String formats the annotation as "@query.capture" (or "@query" without a
+// capture), with a trailing "?" for partial matches.
+func (a Annotation) String() string {
+	s := "@" + a.Query
+	if a.Capture != "" {
+		s += "." + a.Capture
+	}
+	if a.Partial {
+		s += "?"
+	}
+	return s
+}
+
+// NodeKey identifies a node across dumps. The type is part of the key because
+// a node and its only child can cover the same range.
+type NodeKey struct {
+	StartByte uint32
+	EndByte   uint32
+	Type      string
+}
+
+func NewNodeKey(n *sitter.Node) NodeKey {
+	return NodeKey{
+		StartByte: n.StartByte(),
+		EndByte:   n.EndByte(),
+		Type:      n.Type(),
+	}
+}
+
+// Annotations maps nodes to the query captures that matched them.
+type Annotations map[NodeKey][]Annotation
+
+// Add adds an annotation to a node, skipping duplicates.
+func (a Annotations) Add(n *sitter.Node, annotation Annotation) {
+	key := NewNodeKey(n)
+	for _, existing := range a[key] {
+		if existing == annotation {
+			return
+		}
+	}
+	a[key] = append(a[key], annotation)
+}
+
+// ForNode returns the annotations of the given node.
+func (a Annotations) ForNode(n *sitter.Node) []Annotation {
+	if a == nil {
+		return nil
+	}
+	return a[NewNodeKey(n)]
+}
+
+// splitAnnotations returns the formatted full and partial annotations of a node.
+func (a Annotations) splitAnnotations(n *sitter.Node) ([]string, []string) {
+	var captures, partial []string
+	for _, annotation := range a.ForNode(n) {
+		if annotation.Partial {
+			partial = append(partial, annotation.String())
+		} else {
+			captures = append(captures, annotation.String())
+		}
+	}
+	return captures, partial
+}
+
+// formatAnnotations returns the annotations of a node as a single
+// space-separated string, or "" if the node has none.
+func (a Annotations) formatAnnotations(n *sitter.Node) string {
+	annotations := a.ForNode(n)
+	if len(annotations) == 0 {
+		return ""
+	}
+	s := make([]string, len(annotations))
+	for i, annotation := range annotations {
+		s[i] = annotation.String()
+	}
+	return strings.Join(s, " ")
+}
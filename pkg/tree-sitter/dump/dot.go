@@ -0,0 +1,127 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// DOTDumper renders the tree as a Graphviz DOT graph.
+//
+// Named nodes are drawn as boxes, anonymous nodes as plain text, and error
+// or missing nodes are filled in red. Nodes captured by a query are filled in
+// green (yellow if the match failed its predicates). Edges are labeled with
+// field names.
+type DOTDumper struct{}
+
+var _ Dumper = &DOTDumper{}
+
+// Dump outputs the tree in DOT format
+func (d *DOTDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error {
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "digraph tree {")
+	fmt.Fprintln(w, "  node [fontname=\"Helvetica\", fontsize=10];")
+	fmt.Fprintln(w, "  edge [fontname=\"Helvetica\", fontsize=9];")
+
+	id := 0
+	var visitDOT func(n *sitter.Node, depth int) string
+	visitDOT = func(n *sitter.Node, depth int) string {
+		if n.IsNull() {
+			return ""
+		}
+
+		nodeType := n.Type()
+		// Skip pure whitespace nodes
+		if options.SkipWhitespace {
+			if matched, _ := regexp.MatchString(`^\s+$`, nodeType); matched {
+				return ""
+			}
+		}
+
+		nodeID := fmt.Sprintf("n%d", id)
+		id++
+
+		label := graphLabel(n, source, options)
+
+		attrs := []string{fmt.Sprintf("label=%s", dotQuote(label))}
+		captures, partial := options.Annotations.splitAnnotations(n)
+		switch {
+		case n.IsError() || n.IsMissing():
+			attrs = append(attrs, "shape=box", "style=filled", "fillcolor=\"#f8d7da\"", "color=\"#dc3545\"")
+		case len(captures) > 0:
+			attrs = append(attrs, "shape=box", "style=\"rounded,filled\"", "fillcolor=\"#d1e7dd\"", "color=\"#198754\"")
+		case len(partial) > 0:
+			attrs = append(attrs, "shape=box", "style=\"rounded,filled,dashed\"", "fillcolor=\"#fff3cd\"", "color=\"#ffc107\"")
+		case n.IsNamed():
+			attrs = append(attrs, "shape=box", "style=rounded")
+		default:
+			attrs = append(attrs, "shape=plaintext", "fontcolor=\"#6c757d\"")
+		}
+		fmt.Fprintf(w, "  %s [%s];\n", nodeID, strings.Join(attrs, ", "))
+
+		fieldNames := ChildFieldNames(n)
+		for i := 0; options.Descend(depth) && i < int(n.ChildCount()); i++ {
+			fieldName := fieldNames[i]
+			childID := visitDOT(n.Child(i), depth+1)
+			if childID == "" {
+				continue
+			}
+			if fieldName != "" {
+				fmt.Fprintf(w, "  %s -> %s [label=%s];\n", nodeID, childID, dotQuote(fieldName))
+			} else {
+				fmt.Fprintf(w, "  %s -> %s;\n", nodeID, childID)
+			}
+		}
+
+		return nodeID
+	}
+
+	for _, n := range nodes {
+		visitDOT(n, 0)
+	}
+
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+// graphLabel builds the (multi-line) label of a node for the graph dumpers.
+func graphLabel(n *sitter.Node, source []byte, options Options) string {
+	lines := []string{n.Type()}
+	if n.IsMissing() {
+		lines[0] = "MISSING " + lines[0]
+	}
+	position := formatPosition(n)
+	if options.ShowBytes {
+		position += fmt.Sprintf(" bytes:%d-%d", n.StartByte(), n.EndByte())
+	}
+	lines = append(lines, position)
+
+	// anonymous nodes are their own content
+	if source != nil && options.ShowContent && n.IsNamed() {
+		content := strings.Join(strings.Fields(n.Content(source)), " ")
+		if content != "" {
+			lines = append(lines, truncateContent(content, 30))
+		}
+	}
+
+	if annotations := options.Annotations.formatAnnotations(n); annotations != "" {
+		lines = append(lines, annotations)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// dotQuote quotes a string for use as a DOT attribute value.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return "\"" + s + "\""
+}
@@ -1,8 +1,13 @@
 package dump
 
 import (
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/pkg/errors"
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
@@ -18,6 +23,14 @@ const (
 	FormatJSON Format = "json"
 	// FormatYAML is the YAML output format
 	FormatYAML Format = "yaml"
+	// FormatSExpr is the tree-sitter S-expression output format
+	FormatSExpr Format = "sexpr"
+	// FormatDOT is the Graphviz DOT output format
+	FormatDOT Format = "dot"
+	// FormatMermaid is the Mermaid flowchart output format
+	FormatMermaid Format = "mermaid"
+	// FormatHTML is the standalone HTML tree explorer output format
+	FormatHTML Format = "html"
 )
 
 // Options contains settings for tree dumping
@@ -26,6 +39,24 @@ type Options struct {
 	ShowContent    bool
 	ShowAttributes bool
 	SkipWhitespace bool
+
+	// QuerySkeleton makes the S-expression dumper output a query skeleton
+	// for the selected nodes instead of the full tree.
+	QuerySkeleton bool
+
+	// At selects the smallest named node covering the given (0-based) point.
+	At *sitter.Point
+	// Bytes selects the smallest named node covering the given byte range.
+	Bytes *ByteRange
+	// NodeType selects every node of the given type (within the node
+	// selected by At or Bytes, if any).
+	NodeType string
+	// MaxDepth limits how deep below the selected nodes the dump goes.
+	// 0 means unlimited.
+	MaxDepth int
+
+	// Annotations are query captures to show on the dumped nodes.
+	Annotations Annotations
 }
 
 // Dumper is the interface that all tree dumpers must implement
@@ -33,18 +64,81 @@ type Dumper interface {
 	Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error
 }
 
+// DumperOption configures a dumper created by NewDumper.
+type DumperOption func(d Dumper)
+
+// WithTitle sets the title of the formats that show one, like the HTML
+// explorer. Other formats ignore it.
+func WithTitle(title string) DumperOption {
+	return func(d Dumper) {
+		if h, ok := d.(*HTMLDumper); ok {
+			h.Title = title
+		}
+	}
+}
+
 // NewDumper creates a new tree dumper for the specified format
-func NewDumper(format Format) Dumper {
+func NewDumper(format Format, options ...DumperOption) Dumper {
+	var d Dumper
 	switch format {
 	case FormatText:
-		return &TextDumper{}
+		d = &TextDumper{}
 	case FormatXML:
-		return &XMLDumper{}
+		d = &XMLDumper{}
 	case FormatJSON:
-		return &JSONDumper{}
+		d = &JSONDumper{}
 	case FormatYAML:
-		return &YAMLDumper{}
+		d = &YAMLDumper{}
+	case FormatSExpr:
+		d = &SExprDumper{}
+	case FormatDOT:
+		d = &DOTDumper{}
+	case FormatMermaid:
+		d = &MermaidDumper{}
+	case FormatHTML:
+		d = &HTMLDumper{}
 	default:
-		return &TextDumper{} // Default to text format
+		d = &TextDumper{} // Default to text format
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// ParsePoint parses a 1-based "line:col" string (as shown in the dumps) into
+// a 0-based tree-sitter point.
+func ParsePoint(s string) (sitter.Point, error) {
+	line, col, ok := strings.Cut(s, ":")
+	if !ok {
+		return sitter.Point{}, errors.Errorf("invalid position %q, expected line:col", s)
+	}
+	row, err := strconv.ParseUint(strings.TrimSpace(line), 10, 32)
+	if err != nil || row == 0 {
+		return sitter.Point{}, errors.Errorf("invalid line in position %q", s)
+	}
+	column, err := strconv.ParseUint(strings.TrimSpace(col), 10, 32)
+	if err != nil || column == 0 {
+		return sitter.Point{}, errors.Errorf("invalid column in position %q", s)
+	}
+	return sitter.Point{Row: uint32(row - 1), Column: uint32(column - 1)}, nil
+}
+
+// formatPosition formats the position of a node as "startLine,startCol-endLine,endCol",
+// using 1-based line/column numbers.
+func formatPosition(n *sitter.Node) string {
+	startPoint := n.StartPoint()
+	endPoint := n.EndPoint()
+	return fmt.Sprintf("%d,%d-%d,%d",
+		startPoint.Row+1, startPoint.Column+1,
+		endPoint.Row+1, endPoint.Column+1)
+}
+
+// truncateContent truncates content to maxLen characters, adding an ellipsis
+// if it was cut.
+func truncateContent(content string, maxLen int) string {
+	if utf8.RuneCountInString(content) > maxLen {
+		return string([]rune(content)[:maxLen-3]) + "..."
 	}
+	return content
 }
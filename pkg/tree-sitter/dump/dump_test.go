@@ -0,0 +1,101 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+// parseGo parses source with the go grammar.
+func parseGo(t *testing.T, source string) *sitter.Tree {
+	t.Helper()
+
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(golang.GetLanguage())
+	tree, err := parser.ParseCtx(context.Background(), nil, []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestSExprDump(t *testing.T) {
+	source := "package a\n\nfunc f(a, b int) {}\n"
+	tree := parseGo(t, source)
+
+	var buf bytes.Buffer
+	err := NewDumper(FormatSExpr).Dump(tree, []byte(source), &buf, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `(source_file
+  (package_clause
+    (package_identifier))
+  (function_declaration
+    name: (identifier)
+    parameters: (parameter_list
+      (parameter_declaration
+        name: (identifier)
+        name: (identifier)
+        type: (type_identifier)))
+    body: (block)))
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestQuerySkeleton(t *testing.T) {
+	source := "package a\n\nfunc f() {}\n"
+	tree := parseGo(t, source)
+
+	var buf bytes.Buffer
+	err := NewDumper(FormatSExpr).Dump(tree, []byte(source), &buf, Options{
+		QuerySkeleton: true,
+		NodeType:      "function_declaration",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"(function_declaration",
+		"name: (identifier) @name",
+		"@functionDeclaration",
+		`(#eq? @name "f")`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("missing %q in:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestTruncateContent(t *testing.T) {
+	tests := []struct {
+		content  string
+		maxLen   int
+		expected string
+	}{
+		{content: "short", maxLen: 10, expected: "short"},
+		{content: "exactly 10", maxLen: 10, expected: "exactly 10"},
+		{content: "a bit too long", maxLen: 10, expected: "a bit t..."},
+		// cut by characters, never in the middle of one
+		{content: "ééééééééééé", maxLen: 10, expected: "ééééééé..."},
+		{content: "日本語のテキスト", maxLen: 8, expected: "日本語のテキスト"},
+		{content: "日本語のテキストです", maxLen: 8, expected: "日本語のテ..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			if got := truncateContent(tt.content, tt.maxLen); got != tt.expected {
+				t.Errorf("got %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package dump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphDumps(t *testing.T) {
+	source := "package a\n\nfunc f(a, b int) { print(\"quoted\") }\n"
+	tree := parseGo(t, source)
+
+	tests := []struct {
+		format   Format
+		expected []string
+	}{
+		{
+			format: FormatDOT,
+			expected: []string{
+				"digraph tree {",
+				`n5 -> n7 [label="name"];`,
+				`n10 -> n13 [label="name"];`,
+				`n10 -> n14 [label="type"];`,
+				`\"quoted\"`,
+			},
+		},
+		{
+			format: FormatMermaid,
+			expected: []string{
+				"graph TD",
+				"n5 -->|name| n7",
+				"n10 -->|name| n13",
+				"n10 -->|type| n14",
+				"#quot;quoted#quot;",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewDumper(tt.format).Dump(tree, []byte(source), &buf, Options{ShowContent: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("missing %q in:\n%s", expected, buf.String())
+				}
+			}
+		})
+	}
+}
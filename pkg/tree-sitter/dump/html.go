@@ -0,0 +1,239 @@
+package dump
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+//go:embed templates/explorer.html
+var explorerTemplate string
+
+// HTMLDumper renders the tree as a single self-contained HTML page, with the
+// source on one side and a collapsible tree on the other. Clicking a node
+// highlights its range in the source, and clicking in the source selects the
+// smallest node covering that position.
+//
+// If Options.Annotations is set, the page also contains a query box listing
+// the captures of each query.
+type HTMLDumper struct {
+	// Title is shown at the top of the page. Defaults to "oak parse tree".
+	Title string
+}
+
+var _ Dumper = &HTMLDumper{}
+
+// htmlNode is a tree node as serialized into the HTML page. Offsets are in
+// UTF-16 code units, to be used directly with javascript strings.
+type htmlNode struct {
+	ID       int         `json:"id"`
+	Type     string      `json:"type"`
+	Field    string      `json:"field,omitempty"`
+	Named    bool        `json:"named"`
+	Error    bool        `json:"error,omitempty"`
+	Start    int         `json:"start"`
+	End      int         `json:"end"`
+	Position string      `json:"pos"`
+	Bytes    string      `json:"bytes,omitempty"`
+	Content  *string     `json:"content,omitempty"`
+	Captures []string    `json:"captures,omitempty"`
+	Children []*htmlNode `json:"children,omitempty"`
+}
+
+type htmlCapture struct {
+	Capture  string `json:"capture"`
+	Partial  bool   `json:"partial"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Position string `json:"pos"`
+	Text     string `json:"text"`
+}
+
+type htmlQuery struct {
+	Name     string         `json:"name"`
+	Captures []*htmlCapture `json:"captures"`
+}
+
+type htmlData struct {
+	Source  string       `json:"source"`
+	Roots   []*htmlNode  `json:"roots"`
+	Queries []*htmlQuery `json:"queries"`
+}
+
+// Dump outputs the tree as an HTML page
+func (d *HTMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error {
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+
+	offsets := utf16Offsets(source)
+
+	id := 0
+	var buildHTML func(n *sitter.Node, fieldName string, depth int) *htmlNode
+	buildHTML = func(n *sitter.Node, fieldName string, depth int) *htmlNode {
+		if n.IsNull() {
+			return nil
+		}
+
+		nodeType := n.Type()
+		// Skip pure whitespace nodes
+		if options.SkipWhitespace {
+			if matched, _ := regexp.MatchString(`^\s+$`, nodeType); matched {
+				return nil
+			}
+		}
+
+		node := &htmlNode{
+			ID:       id,
+			Type:     nodeType,
+			Field:    fieldName,
+			Named:    n.IsNamed(),
+			Error:    n.IsError() || n.IsMissing(),
+			Start:    offsets[n.StartByte()],
+			End:      offsets[n.EndByte()],
+			Position: formatPosition(n),
+		}
+		id++
+
+		if options.ShowBytes {
+			node.Bytes = fmt.Sprintf("%d-%d", n.StartByte(), n.EndByte())
+		}
+		if options.ShowContent && n.NamedChildCount() == 0 {
+			content := truncateContent(n.Content(source), 60)
+			node.Content = &content
+		}
+		if annotations := options.Annotations.ForNode(n); len(annotations) > 0 {
+			for _, annotation := range annotations {
+				node.Captures = append(node.Captures, annotation.String())
+			}
+		}
+
+		fieldNames := ChildFieldNames(n)
+		for i := 0; options.Descend(depth) && i < int(n.ChildCount()); i++ {
+			child := buildHTML(n.Child(i), fieldNames[i], depth+1)
+			if child != nil {
+				node.Children = append(node.Children, child)
+			}
+		}
+
+		return node
+	}
+
+	data := &htmlData{
+		Source:  string(source),
+		Roots:   []*htmlNode{},
+		Queries: htmlQueries(options.Annotations, source, offsets),
+	}
+	for _, n := range nodes {
+		if root := buildHTML(n, "", 0); root != nil {
+			data.Roots = append(data.Roots, root)
+		}
+	}
+
+	// json.Marshal escapes <, > and &, so the result can be embedded in a
+	// script tag as is
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	title := d.Title
+	if title == "" {
+		title = "oak parse tree"
+	}
+
+	tmpl, err := template.New("explorer").Parse(explorerTemplate)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, map[string]interface{}{
+		"Title": title,
+		"Data":  template.JS(dataJSON),
+	})
+}
+
+// htmlQueries groups the annotations by query, sorted by position.
+func htmlQueries(annotations Annotations, source []byte, offsets []int) []*htmlQuery {
+	position := bytePositions(source)
+	queriesByName := map[string]*htmlQuery{}
+	for key, nodeAnnotations := range annotations {
+		for _, annotation := range nodeAnnotations {
+			query, ok := queriesByName[annotation.Query]
+			if !ok {
+				query = &htmlQuery{Name: annotation.Query}
+				queriesByName[annotation.Query] = query
+			}
+			query.Captures = append(query.Captures, &htmlCapture{
+				Capture:  annotation.Capture,
+				Partial:  annotation.Partial,
+				Start:    offsets[key.StartByte],
+				End:      offsets[key.EndByte],
+				Position: position(key.StartByte) + "-" + position(key.EndByte),
+				Text:     truncateContent(string(source[key.StartByte:key.EndByte]), 60),
+			})
+		}
+	}
+
+	ret := []*htmlQuery{}
+	for _, query := range queriesByName {
+		sort.Slice(query.Captures, func(i, j int) bool {
+			a, b := query.Captures[i], query.Captures[j]
+			if a.Start != b.Start {
+				return a.Start < b.Start
+			}
+			return a.End > b.End
+		})
+		ret = append(ret, query)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+
+	return ret
+}
+
+// utf16Offsets maps each byte offset in source (including len(source)) to the
+// corresponding offset in UTF-16 code units, as used by javascript strings.
+// Offsets in the middle of a UTF-8 sequence map to the start of the character.
+func utf16Offsets(source []byte) []int {
+	offsets := make([]int, len(source)+1)
+	offset := 0
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRune(source[i:])
+		for j := 0; j < size; j++ {
+			offsets[i+j] = offset
+		}
+		i += size
+		if r >= 0x10000 {
+			offset += 2
+		} else {
+			offset++
+		}
+	}
+	offsets[len(source)] = offset
+	return offsets
+}
+
+// bytePositions returns a function that computes the 1-based "line,col"
+// position of a byte offset in source.
+func bytePositions(source []byte) func(offset uint32) string {
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return func(offset uint32) string {
+		line := sort.SearchInts(lineStarts, int(offset)+1) - 1
+		return fmt.Sprintf("%d,%d", line+1, int(offset)-lineStarts[line]+1)
+	}
+}
@@ -0,0 +1,45 @@
+package dump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHTMLDump(t *testing.T) {
+	source := "package a\n\nfunc f() { print(\"</script>é\") }\n"
+	tree := parseGo(t, source)
+
+	var buf bytes.Buffer
+	options := Options{
+		ShowContent: true,
+		Annotations: Annotations{},
+	}
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Annotations.Add(nodes[0].NamedChild(1), Annotation{Query: "functions", Capture: "fn"})
+
+	err = NewDumper(FormatHTML, WithTitle("<a.go>")).Dump(tree, []byte(source), &buf, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+
+	for _, expected := range []string{
+		"<title>&lt;a.go&gt;</title>",
+		`"field":"name"`,
+		`"name":"functions"`,
+		`"capture":"fn"`,
+		// the source is embedded as JSON, which can't close the script
+		`\u003c/script\u003eé`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("missing %q", expected)
+		}
+	}
+	if strings.Count(html, "</script>") != 1 {
+		t.Errorf("expected a single </script>, got %d", strings.Count(html, "</script>"))
+	}
+}
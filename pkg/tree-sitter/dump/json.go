@@ -23,14 +23,16 @@ type NodeJSON struct {
 	IsExtra   bool                   `json:"is_extra,omitempty"`
 	HasError  bool                   `json:"has_error,omitempty"`
 	Content   string                 `json:"content,omitempty"`
+	Captures  []string               `json:"captures,omitempty"`
+	Partial   []string               `json:"partial_captures,omitempty"`
 	Fields    map[string][]*NodeJSON `json:"fields,omitempty"`
 	Children  []*NodeJSON            `json:"children,omitempty"`
 }
 
 // Dump outputs the tree in JSON format
 func (d *JSONDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error {
-	var buildJSON func(n *sitter.Node) *NodeJSON
-	buildJSON = func(n *sitter.Node) *NodeJSON {
+	var buildJSON func(n *sitter.Node, depth int) *NodeJSON
+	buildJSON = func(n *sitter.Node, depth int) *NodeJSON {
 		if n.IsNull() {
 			return nil
 		}
@@ -91,13 +93,17 @@ func (d *JSONDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 			node.Content = content
 		}
 
+		// Add the query captures matching this node
+		node.Captures, node.Partial = options.Annotations.splitAnnotations(n)
+
 		// Process children
 		fieldMap := make(map[string][]*NodeJSON)
 		var plainChildren []*NodeJSON
 
-		for i := 0; i < int(n.ChildCount()); i++ {
-			fieldName := n.FieldNameForChild(i)
-			child := buildJSON(n.Child(i))
+		fieldNames := ChildFieldNames(n)
+		for i := 0; options.Descend(depth) && i < int(n.ChildCount()); i++ {
+			fieldName := fieldNames[i]
+			child := buildJSON(n.Child(i), depth+1)
 
 			if child == nil {
 				continue
@@ -124,12 +130,25 @@ func (d *JSONDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 		return node
 	}
 
-	rootJSON := buildJSON(tree.RootNode())
-	if rootJSON == nil {
-		return errors.New("failed to build JSON representation of tree")
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+
+	rootsJSON := []*NodeJSON{}
+	for _, n := range nodes {
+		rootJSON := buildJSON(n, 0)
+		if rootJSON == nil {
+			return errors.New("failed to build JSON representation of tree")
+		}
+		rootsJSON = append(rootsJSON, rootJSON)
 	}
 
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
-	return enc.Encode(rootJSON)
+	// selecting by node type can return multiple nodes, output a list in that case
+	if options.NodeType != "" {
+		return enc.Encode(rootsJSON)
+	}
+	return enc.Encode(rootsJSON[0])
 }
@@ -0,0 +1,144 @@
+package dump
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ByteRange is a range of bytes in the source, end excluded.
+type ByteRange struct {
+	Start uint32
+	End   uint32
+}
+
+// ParseByteRange parses a "start-end" string into a ByteRange.
+func ParseByteRange(s string) (ByteRange, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return ByteRange{}, errors.Errorf("invalid byte range %q, expected start-end", s)
+	}
+	start_, err := strconv.ParseUint(strings.TrimSpace(start), 10, 32)
+	if err != nil {
+		return ByteRange{}, errors.Wrapf(err, "invalid start in byte range %q", s)
+	}
+	end_, err := strconv.ParseUint(strings.TrimSpace(end), 10, 32)
+	if err != nil {
+		return ByteRange{}, errors.Wrapf(err, "invalid end in byte range %q", s)
+	}
+	if end_ < start_ {
+		return ByteRange{}, errors.Errorf("invalid byte range %q, end is before start", s)
+	}
+	return ByteRange{Start: uint32(start_), End: uint32(end_)}, nil
+}
+
+// HasSelector returns true if the options select a subtree rather than the
+// whole tree.
+func (o Options) HasSelector() bool {
+	return o.At != nil || o.Bytes != nil || o.NodeType != ""
+}
+
+// SelectNodes returns the nodes the dump should start from.
+//
+// The selection is first narrowed down to the smallest named node covering
+// At or Bytes (if set), then, if NodeType is set, to every node of that type
+// within it (including itself). Without any selector, the root node is
+// returned.
+func (o Options) SelectNodes(root *sitter.Node) ([]*sitter.Node, error) {
+	node := root
+
+	if o.At != nil {
+		node = root.NamedDescendantForPointRange(*o.At, *o.At)
+		if node == nil || node.IsNull() {
+			return nil, errors.Errorf("no node found at %d:%d", o.At.Row+1, o.At.Column+1)
+		}
+	}
+
+	if o.Bytes != nil {
+		if o.Bytes.Start < node.StartByte() || o.Bytes.End > node.EndByte() {
+			return nil, errors.Errorf("byte range %d-%d is outside of the selected node", o.Bytes.Start, o.Bytes.End)
+		}
+		node = namedDescendantForByteRange(node, o.Bytes.Start, o.Bytes.End)
+	}
+
+	if o.NodeType == "" {
+		return []*sitter.Node{node}, nil
+	}
+
+	ret := []*sitter.Node{}
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		if n.Type() == o.NodeType {
+			ret = append(ret, n)
+			// nested nodes of the same type are part of the dump already
+			return
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			visit(n.Child(i))
+		}
+	}
+	visit(node)
+
+	if len(ret) == 0 {
+		return nil, errors.Errorf("no node of type %s found", o.NodeType)
+	}
+
+	return ret, nil
+}
+
+// Descend returns true if the children of a node at the given depth (relative
+// to the selected node, which is at depth 0) should be dumped.
+func (o Options) Descend(depth int) bool {
+	return o.MaxDepth <= 0 || depth < o.MaxDepth
+}
+
+// namedDescendantForByteRange returns the smallest named node within n that
+// covers the byte range [start, end).
+func namedDescendantForByteRange(n *sitter.Node, start, end uint32) *sitter.Node {
+	ret := n
+	for {
+		var next *sitter.Node
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			if child.StartByte() <= start && end <= child.EndByte() {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return ret
+		}
+		if next.IsNamed() {
+			ret = next
+		}
+		n = next
+	}
+}
+
+// ChildFieldNames returns the field name of each child of n (or "" if the
+// child has no field name).
+//
+// This walks the children with a tree cursor instead of using
+// Node.FieldNameForChild, which returns wrong names when anonymous nodes
+// (for example the "," in "a, b int") sit between fields.
+func ChildFieldNames(n *sitter.Node) []string {
+	ret := make([]string, 0, n.ChildCount())
+	if n.ChildCount() == 0 {
+		return ret
+	}
+	cursor := sitter.NewTreeCursor(n)
+	defer cursor.Close()
+
+	if !cursor.GoToFirstChild() {
+		return ret
+	}
+	for {
+		ret = append(ret, cursor.CurrentFieldName())
+		if !cursor.GoToNextSibling() {
+			break
+		}
+	}
+	return ret
+}
@@ -0,0 +1,144 @@
+package dump
+
+import (
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+func TestParsePoint(t *testing.T) {
+	tests := []struct {
+		s     string
+		point sitter.Point
+		ok    bool
+	}{
+		{s: "1:1", point: sitter.Point{Row: 0, Column: 0}, ok: true},
+		{s: "12: 5", point: sitter.Point{Row: 11, Column: 4}, ok: true},
+		{s: "0:1", ok: false},
+		{s: "1:0", ok: false},
+		{s: "12", ok: false},
+		{s: "a:b", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			point, err := ParsePoint(tt.s)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, expected ok %v", err, tt.ok)
+			}
+			if tt.ok && point != tt.point {
+				t.Errorf("got %v, expected %v", point, tt.point)
+			}
+		})
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		s      string
+		range_ ByteRange
+		ok     bool
+	}{
+		{s: "3-7", range_: ByteRange{Start: 3, End: 7}, ok: true},
+		{s: "3 - 3", range_: ByteRange{Start: 3, End: 3}, ok: true},
+		{s: "7-3", ok: false},
+		{s: "3", ok: false},
+		{s: "-3", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			r, err := ParseByteRange(tt.s)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, expected ok %v", err, tt.ok)
+			}
+			if tt.ok && r != tt.range_ {
+				t.Errorf("got %v, expected %v", r, tt.range_)
+			}
+		})
+	}
+}
+
+func TestSelectNodes(t *testing.T) {
+	source := "package a\n\nfunc f(a int) {}\n\nfunc g() { f(1) }\n"
+	tree := parseGo(t, source)
+
+	tests := []struct {
+		name    string
+		options Options
+		types   []string
+		ok      bool
+	}{
+		{
+			name:    "whole tree",
+			options: Options{},
+			types:   []string{"source_file"},
+			ok:      true,
+		},
+		{
+			name:    "position",
+			options: Options{At: &sitter.Point{Row: 2, Column: 7}},
+			types:   []string{"identifier"},
+			ok:      true,
+		},
+		{
+			name:    "byte range",
+			options: Options{Bytes: &ByteRange{Start: 17, End: 24}},
+			types:   []string{"parameter_list"},
+			ok:      true,
+		},
+		{
+			name:    "node type",
+			options: Options{NodeType: "function_declaration"},
+			types:   []string{"function_declaration", "function_declaration"},
+			ok:      true,
+		},
+		{
+			name: "node type within a position",
+			options: Options{
+				At:       &sitter.Point{Row: 4, Column: 1},
+				NodeType: "call_expression",
+			},
+			types: []string{"call_expression"},
+			ok:    true,
+		},
+		{
+			name: "no node of the type within a byte range",
+			options: Options{
+				Bytes:    &ByteRange{Start: 11, End: 27},
+				NodeType: "call_expression",
+			},
+			ok: false,
+		},
+		{
+			name:    "byte range outside of the tree",
+			options: Options{Bytes: &ByteRange{Start: 0, End: 1000}},
+			ok:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := tt.options.SelectNodes(tree.RootNode())
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, expected ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			types := []string{}
+			for _, n := range nodes {
+				types = append(types, n.Type())
+			}
+			if len(types) != len(tt.types) {
+				t.Fatalf("got %v, expected %v", types, tt.types)
+			}
+			for i := range types {
+				if types[i] != tt.types[i] {
+					t.Errorf("got %v, expected %v", types, tt.types)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// SExprDumper implements the tree-sitter S-expression format dumper.
+//
+// The output mirrors what tree-sitter itself prints (only named nodes, field
+// names as prefixes), so it can be copy-pasted into a query almost verbatim:
+//
+//	(source_file
+//	  (function_declaration
+//	    name: (identifier)
+//	    parameters: (parameter_list)))
+//
+// When Options.QuerySkeleton is set, the dumper instead emits a query
+// skeleton for the selected node, with captures named after the fields.
+type SExprDumper struct{}
+
+var _ Dumper = &SExprDumper{}
+
+// sexprLine is a single output line. Closing parentheses are appended to the
+// text of the last line emitted for a node, so that trailing comments never
+// swallow them.
+type sexprLine struct {
+	text    string
+	comment string
+}
+
+// Dump outputs the tree in S-expression format
+func (d *SExprDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error {
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+
+	if options.QuerySkeleton {
+		for i, n := range nodes {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprint(w, QuerySkeleton(n, source))
+		}
+		return nil
+	}
+
+	lines := []*sexprLine{}
+
+	var visit func(n *sitter.Node, fieldName string, depth int)
+	visit = func(n *sitter.Node, fieldName string, depth int) {
+		if n.IsNull() {
+			return
+		}
+		// Anonymous nodes are not part of the S-expression form, except
+		// when they are missing, which is worth knowing about.
+		if !n.IsNamed() && !n.IsMissing() {
+			return
+		}
+
+		prefix := ""
+		if fieldName != "" {
+			prefix = fieldName + ": "
+		}
+
+		nodeType := n.Type()
+		if !n.IsNamed() {
+			nodeType = fmt.Sprintf("%q", nodeType)
+		}
+		if n.IsMissing() {
+			nodeType = "MISSING " + nodeType
+		}
+
+		line := &sexprLine{
+			text:    fmt.Sprintf("%s%s(%s", strings.Repeat("  ", depth), prefix, nodeType),
+			comment: sexprComment(n, source, options),
+		}
+		lines = append(lines, line)
+
+		fieldNames := ChildFieldNames(n)
+		for i := 0; options.Descend(depth) && i < int(n.ChildCount()); i++ {
+			visit(n.Child(i), fieldNames[i], depth+1)
+		}
+
+		lines[len(lines)-1].text += ")"
+	}
+
+	for _, n := range nodes {
+		visit(n, "", 0)
+	}
+
+	for _, line := range lines {
+		if line.comment != "" {
+			fmt.Fprintf(w, "%s ; %s\n", line.text, line.comment)
+		} else {
+			fmt.Fprintln(w, line.text)
+		}
+	}
+
+	return nil
+}
+
+// sexprComment builds the trailing comment for a node, containing its
+// position and optionally its byte range, content and query captures.
+func sexprComment(n *sitter.Node, source []byte, options Options) string {
+	annotations := options.Annotations.formatAnnotations(n)
+	if !options.ShowBytes && !(options.ShowContent && source != nil) {
+		return annotations
+	}
+
+	parts := []string{fmt.Sprintf("[%s]", formatPosition(n))}
+	if options.ShowBytes {
+		parts = append(parts, fmt.Sprintf("bytes:%d-%d", n.StartByte(), n.EndByte()))
+	}
+	// Only leaf nodes get their content, otherwise every line would repeat
+	// the source of the whole subtree.
+	if options.ShowContent && source != nil && n.NamedChildCount() == 0 {
+		parts = append(parts, fmt.Sprintf("%q", truncateContent(n.Content(source), 60)))
+	}
+	if annotations != "" {
+		parts = append(parts, annotations)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// maxSkeletonDepth limits how many levels of fields are expanded in a query
+// skeleton. Deeper fields are left as plain node types that can be expanded
+// by hand.
+const maxSkeletonDepth = 2
+
+// QuerySkeleton returns a ready-to-edit tree-sitter query matching the given
+// node. Every child reachable through a field becomes a capture named after
+// the field (camelCased, as in the oak query repository), and the node itself
+// is captured under the name of its type. For example, for a go function:
+//
+//	(function_declaration
+//	  name: (identifier) @name
+//	  parameters: (parameter_list) @parameters
+//	  body: (block) @body) @functionDeclaration
+//	; (#eq? @name "foo")
+func QuerySkeleton(n *sitter.Node, source []byte) string {
+	if n == nil || n.IsNull() {
+		return ""
+	}
+
+	usedNames := map[string]int{}
+	uniqueName := func(name string) string {
+		usedNames[name]++
+		if count := usedNames[name]; count > 1 {
+			return fmt.Sprintf("%s%d", name, count)
+		}
+		return name
+	}
+
+	// firstLeaf records the first capture of a leaf node, used to suggest
+	// an #eq? predicate.
+	var firstLeaf, firstLeafText string
+
+	var buf strings.Builder
+	var visit func(n *sitter.Node, depth int)
+	visit = func(n *sitter.Node, depth int) {
+		fmt.Fprintf(&buf, "(%s", n.Type())
+		if depth >= maxSkeletonDepth {
+			buf.WriteString(")")
+			return
+		}
+
+		fieldNames := ChildFieldNames(n)
+		for i := 0; i < int(n.ChildCount()); i++ {
+			fieldName := fieldNames[i]
+			child := n.Child(i)
+			if fieldName == "" || !child.IsNamed() {
+				continue
+			}
+
+			fmt.Fprintf(&buf, "\n%s%s: ", strings.Repeat("  ", depth+1), fieldName)
+			visit(child, depth+1)
+
+			captureName := uniqueName(camelCase(fieldName))
+			fmt.Fprintf(&buf, " @%s", captureName)
+
+			if firstLeaf == "" && child.NamedChildCount() == 0 && source != nil {
+				firstLeaf = captureName
+				firstLeafText = child.Content(source)
+			}
+		}
+		buf.WriteString(")")
+	}
+
+	visit(n, 0)
+	fmt.Fprintf(&buf, " @%s\n", uniqueName(camelCase(n.Type())))
+
+	if firstLeaf != "" {
+		fmt.Fprintf(&buf, "; (#eq? @%s %q)\n", firstLeaf, firstLeafText)
+	}
+
+	return buf.String()
+}
+
+// camelCase converts a tree-sitter field or node name (snake_case) to
+// camelCase.
+func camelCase(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '-' || unicode.IsSpace(r)
+	})
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
@@ -90,11 +90,21 @@ func (d *TextDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 			fmt.Fprintf(w, " \"%s\"", content)
 		}
 
+		// Query captures matching this node
+		if annotations := options.Annotations.formatAnnotations(n); annotations != "" {
+			fmt.Fprintf(w, " %s", annotations)
+		}
+
 		fmt.Fprintln(w)
 
+		if !options.Descend(depth) {
+			return nil
+		}
+
 		// Visit children
+		fieldNames := ChildFieldNames(n)
 		for i := 0; i < int(n.ChildCount()); i++ {
-			fieldName := n.FieldNameForChild(i)
+			fieldName := fieldNames[i]
 			err := visitEnhanced(n.Child(i), fieldName, depth+1)
 			if err != nil {
 				return err
@@ -104,5 +114,16 @@ func (d *TextDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 		return nil
 	}
 
-	return visitEnhanced(tree.RootNode(), "", 0)
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		err = visitEnhanced(n, "", 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -14,12 +14,18 @@ type XMLDumper struct{}
 
 // Dump outputs the tree in XML format
 func (d *XMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error {
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+
 	// Write XML header
 	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
 	fmt.Fprintf(w, "<tree>\n")
 
-	var visitXML func(n *sitter.Node, depth int) error
-	visitXML = func(n *sitter.Node, depth int) error {
+	// depth is the indentation depth, level the depth relative to the selected node
+	var visitXML func(n *sitter.Node, depth int, level int) error
+	visitXML = func(n *sitter.Node, depth int, level int) error {
 		if n.IsNull() {
 			return nil
 		}
@@ -37,13 +43,7 @@ func (d *XMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 		// Get node text content if source is provided
 		var contentAttr string
 		if source != nil && options.ShowContent {
-			content := n.Content(source)
-			// XML-escape the content
-			content = strings.ReplaceAll(content, "&", "&amp;")
-			content = strings.ReplaceAll(content, "<", "&lt;")
-			content = strings.ReplaceAll(content, ">", "&gt;")
-			content = strings.ReplaceAll(content, "\"", "&quot;")
-			contentAttr = fmt.Sprintf(" content=\"%s\"", content)
+			contentAttr = fmt.Sprintf(" content=\"%s\"", escapeXMLAttribute(n.Content(source)))
 		}
 
 		// Convert to 1-based line/column numbers for better readability
@@ -72,30 +72,42 @@ func (d *XMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 				n.HasError())
 		}
 
+		// Query captures matching this node
+		capturesAttr := ""
+		captures, partial := options.Annotations.splitAnnotations(n)
+		if len(captures) > 0 {
+			capturesAttr += fmt.Sprintf(" captures=\"%s\"", escapeXMLAttribute(strings.Join(captures, " ")))
+		}
+		if len(partial) > 0 {
+			capturesAttr += fmt.Sprintf(" partial_captures=\"%s\"", escapeXMLAttribute(strings.Join(partial, " ")))
+		}
+
 		// Output opening tag with attributes
-		fmt.Fprintf(w, "%s<node type=\"%s\"%s%s%s%s>\n",
+		fmt.Fprintf(w, "%s<node type=\"%s\"%s%s%s%s%s>\n",
 			indent,
 			nodeType,
 			posAttr,
 			bytesAttr,
 			attributesStr,
 			contentAttr,
+			capturesAttr,
 		)
 
 		// Visit children
-		for i := 0; i < int(n.ChildCount()); i++ {
-			fieldName := n.FieldNameForChild(i)
+		fieldNames := ChildFieldNames(n)
+		for i := 0; options.Descend(level) && i < int(n.ChildCount()); i++ {
+			fieldName := fieldNames[i]
 			child := n.Child(i)
 
 			if fieldName != "" {
 				fmt.Fprintf(w, "%s  <field name=\"%s\">\n", indent, fieldName)
-				err := visitXML(child, depth+2)
+				err := visitXML(child, depth+2, level+1)
 				if err != nil {
 					return err
 				}
 				fmt.Fprintf(w, "%s  </field>\n", indent)
 			} else {
-				err := visitXML(child, depth+1)
+				err := visitXML(child, depth+1, level+1)
 				if err != nil {
 					return err
 				}
@@ -107,11 +119,22 @@ func (d *XMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 		return nil
 	}
 
-	err := visitXML(tree.RootNode(), 1)
-	if err != nil {
-		return err
+	for _, n := range nodes {
+		err = visitXML(n, 1, 0)
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Fprintf(w, "</tree>\n")
 	return nil
 }
+
+// escapeXMLAttribute escapes s for use in a double-quoted XML attribute.
+func escapeXMLAttribute(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	s = strings.ReplaceAll(s, "\"", "&quot;")
+	return s
+}
@@ -25,14 +25,16 @@ type NodeYAML struct {
 	Extra    bool                   `yaml:"extra,omitempty"`
 	HasError bool                   `yaml:"has_error,omitempty"`
 	Content  string                 `yaml:"content,omitempty"`
+	Captures []string               `yaml:"captures,omitempty"`
+	Partial  []string               `yaml:"partial_captures,omitempty"`
 	Fields   map[string][]*NodeYAML `yaml:"fields,omitempty"`
 	Children []*NodeYAML            `yaml:"children,omitempty"`
 }
 
 // Dump outputs the tree in YAML format
 func (d *YAMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options Options) error {
-	var buildYAML func(n *sitter.Node) *NodeYAML
-	buildYAML = func(n *sitter.Node) *NodeYAML {
+	var buildYAML func(n *sitter.Node, depth int) *NodeYAML
+	buildYAML = func(n *sitter.Node, depth int) *NodeYAML {
 		if n.IsNull() {
 			return nil
 		}
@@ -100,13 +102,17 @@ func (d *YAMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 			node.Content = content
 		}
 
+		// Add the query captures matching this node
+		node.Captures, node.Partial = options.Annotations.splitAnnotations(n)
+
 		// Process children
 		fieldMap := make(map[string][]*NodeYAML)
 		var plainChildren []*NodeYAML
 
-		for i := 0; i < int(n.ChildCount()); i++ {
-			fieldName := n.FieldNameForChild(i)
-			child := buildYAML(n.Child(i))
+		fieldNames := ChildFieldNames(n)
+		for i := 0; options.Descend(depth) && i < int(n.ChildCount()); i++ {
+			fieldName := fieldNames[i]
+			child := buildYAML(n.Child(i), depth+1)
 
 			if child == nil {
 				continue
@@ -133,9 +139,18 @@ func (d *YAMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 		return node
 	}
 
-	rootYAML := buildYAML(tree.RootNode())
-	if rootYAML == nil {
-		return errors.New("failed to build YAML representation of tree")
+	nodes, err := options.SelectNodes(tree.RootNode())
+	if err != nil {
+		return err
+	}
+
+	rootsYAML := []*NodeYAML{}
+	for _, n := range nodes {
+		rootYAML := buildYAML(n, 0)
+		if rootYAML == nil {
+			return errors.New("failed to build YAML representation of tree")
+		}
+		rootsYAML = append(rootsYAML, rootYAML)
 	}
 
 	enc := yaml.NewEncoder(w)
@@ -144,5 +159,9 @@ func (d *YAMLDumper) Dump(tree *sitter.Tree, source []byte, w io.Writer, options
 			log.Warn().Err(err).Msg("error closing yaml encoder")
 		}
 	}()
-	return enc.Encode(rootYAML)
+	// selecting by node type can return multiple nodes, output a list in that case
+	if options.NodeType != "" {
+		return enc.Encode(rootsYAML)
+	}
+	return enc.Encode(rootsYAML[0])
 }
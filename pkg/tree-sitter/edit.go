@@ -0,0 +1,43 @@
+package tree_sitter
+
+import (
+	"bytes"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ComputeEdit describes the change between oldSource and newSource as a
+// single edit, spanning from the first to the last differing byte, so that
+// a tree of oldSource can be re-parsed incrementally.
+func ComputeEdit(oldSource, newSource []byte) sitter.EditInput {
+	start := 0
+	for start < len(oldSource) && start < len(newSource) && oldSource[start] == newSource[start] {
+		start++
+	}
+
+	oldEnd, newEnd := len(oldSource), len(newSource)
+	for oldEnd > start && newEnd > start && oldSource[oldEnd-1] == newSource[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	return sitter.EditInput{
+		StartIndex:  uint32(start),
+		OldEndIndex: uint32(oldEnd),
+		NewEndIndex: uint32(newEnd),
+		StartPoint:  PointAt(oldSource, start),
+		OldEndPoint: PointAt(oldSource, oldEnd),
+		NewEndPoint: PointAt(newSource, newEnd),
+	}
+}
+
+// PointAt returns the row/column point of the given byte offset.
+func PointAt(source []byte, offset int) sitter.Point {
+	prefix := source[:offset]
+	row := bytes.Count(prefix, []byte("\n"))
+	column := offset
+	if idx := bytes.LastIndexByte(prefix, '\n'); idx >= 0 {
+		column = offset - idx - 1
+	}
+	return sitter.Point{Row: uint32(row), Column: uint32(column)}
+}
@@ -0,0 +1,56 @@
+package tree_sitter
+
+import (
+	"github.com/go-go-golems/oak/pkg/tree-sitter/dump"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ChildFieldNames returns the field name of each child of n (or "" if the
+// child has no field name). Unlike Node.FieldNameForChild, it is not
+// confused by anonymous nodes between fields.
+func ChildFieldNames(n *sitter.Node) []string {
+	return dump.ChildFieldNames(n)
+}
+
+// FindParameters returns the parameters node of the function defined by n,
+// looking at its children (like the declarator of a C function) up to a
+// few levels deep. single is true for the single parameter of arrow
+// functions without parentheses.
+func FindParameters(n *sitter.Node) (*sitter.Node, bool) {
+	return findParameters(n, 0)
+}
+
+func findParameters(n *sitter.Node, depth int) (*sitter.Node, bool) {
+	if p := n.ChildByFieldName("parameters"); p != nil {
+		return p, false
+	}
+	if p := n.ChildByFieldName("parameter"); p != nil {
+		return nil, true
+	}
+	if depth > 3 {
+		return nil, false
+	}
+	fieldNames := ChildFieldNames(n)
+	for i := 0; i < int(n.ChildCount()); i++ {
+		child := n.Child(i)
+		if !child.IsNamed() || fieldNames[i] == "body" {
+			continue
+		}
+		if p, single := findParameters(child, depth+1); p != nil || single {
+			return p, single
+		}
+	}
+	return nil, false
+}
+
+// CountDeclaredNames returns the number of children of n in its name
+// field, like the 3 names of the parameter `a, b, c int` in Go.
+func CountDeclaredNames(n *sitter.Node) int {
+	count := 0
+	for _, field := range ChildFieldNames(n) {
+		if field == "name" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,51 @@
+package tree_sitter
+
+import (
+	"context"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+func TestCountDeclaredNames(t *testing.T) {
+	tests := []struct {
+		source string
+		names  []int
+	}{
+		{source: "func f(a, b, c int) {}", names: []int{3}},
+		{source: "func f(a int, b string) {}", names: []int{1, 1}},
+		{source: "func f(int, string) {}", names: []int{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			source := []byte("package a\n\n" + tt.source + "\n")
+			parser := sitter.NewParser()
+			defer parser.Close()
+			parser.SetLanguage(golang.GetLanguage())
+			tree, err := parser.ParseCtx(context.Background(), nil, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fn := tree.RootNode().NamedChild(1)
+			params, single := FindParameters(fn)
+			if params == nil || single {
+				t.Fatalf("no parameters found in %s", fn.String())
+			}
+			names := []int{}
+			for i := 0; i < int(params.NamedChildCount()); i++ {
+				names = append(names, CountDeclaredNames(params.NamedChild(i)))
+			}
+			if len(names) != len(tt.names) {
+				t.Fatalf("got %v, expected %v", names, tt.names)
+			}
+			for i := range names {
+				if names[i] != tt.names[i] {
+					t.Errorf("got %v, expected %v", names, tt.names)
+				}
+			}
+		})
+	}
+}
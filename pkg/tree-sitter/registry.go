@@ -0,0 +1,103 @@
+package tree_sitter
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// QueryRegistry gives access to a set of queries with one file per
+// language, like the queries embedded by the packages extracting symbols,
+// imports or calls.
+type QueryRegistry struct {
+	fs  fs.FS
+	dir string
+	// what the queries find, for error messages
+	what string
+	// aliases maps languages to the language of their query file, for
+	// languages sharing the query of another one.
+	aliases map[string]string
+
+	mu       sync.Mutex
+	compiled map[string]*sitter.Query
+}
+
+// NewQueryRegistry returns a registry of the queries stored as
+// <language>.scm in dir. what describes the queries in error messages, like
+// "import query".
+func NewQueryRegistry(fsys fs.FS, dir string, what string, aliases map[string]string) *QueryRegistry {
+	return &QueryRegistry{
+		fs:       fsys,
+		dir:      dir,
+		what:     what,
+		aliases:  aliases,
+		compiled: map[string]*sitter.Query{},
+	}
+}
+
+// Languages returns the languages that have a query, including aliases.
+func (r *QueryRegistry) Languages() []string {
+	ret := []string{}
+	entries, _ := fs.ReadDir(r.fs, r.dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".scm") {
+			ret = append(ret, strings.TrimSuffix(entry.Name(), ".scm"))
+		}
+	}
+	for lang := range r.aliases {
+		ret = append(ret, lang)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// HasLanguage returns true if there is a query for the language.
+func (r *QueryRegistry) HasLanguage(lang string) bool {
+	_, err := r.Query(lang)
+	return err == nil
+}
+
+// Family returns the language whose query file is used for lang, which is
+// lang itself unless it is an alias.
+func (r *QueryRegistry) Family(lang string) string {
+	if family, ok := r.aliases[lang]; ok {
+		return family
+	}
+	return lang
+}
+
+// Query returns the query of the language.
+func (r *QueryRegistry) Query(lang string) (string, error) {
+	b, err := fs.ReadFile(r.fs, path.Join(r.dir, r.Family(lang)+".scm"))
+	if err != nil {
+		return "", errors.Errorf("no %s for language %s", r.what, lang)
+	}
+	return string(b), nil
+}
+
+// CompiledQuery returns the query of the language compiled for sitterLang.
+// Queries are compiled once, and shared between callers.
+func (r *QueryRegistry) CompiledQuery(lang string, sitterLang *sitter.Language) (*sitter.Query, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if q, ok := r.compiled[lang]; ok {
+		return q, nil
+	}
+
+	query, err := r.Query(lang)
+	if err != nil {
+		return nil, err
+	}
+	q, err := sitter.NewQuery([]byte(query), sitterLang)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s for language %s", r.what, lang)
+	}
+	r.compiled[lang] = q
+	return q, nil
+}
@@ -0,0 +1,49 @@
+package tree_sitter
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+func TestQueryRegistry(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/go.scm":         {Data: []byte("(function_declaration) @function")},
+		"queries/javascript.scm": {Data: []byte("(function_declaration) @function")},
+		"queries/README.md":      {Data: []byte("not a query")},
+	}
+	r := NewQueryRegistry(fsys, "queries", "function query", map[string]string{
+		"golang":     "go",
+		"typescript": "javascript",
+	})
+
+	languages := r.Languages()
+	expected := []string{"go", "golang", "javascript", "typescript"}
+	if !reflect.DeepEqual(languages, expected) {
+		t.Errorf("got languages %v, expected %v", languages, expected)
+	}
+
+	if !r.HasLanguage("golang") || r.HasLanguage("python") {
+		t.Error("unexpected HasLanguage")
+	}
+	if r.Family("typescript") != "javascript" || r.Family("go") != "go" {
+		t.Error("unexpected Family")
+	}
+	if _, err := r.Query("python"); err == nil || err.Error() != "no function query for language python" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	q1, err := r.CompiledQuery("go", golang.GetLanguage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q2, err := r.CompiledQuery("go", golang.GetLanguage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q1 != q2 {
+		t.Error("the query was compiled twice")
+	}
+}
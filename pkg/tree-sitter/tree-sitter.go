@@ -106,32 +106,7 @@ func ExecuteQueries(
 				continue
 			}
 
-			match = Match{}
-			for _, c := range m.Captures {
-				name := q.CaptureNameForId(c.Index)
-				content := string(sourceCode[c.Node.StartByte():c.Node.EndByte()])
-				if m, ok := match[name]; ok {
-					match[name] = Capture{
-						Name:       name,
-						Text:       m.Text + "\n" + content,
-						StartByte:  m.StartByte,
-						EndByte:    c.Node.EndByte(),
-						StartPoint: m.StartPoint,
-						EndPoint:   c.Node.EndPoint(),
-					}
-					continue
-				}
-				match[name] = Capture{
-					Name:       name,
-					Text:       content,
-					Type:       c.Node.Type(),
-					StartByte:  c.Node.StartByte(),
-					EndByte:    c.Node.EndByte(),
-					StartPoint: c.Node.StartPoint(),
-					EndPoint:   c.Node.EndPoint(),
-				}
-			}
-			matches = append(matches, match)
+			matches = append(matches, newMatch(q, m, sourceCode))
 		}
 
 		results[query.Name] = &Result{
@@ -142,3 +117,35 @@ func ExecuteQueries(
 
 	return results, nil
 }
+
+// newMatch converts the captures of a query match. Captures that match
+// several nodes (like `(comment)+ @comment`) are merged into one spanning
+// all of them.
+func newMatch(q *sitter.Query, m *sitter.QueryMatch, sourceCode []byte) Match {
+	match := Match{}
+	for _, c := range m.Captures {
+		name := q.CaptureNameForId(c.Index)
+		content := string(sourceCode[c.Node.StartByte():c.Node.EndByte()])
+		if m, ok := match[name]; ok {
+			match[name] = Capture{
+				Name:       name,
+				Text:       m.Text + "\n" + content,
+				StartByte:  m.StartByte,
+				EndByte:    c.Node.EndByte(),
+				StartPoint: m.StartPoint,
+				EndPoint:   c.Node.EndPoint(),
+			}
+			continue
+		}
+		match[name] = Capture{
+			Name:       name,
+			Text:       content,
+			Type:       c.Node.Type(),
+			StartByte:  c.Node.StartByte(),
+			EndByte:    c.Node.EndByte(),
+			StartPoint: c.Node.StartPoint(),
+			EndPoint:   c.Node.EndPoint(),
+		}
+	}
+	return match
+}